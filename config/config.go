@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -10,12 +11,40 @@ import (
 type Config struct {
 	Port             string
 	APIKey           string
+	APIKeys          []string
 	AssemblyAIAPIKey string
 	WhisperServerURL string
 	WhisperModelPath string
 	WorkDir          string
 	MaxVideoLength   int
 	FreeJobLimit     int
+	WorkerCount      int
+	MaxQueueSize     int
+	QueueRetryAfter  int
+	JobStore         string
+	JobStoreDSN      string
+	JobMaxAttempts   int
+	JobRetryBackoff  int
+	JobRetryMaxDelay int
+	WebhookURL       string
+	WebhookSecret    string
+	ScanInterval     int
+	KeyWeights       map[string]int
+	IdempotencyTTL   int
+	PublicURL        string
+	ArtifactStore    string
+	ArtifactDir      string
+	ArtifactSecret   string
+	ArtifactURLTTL   int
+	StoreAudio       bool
+	S3Bucket         string
+	S3Region         string
+	S3Endpoint       string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3PathStyle      bool
+	CaptionPreset    string
+	MaxUploadSize    int
 }
 
 func Load() *Config {
@@ -23,22 +52,103 @@ func Load() *Config {
 
 	maxLength, _ := strconv.Atoi(getEnv("MAX_VIDEO_LENGTH", "1800"))
 	freeLimit, _ := strconv.Atoi(getEnv("FREE_JOB_LIMIT", "5"))
+	workerCount, _ := strconv.Atoi(getEnv("WORKER_COUNT", "2"))
+	maxQueueSize, _ := strconv.Atoi(getEnv("MAX_QUEUE_SIZE", "50"))
+	queueRetryAfter, _ := strconv.Atoi(getEnv("QUEUE_RETRY_AFTER", "30"))
+	maxAttempts, _ := strconv.Atoi(getEnv("JOB_MAX_ATTEMPTS", "3"))
+	retryBackoff, _ := strconv.Atoi(getEnv("JOB_RETRY_BACKOFF", "30"))
+	retryMaxDelay, _ := strconv.Atoi(getEnv("JOB_RETRY_MAX_DELAY", "600"))
+	scanInterval, _ := strconv.Atoi(getEnv("SUBSCRIPTION_SCAN_INTERVAL", "900"))
+	idempotencyTTL, _ := strconv.Atoi(getEnv("IDEMPOTENCY_KEY_TTL", "86400"))
+	artifactURLTTL, _ := strconv.Atoi(getEnv("ARTIFACT_URL_TTL", "3600"))
+	storeAudio, _ := strconv.ParseBool(getEnv("ARTIFACT_STORE_AUDIO", "false"))
+	s3PathStyle, _ := strconv.ParseBool(getEnv("S3_FORCE_PATH_STYLE", "false"))
+	maxUploadMB, _ := strconv.Atoi(getEnv("MAX_UPLOAD_MB", "512"))
+	port := getEnv("PORT", "3000")
 
 	return &Config{
-		Port:             getEnv("PORT", "3000"),
+		Port:             port,
 		APIKey:           getEnv("API_KEY", "your-api-key-here"),
+		APIKeys:          splitList(getEnv("API_KEYS", "")),
 		AssemblyAIAPIKey: getEnv("ASSEMBLYAI_API_KEY", ""),
 		WhisperServerURL: getEnv("WHISPER_SERVER_URL", ""),
 		WhisperModelPath: getEnv("WHISPER_MODEL_PATH", ""),
 		WorkDir:          getEnv("WORK_DIR", "/tmp/videotranscript"),
 		MaxVideoLength:   maxLength,
 		FreeJobLimit:     freeLimit,
+		WorkerCount:      workerCount,
+		MaxQueueSize:     maxQueueSize,
+		QueueRetryAfter:  queueRetryAfter,
+		JobStore:         getEnv("JOB_STORE", "memory"),
+		JobStoreDSN:      getEnv("JOB_STORE_DSN", ""),
+		JobMaxAttempts:   maxAttempts,
+		JobRetryBackoff:  retryBackoff,
+		JobRetryMaxDelay: retryMaxDelay,
+		WebhookURL:       getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:    getEnv("WEBHOOK_SECRET", ""),
+		ScanInterval:     scanInterval,
+		KeyWeights:       parseWeights(getEnv("API_KEY_WEIGHTS", "")),
+		IdempotencyTTL:   idempotencyTTL,
+		PublicURL:        getEnv("PUBLIC_BASE_URL", "http://localhost:"+port),
+		ArtifactStore:    getEnv("ARTIFACT_STORE", "local"),
+		ArtifactDir:      getEnv("ARTIFACT_DIR", ""),
+		ArtifactSecret:   getEnv("ARTIFACT_SIGNING_KEY", ""),
+		ArtifactURLTTL:   artifactURLTTL,
+		StoreAudio:       storeAudio,
+		S3Bucket:         getEnv("S3_BUCKET", ""),
+		S3Region:         getEnv("S3_REGION", "us-east-1"),
+		S3Endpoint:       getEnv("S3_ENDPOINT", ""),
+		S3AccessKey:      getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretKey:      getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3PathStyle:      s3PathStyle,
+		CaptionPreset:    getEnv("CAPTION_PRESET", "netflix"),
+		MaxUploadSize:    maxUploadMB << 20,
 	}
 }
 
+// ValidAPIKey reports whether key is API_KEY or one of API_KEYS
+func (c *Config) ValidAPIKey(key string) bool {
+	if key == c.APIKey {
+		return true
+	}
+	for _, k := range c.APIKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseWeights parses "key_id:weight" pairs separated by commas. Entries
+// without a positive weight are ignored.
+func parseWeights(value string) map[string]int {
+	weights := make(map[string]int)
+	for _, item := range splitList(value) {
+		key, weight, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(weight)); err == nil && n > 0 {
+			weights[strings.TrimSpace(key)] = n
+		}
+	}
+	return weights
+}
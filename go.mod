@@ -7,16 +7,18 @@ toolchain go1.24.7
 require (
 	encore.dev v1.41.4
 	github.com/AssemblyAI/assemblyai-go-sdk v1.8.0
+	github.com/aws/aws-sdk-go v1.38.20
 	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
 	github.com/lrstanley/go-ytdlp v1.2.4
 	github.com/stretchr/testify v1.11.1
 	github.com/u2takey/ffmpeg-go v0.5.0
+	go.etcd.io/bbolt v1.3.10
 )
 
 require (
 	github.com/ProtonMail/go-crypto v1.3.0 // indirect
 	github.com/andybalholm/brotli v1.1.0 // indirect
-	github.com/aws/aws-sdk-go v1.38.20 // indirect
 	github.com/cloudflare/circl v1.6.1 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
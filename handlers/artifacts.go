@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"videotranscript-app/config"
+	"videotranscript-app/jobs"
+	"videotranscript-app/lib"
+)
+
+// GetArtifact serves a stored job artifact to the holder of a signed URL.
+// It is registered outside the API key middleware, as the signature is the
+// credential.
+func GetArtifact(c *fiber.Ctx) error {
+	key := c.Params("*")
+	store := lib.GetArtifactStore()
+	verifier, ok := store.(lib.ArtifactVerifier)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Artifact not found",
+		})
+	}
+
+	if err := verifier.VerifyURL(key, c.Query("expires"), c.Query("signature")); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid or expired artifact URL",
+		})
+	}
+
+	reader, err := store.Get(c.Context(), key)
+	if errors.Is(err, lib.ErrArtifactNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Artifact not found",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read artifact",
+		})
+	}
+
+	// The reader is closed once the body has been streamed
+	c.Set(fiber.HeaderContentType, lib.ArtifactContentType(key))
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.SendStream(reader)
+}
+
+// storeArtifacts writes a complete job's subtitles and kept audio to the
+// artifact store. Failures are logged and leave the job complete.
+func storeArtifacts(job *jobs.Job, audioPath string) {
+	if audioPath != "" {
+		defer os.Remove(audioPath)
+	}
+	store := lib.GetArtifactStore()
+	if store == nil {
+		return
+	}
+
+	artifacts, err := lib.StoreJobArtifacts(context.Background(), store, job, audioPath)
+	if err != nil {
+		log.Printf("Failed to store artifacts for job %s: %v", job.ID, err)
+	}
+	job.Artifacts = artifacts
+}
+
+// subtitleFiles links a complete job's subtitle files: signed artifact URLs
+// when they were stored, and the transcript downloads otherwise.
+func subtitleFiles(job *jobs.Job) *lib.SubtitleFiles {
+	files, err := lib.SignedSubtitleFiles(lib.GetArtifactStore(), job, artifactURLTTL())
+	if err != nil {
+		log.Printf("Failed to sign artifact URLs for job %s: %v", job.ID, err)
+	}
+	if files == nil {
+		files = &lib.SubtitleFiles{
+			SRTURL: lib.TranscriptURL(job.ID, "srt"),
+			VTTURL: lib.TranscriptURL(job.ID, "vtt"),
+		}
+	}
+	return files
+}
+
+// artifactURLTTL is how long signed artifact URLs stay valid
+func artifactURLTTL() time.Duration {
+	if ttl := time.Duration(config.Load().ArtifactURLTTL) * time.Second; ttl > 0 {
+		return ttl
+	}
+	return lib.DefaultArtifactURLTTL
+}
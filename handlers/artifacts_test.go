@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/lib"
+)
+
+func TestGetArtifact(t *testing.T) {
+	store, err := lib.NewLocalArtifactStore(t.TempDir(), lib.NewArtifactSigner("http://example.com", "secret"))
+	require.NoError(t, err)
+	previous := lib.GetArtifactStore()
+	lib.InitializeArtifacts(store)
+	t.Cleanup(func() { lib.InitializeArtifacts(previous) })
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/artifacts/*", GetArtifact)
+
+	key := lib.ArtifactKey("job-1", "subtitles.vtt")
+	_, err = store.Put(context.Background(), key, strings.NewReader("WEBVTT\n\n"), lib.ArtifactContentType(key))
+	require.NoError(t, err)
+
+	signed, err := store.SignedURL(key, time.Minute)
+	require.NoError(t, err)
+	target, err := url.Parse(signed)
+	require.NoError(t, err)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, target.RequestURI(), nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/vtt; charset=utf-8", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "WEBVTT\n\n", string(body))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/artifacts/"+key+"?expires=9999999999&signature=bad", nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, 403, resp.StatusCode)
+}
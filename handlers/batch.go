@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"videotranscript-app/config"
+	"videotranscript-app/jobs"
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
+)
+
+// collectionTimeout bounds how long listing a playlist or channel may take
+const collectionTimeout = 2 * time.Minute
+
+// PostTranscribeBatch creates a batch of jobs sharing the request options.
+// Children are processed asynchronously; cached results complete at once.
+func PostTranscribeBatch(c *fiber.Ctx) error {
+	var req models.BatchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	urls, err := models.ValidateBatchURLs(req.URLs)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	priority, err := models.ParseJobPriority(req.Priority)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	pool := jobs.GetPool()
+	if available := pool.Available(); available >= 0 && available < len(urls) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(config.Load().QueueRetryAfter))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "Job queue does not have room for this batch, please retry later",
+		})
+	}
+
+	batch := models.NewBatch(len(urls), req.ForceRefresh)
+	batch.APIKeyID = lib.RequestAPIKeyID(c)
+	children := make([]*jobs.Job, 0, len(urls))
+	for _, url := range urls {
+		job := jobs.NewJob(url)
+		job.Priority = priority
+		children = append(children, job)
+	}
+
+	refs, err := submitBatch(batch, children)
+	if err != nil {
+		log.Printf("Failed to create batch %s: %v", batch.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create batch",
+		})
+	}
+	c.Locals(idempotentBatchLocal, batch.ID)
+
+	return c.JSON(models.BatchResponse{
+		BatchID: batch.ID,
+		Total:   batch.Total,
+		Jobs:    refs,
+	})
+}
+
+// transcribeCollection expands a playlist or channel URL into a batch with
+// one job per selected video.
+func transcribeCollection(c *fiber.Ctx, req models.TranscribeRequest, priority models.JobPriority) error {
+	opts := req.CollectionOptions()
+	if err := opts.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	queue := jobs.GetQueue()
+	var skip func(models.CollectionEntry) bool
+	if opts.SkipExisting {
+		skip = func(entry models.CollectionEntry) bool {
+			cached, err := jobs.FindCachedJob(queue, entry.VideoID, lib.ResultCacheKey(entry.URL))
+			return err == nil && cached != nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), collectionTimeout)
+	defer cancel()
+	collection, err := lib.ExpandCollection(ctx, req.URL, opts, skip)
+	if err != nil {
+		log.Printf("Failed to expand %s: %v", req.URL, err)
+		status := fiber.StatusInternalServerError
+		if !models.IsRetryable(err) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":      "Failed to list playlist or channel videos",
+			"error_code": models.ErrorCodeOf(err),
+		})
+	}
+	if len(collection.Entries) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No videos in the " + string(collection.Kind) + " match the request",
+		})
+	}
+
+	if available := jobs.GetPool().Available(); available >= 0 && available < len(collection.Entries) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(config.Load().QueueRetryAfter))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "Job queue does not have room for this " + string(collection.Kind) + ", please retry later",
+		})
+	}
+
+	batch := models.NewBatch(len(collection.Entries), req.ForceRefresh)
+	batch.SourceURL = req.URL
+	batch.Title = collection.Title
+	batch.APIKeyID = lib.RequestAPIKeyID(c)
+	children := make([]*jobs.Job, 0, len(collection.Entries))
+	for _, entry := range collection.Entries {
+		job := entry.NewJob()
+		job.Priority = priority
+		children = append(children, job)
+	}
+
+	refs, err := submitBatch(batch, children)
+	if err != nil {
+		log.Printf("Failed to create batch %s: %v", batch.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create batch",
+		})
+	}
+	c.Locals(idempotentBatchLocal, batch.ID)
+
+	c.Location("/transcribe/batch/" + batch.ID)
+	return c.Status(fiber.StatusAccepted).JSON(models.TranscribeResponse{
+		BatchID: batch.ID,
+		Jobs:    refs,
+	})
+}
+
+// submitBatch stores the batch and its children and queues every child that
+// cannot reuse a cached result. Every child is stored before any is queued
+// and the batch is stored last, so a failed submission runs nothing and
+// leaves no batch behind; the children it did store are marked failed.
+func submitBatch(batch *models.Batch, children []*jobs.Job) ([]models.BatchJobRef, error) {
+	queue := jobs.GetQueue()
+	pool := jobs.GetPool()
+
+	refs := make([]models.BatchJobRef, 0, len(children))
+	var stored, queued []*jobs.Job
+	for _, job := range children {
+		job.APIKeyID = batch.APIKeyID
+		job.BatchID = batch.ID
+		refs = append(refs, models.BatchJobRef{URL: job.URL, JobID: job.ID})
+
+		cachedResult := false
+		if !batch.ForceRefresh {
+			cached, err := jobs.FindCachedJob(queue, job.VideoID, lib.ResultCacheKey(job.URL))
+			if err != nil {
+				log.Printf("Failed to look up cached result for %s: %v", job.VideoID, err)
+			} else if cached != nil && job.CompleteFromCache(cached) == nil {
+				cachedResult = true
+			}
+		}
+
+		if err := queue.AddJob(job); err != nil {
+			abandonBatch(stored, err)
+			return nil, fmt.Errorf("failed to create batch job %s: %w", job.ID, err)
+		}
+		stored = append(stored, job)
+		if !cachedResult {
+			queued = append(queued, job)
+		}
+	}
+	if err := queue.AddBatch(batch); err != nil {
+		abandonBatch(stored, err)
+		return nil, err
+	}
+
+	for _, job := range queued {
+		if err := pool.Submit(job); err != nil {
+			job.MarkError(err)
+			saveJob(job)
+		}
+	}
+
+	// Every child may already be finished, e.g. when all were cached.
+	finishBatch(batch.ID)
+
+	return refs, nil
+}
+
+// abandonBatch fails the stored children of a batch that could not be
+// submitted. They were never queued, and a retry creates new ones.
+func abandonBatch(stored []*jobs.Job, cause error) {
+	for _, job := range stored {
+		if job.Status == models.StatusPending {
+			job.MarkError(fmt.Errorf("batch submission failed: %w", cause))
+			saveJob(job)
+		}
+	}
+}
+
+func GetTranscribeBatch(c *fiber.Ctx) error {
+	batchID := c.Params("batch_id")
+
+	status, err := jobs.BatchStatus(jobs.GetQueue(), batchID)
+	if errors.Is(err, jobs.ErrBatchNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Batch not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load batch",
+		})
+	}
+
+	return c.JSON(status)
+}
+
+// finishBatch completes a batch once its last job has finished and sends
+// the batch.completed webhook.
+func finishBatch(batchID string) {
+	status, err := jobs.CompleteBatch(jobs.GetQueue(), batchID)
+	if err != nil {
+		log.Printf("Failed to check batch %s: %v", batchID, err)
+		return
+	}
+	if status == nil {
+		return
+	}
+
+	log.Printf("Batch %s completed: %d complete, %d failed", batchID, status.Complete, status.Failed)
+	managers := []*lib.WebhookManager{webhookManager()}
+	if status.SubscriptionID != "" {
+		// Batches found by a subscription scan are also reported to its owner
+		if sub, err := jobs.GetQueue().GetSubscription(status.SubscriptionID); err == nil {
+			managers = append(managers, lib.NewSubscriptionWebhookManager(*sub))
+		}
+	}
+	for _, webhooks := range managers {
+		if webhooks == nil {
+			continue
+		}
+		go func(webhooks *lib.WebhookManager) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			if err := webhooks.SendBatchCompleted(ctx, *status); err != nil {
+				log.Printf("Failed to send batch.completed webhook for %s: %v", batchID, err)
+			}
+		}(webhooks)
+	}
+}
+
+// webhookManager returns the configured webhook sender, or nil if the
+// server has no WEBHOOK_URL.
+func webhookManager() *lib.WebhookManager {
+	cfg := config.Load()
+	if cfg.WebhookURL == "" {
+		return nil
+	}
+
+	webhookConfig := lib.WebhookConfig{
+		URL:     cfg.WebhookURL,
+		Timeout: 10 * time.Second,
+		Retries: 3,
+	}
+	if cfg.WebhookSecret != "" {
+		webhookConfig.Headers = map[string]string{
+			"X-Webhook-Secret": cfg.WebhookSecret,
+		}
+	}
+	return lib.NewWebhookManager(webhookConfig)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"videotranscript-app/jobs"
+	"videotranscript-app/models"
+)
+
+// eventKeepAlive is how often an idle event stream sends a comment so
+// proxies keep the connection open
+const eventKeepAlive = 15 * time.Second
+
+// GetTranscribeJobEvents streams a job's status, stage, progress and segment
+// events as Server-Sent Events. The stream starts with the job's current
+// status and ends after its final status event.
+func GetTranscribeJobEvents(c *fiber.Ctx) error {
+	jobID := c.Params("job_id")
+
+	// Subscribe before reading the job so no event between the two is lost
+	events, unsubscribe := jobs.GetEventBus().Subscribe(jobID)
+
+	job, err := jobs.GetQueue().GetJob(jobID)
+	if err != nil {
+		unsubscribe()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Job not found",
+		})
+	}
+	current := models.NewJobEvent(models.EventStatus, job)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		if writeEvent(w, current) != nil || current.IsFinal() {
+			return
+		}
+
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+		delivered := make(map[int]bool)
+		for {
+			select {
+			case event := <-events:
+				if event.IsFinal() && event.Status == models.StatusComplete {
+					if backfillSegments(w, jobID, delivered) != nil {
+						return
+					}
+				}
+				if writeEvent(w, event) != nil || event.IsFinal() {
+					return
+				}
+				if event.Index != nil {
+					delivered[*event.Index] = true
+				}
+			case <-keepAlive.C:
+				// A failed flush means the client went away
+				if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// backfillSegments writes the segment events of a completed job that the
+// stream has not delivered. The runner publishes all segments at once when
+// the job completes, so a subscriber's buffer may overflow and drop some.
+func backfillSegments(w *bufio.Writer, jobID string, delivered map[int]bool) error {
+	job, err := jobs.GetQueue().GetJob(jobID)
+	if err != nil {
+		return nil
+	}
+	for i := range job.Segments {
+		if delivered[i] {
+			continue
+		}
+		if err := writeEvent(w, models.NewSegmentEvent(job, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEvent writes one SSE message named after the event type and flushes
+// it to the client.
+func writeEvent(w *bufio.Writer, event models.JobEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
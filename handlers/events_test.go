@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/jobs"
+	"videotranscript-app/models"
+)
+
+func TestGetTranscribeJobEvents_StreamsUntilFinalStatus(t *testing.T) {
+	app := setupTestApp()
+
+	job := jobs.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	require.NoError(t, job.MarkRunning())
+	require.NoError(t, jobs.GetQueue().AddJob(job))
+
+	runner := *job
+	go func() {
+		bus := jobs.GetEventBus()
+		for bus.Subscribers(runner.ID) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		runner.MarkStage(models.StageTranscribing, 50)
+		bus.Publish(models.NewJobEvent(models.EventStage, &runner))
+		runner.MarkComplete("Hello world", []models.Segment{{Start: 0, End: 1.5, Text: "Hello world"}})
+		bus.Publish(models.NewSegmentEvent(&runner, 0))
+		bus.Publish(models.NewJobEvent(models.EventStatus, &runner))
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, "/transcribe/"+job.ID+"/events", nil)
+	resp, err := app.Test(req, 5000)
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	stream := string(body)
+
+	assert.Contains(t, stream, "event: status\ndata: {\"type\":\"status\",\"job_id\":\""+job.ID+"\",\"status\":\"running\"")
+	assert.Contains(t, stream, "event: stage\n")
+	assert.Contains(t, stream, `"segment":{"start":0,"end":1.5,"text":"Hello world"},"index":0`)
+	assert.Contains(t, stream, `"status":"complete"`)
+	assert.Equal(t, 0, jobs.GetEventBus().Subscribers(job.ID))
+}
+
+func TestGetTranscribeJobEvents_BackfillsMissedSegments(t *testing.T) {
+	app := setupTestApp()
+
+	job := jobs.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	require.NoError(t, job.MarkRunning())
+	require.NoError(t, jobs.GetQueue().AddJob(job))
+
+	runner := *job
+	go func() {
+		bus := jobs.GetEventBus()
+		for bus.Subscribers(runner.ID) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		runner.MarkComplete("One two three", []models.Segment{
+			{Start: 0, End: 1, Text: "One"},
+			{Start: 1, End: 2, Text: "two"},
+			{Start: 2, End: 3, Text: "three"},
+		})
+		jobs.GetQueue().UpdateJob(&runner)
+		// The events of the later segments were dropped
+		bus.Publish(models.NewSegmentEvent(&runner, 0))
+		bus.Publish(models.NewJobEvent(models.EventStatus, &runner))
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, "/transcribe/"+job.ID+"/events", nil)
+	resp, err := app.Test(req, 5000)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	stream := string(body)
+
+	assert.Equal(t, 3, strings.Count(stream, "event: segment\n"))
+	for _, text := range []string{`"text":"One"},"index":0`, `"text":"two"},"index":1`, `"text":"three"},"index":2`} {
+		assert.Contains(t, stream, text)
+	}
+	assert.Less(t, strings.Index(stream, `"index":2`), strings.LastIndex(stream, "event: status\n"))
+}
+
+func TestGetTranscribeJobEvents_FinishedJob(t *testing.T) {
+	app := setupTestApp()
+
+	job := jobs.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	job.MarkError(assert.AnError)
+	require.NoError(t, jobs.GetQueue().AddJob(job))
+
+	req := httptest.NewRequest(http.MethodGet, "/transcribe/"+job.ID+"/events", nil)
+	resp, err := app.Test(req, 5000)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"status":"error"`)
+	assert.Equal(t, 1, strings.Count(string(body), "event: "))
+}
+
+func TestGetTranscribeJobEvents_NotFound(t *testing.T) {
+	app := setupTestApp()
+
+	req := httptest.NewRequest(http.MethodGet, "/transcribe/unknown/events", nil)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, 0, jobs.GetEventBus().Subscribers("unknown"))
+}
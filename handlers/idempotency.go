@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"videotranscript-app/config"
+	"videotranscript-app/jobs"
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
+)
+
+// Handlers behind Idempotent report what they created in these locals
+const (
+	idempotentJobLocal   = "idempotent_job_id"
+	idempotentBatchLocal = "idempotent_batch_id"
+)
+
+// Idempotent makes job creation safe to retry. The first request with an
+// Idempotency-Key header claims the key for the API key that sent it; a
+// repeat of that request is answered with the job or batch it created, and
+// a different request with the same key is rejected with 409.
+func Idempotent(c *fiber.Ctx) error {
+	value := c.Get(models.IdempotencyKeyHeader)
+	if value == "" {
+		return c.Next()
+	}
+
+	fingerprint := models.RequestFingerprint(c.Method(), c.Path(), c.Body())
+	ttl := time.Duration(config.Load().IdempotencyTTL) * time.Second
+	key, err := models.NewIdempotencyKey(lib.RequestAPIKeyID(c), value, fingerprint, ttl)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	queue := jobs.GetQueue()
+	existing, err := queue.ClaimIdempotencyKey(key)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check idempotency key",
+		})
+	}
+	if existing != nil {
+		return replayIdempotent(c, existing, fingerprint)
+	}
+
+	handlerErr := c.Next()
+
+	completed := *key
+	completed.JobID, _ = c.Locals(idempotentJobLocal).(string)
+	completed.BatchID, _ = c.Locals(idempotentBatchLocal).(string)
+	completed.StatusCode = c.Response().StatusCode()
+	if handlerErr != nil || !completed.Completed() {
+		// Nothing was created, so the client may retry with the same key
+		if err := queue.DeleteIdempotencyKey(key.APIKeyID, key.Key); err != nil {
+			log.Printf("Failed to release idempotency key: %v", err)
+		}
+		return handlerErr
+	}
+	if err := queue.UpdateIdempotencyKey(&completed); err != nil {
+		log.Printf("Failed to save idempotency key: %v", err)
+	}
+	return nil
+}
+
+// replayIdempotent answers a repeated request with the current state of
+// the job or batch the first request created.
+func replayIdempotent(c *fiber.Ctx, key *jobs.IdempotencyKey, fingerprint string) error {
+	if key.Fingerprint != fingerprint {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Idempotency-Key was already used with a different request",
+		})
+	}
+	if !key.Completed() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A request with this Idempotency-Key is still being processed",
+		})
+	}
+
+	c.Set("Idempotent-Replayed", "true")
+	queue := jobs.GetQueue()
+	if key.JobID != "" {
+		job, err := queue.GetJob(key.JobID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load job",
+			})
+		}
+		return respondWithJob(c, job, 0)
+	}
+
+	batch, err := queue.GetBatch(key.BatchID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load batch",
+		})
+	}
+	children, err := jobs.BatchJobs(queue, batch.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load batch",
+		})
+	}
+	refs := make([]models.BatchJobRef, 0, len(children))
+	for _, job := range children {
+		refs = append(refs, models.BatchJobRef{URL: job.URL, JobID: job.ID})
+	}
+
+	if key.StatusCode == fiber.StatusAccepted {
+		c.Location("/transcribe/batch/" + batch.ID)
+	}
+	return c.Status(key.StatusCode).JSON(models.BatchResponse{
+		BatchID: batch.ID,
+		Total:   batch.Total,
+		Jobs:    refs,
+	})
+}
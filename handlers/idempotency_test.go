@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/jobs"
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
+)
+
+func TestIdempotent_ReplaysTranscribe(t *testing.T) {
+	app := setupTestApp()
+	url := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+	done := jobs.NewJob(url)
+	done.MarkRunning()
+	done.CacheKey = lib.ResultCacheKey(url)
+	done.MarkComplete("Cached transcript", nil)
+	require.NoError(t, jobs.GetQueue().AddJob(done))
+
+	post := func(key string, body string) (*http.Response, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, "/transcribe", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp, result
+	}
+
+	// An invalid request creates nothing and leaves the key unused
+	resp, _ := post("key-1", `{"url": "not-a-url"}`)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp, first := post("key-1", `{"url": "`+url+`", "mode": "async"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Idempotent-Replayed"))
+	require.NotEmpty(t, first["job_id"])
+
+	// Key order and whitespace do not change the request
+	resp, repeat := post("key-1", `{"mode":"async","url":"`+url+`"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Idempotent-Replayed"))
+	assert.Equal(t, first["job_id"], repeat["job_id"])
+
+	resp, conflict := post("key-1", `{"url": "`+url+`", "mode": "sync"}`)
+	assert.Equal(t, 409, resp.StatusCode)
+	assert.Equal(t, "Idempotency-Key was already used with a different request", conflict["error"])
+
+	resp, other := post("key-2", `{"url": "`+url+`", "mode": "async"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.NotEqual(t, first["job_id"], other["job_id"])
+}
+
+func TestIdempotent_ReplaysBatch(t *testing.T) {
+	app := setupTestApp()
+
+	body, err := json.Marshal(models.BatchRequest{URLs: []string{
+		"https://www.youtube.com/watch?v=9bZkp7q19f0",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+	}})
+	require.NoError(t, err)
+
+	post := func() (*http.Response, models.BatchResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/transcribe/batch", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "batch-key")
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		var result models.BatchResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp, result
+	}
+
+	resp, first := post()
+	require.Equal(t, 200, resp.StatusCode)
+	resp, repeat := post()
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Idempotent-Replayed"))
+	assert.Equal(t, first, repeat)
+
+	page, err := jobs.GetQueue().QueryJobs(jobs.JobQuery{Limit: models.MaxJobPageSize})
+	require.NoError(t, err)
+	assert.Len(t, page.Jobs, 2)
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"videotranscript-app/jobs"
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
+)
+
+// listCollection lists a subscription's channel or playlist. Tests replace
+// it to avoid calling yt-dlp.
+var listCollection = lib.ListCollection
+
+// subscriptionMu serialises changes to subscriptions so a scan does not
+// overwrite a concurrent update.
+var subscriptionMu sync.Mutex
+
+func PostSubscription(c *fiber.Ctx) error {
+	var req models.SubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	sub, err := models.NewSubscription(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	sub.APIKeyID = lib.RequestAPIKeyID(c)
+
+	if err := jobs.GetQueue().AddSubscription(sub); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create subscription",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(sub.Redacted())
+}
+
+func ListSubscriptions(c *fiber.Ctx) error {
+	subs, err := jobs.GetQueue().ListSubscriptions()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list subscriptions",
+		})
+	}
+
+	apiKeyID := lib.RequestAPIKeyID(c)
+	response := make([]models.Subscription, 0, len(subs))
+	for _, sub := range subs {
+		if sub.APIKeyID == apiKeyID {
+			response = append(response, sub.Redacted())
+		}
+	}
+	return c.JSON(fiber.Map{
+		"subscriptions": response,
+	})
+}
+
+func GetSubscription(c *fiber.Ctx) error {
+	sub, err := ownedSubscription(c)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	return c.JSON(sub.Redacted())
+}
+
+func PatchSubscription(c *fiber.Ctx) error {
+	var req models.SubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	subscriptionMu.Lock()
+	defer subscriptionMu.Unlock()
+
+	sub, err := ownedSubscription(c)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+
+	updated := *sub
+	if err := updated.Apply(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := jobs.GetQueue().UpdateSubscription(&updated); err != nil {
+		return subscriptionError(c, err)
+	}
+	return c.JSON(updated.Redacted())
+}
+
+func DeleteSubscription(c *fiber.Ctx) error {
+	subscriptionMu.Lock()
+	defer subscriptionMu.Unlock()
+
+	sub, err := ownedSubscription(c)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	if err := jobs.GetQueue().DeleteSubscription(sub.ID); err != nil {
+		return subscriptionError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ownedSubscription returns the subscription named in the path. Another
+// API key's subscription is reported as not found, so callers can not
+// tell which IDs exist.
+func ownedSubscription(c *fiber.Ctx) (*models.Subscription, error) {
+	sub, err := jobs.GetQueue().GetSubscription(c.Params("subscription_id"))
+	if err != nil {
+		return nil, err
+	}
+	if sub.APIKeyID != lib.RequestAPIKeyID(c) {
+		return nil, jobs.ErrSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+func subscriptionError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, jobs.ErrSubscriptionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Subscription not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to update subscription",
+	})
+}
+
+// StartSubscriptionScanner scans the active subscriptions now and then
+// every interval until ctx is done.
+func StartSubscriptionScanner(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			ScanSubscriptions(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ScanSubscriptions checks every active subscription once for new videos.
+func ScanSubscriptions(ctx context.Context) {
+	subs, err := jobs.GetQueue().ListSubscriptions()
+	if err != nil {
+		log.Printf("Failed to list subscriptions: %v", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if !sub.Active {
+			continue
+		}
+		if err := scanSubscription(ctx, sub.ID); err != nil {
+			log.Printf("Failed to scan subscription %s: %v", sub.ID, err)
+		}
+	}
+}
+
+// scanSubscription lists the subscription's source and submits the videos
+// it has not seen yet as a batch. The seen videos are only saved once the
+// batch was created, so a failed scan is repeated.
+func scanSubscription(ctx context.Context, id string) error {
+	queue := jobs.GetQueue()
+	sub, err := queue.GetSubscription(id)
+	if err != nil {
+		return err
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, collectionTimeout)
+	defer cancel()
+	collection, listErr := listCollection(listCtx, sub.URL, sub.ScanLimit())
+
+	subscriptionMu.Lock()
+	defer subscriptionMu.Unlock()
+
+	// Re-read so settings changed while listing are kept
+	sub, err = queue.GetSubscription(id)
+	if err != nil {
+		return err
+	}
+	scanned := *sub
+	if listErr != nil {
+		scanned.MarkScanFailed(listErr, time.Now())
+		if err := queue.UpdateSubscription(&scanned); err != nil {
+			log.Printf("Failed to record scan error for subscription %s: %v", id, err)
+		}
+		return listErr
+	}
+
+	if scanned.Title == "" {
+		scanned.Title = collection.Title
+	}
+	fresh := scanned.RecordScan(collection.Entries, time.Now())
+
+	if len(fresh) > 0 {
+		// Leave the videos unseen until the queue has room for all of them
+		if available := jobs.GetPool().Available(); available >= 0 && available < len(fresh) {
+			log.Printf("Job queue is full, postponing %d new videos of subscription %s", len(fresh), id)
+			return nil
+		}
+
+		batch := models.NewBatch(len(fresh), false)
+		batch.SourceURL = scanned.URL
+		batch.Title = scanned.Title
+		batch.SubscriptionID = scanned.ID
+		batch.APIKeyID = scanned.APIKeyID
+		children := make([]*jobs.Job, 0, len(fresh))
+		for _, entry := range fresh {
+			children = append(children, entry.NewJob())
+		}
+
+		refs, err := submitBatch(batch, children)
+		if err != nil {
+			return err
+		}
+		log.Printf("Subscription %s found %d new videos, batch %s", id, len(fresh), batch.ID)
+
+		if webhooks := lib.NewSubscriptionWebhookManager(scanned); webhooks != nil {
+			jobIDs := make([]string, 0, len(refs))
+			for _, ref := range refs {
+				jobIDs = append(jobIDs, ref.JobID)
+			}
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+				if err := webhooks.SendSubscriptionVideos(ctx, scanned, batch.ID, jobIDs); err != nil {
+					log.Printf("Failed to send subscription.new_videos webhook for %s: %v", id, err)
+				}
+			}()
+		}
+	}
+
+	return queue.UpdateSubscription(&scanned)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/jobs"
+	"videotranscript-app/models"
+)
+
+func TestSubscriptions_CRUD(t *testing.T) {
+	app := setupTestApp()
+
+	body, err := json.Marshal(map[string]string{
+		"url":            "https://www.youtube.com/@mitocw",
+		"webhook_url":    "https://example.com/hook",
+		"webhook_secret": "s3cret",
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 201, resp.StatusCode)
+
+	var created models.Subscription
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	assert.Equal(t, models.CollectionChannel, created.Kind)
+	assert.Empty(t, created.WebhookSecret, "secrets are not returned")
+
+	req = httptest.NewRequest(http.MethodPatch, "/subscriptions/"+created.ID, bytes.NewReader([]byte(`{"active": false}`)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	stored, err := jobs.GetQueue().GetSubscription(created.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.Active)
+	assert.Equal(t, "s3cret", stored.WebhookSecret)
+
+	req = httptest.NewRequest(http.MethodDelete, "/subscriptions/"+created.ID, nil)
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 204, resp.StatusCode)
+
+	req = httptest.NewRequest(http.MethodGet, "/subscriptions/"+created.ID, nil)
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	req = httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader([]byte(`{"url": "https://www.youtube.com/watch?v=dQw4w9WgXcQ"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestScanSubscriptions_SubmitsNewVideosOnce(t *testing.T) {
+	setupTestApp()
+	queue := jobs.GetQueue()
+
+	listing := []models.CollectionEntry{
+		{VideoID: "aaaaaaaaaaa", URL: "https://www.youtube.com/watch?v=aaaaaaaaaaa"},
+	}
+	original := listCollection
+	t.Cleanup(func() { listCollection = original })
+	listCollection = func(ctx context.Context, url string, limit int) (*models.Collection, error) {
+		return &models.Collection{Kind: models.CollectionChannel, URL: url, Title: "MIT OCW", Entries: listing}, nil
+	}
+
+	sub, err := models.NewSubscription(models.SubscriptionRequest{URL: "https://www.youtube.com/@mitocw"})
+	require.NoError(t, err)
+	require.NoError(t, queue.AddSubscription(sub))
+
+	ScanSubscriptions(context.Background())
+	all, err := queue.ListJobs()
+	require.NoError(t, err)
+	assert.Empty(t, all, "the first scan only records existing videos")
+
+	listing = append([]models.CollectionEntry{
+		{VideoID: "bbbbbbbbbbb", URL: "https://www.youtube.com/watch?v=bbbbbbbbbbb", Title: "Lecture 2"},
+	}, listing...)
+	ScanSubscriptions(context.Background())
+	ScanSubscriptions(context.Background())
+
+	all, err = queue.ListJobs()
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "bbbbbbbbbbb", all[0].VideoID)
+	assert.Equal(t, "Lecture 2", all[0].Title)
+
+	batch, err := queue.GetBatch(all[0].BatchID)
+	require.NoError(t, err)
+	assert.Equal(t, sub.ID, batch.SubscriptionID)
+	assert.Equal(t, "MIT OCW", batch.Title)
+}
+
+func TestSubscriptions_OtherAPIKeysAreHidden(t *testing.T) {
+	app := setupTestApp()
+
+	other, err := models.NewSubscription(models.SubscriptionRequest{URL: "https://www.youtube.com/@mitocw"})
+	require.NoError(t, err)
+	other.APIKeyID = "key_someone_else"
+	require.NoError(t, jobs.GetQueue().AddSubscription(other))
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	var listed struct {
+		Subscriptions []models.Subscription `json:"subscriptions"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	assert.Empty(t, listed.Subscriptions)
+
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/subscriptions/"+other.ID, bytes.NewReader([]byte(`{"active": false}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		assert.Equal(t, 404, resp.StatusCode, method)
+	}
+
+	stored, err := jobs.GetQueue().GetSubscription(other.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Active)
+}
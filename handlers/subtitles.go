@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"videotranscript-app/lib"
+)
+
+// PostConvertSubtitles converts an uploaded subtitle file between srt, vtt,
+// ass, ttml, sbv and json. The file is the raw request body or the "file"
+// field of a multipart form. The source format comes from the from query
+// parameter, the uploaded file name or the content itself; to is required.
+func PostConvertSubtitles(c *fiber.Ctx) error {
+	var input io.Reader = bytes.NewReader(c.Body())
+	name := "subtitles"
+	fromName := c.Query("from")
+
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Multipart requests must carry the subtitles in a file field",
+			})
+		}
+		file, err := header.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to read uploaded file",
+			})
+		}
+		defer file.Close()
+
+		input = file
+		ext := filepath.Ext(header.Filename)
+		if base := strings.TrimSuffix(filepath.Base(header.Filename), ext); base != "" && base != "." {
+			name = base
+		}
+		if fromName == "" {
+			fromName = ext
+		}
+	} else if len(c.Body()) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Request body must contain the subtitles to convert",
+		})
+	}
+
+	var from lib.SubtitleFormat
+	if fromName != "" {
+		format, err := lib.ParseSubtitleFormat(fromName)
+		if err != nil {
+			return unsupportedSubtitleFormat(c, "from")
+		}
+		from = format
+	}
+	to, err := lib.ParseSubtitleFormat(c.Query("to"))
+	if err != nil {
+		return unsupportedSubtitleFormat(c, "to")
+	}
+
+	opts, err := lib.ParseConvertOptions(func(key string) string { return c.Query(key) })
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	content, err := lib.ConvertSubtitles(input, from, to, opts)
+	if err != nil {
+		// Conversion only fails on input that cannot be read as subtitles
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to convert subtitles: %v", err),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, lib.SubtitleContentType(to))
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name+"."+string(to)))
+	return c.SendString(content)
+}
+
+func unsupportedSubtitleFormat(c *fiber.Ctx, param string) error {
+	formats := make([]string, len(lib.SubtitleFormats))
+	for i, format := range lib.SubtitleFormats {
+		formats[i] = string(format)
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": fmt.Sprintf("Unsupported %s format. Supported: %s", param, strings.Join(formats, ", ")),
+	})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSRT = "1\n00:00:01,000 --> 00:00:02,500\nHello world\n\n"
+
+func TestPostConvertSubtitles(t *testing.T) {
+	app := setupTestApp()
+
+	t.Run("raw body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/subtitles/convert?to=vtt&offset=1", strings.NewReader(testSRT))
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "text/vtt; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="subtitles.vtt"`, resp.Header.Get("Content-Disposition"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "WEBVTT\n\n00:00:02.000 --> 00:00:03.500\nHello world\n\n", string(body))
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		var form bytes.Buffer
+		writer := multipart.NewWriter(&form)
+		part, err := writer.CreateFormFile("file", "episode1.srt")
+		require.NoError(t, err)
+		part.Write([]byte(testSRT))
+		require.NoError(t, writer.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/subtitles/convert?to=ttml&frame_rate=25", &form)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, `attachment; filename="episode1.ttml"`, resp.Header.Get("Content-Disposition"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `<p xml:id="c1" begin="00:00:01:00" end="00:00:02:13">Hello world</p>`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		post := func(path, body string) int {
+			resp, err := app.Test(httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)), -1)
+			require.NoError(t, err)
+			return resp.StatusCode
+		}
+		assert.Equal(t, 400, post("/subtitles/convert?to=vtt", ""))
+		assert.Equal(t, 400, post("/subtitles/convert?to=docx", testSRT))
+		assert.Equal(t, 400, post("/subtitles/convert?to=vtt&from=docx", testSRT))
+		assert.Equal(t, 400, post("/subtitles/convert?to=vtt&from_fps=25", testSRT))
+		assert.Equal(t, 400, post("/subtitles/convert?to=vtt", "not subtitles at all"))
+	})
+}
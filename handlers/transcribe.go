@@ -1,16 +1,24 @@
 package handlers
 
 import (
-	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
+	"videotranscript-app/config"
 	"videotranscript-app/jobs"
 	"videotranscript-app/lib"
 	"videotranscript-app/models"
 )
 
+// submitMu serialises the final in-flight lookup and job creation so
+// concurrent requests for the same video attach to a single job.
+var submitMu sync.Mutex
+
 func PostTranscribe(c *fiber.Ctx) error {
 	var req models.TranscribeRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -19,59 +27,222 @@ func PostTranscribe(c *fiber.Ctx) error {
 		})
 	}
 
+	priority, err := models.ParseJobPriority(req.Priority)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	opts, err := req.WaitOptions()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if models.CollectionKindOf(req.URL) != "" {
+		return transcribeCollection(c, req, priority)
+	}
+
 	if !models.ValidateURL(req.URL) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid YouTube URL",
 		})
 	}
 
-	job := jobs.NewJob(req.URL)
 	queue := jobs.GetQueue()
-	queue.AddJob(job)
+	videoID := models.ExtractVideoID(req.URL)
+
+	if !req.ForceRefresh {
+		cached, err := jobs.FindCachedJob(queue, videoID, lib.ResultCacheKey(req.URL))
+		if err != nil {
+			log.Printf("Failed to look up cached result for %s: %v", videoID, err)
+		} else if cached != nil {
+			return completeFromCache(c, req.URL, cached)
+		}
 
+		if existing := findInFlightJob(queue, videoID); existing != nil {
+			return respondWithInFlightJob(c, existing, opts)
+		}
+	}
+
+	// Capacity is checked before the job is stored, so a full queue does
+	// not leave a failed job behind
+	pool := jobs.GetPool()
+	if pool.Available() == 0 {
+		return queueFull(c)
+	}
+
+	// The video is looked up before the job is stored, so one that can not
+	// be read never leaves a job behind for other requests to attach to
 	duration, err := lib.GetVideoDuration(req.URL)
 	if err != nil {
-		job.MarkError(err)
-		queue.UpdateJob(job)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Failed to get video information",
 		})
 	}
 
-	if duration <= 120 {
-		go processTranscriptionSync(job)
-
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-		defer cancel()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return c.JSON(models.TranscribeResponse{
-					JobID: job.ID,
-				})
-			default:
-				currentJob, _ := queue.GetJob(job.ID)
-				if currentJob.IsComplete() {
-					if currentJob.Status == jobs.StatusError {
-						return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-							"error": currentJob.Error,
-						})
-					}
-					return c.JSON(models.TranscribeResponse{
-						Transcript: currentJob.Transcript,
-						Segments:   currentJob.Segments,
-					})
+	job := jobs.NewJob(req.URL)
+	job.APIKeyID = lib.RequestAPIKeyID(c)
+	job.Priority = priority
+	job.Metadata = &models.VideoMetadata{Duration: float64(duration)}
+
+	submitMu.Lock()
+	if !req.ForceRefresh {
+		// Another request may have created a job for the video meanwhile
+		if existing := findInFlightJob(queue, videoID); existing != nil {
+			submitMu.Unlock()
+			return respondWithInFlightJob(c, existing, opts)
+		}
+	}
+	// The lookup may have taken long enough for the queue to fill up
+	if pool.Available() == 0 {
+		submitMu.Unlock()
+		return queueFull(c)
+	}
+	err = queue.AddJob(job)
+	submitMu.Unlock()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create job",
+		})
+	}
+
+	if err := pool.Submit(job); err != nil {
+		job.MarkError(err)
+		queue.UpdateJob(job)
+		if errors.Is(err, jobs.ErrQueueFull) {
+			return queueFull(c)
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Job queue is not accepting jobs",
+		})
+	}
+
+	return respondWithJob(c, job, opts.WaitFor(duration))
+}
+
+// queueFull answers a request the job queue has no room for with 429 and
+// a Retry-After header.
+func queueFull(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(config.Load().QueueRetryAfter))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error": "Job queue is full, please retry later",
+	})
+}
+
+// ownedJob returns the job named in the path. Another API key's job is
+// reported as not found, like ownedSubscription does.
+func ownedJob(c *fiber.Ctx) (*jobs.Job, error) {
+	job, err := jobs.GetQueue().GetJob(c.Params("job_id"))
+	if err != nil {
+		return nil, err
+	}
+	if job.APIKeyID != lib.RequestAPIKeyID(c) {
+		return nil, jobs.ErrJobNotFound
+	}
+	return job, nil
+}
+
+// findInFlightJob returns the pending or running job for the video, if
+// any, logging lookup failures so the request goes on with a new job.
+func findInFlightJob(queue jobs.JobStore, videoID string) *jobs.Job {
+	existing, err := jobs.FindInFlightJob(queue, videoID)
+	if err != nil {
+		log.Printf("Failed to look up in-flight job for %s: %v", videoID, err)
+		return nil
+	}
+	return existing
+}
+
+// respondWithInFlightJob answers a request with the job already running
+// for its video.
+func respondWithInFlightJob(c *fiber.Ctx, existing *jobs.Job, opts models.WaitOptions) error {
+	// The existing job may not know its video length yet
+	duration := -1
+	if existing.Metadata != nil {
+		duration = int(existing.VideoDuration())
+	}
+	return respondWithJob(c, existing, opts.WaitFor(duration))
+}
+
+// completeFromCache answers a request with a new job that reuses the
+// result of an earlier job for the same video.
+func completeFromCache(c *fiber.Ctx, url string, cached *jobs.Job) error {
+	job := jobs.NewJob(url)
+	job.APIKeyID = lib.RequestAPIKeyID(c)
+	if err := job.CompleteFromCache(cached); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reuse cached result",
+		})
+	}
+	if err := jobs.GetQueue().AddJob(job); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create job",
+		})
+	}
+
+	return respondWithJob(c, job, 0)
+}
+
+// respondWithJob waits up to wait for a job to finish. A finished job is
+// answered with 200, or 500 if it failed; otherwise the answer is 202 with
+// a Location header to poll. Every answer carries the job resource.
+func respondWithJob(c *fiber.Ctx, job *jobs.Job, wait time.Duration) error {
+	current, err := waitForJob(job.ID, wait)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load job",
+		})
+	}
+
+	c.Locals(idempotentJobLocal, current.ID)
+	response := jobResource(current)
+	// job_id predates the job resource and is kept for existing clients
+	response["job_id"] = current.ID
+
+	switch current.Status {
+	case jobs.StatusComplete:
+		return c.JSON(response)
+	case jobs.StatusError:
+		return c.Status(fiber.StatusInternalServerError).JSON(response)
+	}
+	c.Location("/transcribe/" + current.ID)
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// waitForJob waits up to wait for a job to finish and returns its latest
+// state.
+func waitForJob(jobID string, wait time.Duration) (*jobs.Job, error) {
+	queue := jobs.GetQueue()
+	if wait <= 0 {
+		return queue.GetJob(jobID)
+	}
+
+	// Subscribe before reading the job so its final event cannot be missed
+	events, unsubscribe := jobs.GetEventBus().Subscribe(jobID)
+	defer unsubscribe()
+
+	job, err := queue.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	for !job.IsComplete() {
+		select {
+		case <-timeout.C:
+			return queue.GetJob(jobID)
+		case event := <-events:
+			if event.IsFinal() {
+				if job, err = queue.GetJob(jobID); err != nil {
+					return nil, err
 				}
-				time.Sleep(1 * time.Second)
 			}
 		}
-	} else {
-		go processTranscriptionAsync(job)
-		return c.JSON(models.TranscribeResponse{
-			JobID: job.ID,
-		})
 	}
+	return job, nil
 }
 
 func GetTranscribeJob(c *fiber.Ctx) error {
@@ -82,53 +253,175 @@ func GetTranscribeJob(c *fiber.Ctx) error {
 		})
 	}
 
-	queue := jobs.GetQueue()
-	job, err := queue.GetJob(jobID)
+	job, err := jobs.GetQueue().GetJob(jobID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Job not found",
 		})
 	}
 
+	return c.JSON(jobResource(job))
+}
+
+// jobResource is the job as returned by GET /transcribe/:job_id, with the
+// fields relevant to its status.
+func jobResource(job *jobs.Job) fiber.Map {
 	response := fiber.Map{
 		"id":         job.ID,
 		"status":     job.Status,
+		"priority":   job.Priority,
 		"created_at": job.CreatedAt,
 	}
 
-	if job.Status == jobs.StatusComplete {
+	if job.Status == jobs.StatusPending {
+		if position, startAt := jobs.GetPool().Estimate(job.ID); position > 0 {
+			response["queue_position"] = position
+			response["estimated_start_at"] = startAt
+		}
+		if job.RetryAt != nil {
+			response["error"] = job.Error
+			response["error_code"] = job.ErrorCode
+			response["attempts"] = job.Attempts
+			response["retry_at"] = job.RetryAt
+		}
+	} else if job.Status == jobs.StatusRunning {
+		response["stage"] = job.Stage
+		response["progress"] = job.Progress
+	} else if job.Status == jobs.StatusComplete {
 		response["transcript"] = job.Transcript
 		response["segments"] = job.Segments
+		if len(job.Chapters) > 0 {
+			response["chapters"] = job.Chapters
+		}
+		response["engine"] = job.Engine
+		response["language"] = job.Language
 		response["completed_at"] = job.CompletedAt
+		response["subtitle_files"] = subtitleFiles(job)
+		if job.CachedFrom != "" {
+			response["cached_from"] = job.CachedFrom
+		}
 	} else if job.Status == jobs.StatusError {
 		response["error"] = job.Error
+		response["error_code"] = job.ErrorCode
+		response["attempts"] = job.Attempts
 		response["completed_at"] = job.CompletedAt
 	}
 
-	return c.JSON(response)
+	return response
 }
 
-func processTranscriptionSync(job *jobs.Job) {
-	processTranscription(job)
-}
+// ListTranscribeJobs lists jobs newest first, filtered and paginated by the
+// query parameters understood by models.ParseJobQuery.
+func ListTranscribeJobs(c *fiber.Ctx) error {
+	query, err := models.ParseJobQuery(c.Queries())
+	if err == nil {
+		err = query.RestrictToAPIKey(lib.RequestAPIKeyID(c))
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-func processTranscriptionAsync(job *jobs.Job) {
-	processTranscription(job)
-}
+	page, err := jobs.GetQueue().QueryJobs(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list jobs",
+		})
+	}
 
-func processTranscription(job *jobs.Job) {
-	queue := jobs.GetQueue()
+	summaries := make([]models.JobSummary, 0, len(page.Jobs))
+	for _, job := range page.Jobs {
+		summaries = append(summaries, job.Summary())
+	}
 
-	job.MarkRunning()
-	queue.UpdateJob(job)
+	response := fiber.Map{
+		"jobs": summaries,
+	}
+	if page.NextCursor != "" {
+		response["next_cursor"] = page.NextCursor
+	}
+	return c.JSON(response)
+}
 
-	transcript, segments, err := lib.ProcessTranscription(job.URL, job.ID)
+// ProcessJob runs the transcription pipeline for a job. It is the worker
+// function handed to the jobs pool.
+func ProcessJob(job *jobs.Job) {
+	if err := job.MarkRunning(); err != nil {
+		log.Printf("Skipping job %s: %v", job.ID, err)
+		return
+	}
+	saveJob(job)
+	publishEvent(models.EventStatus, job)
+
+	result, err := lib.ProcessTranscription(job.URL, job.ID, func(stage models.JobStage, progress int) {
+		eventType := models.EventProgress
+		if stage != job.Stage {
+			eventType = models.EventStage
+		}
+		job.MarkStage(stage, progress)
+		saveJob(job)
+		publishEvent(eventType, job)
+	})
 	if err != nil {
+		policy := retryPolicy()
+		if policy.ShouldRetry(job, err) {
+			delay := policy.Delay(job.Attempts)
+			if job.MarkRetry(err, time.Now().Add(delay)) == nil {
+				saveJob(job)
+				publishEvent(models.EventStatus, job)
+				log.Printf("Job %s attempt %d failed (%s), retrying in %s", job.ID, job.Attempts, job.ErrorCode, delay)
+				jobs.GetPool().RequeueAfter(job, delay)
+				return
+			}
+		}
+
 		job.MarkError(err)
-		queue.UpdateJob(job)
+		saveJob(job)
+		finishJob(job)
 		return
 	}
 
-	job.MarkComplete(transcript, segments)
-	queue.UpdateJob(job)
+	job.Engine = result.Engine
+	job.Language = result.Language
+	job.CacheKey = result.CacheKey(job.URL)
+	job.Chapters = result.Chapters
+	job.MarkComplete(result.Transcript, result.Segments)
+	storeArtifacts(job, result.AudioPath)
+	saveJob(job)
+	// The engines return all segments at once, after transcribing. Streams
+	// that drop some of them backfill from the saved job.
+	for i := range job.Segments {
+		jobs.GetEventBus().Publish(models.NewSegmentEvent(job, i))
+	}
+	finishJob(job)
+}
+
+// publishEvent publishes the job's current state to its event stream.
+func publishEvent(eventType models.JobEventType, job *jobs.Job) {
+	jobs.GetEventBus().Publish(models.NewJobEvent(eventType, job))
+}
+
+// finishJob publishes the final status event and runs the follow-up work
+// once a job reached a terminal status.
+func finishJob(job *jobs.Job) {
+	publishEvent(models.EventStatus, job)
+	if job.BatchID != "" {
+		finishBatch(job.BatchID)
+	}
+}
+
+func retryPolicy() models.RetryPolicy {
+	cfg := config.Load()
+	return models.RetryPolicy{
+		MaxAttempts: cfg.JobMaxAttempts,
+		Backoff:     time.Duration(cfg.JobRetryBackoff) * time.Second,
+		MaxBackoff:  time.Duration(cfg.JobRetryMaxDelay) * time.Second,
+	}
+}
+
+func saveJob(job *jobs.Job) {
+	if err := jobs.GetQueue().UpdateJob(job); err != nil {
+		log.Printf("Failed to save job %s: %v", job.ID, err)
+	}
 }
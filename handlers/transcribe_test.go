@@ -3,8 +3,10 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"videotranscript-app/jobs"
+	"videotranscript-app/lib"
 	"videotranscript-app/models"
 )
 
@@ -22,9 +25,24 @@ func setupTestApp() *fiber.App {
 	})
 
 	jobs.Initialize()
+	jobs.InitializePool(1, 10, func(job *jobs.Job) {})
 
-	app.Post("/transcribe", PostTranscribe)
+	app.Post("/transcribe", Idempotent, PostTranscribe)
+	app.Get("/transcribe", ListTranscribeJobs)
+	app.Post("/transcribe/batch", Idempotent, PostTranscribeBatch)
+	app.Get("/transcribe/batch/:batch_id", GetTranscribeBatch)
 	app.Get("/transcribe/:job_id", GetTranscribeJob)
+	app.Get("/transcribe/:job_id/events", GetTranscribeJobEvents)
+	app.Get("/transcribe/:job_id/transcript.:format", GetTranscriptFile)
+	app.Get("/transcribe/:job_id/chapters.:format", GetChaptersFile)
+	app.Post("/transcribe/:job_id/video", PostRenderVideo)
+	app.Get("/transcribe/:job_id/video/:render", GetRenderedVideo)
+	app.Post("/subtitles/convert", PostConvertSubtitles)
+	app.Post("/subscriptions", PostSubscription)
+	app.Get("/subscriptions", ListSubscriptions)
+	app.Get("/subscriptions/:subscription_id", GetSubscription)
+	app.Patch("/subscriptions/:subscription_id", PatchSubscription)
+	app.Delete("/subscriptions/:subscription_id", DeleteSubscription)
 
 	return app
 }
@@ -56,6 +74,18 @@ func TestPostTranscribe_ValidationErrors(t *testing.T) {
 			expectedCode: 400,
 			expectedMsg:  "Invalid YouTube URL",
 		},
+		{
+			name:         "Invalid playlist date range",
+			body:         map[string]string{"url": "https://www.youtube.com/playlist?list=PL590L5WQmH8fJ54F369BLDSqIwcs-TCfs", "date_after": "last week"},
+			expectedCode: 400,
+			expectedMsg:  "date_after must be a YYYY-MM-DD date",
+		},
+		{
+			name:         "Invalid priority",
+			body:         map[string]string{"url": "https://www.youtube.com/watch?v=dQw4w9WgXcQ", "priority": "urgent"},
+			expectedCode: 400,
+			expectedMsg:  "priority must be high, normal or low",
+		},
 	}
 
 	for _, tt := range tests {
@@ -106,6 +136,211 @@ func TestGetTranscribeJob_NotFound(t *testing.T) {
 	assert.Equal(t, "Job not found", result["error"])
 }
 
+func TestListTranscribeJobs_FiltersAndPaginates(t *testing.T) {
+	app := setupTestApp()
+	queue := jobs.GetQueue()
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		job := jobs.NewJob("https://youtube.com/watch?v=test")
+		job.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, queue.AddJob(job))
+	}
+	failed := jobs.NewJob("https://youtube.com/watch?v=failed")
+	failed.MarkError(assert.AnError)
+	require.NoError(t, queue.AddJob(failed))
+
+	list := func(query string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodGet, "/transcribe?"+query, nil)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result
+	}
+
+	first := list("status=pending&limit=2")
+	assert.Len(t, first["jobs"], 2)
+	require.NotEmpty(t, first["next_cursor"])
+
+	second := list("status=pending&limit=2&cursor=" + first["next_cursor"].(string))
+	assert.Len(t, second["jobs"], 1)
+	assert.Nil(t, second["next_cursor"])
+
+	failedOnly := list("status=error")
+	require.Len(t, failedOnly["jobs"], 1)
+	assert.Equal(t, failed.ID, failedOnly["jobs"].([]interface{})[0].(map[string]interface{})["id"])
+
+	for _, query := range []string{"sort=title", "api_key_id=key_someone_else"} {
+		req := httptest.NewRequest(http.MethodGet, "/transcribe?"+query, nil)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode, query)
+	}
+}
+
+func TestListTranscribeJobs_OnlyListsCallersJobs(t *testing.T) {
+	t.Setenv("API_KEY", "test-key")
+	setupTestApp()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/transcribe", lib.AuthMiddleware(), ListTranscribeJobs)
+
+	mine := jobs.NewJob("https://youtube.com/watch?v=mine")
+	mine.APIKeyID = lib.APIKeyID("test-key")
+	require.NoError(t, jobs.GetQueue().AddJob(mine))
+	theirs := jobs.NewJob("https://youtube.com/watch?v=theirs")
+	theirs.APIKeyID = "key_someone_else"
+	require.NoError(t, jobs.GetQueue().AddJob(theirs))
+
+	req := httptest.NewRequest(http.MethodGet, "/transcribe", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	var result struct {
+		Jobs []models.JobSummary `json:"jobs"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Len(t, result.Jobs, 1)
+	assert.Equal(t, mine.ID, result.Jobs[0].ID)
+}
+
+func TestPostTranscribe_ReusesCachedResult(t *testing.T) {
+	app := setupTestApp()
+	url := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+	done := jobs.NewJob(url)
+	done.MarkRunning()
+	done.CacheKey = lib.ResultCacheKey(url)
+	done.MarkComplete("Cached transcript", []jobs.Segment{{Start: 0, End: 5, Text: "Cached transcript"}})
+	require.NoError(t, jobs.GetQueue().AddJob(done))
+
+	body, err := json.Marshal(map[string]string{"url": url})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/transcribe", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	var result models.TranscribeResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, "Cached transcript", result.Transcript)
+	require.NotEmpty(t, result.JobID)
+	assert.NotEqual(t, done.ID, result.JobID)
+
+	reused, err := jobs.GetQueue().GetJob(result.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusComplete, reused.Status)
+	assert.Equal(t, done.ID, reused.CachedFrom)
+}
+
+func TestTranscribeBatch_CreatesJobsAndReportsProgress(t *testing.T) {
+	app := setupTestApp()
+	cachedURL := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+	done := jobs.NewJob(cachedURL)
+	done.MarkRunning()
+	done.CacheKey = lib.ResultCacheKey(cachedURL)
+	done.MarkComplete("Cached transcript", nil)
+	require.NoError(t, jobs.GetQueue().AddJob(done))
+
+	body, err := json.Marshal(models.BatchRequest{URLs: []string{
+		cachedURL,
+		"https://www.youtube.com/watch?v=9bZkp7q19f0",
+		"https://youtu.be/9bZkp7q19f0",
+	}})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/transcribe/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	var created models.BatchResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	require.NotEmpty(t, created.BatchID)
+	assert.Equal(t, 2, created.Total)
+	require.Len(t, created.Jobs, 2)
+	assert.Equal(t, cachedURL, created.Jobs[0].URL)
+
+	req = httptest.NewRequest(http.MethodGet, "/transcribe/batch/"+created.BatchID, nil)
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	var status models.BatchStatus
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	assert.Equal(t, models.StatusRunning, status.Status)
+	assert.Equal(t, 2, status.Total)
+	assert.Equal(t, 1, status.Complete)
+	assert.Equal(t, 1, status.Pending)
+	assert.Equal(t, 50, status.Progress)
+	assert.Len(t, status.Jobs, 2)
+
+	req = httptest.NewRequest(http.MethodGet, "/transcribe/batch/non-existent-id", nil)
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	body, err = json.Marshal(models.BatchRequest{URLs: []string{"not-a-url"}})
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodPost, "/transcribe/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+// flakyStore fails every AddJob after the first few
+type flakyStore struct {
+	*jobs.MemoryStore
+	addsLeft int
+}
+
+func (s *flakyStore) AddJob(job *jobs.Job) error {
+	if s.addsLeft == 0 {
+		return errors.New("store unavailable")
+	}
+	s.addsLeft--
+	return s.MemoryStore.AddJob(job)
+}
+
+func TestTranscribeBatch_FailedSubmissionRunsNothing(t *testing.T) {
+	app := setupTestApp()
+	store := &flakyStore{MemoryStore: jobs.NewMemoryStore(), addsLeft: 1}
+	jobs.InitializeWithStore(store)
+	var processed atomic.Int32
+	jobs.InitializePool(1, 10, func(job *jobs.Job) { processed.Add(1) })
+
+	body, err := json.Marshal(models.BatchRequest{URLs: []string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://www.youtube.com/watch?v=9bZkp7q19f0",
+	}})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/transcribe/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 500, resp.StatusCode)
+
+	// The child stored before the failure is failed rather than left
+	// pending, and no batch or queued job is left behind
+	stored, err := store.ListJobs()
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, models.StatusError, stored[0].Status)
+	_, err = store.GetBatch(stored[0].BatchID)
+	assert.ErrorIs(t, err, jobs.ErrBatchNotFound)
+	assert.Equal(t, 0, jobs.GetPool().Pending())
+	assert.Zero(t, processed.Load())
+}
+
 func TestJobQueue_Operations(t *testing.T) {
 	jobs.Initialize()
 	queue := jobs.GetQueue()
@@ -208,6 +443,7 @@ func BenchmarkJobQueue_ConcurrentAccess(b *testing.B) {
 				b.Error(err)
 			}
 
+			job.MarkRunning()
 			job.MarkComplete("Test", []jobs.Segment{})
 			queue.UpdateJob(job)
 		}
@@ -236,6 +472,87 @@ func BenchmarkAPI_HealthEndpoint(b *testing.B) {
 	}
 }
 
+func TestPostTranscribe_ModeAndWait(t *testing.T) {
+	app := setupTestApp()
+	url := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+	// A pending job for the video, which requests attach to
+	existing := jobs.NewJob(url)
+	existing.Metadata = &models.VideoMetadata{Duration: 600}
+	require.NoError(t, jobs.GetQueue().AddJob(existing))
+
+	post := func(body map[string]interface{}) (*http.Response, map[string]interface{}) {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/transcribe", bytes.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		require.NoError(t, err)
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp, result
+	}
+
+	resp, result := post(map[string]interface{}{"url": url, "mode": "async"})
+	assert.Equal(t, 202, resp.StatusCode)
+	assert.Equal(t, "/transcribe/"+existing.ID, resp.Header.Get("Location"))
+	assert.Equal(t, existing.ID, result["id"])
+	assert.Equal(t, existing.ID, result["job_id"])
+	assert.Equal(t, "pending", result["status"])
+
+	// auto mode does not wait for a 10 minute video
+	resp, _ = post(map[string]interface{}{"url": url})
+	assert.Equal(t, 202, resp.StatusCode)
+
+	resp, result = post(map[string]interface{}{"url": url, "mode": "eventually"})
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "mode must be sync, async or auto", result["error"])
+
+	// sync mode waits until the job completes
+	finished := *existing
+	go func() {
+		bus := jobs.GetEventBus()
+		for bus.Subscribers(finished.ID) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		finished.MarkRunning()
+		finished.MarkComplete("Hello world", []jobs.Segment{{Start: 0, End: 1, Text: "Hello world"}})
+		jobs.GetQueue().UpdateJob(&finished)
+		bus.Publish(models.NewJobEvent(models.EventStatus, &finished))
+	}()
+
+	resp, result = post(map[string]interface{}{"url": url, "mode": "sync", "wait_seconds": 5})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Location"))
+	assert.Equal(t, "complete", result["status"])
+	assert.Equal(t, "Hello world", result["transcript"])
+}
+
+func TestPostTranscribe_QueueFullStoresNoJob(t *testing.T) {
+	app := setupTestApp()
+
+	// A busy worker and a full queue of one
+	release := make(chan struct{})
+	defer close(release)
+	jobs.InitializePool(1, 1, func(job *jobs.Job) { <-release })
+	require.NoError(t, jobs.GetPool().Submit(jobs.NewJob("https://www.youtube.com/watch?v=busy")))
+	require.Eventually(t, func() bool { return jobs.GetPool().Pending() == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, jobs.GetPool().Submit(jobs.NewJob("https://www.youtube.com/watch?v=waiting")))
+
+	reqBody, err := json.Marshal(map[string]interface{}{"url": "https://www.youtube.com/watch?v=dQw4w9WgXcQ"})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/transcribe", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	require.NoError(t, err)
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	stored, err := jobs.GetQueue().ListJobs()
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
 // Load test simulation
 func TestAPI_LoadTest(t *testing.T) {
 	if testing.Short() {
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"videotranscript-app/jobs"
+	"videotranscript-app/lib"
+)
+
+// GetTranscriptFile downloads a complete job's transcript as txt, srt, vtt,
+// ass, ttml, json, md, html or docx. Subtitles are laid out by the caption
+// preset and overrides in the query, which also styles ass and times ttml
+// files, and documents by its paragraph options. Responses carry an
+// ETag and answer a matching If-None-Match with 304 Not Modified.
+func GetTranscriptFile(c *fiber.Ctx) error {
+	format := c.Params("format")
+	job, err := jobs.GetQueue().GetJob(c.Params("job_id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Job not found",
+		})
+	}
+
+	opts, err := transcriptOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	file, err := lib.RenderTranscript(job, format, opts)
+	return sendJobFile(c, job, file, err, lib.TranscriptFormats, "Transcript is not available until the job is complete")
+}
+
+// GetChaptersFile downloads a complete job's chapters as YouTube description
+// timestamps (txt), a WebVTT chapters track (vtt), an ffmpeg metadata file
+// (ffmetadata) or json, with the same ETag handling as transcripts.
+func GetChaptersFile(c *fiber.Ctx) error {
+	job, err := jobs.GetQueue().GetJob(c.Params("job_id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Job not found",
+		})
+	}
+
+	file, err := lib.RenderChapters(job, c.Params("format"))
+	return sendJobFile(c, job, file, err, lib.ChapterFormats, "Chapters are not available until the job is complete")
+}
+
+// sendJobFile answers with a file rendered from a job in one of formats, or
+// with the error rendering it returned
+func sendJobFile(c *fiber.Ctx, job *jobs.Job, file *lib.TranscriptFile, err error, formats []string, notReady string) error {
+	if errors.Is(err, lib.ErrUnknownTranscriptFormat) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported format. Supported: " + strings.Join(formats, ", "),
+		})
+	} else if errors.Is(err, lib.ErrTranscriptNotReady) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  notReady,
+			"status": job.Status,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to render file",
+		})
+	}
+
+	c.Set(fiber.HeaderETag, file.ETag)
+	c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	if file.NotModified(c.Get(fiber.HeaderIfNoneMatch)) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, file.ContentType)
+	c.Set(fiber.HeaderContentDisposition, file.ContentDisposition())
+	return c.Send(file.Content)
+}
+
+// transcriptOptions resolves the caption layout requested by the preset,
+// max_line_length, max_lines and max_cps query parameters, and the writer
+// and paragraph options read by lib.ParseSubtitleOptions and
+// lib.ParseParagraphOptions
+func transcriptOptions(c *fiber.Ctx) (lib.TranscriptOptions, error) {
+	captions, err := lib.ParseCaptionOptions(c.Query("preset"), c.Query("max_line_length"), c.Query("max_lines"), c.Query("max_cps"))
+	if err != nil {
+		return lib.TranscriptOptions{}, err
+	}
+	style, err := captions.Style()
+	if err != nil {
+		return lib.TranscriptOptions{}, err
+	}
+	query := func(key string) string { return c.Query(key) }
+	subtitleOpts, err := lib.ParseSubtitleOptions(query)
+	if err != nil {
+		return lib.TranscriptOptions{}, err
+	}
+	paragraphs, err := lib.ParseParagraphOptions(query)
+	if err != nil {
+		return lib.TranscriptOptions{}, err
+	}
+	return lib.TranscriptOptions{Captions: style, SubtitleOptions: subtitleOpts, Paragraphs: paragraphs}, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/jobs"
+	"videotranscript-app/models"
+)
+
+func TestGetTranscriptFile(t *testing.T) {
+	app := setupTestApp()
+
+	done := jobs.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	done.VideoID = "dQw4w9WgXcQ"
+	done.MarkRunning()
+	done.MarkComplete("Hello world", []jobs.Segment{{Start: 0, End: 1.5, Text: "Hello world", Words: []models.Word{
+		{Start: 0, End: 0.6, Text: "Hello"},
+		{Start: 0.7, End: 1.5, Text: "world"},
+	}}})
+	require.NoError(t, jobs.GetQueue().AddJob(done))
+
+	pending := jobs.NewJob("https://www.youtube.com/watch?v=9bZkp7q19f0")
+	require.NoError(t, jobs.GetQueue().AddJob(pending))
+
+	get := func(path, ifNoneMatch string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("srt", func(t *testing.T) {
+		resp := get("/transcribe/"+done.ID+"/transcript.srt", "")
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "application/x-subrip; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="transcript_dQw4w9WgXcQ.srt"`, resp.Header.Get("Content-Disposition"))
+		assert.NotEmpty(t, resp.Header.Get("ETag"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "1\n00:00:00,000 --> 00:00:01,500\nHello world\n\n", string(body))
+	})
+
+	t.Run("vtt karaoke", func(t *testing.T) {
+		resp := get("/transcribe/"+done.ID+"/transcript.vtt?karaoke=true&line=-2&align=center", "")
+		require.Equal(t, 200, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "WEBVTT\n\n00:00:00.000 --> 00:00:01.500 line:-2 align:center\n<c.word>Hello</c> <00:00:00.700><c.word>world</c>\n\n", string(body))
+	})
+
+	t.Run("ass", func(t *testing.T) {
+		resp := get("/transcribe/"+done.ID+"/transcript.ass?font=Impact&alignment=8", "")
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "text/x-ssa; charset=utf-8", resp.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "Style: Default,Impact,64,")
+		assert.Contains(t, string(body), "Dialogue: 0,0:00:00.00,0:00:01.50,Default,,0,0,0,,Hello world\n")
+	})
+
+	t.Run("ttml", func(t *testing.T) {
+		resp := get("/transcribe/"+done.ID+"/transcript.ttml?frame_rate=25", "")
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "application/ttml+xml; charset=utf-8", resp.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `<p xml:id="c1" begin="00:00:00:00" end="00:00:01:13">Hello world</p>`)
+	})
+
+	t.Run("md", func(t *testing.T) {
+		resp := get("/transcribe/"+done.ID+"/transcript.md?timestamps=30", "")
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "text/markdown; charset=utf-8", resp.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "[0:00](https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=0s) Hello world\n")
+	})
+
+	t.Run("html", func(t *testing.T) {
+		resp := get("/transcribe/"+done.ID+"/transcript.html?timestamps=30", "")
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="transcript_dQw4w9WgXcQ.html"`, resp.Header.Get("Content-Disposition"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `<p><a class="timestamp" href="https://www.youtube.com/watch?v=dQw4w9WgXcQ&amp;t=0s">0:00</a>Hello world</p>`)
+	})
+
+	t.Run("if-none-match", func(t *testing.T) {
+		etag := get("/transcribe/"+done.ID+"/transcript.vtt", "").Header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		resp := get("/transcribe/"+done.ID+"/transcript.vtt", `"stale", `+etag)
+		assert.Equal(t, 304, resp.StatusCode)
+		assert.Equal(t, etag, resp.Header.Get("ETag"))
+
+		resp = get("/transcribe/"+done.ID+"/transcript.txt", etag)
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert.Equal(t, 400, get("/transcribe/"+done.ID+"/transcript.pdf", "").StatusCode)
+		assert.Equal(t, 400, get("/transcribe/"+done.ID+"/transcript.md?pause=0", "").StatusCode)
+		assert.Equal(t, 400, get("/transcribe/"+done.ID+"/transcript.txt?speakers=maybe", "").StatusCode)
+		assert.Equal(t, 400, get("/transcribe/"+done.ID+"/transcript.srt?preset=cinema", "").StatusCode)
+		assert.Equal(t, 400, get("/transcribe/"+done.ID+"/transcript.srt?max_lines=9", "").StatusCode)
+		assert.Equal(t, 400, get("/transcribe/"+done.ID+"/transcript.vtt?align=middle", "").StatusCode)
+		assert.Equal(t, 400, get("/transcribe/"+done.ID+"/transcript.ass?color=red", "").StatusCode)
+		assert.Equal(t, 400, get("/transcribe/"+done.ID+"/transcript.ttml?frame_rate=25&drop_frame=true", "").StatusCode)
+		assert.Equal(t, 404, get("/transcribe/missing/transcript.txt", "").StatusCode)
+		assert.Equal(t, 409, get("/transcribe/"+pending.ID+"/transcript.txt", "").StatusCode)
+	})
+}
+
+func TestGetChaptersFile(t *testing.T) {
+	app := setupTestApp()
+
+	done := jobs.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	done.VideoID = "dQw4w9WgXcQ"
+	done.MarkRunning()
+	done.Chapters = []models.Chapter{
+		{Start: 0, End: 95, Title: "Intro"},
+		{Start: 95, End: 212, Title: "Chorus"},
+	}
+	done.MarkComplete("Hello world", []jobs.Segment{{Start: 0, End: 212, Text: "Hello world"}})
+	require.NoError(t, jobs.GetQueue().AddJob(done))
+
+	pending := jobs.NewJob("https://www.youtube.com/watch?v=9bZkp7q19f0")
+	require.NoError(t, jobs.GetQueue().AddJob(pending))
+
+	get := func(path string) *http.Response {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil), -1)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := get("/transcribe/" + done.ID + "/chapters.txt")
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, `attachment; filename="chapters_dQw4w9WgXcQ.txt"`, resp.Header.Get("Content-Disposition"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "0:00 Intro\n1:35 Chorus\n", string(body))
+
+	resp = get("/transcribe/" + done.ID + "/chapters.ffmetadata")
+	require.Equal(t, 200, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "[CHAPTER]\nTIMEBASE=1/1000\nSTART=95000\nEND=212000\ntitle=Chorus\n")
+
+	assert.Equal(t, 400, get("/transcribe/"+done.ID+"/chapters.srt").StatusCode)
+	assert.Equal(t, 404, get("/transcribe/missing/chapters.txt").StatusCode)
+	assert.Equal(t, 409, get("/transcribe/"+pending.ID+"/chapters.vtt").StatusCode)
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"videotranscript-app/config"
+	"videotranscript-app/jobs"
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
+)
+
+// renderMu serializes re-reading a job and saving it with a render's
+// artifact, so concurrent renders of one job keep each other's artifacts
+var renderMu sync.Mutex
+
+// PostRenderVideo queues a render of a complete job's captions into its
+// video, burned in or as a soft subtitle track. The video is the "video"
+// field of a multipart upload or, without one, is downloaded from the
+// job's URL. Renders wait in the job pool with the caller's other work; the
+// response points at GetRenderedVideo, which reports when the render is
+// stored.
+func PostRenderVideo(c *fiber.Ctx) error {
+	job, err := ownedJob(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Job not found",
+		})
+	}
+
+	store := lib.GetArtifactStore()
+	if store == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Artifact storage is not configured",
+		})
+	}
+
+	opts, err := lib.ParseRenderOptions(func(key string) string { return c.Query(key) })
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if job.Status != models.StatusComplete {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  "Video can not be rendered until the job is complete",
+			"status": job.Status,
+		})
+	}
+
+	pool := jobs.GetPool()
+	if pool.Available() == 0 {
+		return queueFull(c)
+	}
+
+	// The upload is kept on disk until a worker renders it
+	var uploadPath string
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		header, err := c.FormFile("video")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Multipart requests must carry the video in a video field",
+			})
+		}
+		if uploadPath, err = saveUpload(job.ID, header); err != nil {
+			log.Printf("Failed to save video upload for job %s: %v", job.ID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to read uploaded video",
+			})
+		}
+	}
+
+	artifact := lib.VideoArtifact(job.ID, opts)
+	artifact.Status = models.ArtifactPending
+	if err := putRenderArtifact(job.ID, artifact); err != nil {
+		os.Remove(uploadPath)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to queue render",
+		})
+	}
+
+	err = pool.SubmitWork(job, func() {
+		renderVideo(job.ID, uploadPath, opts)
+	})
+	if err != nil {
+		os.Remove(uploadPath)
+		artifact.MarkError(err)
+		if err := putRenderArtifact(job.ID, artifact); err != nil {
+			log.Printf("Failed to save render of job %s: %v", job.ID, err)
+		}
+		if errors.Is(err, jobs.ErrQueueFull) {
+			return queueFull(c)
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Job queue is not accepting jobs",
+		})
+	}
+
+	c.Location("/transcribe/" + job.ID + "/video/" + lib.VideoRenderName(opts))
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"artifact": artifact,
+	})
+}
+
+// GetRenderedVideo reports a render queued by PostRenderVideo, named by
+// its mode and container as in "burn.mp4". A stored render is returned
+// with a signed download URL, a queued one with 202.
+func GetRenderedVideo(c *fiber.Ctx) error {
+	job, err := ownedJob(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Job not found",
+		})
+	}
+
+	artifact, ok := job.Artifact(lib.VideoArtifactKey(job.ID, c.Params("render")))
+	if !ok || artifact.Kind != "video" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Video render not found",
+		})
+	}
+
+	switch artifact.Status {
+	case models.ArtifactPending:
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"artifact": artifact,
+		})
+	case models.ArtifactError:
+		return c.Status(renderErrorStatus(artifact.ErrorCode)).JSON(fiber.Map{
+			"artifact":   artifact,
+			"error":      artifact.Error,
+			"error_code": artifact.ErrorCode,
+		})
+	}
+
+	store := lib.GetArtifactStore()
+	if store == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Artifact storage is not configured",
+		})
+	}
+	url, err := store.SignedURL(artifact.Key, artifactURLTTL())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to sign video URL",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"artifact": artifact,
+		"url":      url,
+	})
+}
+
+// renderVideo renders a queued video on a pool worker and records the
+// stored artifact, or why it failed, on the job
+func renderVideo(jobID, uploadPath string, opts lib.RenderOptions) {
+	artifact := lib.VideoArtifact(jobID, opts)
+	err := func() error {
+		job, err := jobs.GetQueue().GetJob(jobID)
+		if err != nil {
+			return err
+		}
+
+		var upload io.Reader
+		if uploadPath != "" {
+			defer os.Remove(uploadPath)
+			file, err := os.Open(uploadPath)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			upload = file
+		}
+
+		rendered, err := lib.RenderJobVideo(context.Background(), lib.GetArtifactStore(), job, upload, opts)
+		if err != nil {
+			return err
+		}
+		artifact = rendered
+		return nil
+	}()
+	if err != nil {
+		log.Printf("Failed to render video of job %s: %v", jobID, err)
+		artifact.MarkError(err)
+	}
+
+	if err := putRenderArtifact(jobID, artifact); err != nil {
+		log.Printf("Failed to save render of job %s: %v", jobID, err)
+	}
+}
+
+// putRenderArtifact adds a render's artifact to the stored job, replacing
+// an earlier one with the same key
+func putRenderArtifact(jobID string, artifact models.Artifact) error {
+	renderMu.Lock()
+	defer renderMu.Unlock()
+
+	job, err := jobs.GetQueue().GetJob(jobID)
+	if err != nil {
+		return err
+	}
+	job.PutArtifact(artifact)
+	return jobs.GetQueue().UpdateJob(job)
+}
+
+// saveUpload copies an uploaded video into the work directory
+func saveUpload(jobID string, header *multipart.FileHeader) (string, error) {
+	upload, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer upload.Close()
+
+	workDir := config.Load().WorkDir
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp(workDir, jobID+"_upload_*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, upload)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// renderErrorStatus answers a failed render with 422 when the job or its
+// video can not be rendered, and 500 for failures of the server
+func renderErrorStatus(code models.ErrorCode) int {
+	if code == models.ErrCodeInternal || code == "" {
+		return fiber.StatusInternalServerError
+	}
+	return fiber.StatusUnprocessableEntity
+}
+
+// IsVideoUpload reports whether the request is for PostRenderVideo, whose
+// route accepts uploads up to MAX_UPLOAD_MB instead of the default limit
+func IsVideoUpload(c *fiber.Ctx) bool {
+	path := strings.TrimSuffix(c.Path(), "/")
+	return c.Method() == fiber.MethodPost && strings.HasPrefix(path, "/transcribe/") && strings.HasSuffix(path, "/video")
+}
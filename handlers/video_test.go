@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/jobs"
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
+)
+
+func TestPostRenderVideo_Errors(t *testing.T) {
+	app := setupTestApp()
+
+	pending := jobs.NewJob("https://www.youtube.com/watch?v=9bZkp7q19f0")
+	require.NoError(t, jobs.GetQueue().AddJob(pending))
+
+	post := func(path string) int {
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, path, nil), -1)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, 404, post("/transcribe/missing/video"))
+
+	// Other API keys' jobs are not found either
+	other := jobs.NewJob("https://www.youtube.com/watch?v=9bZkp7q19f0")
+	other.APIKeyID = "other"
+	require.NoError(t, jobs.GetQueue().AddJob(other))
+	assert.Equal(t, 404, post("/transcribe/"+other.ID+"/video"))
+
+	previous := lib.GetArtifactStore()
+	lib.InitializeArtifacts(nil)
+	assert.Equal(t, 503, post("/transcribe/"+pending.ID+"/video"))
+
+	store, err := lib.NewLocalArtifactStore(t.TempDir(), lib.NewArtifactSigner("http://example.com", "secret"))
+	require.NoError(t, err)
+	lib.InitializeArtifacts(store)
+	t.Cleanup(func() { lib.InitializeArtifacts(previous) })
+
+	assert.Equal(t, 400, post("/transcribe/"+pending.ID+"/video?mode=overlay"))
+	assert.Equal(t, 400, post("/transcribe/"+pending.ID+"/video?language=en"))
+	assert.Equal(t, 409, post("/transcribe/"+pending.ID+"/video"))
+}
+
+func TestPostRenderVideo_QueuesOnPool(t *testing.T) {
+	app := setupTestApp()
+	t.Setenv("WORK_DIR", t.TempDir())
+
+	previous := lib.GetArtifactStore()
+	store, err := lib.NewLocalArtifactStore(t.TempDir(), lib.NewArtifactSigner("http://example.com", "secret"))
+	require.NoError(t, err)
+	lib.InitializeArtifacts(store)
+	t.Cleanup(func() { lib.InitializeArtifacts(previous) })
+
+	// A busy worker and a queue of one keep the render waiting
+	release := make(chan struct{})
+	jobs.InitializePool(1, 1, func(job *jobs.Job) { <-release })
+	require.NoError(t, jobs.GetPool().Submit(jobs.NewJob("https://www.youtube.com/watch?v=busy")))
+	require.Eventually(t, func() bool { return jobs.GetPool().Pending() == 0 }, time.Second, time.Millisecond)
+
+	job := jobs.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	require.NoError(t, job.MarkRunning())
+	require.NoError(t, job.MarkComplete("Hello", []models.Segment{{Start: 0, End: 1, Text: "Hello"}}))
+	require.NoError(t, jobs.GetQueue().AddJob(job))
+
+	upload := func() *http.Request {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("video", "clip.mp4")
+		require.NoError(t, err)
+		part.Write([]byte("not a video"))
+		require.NoError(t, form.Close())
+		req := httptest.NewRequest(http.MethodPost, "/transcribe/"+job.ID+"/video?mode=soft", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		return req
+	}
+	get := func(path string) (int, models.Artifact) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil), -1)
+		require.NoError(t, err)
+		var body struct {
+			Artifact models.Artifact `json:"artifact"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Artifact
+	}
+
+	resp, err := app.Test(upload(), -1)
+	require.NoError(t, err)
+	assert.Equal(t, 202, resp.StatusCode)
+	location := resp.Header.Get("Location")
+	assert.Equal(t, "/transcribe/"+job.ID+"/video/soft.mp4", location)
+
+	status, artifact := get(location)
+	assert.Equal(t, 202, status)
+	assert.Equal(t, models.ArtifactPending, artifact.Status)
+	assert.Equal(t, 404, func() int { status, _ := get("/transcribe/" + job.ID + "/video/burn.mp4"); return status }())
+
+	// The queue is full until the worker is free
+	resp, err = app.Test(upload(), -1)
+	require.NoError(t, err)
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	// The upload is not a video, so the render fails on the worker
+	close(release)
+	require.Eventually(t, func() bool {
+		status, _ := get(location)
+		return status != 202
+	}, 10*time.Second, 10*time.Millisecond)
+	status, artifact = get(location)
+	assert.Equal(t, 500, status)
+	assert.Equal(t, models.ArtifactError, artifact.Status)
+
+	stored, err := jobs.GetQueue().GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Len(t, stored.Artifacts, 1)
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"time"
+
+	"videotranscript-app/models"
+)
+
+// BatchJobs returns the child jobs of a batch in submission order.
+func BatchJobs(store JobStore, batchID string) ([]*Job, error) {
+	var children []*Job
+	query := JobQuery{BatchID: batchID, Ascending: true, Limit: models.MaxJobPageSize}
+	for {
+		page, err := store.QueryJobs(query)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, page.Jobs...)
+		if page.NextCursor == "" {
+			return children, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// BatchStatus aggregates the progress of a batch's child jobs.
+func BatchStatus(store JobStore, batchID string) (*models.BatchStatus, error) {
+	batch, err := store.GetBatch(batchID)
+	if err != nil {
+		return nil, err
+	}
+	children, err := BatchJobs(store, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := batch.Summarize(children)
+	return &status, nil
+}
+
+// CompleteBatch marks a batch complete once all of its jobs have finished.
+// It returns the final status only to the caller that completed the batch,
+// and nil while jobs are outstanding or if the batch was already complete.
+func CompleteBatch(store JobStore, batchID string) (*models.BatchStatus, error) {
+	status, err := BatchStatus(store, batchID)
+	if err != nil || !status.IsFinished() || status.CompletedAt != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	completed, err := store.CompleteBatch(batchID, now)
+	if err != nil || !completed {
+		return nil, err
+	}
+	status.CompletedAt = &now
+	return status, nil
+}
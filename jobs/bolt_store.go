@@ -0,0 +1,331 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	jobsBucket          = []byte("jobs")
+	batchesBucket       = []byte("batches")
+	subscriptionsBucket = []byte("subscriptions")
+	idempotencyBucket   = []byte("idempotency_keys")
+)
+
+// BoltStore keeps jobs in an embedded bbolt database, one record per job,
+// batch, subscription and idempotency key, so a single-node server survives
+// restarts without an external database. Every change writes only its own
+// record; reads are served from the in-memory copy loaded at startup.
+type BoltStore struct {
+	*MemoryStore
+	db *bolt.DB
+	// writeMu keeps the database and the in-memory copy applying changes
+	// in the same order
+	writeMu sync.Mutex
+}
+
+// legacyStoreData is the layout of the JSON file the store used before it
+// moved to bbolt. Older files hold a bare array of jobs.
+type legacyStoreData struct {
+	Jobs            []*Job            `json:"jobs"`
+	Batches         []*Batch          `json:"batches,omitempty"`
+	Subscriptions   []*Subscription   `json:"subscriptions,omitempty"`
+	IdempotencyKeys []*IdempotencyKey `json:"idempotency_keys,omitempty"`
+}
+
+// NewBoltStore opens or creates the database at path. A JSON job store
+// left at path by an earlier version is imported and kept as path.bak.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job store directory: %w", err)
+	}
+
+	legacy, err := readLegacyStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if legacy != nil {
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return nil, fmt.Errorf("failed to move aside JSON job store: %w", err)
+		}
+		log.Printf("Importing JSON job store %s, kept as %s.bak", path, path)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store: %w", err)
+	}
+	store := &BoltStore{MemoryStore: NewMemoryStore(), db: db}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{jobsBucket, batchesBucket, subscriptionsBucket, idempotencyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if legacy != nil {
+			return importLegacyStore(tx, legacy)
+		}
+		return nil
+	})
+	if err == nil {
+		err = store.load()
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare job store %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// readLegacyStore parses path if it holds a JSON job store, and returns
+// nil for a missing file or a bbolt database
+func readLegacyStore(path string) (*legacyStoreData, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job store: %w", err)
+	}
+
+	data = bytes.TrimSpace(data)
+	var contents legacyStoreData
+	switch {
+	case len(data) == 0:
+		return &contents, nil
+	case data[0] == '[':
+		err = json.Unmarshal(data, &contents.Jobs)
+	case data[0] == '{':
+		err = json.Unmarshal(data, &contents)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse job store %s: %w", path, err)
+	}
+	return &contents, nil
+}
+
+func importLegacyStore(tx *bolt.Tx, contents *legacyStoreData) error {
+	for _, job := range contents.Jobs {
+		if err := putRecord(tx, jobsBucket, job.ID, job); err != nil {
+			return err
+		}
+	}
+	for _, batch := range contents.Batches {
+		if err := putRecord(tx, batchesBucket, batch.ID, batch); err != nil {
+			return err
+		}
+	}
+	for _, sub := range contents.Subscriptions {
+		if err := putRecord(tx, subscriptionsBucket, sub.ID, sub); err != nil {
+			return err
+		}
+	}
+	for _, key := range contents.IdempotencyKeys {
+		if err := putRecord(tx, idempotencyBucket, idempotencyID(key.APIKeyID, key.Key), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads every record into the in-memory copy
+func (s *BoltStore) load() error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(jobsBucket).ForEach(func(_, value []byte) error {
+			var job Job
+			if err := json.Unmarshal(value, &job); err != nil {
+				return err
+			}
+			s.jobs[job.ID] = &job
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(batchesBucket).ForEach(func(_, value []byte) error {
+			var batch Batch
+			if err := json.Unmarshal(value, &batch); err != nil {
+				return err
+			}
+			s.batches[batch.ID] = &batch
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(subscriptionsBucket).ForEach(func(_, value []byte) error {
+			var sub Subscription
+			if err := json.Unmarshal(value, &sub); err != nil {
+				return err
+			}
+			s.subscriptions[sub.ID] = &sub
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(idempotencyBucket).ForEach(func(id, value []byte) error {
+			var key IdempotencyKey
+			if err := json.Unmarshal(value, &key); err != nil {
+				return err
+			}
+			s.idempotency[string(id)] = &key
+			return nil
+		})
+	})
+}
+
+// put writes one record in its own transaction
+func (s *BoltStore) put(bucket []byte, id string, value interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putRecord(tx, bucket, id, value)
+	})
+}
+
+func putRecord(tx *bolt.Tx, bucket []byte, id string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s record: %w", bucket, err)
+	}
+	return tx.Bucket(bucket).Put([]byte(id), data)
+}
+
+func (s *BoltStore) delete(bucket []byte, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(id))
+	})
+}
+
+func (s *BoltStore) AddJob(job *Job) error {
+	return s.UpdateJob(job)
+}
+
+func (s *BoltStore) UpdateJob(job *Job) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.put(jobsBucket, job.ID, job); err != nil {
+		return err
+	}
+	return s.MemoryStore.UpdateJob(job)
+}
+
+func (s *BoltStore) AddBatch(batch *Batch) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.put(batchesBucket, batch.ID, batch); err != nil {
+		return err
+	}
+	return s.MemoryStore.AddBatch(batch)
+}
+
+func (s *BoltStore) CompleteBatch(id string, at time.Time) (bool, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	completed, err := s.MemoryStore.CompleteBatch(id, at)
+	if err != nil || !completed {
+		return completed, err
+	}
+	batch, err := s.MemoryStore.GetBatch(id)
+	if err != nil {
+		return false, err
+	}
+	return true, s.put(batchesBucket, id, batch)
+}
+
+func (s *BoltStore) AddSubscription(sub *Subscription) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.put(subscriptionsBucket, sub.ID, sub); err != nil {
+		return err
+	}
+	return s.MemoryStore.AddSubscription(sub)
+}
+
+func (s *BoltStore) UpdateSubscription(sub *Subscription) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.MemoryStore.UpdateSubscription(sub); err != nil {
+		return err
+	}
+	return s.put(subscriptionsBucket, sub.ID, sub)
+}
+
+func (s *BoltStore) DeleteSubscription(id string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.MemoryStore.DeleteSubscription(id); err != nil {
+		return err
+	}
+	return s.delete(subscriptionsBucket, id)
+}
+
+func (s *BoltStore) ClaimIdempotencyKey(key *IdempotencyKey) (*IdempotencyKey, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	existing, err := s.MemoryStore.ClaimIdempotencyKey(key)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	// Drop the expired keys the memory store dropped as well
+	return nil, s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(idempotencyBucket)
+		var expired [][]byte
+		err := bucket.ForEach(func(id, value []byte) error {
+			var stored IdempotencyKey
+			if err := json.Unmarshal(value, &stored); err == nil && stored.Expired(key.CreatedAt) {
+				expired = append(expired, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range expired {
+			if err := bucket.Delete(id); err != nil {
+				return err
+			}
+		}
+		return putRecord(tx, idempotencyBucket, idempotencyID(key.APIKeyID, key.Key), key)
+	})
+}
+
+func (s *BoltStore) UpdateIdempotencyKey(key *IdempotencyKey) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.MemoryStore.UpdateIdempotencyKey(key); err != nil {
+		return err
+	}
+	return s.put(idempotencyBucket, idempotencyID(key.APIKeyID, key.Key), key)
+}
+
+func (s *BoltStore) DeleteIdempotencyKey(apiKeyID, key string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.MemoryStore.DeleteIdempotencyKey(apiKeyID, key)
+	return s.delete(idempotencyBucket, idempotencyID(apiKeyID, key))
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,42 @@
+package jobs
+
+// FindCachedJob returns the newest complete job for the video whose result
+// is stored under cacheKey, or nil if there is none.
+func FindCachedJob(store JobStore, videoID, cacheKey string) (*Job, error) {
+	if cacheKey == "" {
+		return nil, nil
+	}
+
+	query := JobQuery{VideoID: videoID, Status: StatusComplete, Limit: 100}
+	for {
+		page, err := store.QueryJobs(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range page.Jobs {
+			if job.CacheKey == cacheKey {
+				return job, nil
+			}
+		}
+		if page.NextCursor == "" {
+			return nil, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// FindInFlightJob returns the oldest pending or running job for the video,
+// or nil if there is none.
+func FindInFlightJob(store JobStore, videoID string) (*Job, error) {
+	var found *Job
+	for _, status := range []JobStatus{StatusRunning, StatusPending} {
+		page, err := store.QueryJobs(JobQuery{VideoID: videoID, Status: status, Ascending: true, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Jobs) > 0 && (found == nil || page.Jobs[0].CreatedAt.Before(found.CreatedAt)) {
+			found = page.Jobs[0]
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"sync"
+
+	"videotranscript-app/models"
+)
+
+type JobEvent = models.JobEvent
+
+// eventBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it
+const eventBuffer = 64
+
+// EventBus fans job events out to in-process subscribers. Publishing never
+// blocks the job runner: a subscriber whose buffer is full misses events,
+// except the final status event, which replaces the oldest buffered one.
+type EventBus struct {
+	mu   sync.Mutex
+	subs map[string]map[chan JobEvent]struct{}
+}
+
+var bus = NewEventBus()
+
+// GetEventBus returns the bus the job runner publishes to
+func GetEventBus() *EventBus {
+	return bus
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[string]map[chan JobEvent]struct{})}
+}
+
+// Subscribe returns a channel receiving the events of a job, and a function
+// that ends the subscription and closes the channel.
+func (b *EventBus) Subscribe(jobID string) (<-chan JobEvent, func()) {
+	ch := make(chan JobEvent, eventBuffer)
+
+	b.mu.Lock()
+	if b.subs[jobID] == nil {
+		b.subs[jobID] = make(map[chan JobEvent]struct{})
+	}
+	b.subs[jobID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs[jobID], ch)
+			if len(b.subs[jobID]) == 0 {
+				delete(b.subs, jobID)
+			}
+			close(ch)
+		})
+	}
+}
+
+func (b *EventBus) Publish(event JobEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[event.JobID] {
+		select {
+		case ch <- event:
+			continue
+		default:
+		}
+		if event.IsFinal() {
+			// Make room so the subscriber still learns the job finished
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- event
+		}
+	}
+}
+
+// Subscribers returns the number of open subscriptions to a job's events
+func (b *EventBus) Subscribers(jobID string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[jobID])
+}
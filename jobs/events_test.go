@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"videotranscript-app/models"
+)
+
+func TestEventBus_DeliversToJobSubscribers(t *testing.T) {
+	bus := NewEventBus()
+	job := NewJob("https://youtube.com/watch?v=test")
+	other := NewJob("https://youtube.com/watch?v=other")
+
+	events, unsubscribe := bus.Subscribe(job.ID)
+	bus.Publish(models.NewJobEvent(models.EventProgress, other))
+	bus.Publish(models.NewJobEvent(models.EventProgress, job))
+
+	event := <-events
+	assert.Equal(t, job.ID, event.JobID)
+	assert.Equal(t, models.EventProgress, event.Type)
+	assert.Empty(t, events)
+
+	unsubscribe()
+	unsubscribe()
+	_, open := <-events
+	assert.False(t, open)
+	assert.Equal(t, 0, bus.Subscribers(job.ID))
+}
+
+func TestEventBus_FinalEventSurvivesFullBuffer(t *testing.T) {
+	bus := NewEventBus()
+	job := NewJob("https://youtube.com/watch?v=test")
+	events, unsubscribe := bus.Subscribe(job.ID)
+	defer unsubscribe()
+
+	for i := 0; i < eventBuffer+10; i++ {
+		bus.Publish(models.NewJobEvent(models.EventProgress, job))
+	}
+	job.MarkError(assert.AnError)
+	bus.Publish(models.NewJobEvent(models.EventStatus, job))
+
+	var last JobEvent
+	for len(events) > 0 {
+		last = <-events
+	}
+	assert.True(t, last.IsFinal())
+}
@@ -0,0 +1,254 @@
+package jobs
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull   = errors.New("job queue is full")
+	ErrPoolStopped = errors.New("worker pool is stopped")
+)
+
+// defaultRuntime is assumed for start time estimates until a job finished
+const defaultRuntime = 2 * time.Minute
+
+type ProcessFunc func(job *Job)
+
+// Pool runs jobs on a fixed number of workers. Submitted jobs wait in a
+// pending queue shared fairly between API keys, and stay pending until a
+// worker picks them up. Other work done for a job, such as rendering its
+// video, waits in the same queue.
+type Pool struct {
+	workers    int
+	maxQueue   int
+	process    ProcessFunc
+	pending    *fairQueue
+	tasks      map[*Job]func()
+	running    map[*Job]time.Time
+	avgRuntime time.Duration
+	stopped    bool
+	mu         sync.Mutex
+	cond       *sync.Cond
+	wg         sync.WaitGroup
+}
+
+var pool *Pool
+
+func InitializePool(workers, maxQueue int, process ProcessFunc) {
+	if pool != nil {
+		pool.Stop()
+	}
+	pool = NewPool(workers, maxQueue, process)
+	pool.Start()
+}
+
+func GetPool() *Pool {
+	return pool
+}
+
+func NewPool(workers, maxQueue int, process ProcessFunc) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &Pool{
+		workers:  workers,
+		maxQueue: maxQueue,
+		process:  process,
+		pending:  newFairQueue(),
+		tasks:    make(map[*Job]func()),
+		running:  make(map[*Job]time.Time),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// SetWeights sets how many jobs each API key may start per scheduling turn.
+// API keys without a weight get 1.
+func (p *Pool) SetWeights(weights map[string]int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending.weights = weights
+}
+
+func (p *Pool) Start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// Stop signals the workers to exit and waits for in-flight jobs to finish.
+// Jobs still in the pending queue are not processed.
+func (p *Pool) Stop() {
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+	p.cond.Broadcast()
+	p.wg.Wait()
+}
+
+// Submit appends a job to the pending queue. A maxQueue of zero or less
+// means the queue is unbounded.
+func (p *Pool) Submit(job *Job) error {
+	return p.submit(job, false)
+}
+
+// Requeue appends a previously accepted job regardless of the queue limit.
+func (p *Pool) Requeue(job *Job) error {
+	return p.submit(job, true)
+}
+
+// RequeueAfter requeues a job once the delay has passed. If the pool has
+// stopped by then the job stays pending and is picked up by RecoverJobs.
+func (p *Pool) RequeueAfter(job *Job, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		p.Requeue(job)
+	})
+}
+
+// SubmitWork queues run to be called by a worker in place of processing the
+// job. It is scheduled with the job's API key and priority, and counts
+// against the queue limit like Submit.
+func (p *Pool) SubmitWork(job *Job, run func()) error {
+	// The queue holds its own copy, so the entry is told apart from the job
+	entry := job.Clone()
+	return p.enqueue(entry, false, func() { p.tasks[entry] = run })
+}
+
+func (p *Pool) submit(job *Job, force bool) error {
+	return p.enqueue(job, force, nil)
+}
+
+// enqueue pushes an entry, calling prepare with p.mu held once it was
+// accepted
+func (p *Pool) enqueue(job *Job, force bool, prepare func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return ErrPoolStopped
+	}
+	if !force && p.maxQueue > 0 && p.pending.len() >= p.maxQueue {
+		return ErrQueueFull
+	}
+
+	if prepare != nil {
+		prepare()
+	}
+	p.pending.push(job)
+	p.cond.Signal()
+	return nil
+}
+
+// Position returns the 1-based position of a job in the pending queue,
+// or 0 if the job is not waiting.
+func (p *Pool) Position(jobID string) int {
+	position, _ := p.Estimate(jobID)
+	return position
+}
+
+// Estimate returns the position of a pending job like Position, and when a
+// worker is expected to start it based on the average job runtime. The
+// start time is zero if the job is not waiting.
+func (p *Pool) Estimate(jobID string) (int, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	runtime := p.avgRuntime
+	if runtime <= 0 {
+		runtime = defaultRuntime
+	}
+
+	// When each worker becomes free, earliest first
+	free := make([]time.Time, 0, p.workers)
+	for _, started := range p.running {
+		done := started.Add(runtime)
+		if done.Before(now) {
+			done = now
+		}
+		free = append(free, done)
+	}
+	for len(free) < p.workers {
+		free = append(free, now)
+	}
+	sort.Slice(free, func(i, j int) bool { return free[i].Before(free[j]) })
+
+	for i, job := range p.pending.order() {
+		startAt := free[0]
+		if _, isWork := p.tasks[job]; job.ID == jobID && !isWork {
+			return i + 1, startAt
+		}
+		free[0] = startAt.Add(runtime)
+		sort.Slice(free, func(i, j int) bool { return free[i].Before(free[j]) })
+	}
+	return 0, time.Time{}
+}
+
+// Available returns how many more jobs Submit accepts right now, or -1 if
+// the queue is unbounded.
+func (p *Pool) Available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.maxQueue <= 0 {
+		return -1
+	}
+	if free := p.maxQueue - p.pending.len(); free > 0 {
+		return free
+	}
+	return 0
+}
+
+func (p *Pool) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pending.len()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		for p.pending.len() == 0 && !p.stopped {
+			p.cond.Wait()
+		}
+		if p.stopped {
+			p.mu.Unlock()
+			return
+		}
+
+		job := p.pending.pop()
+		run, isWork := p.tasks[job]
+		delete(p.tasks, job)
+		started := time.Now()
+		p.running[job] = started
+		p.mu.Unlock()
+
+		if isWork {
+			run()
+		} else {
+			p.process(job)
+		}
+
+		p.mu.Lock()
+		delete(p.running, job)
+		p.recordRuntime(time.Since(started))
+		p.mu.Unlock()
+	}
+}
+
+// recordRuntime folds a finished job's runtime into the moving average used
+// for start time estimates. The caller holds p.mu.
+func (p *Pool) recordRuntime(runtime time.Duration) {
+	if p.avgRuntime <= 0 {
+		p.avgRuntime = runtime
+		return
+	}
+	p.avgRuntime = (4*p.avgRuntime + runtime) / 5
+}
@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_BoundedConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	release := make(chan struct{})
+	var done sync.WaitGroup
+
+	p := NewPool(2, 0, func(job *Job) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		done.Done()
+	})
+	p.Start()
+	defer p.Stop()
+
+	for i := 0; i < 5; i++ {
+		done.Add(1)
+		require.NoError(t, p.Submit(NewJob("https://youtube.com/watch?v=test")))
+	}
+
+	require.Eventually(t, func() bool { return p.Pending() == 3 }, time.Second, 5*time.Millisecond)
+	close(release)
+	done.Wait()
+
+	assert.Equal(t, 2, maxRunning)
+}
+
+func TestPool_QueuePositionAndBackPressure(t *testing.T) {
+	p := NewPool(1, 2, func(job *Job) {})
+
+	first := NewJob("https://youtube.com/watch?v=first")
+	second := NewJob("https://youtube.com/watch?v=second")
+	require.NoError(t, p.Submit(first))
+	require.NoError(t, p.Submit(second))
+
+	assert.Equal(t, 1, p.Position(first.ID))
+	assert.Equal(t, 2, p.Position(second.ID))
+	assert.Equal(t, 0, p.Position("unknown"))
+
+	assert.ErrorIs(t, p.Submit(NewJob("https://youtube.com/watch?v=third")), ErrQueueFull)
+
+	p.Stop()
+	assert.ErrorIs(t, p.Submit(NewJob("https://youtube.com/watch?v=fourth")), ErrPoolStopped)
+}
+
+func TestPool_SubmitWork(t *testing.T) {
+	processed := make(chan string, 1)
+	p := NewPool(1, 2, func(job *Job) { processed <- job.ID })
+
+	job := NewJob("https://youtube.com/watch?v=test")
+	ran := make(chan struct{})
+	require.NoError(t, p.SubmitWork(job, func() { close(ran) }))
+	require.NoError(t, p.Submit(job))
+
+	// Work shares the queue limit, but is not the job's queue position
+	assert.ErrorIs(t, p.SubmitWork(job, func() {}), ErrQueueFull)
+	assert.Equal(t, 2, p.Position(job.ID))
+
+	p.Start()
+	defer p.Stop()
+	<-ran
+	assert.Equal(t, job.ID, <-processed)
+}
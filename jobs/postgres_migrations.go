@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"videotranscript-app/transcribe/migrations"
+)
+
+// migrationLockID names the advisory lock Fiber servers hold while they
+// migrate, so servers starting together apply each migration once
+const migrationLockID = 724612027
+
+// postgresMigration is one of the Encore service's up migrations
+type postgresMigration struct {
+	version int
+	file    string
+}
+
+// postgresMigrations lists the migration files in fsys in version order
+func postgresMigrations(fsys fs.FS) ([]postgresMigration, error) {
+	files, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]postgresMigration, 0, len(files))
+	for _, file := range files {
+		prefix, _, ok := strings.Cut(file, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s is not named <version>_<description>.up.sql", file)
+		}
+		list = append(list, postgresMigration{version: version, file: file})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+	for i := 1; i < len(list); i++ {
+		if list[i].version == list[i-1].version {
+			return nil, fmt.Errorf("migrations %s and %s share a version", list[i-1].file, list[i].file)
+		}
+	}
+	return list, nil
+}
+
+// migratePostgres applies the Encore service's migrations the database has
+// not had yet. Versions are recorded the way Encore records them, as the
+// single row of golang-migrate's schema_migrations table, so the Fiber
+// server and the Encore service can migrate a shared database in turn.
+// Each migration runs in its own transaction.
+func migratePostgres(db *sql.DB) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`); err != nil {
+		return err
+	}
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	list, err := postgresMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	for _, migration := range list {
+		if migration.version <= current {
+			continue
+		}
+		script, err := fs.ReadFile(migrations.FS, migration.file)
+		if err != nil {
+			return err
+		}
+		if err := applyMigration(ctx, conn, migration.version, string(script)); err != nil {
+			return fmt.Errorf("migration %s: %w", migration.file, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs a migration script and records its version
+func applyMigration(ctx context.Context, conn *sql.Conn, version int, script string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// schemaVersion returns the last migration applied to the database. Fiber
+// servers used to create their tables without recording a version; the
+// later migrations apply cleanly to those tables, so such a database counts
+// as having had the first one.
+func schemaVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var version int
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == nil && dirty {
+		return 0, fmt.Errorf("migration %d did not finish; repair the schema and its schema_migrations row", version)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return version, err
+	}
+
+	var legacy bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('jobs') IS NOT NULL`).Scan(&legacy); err != nil {
+		return 0, err
+	}
+	if legacy {
+		return 1, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"videotranscript-app/models"
+)
+
+// PostgresStore keeps jobs in the same `jobs` table the Encore service
+// uses, so self-hosted Fiber deployments can share its schema.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres job store: %w", err)
+	}
+
+	if err := migratePostgres(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate postgres job store: %w", err)
+	}
+
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) AddJob(job *Job) error {
+	values, err := models.JobValues(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(models.InsertJobSQL, values...)
+	return err
+}
+
+func (s *PostgresStore) GetJob(id string) (*Job, error) {
+	query := `SELECT ` + models.JobColumns + ` FROM jobs WHERE id = $1`
+
+	job, err := models.ScanJob(s.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	return job, err
+}
+
+func (s *PostgresStore) UpdateJob(job *Job) error {
+	values, err := models.JobValues(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(models.UpdateJobSQL, values...)
+	return err
+}
+
+func (s *PostgresStore) ListJobs() ([]*Job, error) {
+	return s.queryJobs(`SELECT ` + models.JobColumns + ` FROM jobs ORDER BY created_at`)
+}
+
+func (s *PostgresStore) QueryJobs(query JobQuery) (*JobPage, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, err
+	}
+	clause, args, err := query.SQL()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := s.queryJobs(`SELECT `+models.JobColumns+` FROM jobs `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return query.Paginate(jobs), nil
+}
+
+func (s *PostgresStore) queryJobs(query string, args ...any) ([]*Job, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job, err := models.ScanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *PostgresStore) AddBatch(batch *Batch) error {
+	_, err := s.db.Exec(models.InsertBatchSQL, models.BatchValues(batch)...)
+	return err
+}
+
+func (s *PostgresStore) GetBatch(id string) (*Batch, error) {
+	query := `SELECT ` + models.BatchColumns + ` FROM batches WHERE id = $1`
+
+	batch, err := models.ScanBatch(s.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBatchNotFound
+	}
+	return batch, err
+}
+
+// CompleteBatch only updates batches that are not complete yet, so of
+// several servers finishing the last jobs at once exactly one wins.
+func (s *PostgresStore) CompleteBatch(id string, at time.Time) (bool, error) {
+	result, err := s.db.Exec(`UPDATE batches SET completed_at = $2 WHERE id = $1 AND completed_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+func (s *PostgresStore) AddSubscription(sub *Subscription) error {
+	values, err := models.SubscriptionValues(sub)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(models.InsertSubscriptionSQL, values...)
+	return err
+}
+
+func (s *PostgresStore) GetSubscription(id string) (*Subscription, error) {
+	query := `SELECT ` + models.SubscriptionColumns + ` FROM subscriptions WHERE id = $1`
+
+	sub, err := models.ScanSubscription(s.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	return sub, err
+}
+
+func (s *PostgresStore) ListSubscriptions() ([]*Subscription, error) {
+	rows, err := s.db.Query(`SELECT ` + models.SubscriptionColumns + ` FROM subscriptions ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*Subscription
+	for rows.Next() {
+		sub, err := models.ScanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *PostgresStore) UpdateSubscription(sub *Subscription) error {
+	values, err := models.SubscriptionValues(sub)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(models.UpdateSubscriptionSQL, values...)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteSubscription(id string) error {
+	result, err := s.db.Exec(`DELETE FROM subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// ClaimIdempotencyKey relies on the primary key so that of concurrent
+// requests with the same key exactly one inserts it.
+func (s *PostgresStore) ClaimIdempotencyKey(key *IdempotencyKey) (*IdempotencyKey, error) {
+	if _, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= $1`, key.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(models.ClaimIdempotencyKeySQL, models.IdempotencyKeyValues(key)...)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 1 {
+		return nil, err
+	}
+
+	query := `SELECT ` + models.IdempotencyKeyColumns + ` FROM idempotency_keys WHERE api_key_id = $1 AND key = $2`
+	existing, err := models.ScanIdempotencyKey(s.db.QueryRow(query, key.APIKeyID, key.Key))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted since the insert conflicted; let the caller retry
+		return nil, ErrIdempotencyKeyNotFound
+	}
+	return existing, err
+}
+
+func (s *PostgresStore) UpdateIdempotencyKey(key *IdempotencyKey) error {
+	result, err := s.db.Exec(`
+		UPDATE idempotency_keys SET job_id = $3, batch_id = $4, status_code = $5
+		WHERE api_key_id = $1 AND key = $2
+	`, key.APIKeyID, key.Key, key.JobID, key.BatchID, key.StatusCode)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrIdempotencyKeyNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteIdempotencyKey(apiKeyID, key string) error {
+	_, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE api_key_id = $1 AND key = $2`, apiKeyID, key)
+	return err
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
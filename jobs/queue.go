@@ -1,57 +1,304 @@
 package jobs
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 )
 
-type Queue struct {
-	jobs map[string]*Job
-	mu   sync.RWMutex
+var (
+	ErrJobNotFound   = errors.New("job not found")
+	ErrBatchNotFound = errors.New("batch not found")
+
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+)
+
+// JobStore persists jobs for the Fiber job runner.
+type JobStore interface {
+	AddJob(job *Job) error
+	GetJob(id string) (*Job, error)
+	UpdateJob(job *Job) error
+	ListJobs() ([]*Job, error)
+	QueryJobs(query JobQuery) (*JobPage, error)
+
+	AddBatch(batch *Batch) error
+	GetBatch(id string) (*Batch, error)
+	// CompleteBatch marks a batch complete and reports whether this call
+	// did so, so batch completion is only acted on once.
+	CompleteBatch(id string, at time.Time) (bool, error)
+
+	AddSubscription(sub *Subscription) error
+	GetSubscription(id string) (*Subscription, error)
+	ListSubscriptions() ([]*Subscription, error)
+	UpdateSubscription(sub *Subscription) error
+	DeleteSubscription(id string) error
+
+	// ClaimIdempotencyKey stores key unless an unexpired key with the same
+	// API key and value exists, in which case that one is returned.
+	ClaimIdempotencyKey(key *IdempotencyKey) (*IdempotencyKey, error)
+	UpdateIdempotencyKey(key *IdempotencyKey) error
+	DeleteIdempotencyKey(apiKeyID, key string) error
+
+	Close() error
+}
+
+// MemoryStore keeps jobs in maps. Jobs, batches and subscriptions are
+// copied on the way in and out, as with the database stores, so callers
+// never share them with the store or with each other.
+type MemoryStore struct {
+	jobs          map[string]*Job
+	batches       map[string]*Batch
+	subscriptions map[string]*Subscription
+	idempotency   map[string]*IdempotencyKey
+	mu            sync.RWMutex
 }
 
-var instance *Queue
+var instance JobStore
 
 func Initialize() {
-	instance = &Queue{
-		jobs: make(map[string]*Job),
-	}
+	InitializeWithStore(NewMemoryStore())
+}
+
+func InitializeWithStore(store JobStore) {
+	instance = store
 }
 
-func GetQueue() *Queue {
+func GetQueue() JobStore {
 	return instance
 }
 
-func (q *Queue) AddJob(job *Job) {
-	q.mu.Lock()
-	defer q.mu.Unlock()
-	q.jobs[job.ID] = job
+// OpenStore creates a job store by driver name: "memory", "file" (dsn is
+// the path of the embedded bbolt database) or "postgres" (dsn is the
+// connection URL).
+func OpenStore(driver, dsn string) (JobStore, error) {
+	switch driver {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "file":
+		if dsn == "" {
+			dsn = "data/jobs.db"
+		}
+		return NewBoltStore(dsn)
+	case "postgres":
+		if dsn == "" {
+			return nil, fmt.Errorf("postgres job store requires a connection URL")
+		}
+		return NewPostgresStore(dsn)
+	default:
+		return nil, fmt.Errorf("unknown job store %q", driver)
+	}
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		jobs:          make(map[string]*Job),
+		batches:       make(map[string]*Batch),
+		subscriptions: make(map[string]*Subscription),
+		idempotency:   make(map[string]*IdempotencyKey),
+	}
+}
+
+func (s *MemoryStore) AddJob(job *Job) error {
+	job = job.Clone()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = job
+	return nil
 }
 
-func (q *Queue) GetJob(id string) (*Job, error) {
-	q.mu.RLock()
-	defer q.mu.RUnlock()
+func (s *MemoryStore) GetJob(id string) (*Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	job, exists := q.jobs[id]
+	job, exists := s.jobs[id]
 	if !exists {
-		return nil, fmt.Errorf("job not found")
+		return nil, ErrJobNotFound
 	}
-	return job, nil
+	return job.Clone(), nil
 }
 
-func (q *Queue) UpdateJob(job *Job) {
-	q.mu.Lock()
-	defer q.mu.Unlock()
-	q.jobs[job.ID] = job
+func (s *MemoryStore) UpdateJob(job *Job) error {
+	job = job.Clone()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = job
+	return nil
 }
 
-func (q *Queue) ListJobs() []*Job {
-	q.mu.RLock()
-	defer q.mu.RUnlock()
+func (s *MemoryStore) ListJobs() ([]*Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	jobs := make([]*Job, 0, len(q.jobs))
-	for _, job := range q.jobs {
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job.Clone())
+	}
+	sortByCreatedAt(jobs)
+	return jobs, nil
+}
+
+// QueryJobs filters the stored jobs in place and only copies the page
+func (s *MemoryStore) QueryJobs(query JobQuery) (*JobPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
 		jobs = append(jobs, job)
 	}
-	return jobs
+	sortByCreatedAt(jobs)
+	page, err := query.Apply(jobs)
+	if err != nil {
+		return nil, err
+	}
+	for i, job := range page.Jobs {
+		page.Jobs[i] = job.Clone()
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) AddBatch(batch *Batch) error {
+	batch = batch.Clone()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[batch.ID] = batch
+	return nil
+}
+
+func (s *MemoryStore) GetBatch(id string) (*Batch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	batch, exists := s.batches[id]
+	if !exists {
+		return nil, ErrBatchNotFound
+	}
+	return batch.Clone(), nil
+}
+
+func (s *MemoryStore) CompleteBatch(id string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch, exists := s.batches[id]
+	if !exists {
+		return false, ErrBatchNotFound
+	}
+	if batch.CompletedAt != nil {
+		return false, nil
+	}
+	batch.CompletedAt = &at
+	return true, nil
+}
+
+func (s *MemoryStore) AddSubscription(sub *Subscription) error {
+	sub = sub.Clone()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[sub.ID] = sub
+	return nil
+}
+
+func (s *MemoryStore) GetSubscription(id string) (*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, exists := s.subscriptions[id]
+	if !exists {
+		return nil, ErrSubscriptionNotFound
+	}
+	return sub.Clone(), nil
+}
+
+func (s *MemoryStore) ListSubscriptions() ([]*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := make([]*Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		subs = append(subs, sub.Clone())
+	}
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs, nil
+}
+
+func (s *MemoryStore) UpdateSubscription(sub *Subscription) error {
+	sub = sub.Clone()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.subscriptions[sub.ID]; !exists {
+		return ErrSubscriptionNotFound
+	}
+	s.subscriptions[sub.ID] = sub
+	return nil
+}
+
+func (s *MemoryStore) DeleteSubscription(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.subscriptions[id]; !exists {
+		return ErrSubscriptionNotFound
+	}
+	delete(s.subscriptions, id)
+	return nil
+}
+
+func (s *MemoryStore) ClaimIdempotencyKey(key *IdempotencyKey) (*IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired keys so the map does not grow without bound
+	for id, existing := range s.idempotency {
+		if existing.Expired(key.CreatedAt) {
+			delete(s.idempotency, id)
+		}
+	}
+
+	id := idempotencyID(key.APIKeyID, key.Key)
+	if existing, exists := s.idempotency[id]; exists {
+		return existing, nil
+	}
+	s.idempotency[id] = key
+	return nil, nil
+}
+
+func (s *MemoryStore) UpdateIdempotencyKey(key *IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := idempotencyID(key.APIKeyID, key.Key)
+	if _, exists := s.idempotency[id]; !exists {
+		return ErrIdempotencyKeyNotFound
+	}
+	s.idempotency[id] = key
+	return nil
+}
+
+func (s *MemoryStore) DeleteIdempotencyKey(apiKeyID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.idempotency, idempotencyID(apiKeyID, key))
+	return nil
+}
+
+func idempotencyID(apiKeyID, key string) string {
+	return apiKeyID + "\x00" + key
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func sortByCreatedAt(jobs []*Job) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
 }
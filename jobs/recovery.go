@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInterrupted fails artifacts whose queued work was lost in a restart
+var ErrInterrupted = errors.New("interrupted by a server restart")
+
+// RecoverJobs re-queues jobs that were pending or running when the server
+// last stopped. Running jobs are reset to pending because their pipeline
+// did not survive the restart, and jobs waiting for a retry keep their
+// backoff. Artifacts still pending on complete jobs, such as queued video
+// renders, are marked failed. It returns the number of recovered jobs.
+func RecoverJobs(store JobStore, pool *Pool) (int, error) {
+	jobs, err := store.ListJobs()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	recovered := 0
+	for _, job := range jobs {
+		if job.Status == StatusComplete && job.FailPendingArtifacts(ErrInterrupted) {
+			if err := store.UpdateJob(job); err != nil {
+				return recovered, fmt.Errorf("failed to reset job %s: %w", job.ID, err)
+			}
+		}
+		if job.Status != StatusPending && job.Status != StatusRunning {
+			continue
+		}
+
+		if job.Status == StatusRunning {
+			if err := job.MarkPending(); err != nil {
+				return recovered, err
+			}
+			if err := store.UpdateJob(job); err != nil {
+				return recovered, fmt.Errorf("failed to reset job %s: %w", job.ID, err)
+			}
+		}
+
+		if job.RetryAt != nil && job.RetryAt.After(time.Now()) {
+			pool.RequeueAfter(job, time.Until(*job.RetryAt))
+		} else if err := pool.Requeue(job); err != nil {
+			return recovered, fmt.Errorf("failed to requeue job %s: %w", job.ID, err)
+		}
+		recovered++
+	}
+
+	return recovered, nil
+}
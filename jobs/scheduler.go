@@ -0,0 +1,124 @@
+package jobs
+
+import "videotranscript-app/models"
+
+// fairQueue orders pending jobs by weighted round-robin across API keys.
+// Each turn an API key may start as many jobs as its weight before the next
+// key is served. Within a key, higher priority lanes go first and each lane
+// is FIFO.
+type fairQueue struct {
+	tenants []*tenantQueue
+	byKey   map[string]*tenantQueue
+	weights map[string]int
+	next    int
+	size    int
+}
+
+type tenantQueue struct {
+	key    string
+	lanes  [models.PriorityLanes][]*Job
+	credit int
+}
+
+func newFairQueue() *fairQueue {
+	return &fairQueue{byKey: make(map[string]*tenantQueue)}
+}
+
+func (q *fairQueue) len() int {
+	return q.size
+}
+
+// weight returns the number of jobs an API key may start per turn
+func (q *fairQueue) weight(key string) int {
+	if w := q.weights[key]; w > 0 {
+		return w
+	}
+	return 1
+}
+
+// push appends a job to its API key's priority lane. An API key without
+// pending jobs joins the end of the rotation.
+func (q *fairQueue) push(job *Job) {
+	t := q.byKey[job.APIKeyID]
+	if t == nil {
+		t = &tenantQueue{key: job.APIKeyID}
+		q.byKey[job.APIKeyID] = t
+		q.tenants = append(q.tenants, t)
+	}
+	lane := job.Priority.Lane()
+	t.lanes[lane] = append(t.lanes[lane], job)
+	q.size++
+}
+
+// pop removes the next job to run, or returns nil if the queue is empty
+func (q *fairQueue) pop() *Job {
+	if q.size == 0 {
+		return nil
+	}
+	if q.next >= len(q.tenants) {
+		q.next = 0
+	}
+
+	t := q.tenants[q.next]
+	if t.credit <= 0 {
+		t.credit = q.weight(t.key)
+	}
+	job := t.take()
+	t.credit--
+	q.size--
+
+	if t.empty() {
+		// The following API key moves into the current slot
+		q.tenants = append(q.tenants[:q.next], q.tenants[q.next+1:]...)
+		delete(q.byKey, t.key)
+	} else if t.credit == 0 {
+		q.next++
+	}
+	return job
+}
+
+// order returns the pending jobs in the order they would be started
+func (q *fairQueue) order() []*Job {
+	clone := &fairQueue{
+		tenants: make([]*tenantQueue, len(q.tenants)),
+		byKey:   make(map[string]*tenantQueue, len(q.tenants)),
+		weights: q.weights,
+		next:    q.next,
+		size:    q.size,
+	}
+	for i, t := range q.tenants {
+		c := &tenantQueue{key: t.key, credit: t.credit}
+		for lane, jobs := range t.lanes {
+			c.lanes[lane] = append([]*Job(nil), jobs...)
+		}
+		clone.tenants[i] = c
+		clone.byKey[c.key] = c
+	}
+
+	ordered := make([]*Job, 0, q.size)
+	for job := clone.pop(); job != nil; job = clone.pop() {
+		ordered = append(ordered, job)
+	}
+	return ordered
+}
+
+func (t *tenantQueue) take() *Job {
+	for lane, jobs := range t.lanes {
+		if len(jobs) > 0 {
+			job := jobs[0]
+			jobs[0] = nil
+			t.lanes[lane] = jobs[1:]
+			return job
+		}
+	}
+	return nil
+}
+
+func (t *tenantQueue) empty() bool {
+	for _, jobs := range t.lanes {
+		if len(jobs) > 0 {
+			return false
+		}
+	}
+	return true
+}
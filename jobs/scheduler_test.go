@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+func tenantJob(key string, priority models.JobPriority, id string) *Job {
+	job := NewJob("https://youtube.com/watch?v=" + id)
+	job.ID = id
+	job.APIKeyID = key
+	job.Priority = priority
+	return job
+}
+
+func jobIDs(jobs []*Job) []string {
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
+
+func TestFairQueue_RoundRobinAcrossKeys(t *testing.T) {
+	q := newFairQueue()
+	for _, id := range []string{"a1", "a2", "a3", "a4"} {
+		q.push(tenantJob("key_a", models.PriorityNormal, id))
+	}
+	q.push(tenantJob("key_b", models.PriorityNormal, "b1"))
+	q.push(tenantJob("key_c", models.PriorityNormal, "c1"))
+
+	assert.Equal(t, []string{"a1", "b1", "c1", "a2", "a3", "a4"}, jobIDs(q.order()))
+	assert.Equal(t, 6, q.len())
+}
+
+func TestFairQueue_PriorityLanesWithinKey(t *testing.T) {
+	q := newFairQueue()
+	q.push(tenantJob("key_a", models.PriorityLow, "a-low"))
+	q.push(tenantJob("key_a", models.PriorityNormal, "a-normal"))
+	q.push(tenantJob("key_b", models.PriorityNormal, "b-normal"))
+	q.push(tenantJob("key_a", models.PriorityHigh, "a-high"))
+	q.push(tenantJob("key_b", models.PriorityHigh, "b-high"))
+
+	assert.Equal(t, []string{"a-high", "b-high", "a-normal", "b-normal", "a-low"}, jobIDs(q.order()))
+}
+
+func TestFairQueue_Weights(t *testing.T) {
+	q := newFairQueue()
+	q.weights = map[string]int{"key_a": 3}
+	for _, id := range []string{"a1", "a2", "a3", "a4"} {
+		q.push(tenantJob("key_a", models.PriorityNormal, id))
+	}
+	q.push(tenantJob("key_b", models.PriorityNormal, "b1"))
+	q.push(tenantJob("key_b", models.PriorityNormal, "b2"))
+
+	var popped []*Job
+	for job := q.pop(); job != nil; job = q.pop() {
+		popped = append(popped, job)
+	}
+	assert.Equal(t, []string{"a1", "a2", "a3", "b1", "a4", "b2"}, jobIDs(popped))
+	assert.Equal(t, 0, q.len())
+}
+
+func TestFairQueue_KeyRejoinsAtEnd(t *testing.T) {
+	q := newFairQueue()
+	q.push(tenantJob("key_a", models.PriorityNormal, "a1"))
+	q.push(tenantJob("key_b", models.PriorityNormal, "b1"))
+	q.push(tenantJob("key_b", models.PriorityNormal, "b2"))
+
+	require.Equal(t, "a1", q.pop().ID)
+	q.push(tenantJob("key_a", models.PriorityNormal, "a2"))
+
+	assert.Equal(t, []string{"b1", "a2", "b2"}, jobIDs(q.order()))
+}
+
+func TestPool_EstimateStartTime(t *testing.T) {
+	p := NewPool(1, 0, func(job *Job) {})
+	p.avgRuntime = time.Minute
+
+	first := tenantJob("key_a", models.PriorityNormal, "first")
+	second := tenantJob("key_a", models.PriorityNormal, "second")
+	urgent := tenantJob("key_b", models.PriorityHigh, "urgent")
+	require.NoError(t, p.Submit(first))
+	require.NoError(t, p.Submit(second))
+	require.NoError(t, p.Submit(urgent))
+
+	before := time.Now()
+	position, startAt := p.Estimate(urgent.ID)
+	assert.Equal(t, 2, position)
+	assert.WithinDuration(t, before.Add(time.Minute), startAt, time.Second)
+
+	position, startAt = p.Estimate(second.ID)
+	assert.Equal(t, 3, position)
+	assert.WithinDuration(t, before.Add(2*time.Minute), startAt, time.Second)
+
+	position, startAt = p.Estimate("unknown")
+	assert.Equal(t, 0, position)
+	assert.True(t, startAt.IsZero())
+}
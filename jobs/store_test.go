@@ -0,0 +1,305 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+	"videotranscript-app/transcribe/migrations"
+)
+
+func TestBoltStore_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+
+	store, err := NewBoltStore(path)
+	require.NoError(t, err)
+
+	job := NewJob("https://youtube.com/watch?v=test")
+	require.NoError(t, store.AddJob(job))
+
+	require.NoError(t, job.MarkRunning())
+	require.NoError(t, job.MarkComplete("Test transcript", []Segment{{Start: 0, End: 5, Text: "Test segment"}}))
+	require.NoError(t, store.UpdateJob(job))
+	require.NoError(t, store.Close())
+
+	reopened, err := NewBoltStore(path)
+	require.NoError(t, err)
+
+	retrieved, err := reopened.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusComplete, retrieved.Status)
+	assert.Equal(t, "Test transcript", retrieved.Transcript)
+	assert.Len(t, retrieved.Segments, 1)
+
+	_, err = reopened.GetJob("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestBoltStore_ImportsJSONStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	job := NewJob("https://youtube.com/watch?v=legacy")
+	data, err := json.Marshal(map[string]interface{}{"jobs": []*Job{job}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	store, err := NewBoltStore(path)
+	require.NoError(t, err)
+	retrieved, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, job.URL, retrieved.URL)
+	require.NoError(t, store.Close())
+	assert.FileExists(t, path+".bak")
+
+	// The database is reopened rather than imported again
+	reopened, err := NewBoltStore(path)
+	require.NoError(t, err)
+	_, err = reopened.GetJob(job.ID)
+	assert.NoError(t, err)
+	require.NoError(t, reopened.Close())
+}
+
+func TestRecoverJobs_RequeuesInterruptedJobs(t *testing.T) {
+	store := NewMemoryStore()
+
+	running := NewJob("https://youtube.com/watch?v=running")
+	require.NoError(t, running.MarkRunning())
+	pending := NewJob("https://youtube.com/watch?v=pending")
+	pending.CreatedAt = running.CreatedAt.Add(time.Second)
+	done := NewJob("https://youtube.com/watch?v=done")
+	require.NoError(t, done.MarkRunning())
+	require.NoError(t, done.MarkComplete("done", nil))
+
+	for _, job := range []*Job{running, pending, done} {
+		require.NoError(t, store.AddJob(job))
+	}
+
+	pool := NewPool(1, 1, func(job *Job) {})
+	recovered, err := RecoverJobs(store, pool)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, recovered)
+	reset, err := store.GetJob(running.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, reset.Status)
+	assert.Equal(t, 1, pool.Position(running.ID))
+	assert.Equal(t, 2, pool.Position(pending.ID))
+	assert.Equal(t, 0, pool.Position(done.ID))
+}
+
+func TestFindCachedAndInFlightJobs(t *testing.T) {
+	store := NewMemoryStore()
+	url := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	key := "youtube:dQw4w9WgXcQ|demo||en"
+
+	cached, err := FindCachedJob(store, "dQw4w9WgXcQ", key)
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+
+	done := NewJob(url)
+	require.NoError(t, done.MarkRunning())
+	done.CacheKey = key
+	done.Chapters = []models.Chapter{{Start: 0, End: 212, Title: "Intro"}}
+	require.NoError(t, done.MarkComplete("cached transcript", nil))
+	otherEngine := NewJob(url)
+	require.NoError(t, otherEngine.MarkRunning())
+	otherEngine.CacheKey = "youtube:dQw4w9WgXcQ|whisper.cpp|ggml-base.en.bin|en"
+	require.NoError(t, otherEngine.MarkComplete("other transcript", nil))
+	inFlight := NewJob(url)
+	for _, job := range []*Job{done, otherEngine, inFlight} {
+		require.NoError(t, store.AddJob(job))
+	}
+
+	cached, err = FindCachedJob(store, "dQw4w9WgXcQ", key)
+	require.NoError(t, err)
+	require.NotNil(t, cached)
+	assert.Equal(t, done.ID, cached.ID)
+
+	existing, err := FindInFlightJob(store, "dQw4w9WgXcQ")
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, inFlight.ID, existing.ID)
+
+	reused := NewJob(url)
+	require.NoError(t, reused.CompleteFromCache(cached))
+	assert.Equal(t, StatusComplete, reused.Status)
+	assert.Equal(t, "cached transcript", reused.Transcript)
+	assert.Equal(t, done.Chapters, reused.Chapters)
+	assert.Equal(t, done.ID, reused.CachedFrom)
+	assert.Equal(t, key, reused.CacheKey)
+}
+
+func TestCompleteBatch_OnlyOnceAllJobsFinish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	store, err := NewBoltStore(path)
+	require.NoError(t, err)
+
+	batch := models.NewBatch(2, false)
+	require.NoError(t, store.AddBatch(batch))
+
+	first := NewJob("https://youtube.com/watch?v=first")
+	second := NewJob("https://youtube.com/watch?v=second")
+	for _, job := range []*Job{first, second} {
+		job.BatchID = batch.ID
+		require.NoError(t, store.AddJob(job))
+	}
+
+	require.NoError(t, first.MarkRunning())
+	require.NoError(t, first.MarkComplete("done", nil))
+	require.NoError(t, store.UpdateJob(first))
+
+	status, err := CompleteBatch(store, batch.ID)
+	require.NoError(t, err)
+	assert.Nil(t, status)
+
+	require.NoError(t, second.MarkRunning())
+	require.NoError(t, second.MarkError(errors.New("boom")))
+	require.NoError(t, store.UpdateJob(second))
+
+	status, err = CompleteBatch(store, batch.ID)
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, 1, status.Complete)
+	assert.Equal(t, 1, status.Failed)
+	assert.Equal(t, 100, status.Progress)
+	require.NotNil(t, status.CompletedAt)
+
+	status, err = CompleteBatch(store, batch.ID)
+	require.NoError(t, err)
+	assert.Nil(t, status, "a batch completes only once")
+	require.NoError(t, store.Close())
+
+	reopened, err := NewBoltStore(path)
+	require.NoError(t, err)
+	stored, err := reopened.GetBatch(batch.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored.CompletedAt)
+
+	_, err = reopened.GetBatch("missing")
+	assert.ErrorIs(t, err, ErrBatchNotFound)
+}
+
+func TestBoltStore_PersistsSubscriptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	store, err := NewBoltStore(path)
+	require.NoError(t, err)
+
+	sub, err := models.NewSubscription(models.SubscriptionRequest{URL: "https://www.youtube.com/@mitocw"})
+	require.NoError(t, err)
+	require.NoError(t, store.AddSubscription(sub))
+
+	sub.RecordScan([]models.CollectionEntry{{VideoID: "dQw4w9WgXcQ"}}, time.Now())
+	require.NoError(t, store.UpdateSubscription(sub))
+	require.NoError(t, store.Close())
+
+	reopened, err := NewBoltStore(path)
+	require.NoError(t, err)
+	retrieved, err := reopened.GetSubscription(sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dQw4w9WgXcQ"}, retrieved.SeenVideoIDs)
+
+	require.NoError(t, reopened.DeleteSubscription(sub.ID))
+	_, err = reopened.GetSubscription(sub.ID)
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+	assert.ErrorIs(t, reopened.DeleteSubscription(sub.ID), ErrSubscriptionNotFound)
+}
+
+func TestMemoryStore_CopiesBatchesAndSubscriptions(t *testing.T) {
+	store := NewMemoryStore()
+
+	batch := models.NewBatch(1, false)
+	require.NoError(t, store.AddBatch(batch))
+	batch.Title = "changed"
+	retrieved, err := store.GetBatch(batch.ID)
+	require.NoError(t, err)
+	assert.Empty(t, retrieved.Title)
+
+	completed, err := store.CompleteBatch(batch.ID, time.Now())
+	require.NoError(t, err)
+	assert.True(t, completed)
+	assert.Nil(t, retrieved.CompletedAt)
+
+	sub, err := models.NewSubscription(models.SubscriptionRequest{URL: "https://www.youtube.com/@mitocw"})
+	require.NoError(t, err)
+	sub.RecordScan([]models.CollectionEntry{{VideoID: "dQw4w9WgXcQ"}}, time.Now())
+	require.NoError(t, store.AddSubscription(sub))
+	sub.SeenVideoIDs[0] = "changed"
+
+	stored, err := store.GetSubscription(sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dQw4w9WgXcQ"}, stored.SeenVideoIDs)
+	stored.Active = false
+	*stored.LastScannedAt = time.Time{}
+
+	listed, err := store.ListSubscriptions()
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.True(t, listed[0].Active)
+	assert.False(t, listed[0].LastScannedAt.IsZero())
+}
+
+func TestPostgresMigrations_InVersionOrder(t *testing.T) {
+	list, err := postgresMigrations(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+	assert.Equal(t, "1_create_jobs.up.sql", list[0].file)
+	for i, migration := range list {
+		assert.Equal(t, i+1, migration.version, migration.file)
+	}
+
+	_, err = postgresMigrations(fstest.MapFS{"create_jobs.up.sql": {}})
+	assert.Error(t, err)
+	_, err = postgresMigrations(fstest.MapFS{"2_a.up.sql": {}, "2_b.up.sql": {}})
+	assert.Error(t, err)
+}
+
+func TestBoltStore_ClaimsIdempotencyKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	store, err := NewBoltStore(path)
+	require.NoError(t, err)
+
+	key, err := models.NewIdempotencyKey("key_a", "retry-1", "fingerprint", time.Hour)
+	require.NoError(t, err)
+	existing, err := store.ClaimIdempotencyKey(key)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	completed := *key
+	completed.JobID = "job_1"
+	require.NoError(t, store.UpdateIdempotencyKey(&completed))
+	require.NoError(t, store.Close())
+
+	reopened, err := NewBoltStore(path)
+	require.NoError(t, err)
+	repeat, err := models.NewIdempotencyKey("key_a", "retry-1", "fingerprint", time.Hour)
+	require.NoError(t, err)
+	existing, err = reopened.ClaimIdempotencyKey(repeat)
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "job_1", existing.JobID)
+
+	// Keys are scoped to the API key that sent them
+	other, err := models.NewIdempotencyKey("key_b", "retry-1", "fingerprint", time.Hour)
+	require.NoError(t, err)
+	existing, err = reopened.ClaimIdempotencyKey(other)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// An expired key can be claimed again
+	later := *repeat
+	later.CreatedAt = key.ExpiresAt
+	later.ExpiresAt = later.CreatedAt.Add(time.Hour)
+	existing, err = reopened.ClaimIdempotencyKey(&later)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	require.NoError(t, reopened.DeleteIdempotencyKey("key_a", "retry-1"))
+	assert.ErrorIs(t, reopened.UpdateIdempotencyKey(&later), ErrIdempotencyKeyNotFound)
+}
@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"videotranscript-app/config"
+	"videotranscript-app/models"
+)
+
+// ArtifactStore persists the files a job produces, such as subtitle files
+// and normalized audio, under job-scoped keys
+type ArtifactStore interface {
+	// Put stores the content of r under key and returns its size
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error)
+	// Get opens a stored artifact. It returns ErrArtifactNotFound for
+	// unknown keys.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a download URL for key that expires after ttl
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// ArtifactVerifier is implemented by stores whose signed URLs point back at
+// this application's /artifacts/ route instead of the storage backend
+type ArtifactVerifier interface {
+	VerifyURL(key, expires, signature string) error
+}
+
+var (
+	ErrArtifactNotFound = errors.New("artifact not found")
+	// ErrInvalidSignature is returned for artifact URLs that were not signed
+	// by this server or have expired
+	ErrInvalidSignature = errors.New("invalid or expired artifact signature")
+)
+
+// DefaultArtifactURLTTL is how long signed artifact URLs stay valid unless
+// configured otherwise
+const DefaultArtifactURLTTL = time.Hour
+
+var artifactContentTypes = map[string]string{
+	".srt": "application/x-subrip; charset=utf-8",
+	".vtt": "text/vtt; charset=utf-8",
+	".wav": "audio/wav",
+	".mp4": "video/mp4",
+	".mkv": "video/x-matroska",
+}
+
+// ArtifactKey returns the store key of a file produced by a job
+func ArtifactKey(jobID, name string) string {
+	return path.Join("jobs", jobID, name)
+}
+
+// ArtifactContentType returns the content type of an artifact from its key
+func ArtifactContentType(key string) string {
+	if contentType, ok := artifactContentTypes[path.Ext(key)]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// validArtifactKey rejects keys that could escape a store's root
+func validArtifactKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key && !strings.HasPrefix(key, "..")
+}
+
+// OpenArtifactStore creates the artifact store selected by ARTIFACT_STORE:
+// "local" (the default) keeps files under ARTIFACT_DIR and "s3" uses an
+// S3-compatible bucket
+func OpenArtifactStore(cfg *config.Config) (ArtifactStore, error) {
+	switch cfg.ArtifactStore {
+	case "", "local":
+		dir := cfg.ArtifactDir
+		if dir == "" {
+			dir = path.Join(cfg.WorkDir, "artifacts")
+		}
+		return NewLocalArtifactStore(dir, NewArtifactSigner(cfg.PublicURL, cfg.ArtifactSecret))
+	case "s3":
+		return NewS3ArtifactStore(S3Options{
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			Endpoint:  cfg.S3Endpoint,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown artifact store %q", cfg.ArtifactStore)
+	}
+}
+
+var (
+	artifactStore   ArtifactStore
+	artifactStoreMu sync.RWMutex
+)
+
+// InitializeArtifacts sets the store job artifacts are written to
+func InitializeArtifacts(store ArtifactStore) {
+	artifactStoreMu.Lock()
+	defer artifactStoreMu.Unlock()
+	artifactStore = store
+}
+
+// GetArtifactStore returns the configured artifact store, or nil if
+// artifacts are not stored
+func GetArtifactStore() ArtifactStore {
+	artifactStoreMu.RLock()
+	defer artifactStoreMu.RUnlock()
+	return artifactStore
+}
+
+// StoreJobArtifacts writes a job's SRT and VTT subtitles, laid out in the
+// CAPTION_PRESET style, and the normalized audio file if audioPath is set,
+// to the store and returns them as artifacts
+func StoreJobArtifacts(ctx context.Context, store ArtifactStore, job *models.Job, audioPath string) ([]models.Artifact, error) {
+	var artifacts []models.Artifact
+	if len(job.Segments) > 0 {
+		style, err := CaptionPreset("")
+		if err != nil {
+			return nil, err
+		}
+		captions := captionSegments(job.Segments, style)
+		for _, format := range []SubtitleFormat{FormatSRT, FormatVTT} {
+			key := ArtifactKey(job.ID, "subtitles."+string(format))
+			content := ConvertSegmentsToSubtitles(captions, format)
+			size, err := store.Put(ctx, key, strings.NewReader(content), ArtifactContentType(key))
+			if err != nil {
+				return artifacts, fmt.Errorf("failed to store %s subtitles: %w", format, err)
+			}
+			artifacts = append(artifacts, models.Artifact{Kind: "subtitle", Format: string(format), Key: key, Size: size})
+		}
+	}
+
+	if audioPath != "" {
+		file, err := os.Open(audioPath)
+		if err != nil {
+			return artifacts, fmt.Errorf("failed to open audio: %w", err)
+		}
+		defer file.Close()
+
+		key := ArtifactKey(job.ID, "audio.wav")
+		size, err := store.Put(ctx, key, file, ArtifactContentType(key))
+		if err != nil {
+			return artifacts, fmt.Errorf("failed to store audio: %w", err)
+		}
+		artifacts = append(artifacts, models.Artifact{Kind: "audio", Format: "wav", Key: key, Size: size})
+	}
+
+	return artifacts, nil
+}
+
+// SignedSubtitleFiles returns download URLs for a job's stored subtitle
+// artifacts that expire after ttl, or nil if it has none
+func SignedSubtitleFiles(store ArtifactStore, job *models.Job, ttl time.Duration) (*SubtitleFiles, error) {
+	if store == nil {
+		return nil, nil
+	}
+
+	var files *SubtitleFiles
+	for _, artifact := range job.Artifacts {
+		if artifact.Kind != "subtitle" || artifact.Key == "" {
+			continue
+		}
+		signed, err := store.SignedURL(artifact.Key, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if files == nil {
+			files = &SubtitleFiles{}
+		}
+		switch artifact.Format {
+		case string(FormatSRT):
+			files.SRTURL = signed
+		case string(FormatVTT):
+			files.VTTURL = signed
+		}
+	}
+	return files, nil
+}
+
+// ArtifactSigner signs and verifies artifact download URLs served by this
+// application, for stores without native signed URLs. A URL carries its
+// expiry and an HMAC-SHA256 of the key and expiry.
+type ArtifactSigner struct {
+	baseURL string
+	secret  []byte
+}
+
+// NewArtifactSigner creates a signer for URLs under baseURL + "/artifacts/".
+// Without a secret a random one is generated, so URLs stop working when the
+// process restarts.
+func NewArtifactSigner(baseURL, secret string) *ArtifactSigner {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &ArtifactSigner{baseURL: strings.TrimSuffix(baseURL, "/"), secret: key}
+}
+
+// URL returns the download URL of key, valid until expires
+func (s *ArtifactSigner) URL(key string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", exp)
+	query.Set("signature", s.signature(key, exp))
+	return s.baseURL + "/artifacts/" + key + "?" + query.Encode()
+}
+
+// Verify checks the expires and signature query parameters of a request for
+// key
+func (s *ArtifactSigner) Verify(key, expires, signature string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ErrInvalidSignature
+	}
+	expected := s.signature(key, expires)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *ArtifactSigner) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
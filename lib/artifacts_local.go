@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LocalArtifactStore keeps artifacts as files under a directory. Its signed
+// URLs are served by the application's /artifacts/ route.
+type LocalArtifactStore struct {
+	dir    string
+	signer *ArtifactSigner
+}
+
+func NewLocalArtifactStore(dir string, signer *ArtifactSigner) (*LocalArtifactStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	return &LocalArtifactStore{dir: dir, signer: signer}, nil
+}
+
+func (s *LocalArtifactStore) path(key string) (string, error) {
+	if !validArtifactKey(key) {
+		return "", fmt.Errorf("invalid artifact key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalArtifactStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	target, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+
+	// Write to a temporary file first so readers never see partial content
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".artifact-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return size, os.Rename(tmp.Name(), target)
+}
+
+func (s *LocalArtifactStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	target, err := s.path(key)
+	if err != nil {
+		return nil, ErrArtifactNotFound
+	}
+	file, err := os.Open(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrArtifactNotFound
+	}
+	return file, err
+}
+
+func (s *LocalArtifactStore) Delete(ctx context.Context, key string) error {
+	target, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalArtifactStore) SignedURL(key string, ttl time.Duration) (string, error) {
+	if !validArtifactKey(key) {
+		return "", fmt.Errorf("invalid artifact key %q", key)
+	}
+	return s.signer.URL(key, time.Now().Add(ttl)), nil
+}
+
+func (s *LocalArtifactStore) VerifyURL(key, expires, signature string) error {
+	return s.signer.Verify(key, expires, signature)
+}
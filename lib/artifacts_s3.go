@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3Options configures an S3ArtifactStore. Endpoint and PathStyle select
+// S3-compatible services such as MinIO or R2; without AccessKey the default
+// AWS credential chain is used.
+type S3Options struct {
+	Bucket    string
+	Region    string
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+}
+
+// S3ArtifactStore keeps artifacts in an S3-compatible bucket and hands out
+// presigned GET URLs
+type S3ArtifactStore struct {
+	bucket   string
+	client   *s3.S3
+	uploader *s3manager.Uploader
+}
+
+func NewS3ArtifactStore(opts S3Options) (*S3ArtifactStore, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("s3 artifact store requires a bucket")
+	}
+
+	awsConfig := aws.NewConfig().WithS3ForcePathStyle(opts.PathStyle)
+	if opts.Region != "" {
+		awsConfig = awsConfig.WithRegion(opts.Region)
+	}
+	if opts.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(opts.Endpoint)
+	}
+	if opts.AccessKey != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(opts.AccessKey, opts.SecretKey, ""))
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 session: %w", err)
+	}
+	client := s3.New(sess)
+	return &S3ArtifactStore{
+		bucket:   opts.Bucket,
+		client:   client,
+		uploader: s3manager.NewUploaderWithClient(client),
+	}, nil
+}
+
+func (s *S3ArtifactStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	if !validArtifactKey(key) {
+		return 0, fmt.Errorf("invalid artifact key %q", key)
+	}
+	body := &countingReader{r: r}
+	_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return body.n, nil
+}
+
+func (s *S3ArtifactStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *S3ArtifactStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// SignedURL presigns a GET request, so downloads go straight to the bucket
+func (s *S3ArtifactStore) SignedURL(key string, ttl time.Duration) (string, error) {
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return req.Presign(ttl)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
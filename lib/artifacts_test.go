@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+func TestLocalArtifactStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalArtifactStore(t.TempDir(), NewArtifactSigner("http://localhost:3000", "secret"))
+	require.NoError(t, err)
+
+	key := ArtifactKey("job-1", "subtitles.srt")
+	srt := "1\n00:00:00,000 --> 00:00:01,000\nHi\n\n"
+	size, err := store.Put(ctx, key, strings.NewReader(srt), ArtifactContentType(key))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(srt)), size)
+
+	r, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	content, err := io.ReadAll(r)
+	require.NoError(t, r.Close())
+	require.NoError(t, err)
+	assert.Equal(t, srt, string(content))
+
+	_, err = store.Put(ctx, "../escape.srt", strings.NewReader("x"), "text/plain")
+	assert.Error(t, err)
+
+	require.NoError(t, store.Delete(ctx, key))
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrArtifactNotFound)
+}
+
+func TestArtifactSigner(t *testing.T) {
+	signer := NewArtifactSigner("https://api.example.com/", "secret")
+	key := ArtifactKey("job-1", "subtitles.vtt")
+
+	signed, err := url.Parse(signer.URL(key, time.Now().Add(time.Minute)))
+	require.NoError(t, err)
+	assert.Equal(t, "/artifacts/jobs/job-1/subtitles.vtt", signed.Path)
+	expires, signature := signed.Query().Get("expires"), signed.Query().Get("signature")
+
+	assert.NoError(t, signer.Verify(key, expires, signature))
+	assert.ErrorIs(t, signer.Verify(ArtifactKey("job-2", "subtitles.vtt"), expires, signature), ErrInvalidSignature)
+	tampered := "0" + signature[1:]
+	if signature[0] == '0' {
+		tampered = "1" + signature[1:]
+	}
+	assert.ErrorIs(t, signer.Verify(key, expires, tampered), ErrInvalidSignature)
+	assert.ErrorIs(t, NewArtifactSigner("", "other").Verify(key, expires, signature), ErrInvalidSignature)
+
+	expired, err := url.Parse(signer.URL(key, time.Now().Add(-time.Minute)))
+	require.NoError(t, err)
+	assert.ErrorIs(t, signer.Verify(key, expired.Query().Get("expires"), expired.Query().Get("signature")), ErrInvalidSignature)
+}
+
+func TestStoreJobArtifacts(t *testing.T) {
+	store, err := NewLocalArtifactStore(t.TempDir(), NewArtifactSigner("https://api.example.com", "secret"))
+	require.NoError(t, err)
+
+	job := models.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	job.Segments = []models.Segment{{Start: 0, End: 1.5, Text: "Hello"}}
+
+	artifacts, err := StoreJobArtifacts(context.Background(), store, job, "")
+	require.NoError(t, err)
+	require.Len(t, artifacts, 2)
+	assert.Equal(t, models.Artifact{Kind: "subtitle", Format: "srt", Key: "jobs/" + job.ID + "/subtitles.srt", Size: int64(len(ConvertSegmentsToSubtitles(job.Segments, FormatSRT)))}, artifacts[0])
+	assert.Equal(t, "vtt", artifacts[1].Format)
+
+	job.Artifacts = artifacts
+	files, err := SignedSubtitleFiles(store, job, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, files)
+	assert.True(t, strings.HasPrefix(files.SRTURL, "https://api.example.com/artifacts/jobs/"+job.ID+"/subtitles.srt?"))
+	assert.True(t, strings.HasPrefix(files.VTTURL, "https://api.example.com/artifacts/jobs/"+job.ID+"/subtitles.vtt?"))
+
+	job.Artifacts = nil
+	files, err = SignedSubtitleFiles(store, job, time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, files)
+}
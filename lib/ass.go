@@ -0,0 +1,414 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"videotranscript-app/models"
+)
+
+// ASSStyle is the caption style of an Advanced SubStation Alpha file.
+// Colors are "#RRGGBB", or "#RRGGBBAA" with AA the opacity.
+type ASSStyle struct {
+	FontName       string
+	FontSize       int
+	PrimaryColor   string
+	SecondaryColor string
+	OutlineColor   string
+	BackColor      string
+	Bold           bool
+	Italic         bool
+	Outline        float64
+	Shadow         float64
+	// Alignment is a numpad position: 1-3 bottom, 4-6 middle, 7-9 top
+	Alignment int
+	MarginV   int
+}
+
+// ASSOptions configure ASS export. Karaoke adds \k timing tags to cues with
+// word timings; SecondaryColor is the color of words before they are sung.
+type ASSOptions struct {
+	Title    string
+	Style    ASSStyle
+	Karaoke  bool
+	PlayResX int
+	PlayResY int
+}
+
+// DefaultASSStyle is white Arial with a black outline, centred at the bottom
+// of a 1080p frame
+func DefaultASSStyle() ASSStyle {
+	return ASSStyle{
+		FontName:       "Arial",
+		FontSize:       64,
+		PrimaryColor:   "#FFFFFF",
+		SecondaryColor: "#FFFF00",
+		OutlineColor:   "#000000",
+		BackColor:      "#00000080",
+		Outline:        3,
+		Shadow:         0,
+		Alignment:      2,
+		MarginV:        60,
+	}
+}
+
+// withDefaults fills unset options from DefaultASSStyle and a 1080p canvas
+func (o ASSOptions) withDefaults() ASSOptions {
+	defaults := DefaultASSStyle()
+	style := &o.Style
+	if style.FontName == "" {
+		style.FontName = defaults.FontName
+	}
+	if style.FontSize == 0 {
+		style.FontSize = defaults.FontSize
+	}
+	if style.PrimaryColor == "" {
+		style.PrimaryColor = defaults.PrimaryColor
+	}
+	if style.SecondaryColor == "" {
+		style.SecondaryColor = defaults.SecondaryColor
+	}
+	if style.OutlineColor == "" {
+		style.OutlineColor = defaults.OutlineColor
+	}
+	if style.BackColor == "" {
+		style.BackColor = defaults.BackColor
+	}
+	if style.Alignment == 0 {
+		style.Alignment = defaults.Alignment
+	}
+	if style.MarginV == 0 {
+		style.MarginV = defaults.MarginV
+	}
+	if o.PlayResX == 0 || o.PlayResY == 0 {
+		o.PlayResX, o.PlayResY = 1920, 1080
+	}
+	return o
+}
+
+// Validate reports options that would produce an unusable script. Unset
+// fields are valid and take their defaults.
+func (o ASSOptions) Validate() error {
+	style := o.withDefaults().Style
+	switch {
+	case style.FontSize < 1 || style.FontSize > 400:
+		return fmt.Errorf("font_size must be between 1 and 400")
+	case style.Alignment < 1 || style.Alignment > 9:
+		return fmt.Errorf("alignment must be between 1 and 9")
+	case style.Outline < 0 || style.Shadow < 0:
+		return fmt.Errorf("outline and shadow must not be negative")
+	case style.MarginV < 0:
+		return fmt.Errorf("margin_v must not be negative")
+	}
+	for _, color := range []string{style.PrimaryColor, style.SecondaryColor, style.OutlineColor, style.BackColor} {
+		if _, err := assColor(color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteASS writes segments to w as an ASS script with a single Default
+// style. Lines of multi-line cues, as produced by LayoutCaptions, are kept.
+func WriteASS(w io.Writer, segments []models.Segment, opts ASSOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	opts = opts.withDefaults()
+	style := opts.Style
+
+	colors := make([]string, 4)
+	for i, color := range []string{style.PrimaryColor, style.SecondaryColor, style.OutlineColor, style.BackColor} {
+		converted, err := assColor(color)
+		if err != nil {
+			return err
+		}
+		colors[i] = converted
+	}
+
+	b := bufio.NewWriter(w)
+	b.WriteString("[Script Info]\n")
+	if opts.Title != "" {
+		fmt.Fprintf(b, "Title: %s\n", strings.ReplaceAll(opts.Title, "\n", " "))
+	}
+	b.WriteString("ScriptType: v4.00+\n")
+	b.WriteString("WrapStyle: 0\n")
+	b.WriteString("ScaledBorderAndShadow: yes\n")
+	fmt.Fprintf(b, "PlayResX: %d\nPlayResY: %d\n\n", opts.PlayResX, opts.PlayResY)
+
+	b.WriteString("[V4+ Styles]\n")
+	b.WriteString("Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n")
+	fmt.Fprintf(b, "Style: Default,%s,%d,%s,%s,%s,%s,%d,%d,0,0,100,100,0,0,1,%s,%s,%d,60,60,%d,1\n\n",
+		strings.ReplaceAll(style.FontName, ",", " "), style.FontSize,
+		colors[0], colors[1], colors[2], colors[3],
+		assBool(style.Bold), assBool(style.Italic),
+		strconv.FormatFloat(style.Outline, 'f', -1, 64), strconv.FormatFloat(style.Shadow, 'f', -1, 64),
+		style.Alignment, style.MarginV)
+
+	b.WriteString("[Events]\n")
+	b.WriteString("Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+	for _, segment := range segments {
+		text := assText(segment.Text)
+		if opts.Karaoke {
+			if karaoke, ok := assKaraoke(segment); ok {
+				text = karaoke
+			}
+		}
+		fmt.Fprintf(b, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n", formatASSTime(segment.Start), formatASSTime(segment.End), text)
+	}
+
+	return b.Flush()
+}
+
+// assKaraoke tags each word of a cue with its duration in centiseconds. It
+// reports false if the cue has no word timings or they do not match its
+// text.
+func assKaraoke(segment models.Segment) (string, bool) {
+	lines := strings.Split(segment.Text, "\n")
+	total := 0
+	for _, line := range lines {
+		total += len(strings.Fields(line))
+	}
+	if len(segment.Words) == 0 || len(segment.Words) != total {
+		return "", false
+	}
+
+	var b strings.Builder
+	if lead := centiseconds(segment.Words[0].Start - segment.Start); lead > 0 {
+		fmt.Fprintf(&b, "{\\k%d}", lead)
+	}
+	i := 0
+	for l, line := range lines {
+		if l > 0 {
+			b.WriteString("\\N")
+		}
+		for j, field := range strings.Fields(line) {
+			// A word is held until the next one starts, so pauses stay lit
+			end := segment.Words[i].End
+			if i+1 < len(segment.Words) {
+				end = segment.Words[i+1].Start
+			}
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, "{\\k%d}%s", centiseconds(end-segment.Words[i].Start), assText(field))
+			i++
+		}
+	}
+	return b.String(), true
+}
+
+// assText escapes override braces and turns line breaks into \N
+func assText(text string) string {
+	text = strings.NewReplacer("{", "(", "}", ")").Replace(text)
+	return strings.ReplaceAll(strings.TrimSpace(text), "\n", "\\N")
+}
+
+// assColor converts "#RRGGBB" or "#RRGGBBAA" to ASS's &HAABBGGRR, in which
+// AA is the transparency
+func assColor(color string) (string, error) {
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return "", fmt.Errorf("invalid color %q, expected #RRGGBB or #RRGGBBAA", color)
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid color %q, expected #RRGGBB or #RRGGBBAA", color)
+	}
+	alpha := uint64(0xFF)
+	if len(hex) == 8 {
+		alpha = value & 0xFF
+		value >>= 8
+	}
+	r, g, b := value>>16&0xFF, value>>8&0xFF, value&0xFF
+	return fmt.Sprintf("&H%02X%02X%02X%02X", 0xFF-alpha, b, g, r), nil
+}
+
+func assBool(v bool) int {
+	if v {
+		return -1
+	}
+	return 0
+}
+
+func centiseconds(seconds float64) int {
+	return int(math.Max(0, math.Round(seconds*100)))
+}
+
+// formatASSTime formats seconds as H:MM:SS.cc
+func formatASSTime(seconds float64) string {
+	h, m, s, cs := clockTime(seconds, 100)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, cs)
+}
+
+// ParseASSOptions reads ASS export options from query parameters: font,
+// font_size, color, karaoke_color, outline_color, back_color, bold,
+// italic, outline, shadow, alignment, margin_v and karaoke
+func ParseASSOptions(query func(string) string) (ASSOptions, error) {
+	opts := ASSOptions{Style: ASSStyle{
+		FontName:       query("font"),
+		PrimaryColor:   query("color"),
+		SecondaryColor: query("karaoke_color"),
+		OutlineColor:   query("outline_color"),
+		BackColor:      query("back_color"),
+	}}
+
+	ints := map[string]*int{"font_size": &opts.Style.FontSize, "alignment": &opts.Style.Alignment, "margin_v": &opts.Style.MarginV}
+	for name, target := range ints {
+		if value := query(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be an integer", name)
+			}
+			*target = n
+		}
+	}
+	floats := map[string]*float64{"outline": &opts.Style.Outline, "shadow": &opts.Style.Shadow}
+	for name, target := range floats {
+		if value := query(name); value != "" {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be a number", name)
+			}
+			*target = f
+		}
+	}
+	bools := map[string]*bool{"bold": &opts.Style.Bold, "italic": &opts.Style.Italic, "karaoke": &opts.Karaoke}
+	for name, target := range bools {
+		if value := query(name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be true or false", name)
+			}
+			*target = b
+		}
+	}
+
+	// An explicit outline of 0 is kept; the default applies only when unset
+	if query("outline") == "" {
+		opts.Style.Outline = DefaultASSStyle().Outline
+	}
+	return opts, opts.Validate()
+}
+
+// assDefaultEventFormat is the field order of V4+ events, used when a file
+// has no Format line
+var assDefaultEventFormat = []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+
+var (
+	assOverrideRegex = regexp.MustCompile(`\{[^}]*\}`)
+	assKaraokeRegex  = regexp.MustCompile(`\\[kK][fo]?(\d+)`)
+)
+
+// ParseASS parses the Dialogue events of an ASS or SSA script. Override
+// tags are removed and \k karaoke tags become word timings; Comment events
+// and styles are ignored.
+func ParseASS(r io.Reader) ([]models.Segment, error) {
+	lines, err := readSubtitleLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []models.Segment
+	section := ""
+	format := assDefaultEventFormat
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(line)
+			continue
+		}
+		if section != "[events]" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Format":
+			format = nil
+			for _, field := range strings.Split(value, ",") {
+				format = append(format, strings.ToLower(strings.TrimSpace(field)))
+			}
+		case "Dialogue":
+			// Text is the last field and may itself contain commas
+			fields := strings.SplitN(value, ",", len(format))
+			if len(fields) != len(format) {
+				continue
+			}
+			event := make(map[string]string, len(fields))
+			for i, name := range format {
+				event[name] = fields[i]
+			}
+			start, err := parseCueTimestamp(strings.TrimSpace(event["start"]))
+			if err != nil {
+				continue
+			}
+			end, err := parseCueTimestamp(strings.TrimSpace(event["end"]))
+			if err != nil {
+				continue
+			}
+			text, words := assPlainText(event["text"], start)
+			segments = appendCue(segments, start, end, text, words)
+		}
+	}
+
+	return finishCues(segments, blankLines(lines))
+}
+
+// assPlainText strips override blocks from event text and converts its
+// line breaks. Karaoke durations, counted from the event start, time the
+// words that follow each \k tag.
+func assPlainText(raw string, start float64) (string, []models.Word) {
+	unescape := strings.NewReplacer("\\N", "\n", "\\n", "\n", "\\h", " ")
+
+	var words []models.Word
+	karaoke := false
+	cursor := start
+	chunkStart, chunkEnd := start, start
+	last := 0
+	addWords := func(text string) {
+		fields := strings.Fields(unescape.Replace(assOverrideRegex.ReplaceAllString(text, "")))
+		if !karaoke || len(fields) == 0 {
+			return
+		}
+		step := (chunkEnd - chunkStart) / float64(len(fields))
+		for i, field := range fields {
+			words = append(words, models.Word{
+				Start: chunkStart + step*float64(i),
+				End:   chunkStart + step*float64(i+1),
+				Text:  field,
+			})
+		}
+	}
+	for _, block := range assOverrideRegex.FindAllStringIndex(raw, -1) {
+		tags := assKaraokeRegex.FindAllStringSubmatch(raw[block[0]:block[1]], -1)
+		if len(tags) == 0 {
+			continue
+		}
+		addWords(raw[last:block[0]])
+		last = block[1]
+		karaoke = true
+		for _, tag := range tags {
+			centis, _ := strconv.Atoi(tag[1])
+			chunkStart = cursor
+			cursor += float64(centis) / 100
+			chunkEnd = cursor
+		}
+	}
+	addWords(raw[last:])
+
+	text := unescape.Replace(assOverrideRegex.ReplaceAllString(raw, ""))
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), words
+}
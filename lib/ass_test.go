@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+func TestWriteASS(t *testing.T) {
+	segments := []models.Segment{
+		{Start: 0, End: 2.345, Text: "Hello {world}"},
+		{Start: 3661.5, End: 3663, Text: "First line\nSecond line"},
+	}
+
+	var b strings.Builder
+	require.NoError(t, WriteASS(&b, segments, ASSOptions{
+		Title: "Demo",
+		Style: ASSStyle{FontName: "Roboto", FontSize: 72, PrimaryColor: "#FFCC00", BackColor: "#00000080", Outline: 2, Alignment: 8},
+	}))
+	content := b.String()
+
+	assert.Equal(t, `[Script Info]
+Title: Demo
+ScriptType: v4.00+
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+PlayResX: 1920
+PlayResY: 1080
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Roboto,72,&H0000CCFF,&H0000FFFF,&H00000000,&H7F000000,0,0,0,0,100,100,0,0,1,2,0,8,60,60,60,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:00.00,0:00:02.35,Default,,0,0,0,,Hello (world)
+Dialogue: 0,1:01:01.50,1:01:03.00,Default,,0,0,0,,First line\NSecond line
+`, content)
+}
+
+func TestWriteASS_Karaoke(t *testing.T) {
+	segments := []models.Segment{
+		{
+			Start: 1,
+			End:   3,
+			Text:  "Never gonna\ngive you up",
+			Words: []models.Word{
+				{Start: 1.2, End: 1.5, Text: "Never"},
+				{Start: 1.5, End: 1.9, Text: "gonna"},
+				{Start: 2.1, End: 2.3, Text: "give"},
+				{Start: 2.3, End: 2.5, Text: "you"},
+				{Start: 2.5, End: 3, Text: "up"},
+			},
+		},
+		// Without word timings the cue is written as plain text
+		{Start: 4, End: 5, Text: "Never gonna let you down"},
+	}
+
+	var b strings.Builder
+	require.NoError(t, WriteASS(&b, segments, ASSOptions{Karaoke: true}))
+	content := b.String()
+
+	assert.Contains(t, content, `Dialogue: 0,0:00:01.00,0:00:03.00,Default,,0,0,0,,{\k20}{\k30}Never {\k60}gonna\N{\k20}give {\k20}you {\k50}up`+"\n")
+	assert.Contains(t, content, `Dialogue: 0,0:00:04.00,0:00:05.00,Default,,0,0,0,,Never gonna let you down`+"\n")
+}
+
+func TestParseASSOptions(t *testing.T) {
+	query := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	opts, err := ParseASSOptions(query(map[string]string{"font": "Impact", "font_size": "90", "outline": "0", "alignment": "5", "karaoke": "true"}))
+	require.NoError(t, err)
+	assert.Equal(t, "Impact", opts.Style.FontName)
+	assert.Equal(t, 90, opts.Style.FontSize)
+	assert.Equal(t, 0.0, opts.Style.Outline)
+	assert.Equal(t, 5, opts.Style.Alignment)
+	assert.True(t, opts.Karaoke)
+
+	opts, err = ParseASSOptions(query(nil))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultASSStyle().Outline, opts.Style.Outline)
+
+	for _, values := range []map[string]string{
+		{"color": "red"},
+		{"color": "#GGGGGG"},
+		{"alignment": "10"},
+		{"font_size": "big"},
+		{"karaoke": "maybe"},
+		{"shadow": "-1"},
+	} {
+		_, err := ParseASSOptions(query(values))
+		assert.Error(t, err, values)
+	}
+}
+
+func TestConvertSegmentsToSubtitles_ASS(t *testing.T) {
+	content := ConvertSegmentsToSubtitles([]models.Segment{{Start: 0, End: 1, Text: "Hi"}}, FormatASS)
+	assert.True(t, strings.HasPrefix(content, "[Script Info]\n"))
+	assert.Contains(t, content, "Style: Default,Arial,64,&H00FFFFFF,")
+	assert.Contains(t, content, "Dialogue: 0,0:00:00.00,0:00:01.00,Default,,0,0,0,,Hi\n")
+}
@@ -1,6 +1,8 @@
 package lib
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -27,12 +29,29 @@ func AuthMiddleware() fiber.Handler {
 		}
 
 		token := tokenParts[1]
-		if token != cfg.APIKey {
+		if !cfg.ValidAPIKey(token) {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Invalid API key",
 			})
 		}
 
+		c.Locals(apiKeyIDLocal, APIKeyID(token))
 		return c.Next()
 	}
 }
+
+const apiKeyIDLocal = "api_key_id"
+
+// APIKeyID derives a stable, non-secret identifier for an API key so jobs
+// can be attributed to callers without storing the key itself.
+func APIKeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key_" + hex.EncodeToString(sum[:8])
+}
+
+// RequestAPIKeyID returns the identifier of the API key that authenticated
+// the request, or "" when the request did not pass AuthMiddleware.
+func RequestAPIKeyID(c *fiber.Ctx) string {
+	id, _ := c.Locals(apiKeyIDLocal).(string)
+	return id
+}
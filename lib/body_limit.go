@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit rejects request bodies larger than limit bytes with 413. The
+// server streams request bodies, so the fiber BodyLimit no longer caps them:
+// a BodyLimit installed with app.Use holds every route to the default size,
+// skipping the requests skip reports, and those routes install their own
+// larger BodyLimit. Bodies sent without a Content-Length are read up to the
+// limit before the handler runs.
+func BodyLimit(limit int, skip func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if skip != nil && skip(c) {
+			return c.Next()
+		}
+
+		req := c.Request()
+		length := req.Header.ContentLength()
+		if length > limit {
+			return bodyTooLarge(c, limit)
+		}
+		if length == -1 && req.BodyStream() != nil {
+			body, err := io.ReadAll(io.LimitReader(req.BodyStream(), int64(limit)+1))
+			if err != nil {
+				c.Context().SetConnectionClose()
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Failed to read request body",
+				})
+			}
+			if len(body) > limit {
+				return bodyTooLarge(c, limit)
+			}
+			req.SetBody(body)
+			req.Header.SetContentLength(len(body))
+		}
+		return c.Next()
+	}
+}
+
+// bodyTooLarge closes the connection, as the rest of the body is never read
+func bodyTooLarge(c *fiber.Ctx, limit int) error {
+	c.Context().SetConnectionClose()
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"error": fmt.Sprintf("Request body must not be larger than %s", formatBytes(limit)),
+	})
+}
+
+// formatBytes writes a size in whole megabytes where it is one
+func formatBytes(n int) string {
+	if n >= 1<<20 && n%(1<<20) == 0 {
+		return fmt.Sprintf("%d MB", n>>20)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLimit(t *testing.T) {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage:        true,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	})
+	isUpload := func(c *fiber.Ctx) bool { return c.Path() == "/upload" }
+	app.Use(BodyLimit(1024, isUpload))
+	bodyLength := func(c *fiber.Ctx) error {
+		return c.SendString(strconv.Itoa(len(c.Body())))
+	}
+	app.Post("/echo", bodyLength)
+	app.Post("/upload", BodyLimit(4096, nil), bodyLength)
+
+	// A real connection, as app.Test can not send chunked bodies
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	for _, tc := range []struct {
+		path    string
+		size    int
+		chunked bool
+		status  int
+	}{
+		{"/echo", 512, false, fiber.StatusOK},
+		{"/echo", 2048, false, fiber.StatusRequestEntityTooLarge},
+		{"/echo", 512, true, fiber.StatusOK},
+		{"/echo", 2048, true, fiber.StatusRequestEntityTooLarge},
+		{"/upload", 2048, false, fiber.StatusOK},
+		{"/upload", 2048, true, fiber.StatusOK},
+		{"/upload", 8192, false, fiber.StatusRequestEntityTooLarge},
+		{"/upload", 8192, true, fiber.StatusRequestEntityTooLarge},
+	} {
+		var body io.Reader = bytes.NewReader(make([]byte, tc.size))
+		if tc.chunked {
+			// Hide the length so the request is sent chunked
+			body = io.MultiReader(body)
+		}
+		req, err := http.NewRequest("POST", "http://"+ln.Addr().String()+tc.path, body)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, tc.status, resp.StatusCode, "%s %d bytes chunked=%v", tc.path, tc.size, tc.chunked)
+		if tc.status == fiber.StatusOK {
+			got, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, strconv.Itoa(tc.size), string(got))
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"videotranscript-app/config"
+	"videotranscript-app/models"
+)
+
+// CaptionStyle controls how transcript segments are re-laid out as caption
+// cues. Durations are in seconds.
+type CaptionStyle struct {
+	MaxLineLength int     `json:"max_line_length"`
+	MaxLines      int     `json:"max_lines"`
+	MaxCPS        float64 `json:"max_cps"`
+	MinDuration   float64 `json:"min_duration"`
+	MaxDuration   float64 `json:"max_duration"`
+	MinGap        float64 `json:"min_gap"`
+}
+
+// CaptionPresetNone keeps one cue per transcript segment
+const CaptionPresetNone = "none"
+
+// CaptionPresets are caption styles following common platform guidelines
+var CaptionPresets = map[string]CaptionStyle{
+	"netflix": {MaxLineLength: 42, MaxLines: 2, MaxCPS: 20, MinDuration: 5.0 / 6, MaxDuration: 7, MinGap: 2.0 / 24},
+	"bbc":     {MaxLineLength: 37, MaxLines: 2, MaxCPS: 16, MinDuration: 1, MaxDuration: 7, MinGap: 1.0 / 25},
+	"youtube": {MaxLineLength: 32, MaxLines: 2, MaxCPS: 21, MinDuration: 1, MaxDuration: 6},
+}
+
+var ErrUnknownCaptionPreset = errors.New("unknown caption preset")
+
+// captionPauseBreak is the silence between words that always starts a new
+// cue
+const captionPauseBreak = 1.5
+
+// weakLineEnds are words a caption line should not end on, as they bind to
+// the word that follows
+var weakLineEnds = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "but": true, "or": true, "nor": true,
+	"of": true, "to": true, "in": true, "on": true, "at": true, "by": true, "for": true,
+	"with": true, "from": true, "into": true, "as": true, "if": true, "that": true,
+	"my": true, "your": true, "his": true, "her": true, "its": true, "our": true, "their": true,
+	"i": true, "we": true, "you": true, "is": true, "are": true, "was": true, "not": true,
+}
+
+// phraseStarts are conjunctions and prepositions a new cue may start on when
+// no punctuation offers a better break
+var phraseStarts = map[string]bool{
+	"and": true, "but": true, "or": true, "so": true, "because": true, "which": true,
+	"who": true, "when": true, "where": true, "while": true, "that": true, "if": true,
+	"then": true, "although": true, "though": true, "until": true, "unless": true,
+	"of": true, "in": true, "on": true, "at": true, "with": true, "for": true,
+	"from": true, "to": true, "into": true, "about": true, "through": true,
+}
+
+// CaptionPreset returns the style of a named preset. "" selects the
+// CAPTION_PRESET default and "none" returns nil, which keeps the segments as
+// they are.
+func CaptionPreset(name string) (*CaptionStyle, error) {
+	if name == "" {
+		name = config.Load().CaptionPreset
+	}
+	if name == "" || name == CaptionPresetNone {
+		return nil, nil
+	}
+	style, ok := CaptionPresets[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownCaptionPreset, name)
+	}
+	return &style, nil
+}
+
+// CaptionOptions select a caption preset and override parts of it
+type CaptionOptions struct {
+	Preset        string
+	MaxLineLength int
+	MaxLines      int
+	MaxCPS        float64
+}
+
+// ParseCaptionOptions parses the preset, max_line_length, max_lines and
+// max_cps query parameters of a subtitle request. Empty values keep the
+// preset's setting.
+func ParseCaptionOptions(preset, maxLineLength, maxLines, maxCPS string) (CaptionOptions, error) {
+	opts := CaptionOptions{Preset: preset}
+	var err error
+	if maxLineLength != "" {
+		if opts.MaxLineLength, err = strconv.Atoi(maxLineLength); err != nil {
+			return opts, fmt.Errorf("max_line_length must be an integer")
+		}
+	}
+	if maxLines != "" {
+		if opts.MaxLines, err = strconv.Atoi(maxLines); err != nil {
+			return opts, fmt.Errorf("max_lines must be an integer")
+		}
+	}
+	if maxCPS != "" {
+		if opts.MaxCPS, err = strconv.ParseFloat(maxCPS, 64); err != nil {
+			return opts, fmt.Errorf("max_cps must be a number")
+		}
+	}
+	return opts, nil
+}
+
+// Style resolves the options to a caption style, or nil if segments are
+// kept as they are. Overrides of the "none" preset start from netflix.
+func (o CaptionOptions) Style() (*CaptionStyle, error) {
+	style, err := CaptionPreset(o.Preset)
+	if err != nil {
+		return nil, err
+	}
+	if o.MaxLineLength == 0 && o.MaxLines == 0 && o.MaxCPS == 0 {
+		return style, nil
+	}
+
+	if style == nil {
+		netflix := CaptionPresets["netflix"]
+		style = &netflix
+	}
+	if o.MaxLineLength != 0 {
+		style.MaxLineLength = o.MaxLineLength
+	}
+	if o.MaxLines != 0 {
+		style.MaxLines = o.MaxLines
+	}
+	if o.MaxCPS != 0 {
+		style.MaxCPS = o.MaxCPS
+	}
+	return style, style.Validate()
+}
+
+// Validate reports settings the layout cannot work with
+func (s CaptionStyle) Validate() error {
+	switch {
+	case s.MaxLineLength < 10 || s.MaxLineLength > 200:
+		return fmt.Errorf("max_line_length must be between 10 and 200")
+	case s.MaxLines < 1 || s.MaxLines > 4:
+		return fmt.Errorf("max_lines must be between 1 and 4")
+	case s.MaxCPS < 0:
+		return fmt.Errorf("max_cps must not be negative")
+	case s.MinDuration < 0 || s.MinGap < 0:
+		return fmt.Errorf("min_duration and min_gap must not be negative")
+	case s.MaxDuration > 0 && s.MaxDuration < s.MinDuration:
+		return fmt.Errorf("max_duration must not be shorter than min_duration")
+	}
+	return nil
+}
+
+// captionWord is a word with its timing, taken from the engine's word
+// timings or interpolated across its segment
+type captionWord struct {
+	text       string
+	start, end float64
+	timed      bool
+}
+
+// LayoutCaptions re-segments a transcript into caption cues that follow the
+// style: at most MaxLines lines of MaxLineLength characters, broken at
+// punctuation and phrase boundaries, no longer than MaxDuration and shown
+// long enough to be read at MaxCPS. Word timings are used when the
+// segments have them. Each returned segment is one cue, with its lines
+// separated by "\n".
+func LayoutCaptions(segments []models.Segment, style CaptionStyle) []models.Segment {
+	words := captionWords(segments)
+
+	var cues [][]captionWord
+	for i := 0; i < len(words); {
+		n := 1
+		full := false
+		for i+n < len(words) {
+			if words[i+n].start-words[i+n-1].end >= captionPauseBreak {
+				break
+			}
+			if !style.fits(words[i : i+n+1]) {
+				full = true
+				break
+			}
+			n++
+		}
+		if full {
+			n = style.phraseBreak(words[i:], n)
+		}
+		cues = append(cues, words[i:i+n])
+		i += n
+	}
+
+	captions := make([]models.Segment, 0, len(cues))
+	for _, cue := range cues {
+		caption := models.Segment{
+			Start: cue[0].start,
+			End:   cue[len(cue)-1].end,
+			Text:  strings.Join(style.breakLines(cueTexts(cue)), "\n"),
+		}
+		if allTimed(cue) {
+			for _, w := range cue {
+				caption.Words = append(caption.Words, models.Word{Start: w.start, End: w.end, Text: w.text})
+			}
+		}
+		captions = append(captions, caption)
+	}
+	style.retime(captions)
+	return captions
+}
+
+// captionWords splits segments into words. Segments without word timings
+// spread their duration over their words by length.
+func captionWords(segments []models.Segment) []captionWord {
+	var words []captionWord
+	for _, segment := range segments {
+		if len(segment.Words) > 0 {
+			for _, w := range segment.Words {
+				if text := strings.TrimSpace(w.Text); text != "" {
+					words = append(words, captionWord{text: text, start: w.Start, end: w.End, timed: true})
+				}
+			}
+			continue
+		}
+
+		fields := strings.Fields(segment.Text)
+		total := 0
+		for _, f := range fields {
+			total += utf8.RuneCountInString(f) + 1
+		}
+		duration := segment.End - segment.Start
+		at := segment.Start
+		for _, f := range fields {
+			length := duration * float64(utf8.RuneCountInString(f)+1) / float64(total)
+			words = append(words, captionWord{text: f, start: at, end: at + length})
+			at += length
+		}
+	}
+	sort.SliceStable(words, func(i, j int) bool { return words[i].start < words[j].start })
+	return words
+}
+
+// fits reports whether the words make a single cue of the style
+func (s CaptionStyle) fits(words []captionWord) bool {
+	if s.MaxDuration > 0 && words[len(words)-1].end-words[0].start > s.MaxDuration {
+		return false
+	}
+	return s.breakLines(cueTexts(words)) != nil
+}
+
+// phraseBreak returns how many of the words to put in the next cue when
+// only the first n fit. If the rest of the sentence fits in one more cue,
+// the two cues are balanced; otherwise the cue ends on a sentence, then a
+// clause, then before a conjunction or preposition, as long as it keeps at
+// least half of the words that fit.
+func (s CaptionStyle) phraseBreak(words []captionWord, n int) int {
+	rest := n + 1
+	for rest < len(words) && breakScore(words[rest-1].text, "") < 3 && words[rest].start-words[rest-1].end < captionPauseBreak {
+		rest++
+	}
+	if s.fits(words[n:rest]) {
+		best, bestCost := 0, math.Inf(1)
+		for k := n; k > 0 && s.fits(words[k:rest]); k-- {
+			balance := float64(lineLength(cueTexts(words[:k])) - lineLength(cueTexts(words[k:rest])))
+			cost := math.Abs(balance) - 20*float64(breakScore(words[k-1].text, words[k].text))
+			if cost < bestCost {
+				best, bestCost = k, cost
+			}
+		}
+		return best
+	}
+
+	best, bestScore := n, 0
+	for k := n; k >= (n+1)/2 && k > 0; k-- {
+		score := breakScore(words[k-1].text, words[k].text)
+		if score > bestScore {
+			best, bestScore = k, score
+		}
+	}
+	return best
+}
+
+// breakScore rates a break between two words: 3 after a sentence, 2 after
+// a clause, 1 before a conjunction and 0 elsewhere
+func breakScore(before, after string) int {
+	switch {
+	case strings.HasSuffix(before, ".") || strings.HasSuffix(before, "?") || strings.HasSuffix(before, "!"):
+		return 3
+	case strings.HasSuffix(before, ",") || strings.HasSuffix(before, ";") || strings.HasSuffix(before, ":") ||
+		strings.HasSuffix(before, "—") || strings.HasSuffix(before, "-"):
+		return 2
+	case phraseStarts[normalizeWord(after)]:
+		return 1
+	}
+	return 0
+}
+
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.Trim(word, ".,;:!?\"'()[]—-"))
+}
+
+// breakLines splits the words of a cue into as few balanced lines as the
+// style allows, avoiding line ends on weak words. It returns nil if the
+// words do not fit in MaxLines lines; a single word that is longer than a
+// line is returned as is.
+func (s CaptionStyle) breakLines(words []string) []string {
+	if len(words) == 0 {
+		return nil
+	}
+	if lineLength(words) <= s.MaxLineLength || len(words) == 1 {
+		return []string{strings.Join(words, " ")}
+	}
+
+	for lines := 2; lines <= s.MaxLines && lines <= len(words); lines++ {
+		if breaks := s.balancedBreaks(words, lines); breaks != nil {
+			result := make([]string, 0, lines)
+			from := 0
+			for _, to := range breaks {
+				result = append(result, strings.Join(words[from:to], " "))
+				from = to
+			}
+			return result
+		}
+	}
+	return nil
+}
+
+// balancedBreaks finds where to end each of the given number of lines,
+// minimising the difference in line lengths plus a penalty for each line
+// that does not end on punctuation. It returns nil if no split fits.
+func (s CaptionStyle) balancedBreaks(words []string, lines int) []int {
+	n := len(words)
+	target := float64(lineLength(words)) / float64(lines)
+
+	// cost[l][j] is the cheapest way to put words[:j] on l lines
+	inf := math.Inf(1)
+	cost := make([][]float64, lines+1)
+	prev := make([][]int, lines+1)
+	for l := range cost {
+		cost[l] = make([]float64, n+1)
+		prev[l] = make([]int, n+1)
+		for j := range cost[l] {
+			cost[l][j] = inf
+		}
+	}
+	cost[0][0] = 0
+
+	for l := 1; l <= lines; l++ {
+		for j := l; j <= n; j++ {
+			for i := l - 1; i < j; i++ {
+				if math.IsInf(cost[l-1][i], 1) {
+					continue
+				}
+				length := lineLength(words[i:j])
+				if length > s.MaxLineLength {
+					continue
+				}
+				c := cost[l-1][i] + math.Pow(float64(length)-target, 2)
+				if j < n {
+					c += lineEndPenalty(words[j-1])
+				}
+				if c < cost[l][j] {
+					cost[l][j], prev[l][j] = c, i
+				}
+			}
+		}
+	}
+	if math.IsInf(cost[lines][n], 1) {
+		return nil
+	}
+
+	breaks := make([]int, lines)
+	for l, j := lines, n; l > 0; l-- {
+		breaks[l-1] = j
+		j = prev[l][j]
+	}
+	return breaks
+}
+
+// lineEndPenalty discourages line ends away from punctuation, and most of
+// all after words that bind to the next one
+func lineEndPenalty(word string) float64 {
+	switch {
+	case breakScore(word, "") >= 2:
+		return 0
+	case weakLineEnds[normalizeWord(word)]:
+		return 400
+	}
+	return 50
+}
+
+// retime stretches cues that are too short to be read at MaxCPS or shorter
+// than MinDuration into the following silence, keeping MinGap before the
+// next cue, and caps cues at MaxDuration
+func (s CaptionStyle) retime(captions []models.Segment) {
+	for i := range captions {
+		caption := &captions[i]
+		spoken := caption.End
+
+		need := s.MinDuration
+		if s.MaxCPS > 0 {
+			chars := utf8.RuneCountInString(strings.ReplaceAll(caption.Text, "\n", " "))
+			need = math.Max(need, float64(chars)/s.MaxCPS)
+		}
+		if s.MaxDuration > 0 {
+			need = math.Min(need, s.MaxDuration)
+		}
+		if caption.End-caption.Start < need {
+			caption.End = caption.Start + need
+		} else if s.MaxDuration > 0 && caption.End-caption.Start > s.MaxDuration {
+			caption.End = caption.Start + s.MaxDuration
+		}
+
+		if i+1 < len(captions) {
+			limit := captions[i+1].Start - s.MinGap
+			if caption.End > limit {
+				caption.End = math.Max(limit, math.Min(spoken, captions[i+1].Start))
+			}
+		}
+		if caption.End < caption.Start {
+			caption.End = caption.Start
+		}
+	}
+}
+
+func cueTexts(words []captionWord) []string {
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.text
+	}
+	return texts
+}
+
+func allTimed(words []captionWord) bool {
+	for _, w := range words {
+		if !w.timed {
+			return false
+		}
+	}
+	return true
+}
+
+// lineLength is the length in characters of the words joined by spaces
+func lineLength(words []string) int {
+	length := len(words) - 1
+	for _, w := range words {
+		length += utf8.RuneCountInString(w)
+	}
+	return length
+}
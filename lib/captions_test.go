@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+func TestLayoutCaptions_LongSegment(t *testing.T) {
+	segments := []models.Segment{{
+		Start: 8.5,
+		End:   17.2,
+		Text:  " In today's session, we'll explore the revolutionary capabilities of Model Context Protocol and how it integrates seamlessly with Claude's advanced reasoning engine.",
+	}}
+	style := CaptionPresets["netflix"]
+
+	captions := LayoutCaptions(segments, style)
+	require.Greater(t, len(captions), 1)
+
+	var words []string
+	for i, caption := range captions {
+		lines := strings.Split(caption.Text, "\n")
+		assert.LessOrEqual(t, len(lines), style.MaxLines, caption.Text)
+		for _, line := range lines {
+			assert.LessOrEqual(t, utf8.RuneCountInString(line), style.MaxLineLength, line)
+		}
+		assert.LessOrEqual(t, caption.End-caption.Start, style.MaxDuration)
+		if i > 0 {
+			assert.GreaterOrEqual(t, caption.Start, captions[i-1].End)
+		}
+		words = append(words, strings.Fields(caption.Text)...)
+	}
+	assert.Equal(t, strings.Fields(segments[0].Text), words)
+	assert.Equal(t, 8.5, captions[0].Start)
+
+	require.Len(t, captions, 3)
+	// Cues break before prepositions and conjunctions rather than inside
+	// a name, and the end of the sentence is balanced with the cue before
+	assert.Equal(t, []string{
+		"In today's session, we'll explore\nthe revolutionary capabilities",
+		"of Model Context Protocol\nand how it integrates seamlessly",
+		"with Claude's advanced reasoning engine.",
+	}, []string{captions[0].Text, captions[1].Text, captions[2].Text})
+}
+
+func TestLayoutCaptions_WordTimings(t *testing.T) {
+	segments := []models.Segment{{
+		Start: 0,
+		End:   6,
+		Text:  "Hello there. General Kenobi!",
+		Words: []models.Word{
+			{Start: 0, End: 0.2, Text: "Hello"},
+			{Start: 0.2, End: 0.5, Text: "there."},
+			{Start: 3, End: 3.6, Text: "General"},
+			{Start: 3.6, End: 4.2, Text: "Kenobi!"},
+		},
+	}}
+
+	captions := LayoutCaptions(segments, CaptionPresets["netflix"])
+	require.Len(t, captions, 2)
+
+	// The pause splits the cues, and the short first cue is held for the
+	// minimum duration
+	assert.Equal(t, "Hello there.", captions[0].Text)
+	assert.Equal(t, 0.0, captions[0].Start)
+	assert.InDelta(t, 5.0/6, captions[0].End, 1e-9)
+	assert.Len(t, captions[0].Words, 2)
+	assert.Equal(t, "General Kenobi!", captions[1].Text)
+	assert.Equal(t, 3.0, captions[1].Start)
+}
+
+func TestLayoutCaptions_ReadingSpeed(t *testing.T) {
+	text := "This sentence is far too long to read in half a second."
+	style := CaptionPresets["netflix"]
+	segments := []models.Segment{
+		{Start: 0, End: 0.5, Text: text},
+		{Start: 2, End: 3, Text: "Next."},
+	}
+
+	captions := LayoutCaptions(segments, style)
+	require.Len(t, captions, 2)
+	assert.Equal(t, "This sentence is far too long\nto read in half a second.", captions[0].Text)
+	// Stretched towards len/MaxCPS, but kept MinGap before the next cue
+	assert.InDelta(t, 2-style.MinGap, captions[0].End, 1e-9)
+}
+
+func TestCaptionOptions(t *testing.T) {
+	style, err := CaptionOptions{Preset: "bbc"}.Style()
+	require.NoError(t, err)
+	assert.Equal(t, 37, style.MaxLineLength)
+
+	style, err = CaptionOptions{Preset: CaptionPresetNone}.Style()
+	require.NoError(t, err)
+	assert.Nil(t, style)
+
+	style, err = CaptionOptions{Preset: "youtube", MaxLines: 1}.Style()
+	require.NoError(t, err)
+	assert.Equal(t, 1, style.MaxLines)
+	assert.Equal(t, 32, style.MaxLineLength)
+
+	_, err = CaptionOptions{Preset: "cinema"}.Style()
+	assert.ErrorIs(t, err, ErrUnknownCaptionPreset)
+
+	_, err = CaptionOptions{Preset: "netflix", MaxLines: 9}.Style()
+	assert.Error(t, err)
+
+	_, err = ParseCaptionOptions("netflix", "wide", "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"videotranscript-app/models"
+)
+
+// ChapterFormats lists the formats a complete job's chapters can be
+// downloaded in: YouTube description timestamps, a WebVTT chapters track,
+// ffmpeg metadata and JSON
+var ChapterFormats = []string{"txt", "vtt", "ffmetadata", "json"}
+
+var chapterContentTypes = map[string]string{
+	"txt":        "text/plain; charset=utf-8",
+	"vtt":        "text/vtt; charset=utf-8",
+	"ffmetadata": "text/plain; charset=utf-8",
+	"json":       "application/json",
+}
+
+// chaptersJSON is the document served as chapters.json
+type chaptersJSON struct {
+	JobID    string           `json:"job_id"`
+	VideoID  string           `json:"video_id,omitempty"`
+	Chapters []models.Chapter `json:"chapters"`
+}
+
+// ParseChaptersFileName returns the format of a download file name such
+// as "chapters.vtt"
+func ParseChaptersFileName(name string) (string, error) {
+	format, ok := strings.CutPrefix(name, "chapters.")
+	if !ok {
+		return "", ErrUnknownTranscriptFormat
+	}
+	if _, ok := chapterContentTypes[format]; !ok {
+		return "", ErrUnknownTranscriptFormat
+	}
+	return format, nil
+}
+
+// RenderChapters renders a complete job's chapters in the given format.
+// Jobs completed before chapters were kept get them generated from their
+// segments.
+func RenderChapters(job *models.Job, format string) (*TranscriptFile, error) {
+	contentType, ok := chapterContentTypes[format]
+	if !ok {
+		return nil, ErrUnknownTranscriptFormat
+	}
+	if job.Status != models.StatusComplete {
+		return nil, ErrTranscriptNotReady
+	}
+
+	chapters := job.Chapters
+	if chapters == nil {
+		chapters = GenerateChapters(job.Segments, DefaultChapterOptions)
+	}
+
+	var b bytes.Buffer
+	var err error
+	switch format {
+	case "txt":
+		err = WriteYouTubeChapters(&b, chapters)
+	case "vtt":
+		err = WriteVTTChapters(&b, chapters)
+	case "ffmetadata":
+		err = WriteFFMetadata(&b, job.Title, chapters)
+	case "json":
+		if chapters == nil {
+			chapters = []models.Chapter{}
+		}
+		encoder := json.NewEncoder(&b)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(chaptersJSON{JobID: job.ID, VideoID: job.VideoID, Chapters: chapters})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newTranscriptFile(b.Bytes(), contentType, jobFileName(job, "chapters", format)), nil
+}
+
+// WriteYouTubeChapters writes chapters as the timestamp list YouTube turns
+// into chapters when pasted into a video description, such as
+// "0:00 Introduction". YouTube only does so for at least three chapters of
+// ten seconds or more, starting at 0:00.
+func WriteYouTubeChapters(w io.Writer, chapters []models.Chapter) error {
+	long := len(chapters) > 0 && chapters[len(chapters)-1].Start >= 3600
+	b := bufio.NewWriter(w)
+	for _, chapter := range chapters {
+		fmt.Fprintf(b, "%s %s\n", formatYouTubeTime(chapter.Start, long), oneLine(chapter.Title))
+	}
+	return b.Flush()
+}
+
+// formatYouTubeTime formats seconds as M:SS, or as H:MM:SS for videos of
+// an hour or more. Times are truncated so no chapter starts late.
+func formatYouTubeTime(seconds float64, long bool) string {
+	total := int64(math.Max(0, seconds))
+	if long {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// WriteVTTChapters writes chapters as a WebVTT chapters track, one cue per
+// chapter titled with its name, for <track kind="chapters">
+func WriteVTTChapters(w io.Writer, chapters []models.Chapter) error {
+	b := bufio.NewWriter(w)
+	b.WriteString("WEBVTT\n\n")
+	for i, chapter := range chapters {
+		fmt.Fprintf(b, "Chapter %d\n%s --> %s\n%s\n\n", i+1, formatVTTTime(chapter.Start), formatVTTTime(chapter.End), escapeVTTText(oneLine(chapter.Title)))
+	}
+	return b.Flush()
+}
+
+// WriteFFMetadata writes chapters as an ffmpeg metadata file, which
+// `ffmpeg -i video.mp4 -i chapters.ffmetadata -map_metadata 1 -codec copy`
+// embeds into a video
+func WriteFFMetadata(w io.Writer, title string, chapters []models.Chapter) error {
+	b := bufio.NewWriter(w)
+	b.WriteString(";FFMETADATA1\n")
+	if title != "" {
+		fmt.Fprintf(b, "title=%s\n", ffmetadataEscape(title))
+	}
+	for _, chapter := range chapters {
+		fmt.Fprintf(b, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			int64(math.Round(chapter.Start*1000)), int64(math.Round(chapter.End*1000)), ffmetadataEscape(chapter.Title))
+	}
+	return b.Flush()
+}
+
+// ffmetadataEscape escapes the characters ffmpeg metadata files give a
+// meaning to with a backslash
+func ffmetadataEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, `;`, `\;`, `#`, `\#`, "\n", "\\\n").Replace(value)
+}
+
+// oneLine joins the lines of a title for line-based formats
+func oneLine(title string) string {
+	return strings.Join(strings.Fields(title), " ")
+}
@@ -0,0 +1,382 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"videotranscript-app/models"
+)
+
+// ChapterOptions control how chapters are generated from a transcript
+type ChapterOptions struct {
+	// MinDuration is the shortest chapter in seconds
+	MinDuration float64
+	// MaxChapters caps the number of chapters
+	MaxChapters int
+	// BlockSize is the number of content words compared on either side of
+	// a candidate boundary
+	BlockSize int
+	// TitleWords is the number of keywords a generated title names
+	TitleWords int
+}
+
+// DefaultChapterOptions suit videos of a few minutes to a few hours
+var DefaultChapterOptions = ChapterOptions{MinDuration: 60, MaxChapters: 20, BlockSize: 40, TitleWords: 3}
+
+// GenerateChapters splits a transcript into chapters where its topic
+// changes, TextTiling style: the vocabulary of the blocks before and after
+// every segment boundary is compared, and boundaries in the deepest valleys
+// of that lexical cohesion become chapter starts. Chapters are titled with
+// their most distinctive keywords. It runs offline and is deterministic.
+// The first chapter starts at 0 and the last ends with the last segment.
+func GenerateChapters(segments []models.Segment, opts ChapterOptions) []models.Chapter {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	surface := map[string]map[string]int{}
+	terms := make([][]string, len(segments))
+	for i, segment := range segments {
+		terms[i] = chapterTerms(segment.Text, surface)
+	}
+
+	starts := append([]int{0}, chapterBoundaries(segments, terms, opts)...)
+	titles := chapterTitles(terms, starts, surface, opts.TitleWords)
+
+	chapters := make([]models.Chapter, len(starts))
+	for k, first := range starts {
+		chapter := models.Chapter{Start: segments[first].Start, End: segments[len(segments)-1].End, Title: titles[k]}
+		if k == 0 {
+			chapter.Start = 0
+		}
+		if k+1 < len(starts) {
+			chapter.End = segments[starts[k+1]].Start
+		}
+		chapters[k] = chapter
+	}
+	return chapters
+}
+
+// chapterBoundaries returns the indexes of the segments that start a new
+// chapter, in order
+func chapterBoundaries(segments []models.Segment, terms [][]string, opts ChapterOptions) []int {
+	gaps := len(segments) - 1
+	if gaps < 1 || opts.MaxChapters == 1 {
+		return nil
+	}
+
+	// Cohesion across the gap after each segment, smoothed over neighbours
+	similarity := make([]float64, gaps)
+	for g := range similarity {
+		similarity[g] = cosineSimilarity(termBlock(terms, g, -1, opts.BlockSize), termBlock(terms, g+1, 1, opts.BlockSize))
+	}
+	smoothed := make([]float64, gaps)
+	for g := range smoothed {
+		lo, hi := max(g-1, 0), min(g+1, gaps-1)
+		sum := 0.0
+		for i := lo; i <= hi; i++ {
+			sum += similarity[i]
+		}
+		smoothed[g] = sum / float64(hi-lo+1)
+	}
+
+	// Score each valley by how far cohesion rises on both sides of it
+	type valley struct {
+		gap   int
+		depth float64
+	}
+	var valleys []valley
+	for g, s := range smoothed {
+		if (g > 0 && smoothed[g-1] < s) || (g < gaps-1 && smoothed[g+1] < s) {
+			continue
+		}
+		left, right := g, g
+		for left > 0 && smoothed[left-1] >= smoothed[left] {
+			left--
+		}
+		for right < gaps-1 && smoothed[right+1] >= smoothed[right] {
+			right++
+		}
+		if depth := smoothed[left] - s + smoothed[right] - s; depth > 0 {
+			valleys = append(valleys, valley{gap: g, depth: depth})
+		}
+	}
+	if len(valleys) == 0 {
+		return nil
+	}
+
+	// Hearst's cutoff keeps valleys deeper than the mean less half a
+	// standard deviation
+	mean, variance := 0.0, 0.0
+	for _, v := range valleys {
+		mean += v.depth
+	}
+	mean /= float64(len(valleys))
+	for _, v := range valleys {
+		variance += (v.depth - mean) * (v.depth - mean)
+	}
+	cutoff := mean - math.Sqrt(variance/float64(len(valleys)))/2
+
+	// Take the deepest valleys first, keeping chapters at least
+	// MinDuration long
+	sort.SliceStable(valleys, func(i, j int) bool { return valleys[i].depth > valleys[j].depth })
+	end := segments[len(segments)-1].End
+	var boundaries []int
+	for _, v := range valleys {
+		if v.depth < cutoff || (opts.MaxChapters > 0 && len(boundaries) >= opts.MaxChapters-1) {
+			break
+		}
+		start := segments[v.gap+1].Start
+		if start < opts.MinDuration || end-start < opts.MinDuration {
+			continue
+		}
+		spaced := true
+		for _, b := range boundaries {
+			if math.Abs(segments[b].Start-start) < opts.MinDuration {
+				spaced = false
+				break
+			}
+		}
+		if spaced {
+			boundaries = append(boundaries, v.gap+1)
+		}
+	}
+	sort.Ints(boundaries)
+	return boundaries
+}
+
+// termBlock counts the terms of the segments from index i in direction
+// step until it holds size terms or runs out of segments
+func termBlock(terms [][]string, i, step, size int) map[string]int {
+	block := map[string]int{}
+	n := 0
+	for ; i >= 0 && i < len(terms) && n < size; i += step {
+		for _, term := range terms[i] {
+			block[term]++
+		}
+		n += len(terms[i])
+	}
+	return block
+}
+
+// cosineSimilarity compares two term blocks, from 0 for no shared terms to
+// 1 for the same distribution
+func cosineSimilarity(a, b map[string]int) float64 {
+	var dot, normA, normB float64
+	for term, count := range a {
+		dot += float64(count * b[term])
+		normA += float64(count * count)
+	}
+	for _, count := range b {
+		normB += float64(count * count)
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// chapterTitles names each chapter, starting at the given segments, after
+// the terms it uses often that the other chapters use least
+func chapterTitles(terms [][]string, starts []int, surface map[string]map[string]int, words int) []string {
+	counts := make([]map[string]int, len(starts))
+	firstSeen := make([]map[string]int, len(starts))
+	df := map[string]int{}
+	for k, first := range starts {
+		last := len(terms)
+		if k+1 < len(starts) {
+			last = starts[k+1]
+		}
+		counts[k], firstSeen[k] = map[string]int{}, map[string]int{}
+		for i := first; i < last; i++ {
+			for _, term := range terms[i] {
+				if counts[k][term] == 0 {
+					firstSeen[k][term] = i
+					df[term]++
+				}
+				counts[k][term]++
+			}
+		}
+	}
+
+	titles := make([]string, len(starts))
+	for k := range starts {
+		type keyword struct {
+			term  string
+			score float64
+		}
+		var keywords []keyword
+		repeated := false
+		for term, count := range counts[k] {
+			repeated = repeated || count > 1
+			keywords = append(keywords, keyword{term, float64(count) * math.Log(1+float64(len(starts))/float64(df[term]))})
+		}
+		sort.Slice(keywords, func(i, j int) bool {
+			if keywords[i].score != keywords[j].score {
+				return keywords[i].score > keywords[j].score
+			}
+			return firstSeen[k][keywords[i].term] < firstSeen[k][keywords[j].term] ||
+				firstSeen[k][keywords[i].term] == firstSeen[k][keywords[j].term] && keywords[i].term < keywords[j].term
+		})
+
+		var names []string
+		for _, kw := range keywords {
+			if len(names) == words {
+				break
+			}
+			if repeated && counts[k][kw.term] < 2 {
+				continue
+			}
+			names = append(names, titleCase(surfaceForm(surface[kw.term])))
+		}
+		titles[k] = joinTitle(names, k)
+	}
+	return titles
+}
+
+// joinTitle lists keywords as "A, B and C", or numbers the chapter when it
+// has none
+func joinTitle(names []string, k int) string {
+	switch len(names) {
+	case 0:
+		return fmt.Sprintf("Chapter %d", k+1)
+	case 1:
+		return names[0]
+	default:
+		return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+	}
+}
+
+var chapterWordRegex = regexp.MustCompile(`[\p{L}\p{N}]+(?:'\p{L}+)?`)
+
+// chapterTerms returns the stemmed content words of a text, counting the
+// spelling of each stem in surface
+func chapterTerms(text string, surface map[string]map[string]int) []string {
+	text = strings.ToLower(strings.ReplaceAll(text, "’", "'"))
+	var terms []string
+	for _, word := range chapterWordRegex.FindAllString(text, -1) {
+		word = strings.TrimSuffix(word, "'s")
+		if utf8.RuneCountInString(word) < 3 || chapterStopwords[word] || strings.IndexFunc(word, unicode.IsLetter) < 0 {
+			continue
+		}
+		term := stemChapterTerm(word)
+		if surface[term] == nil {
+			surface[term] = map[string]int{}
+		}
+		surface[term][word]++
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// stemChapterTerm folds plurals onto their singular
+func stemChapterTerm(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us"):
+		return word[:len(word)-1]
+	default:
+		return word
+	}
+}
+
+// surfaceForm returns the most used spelling of a stem
+func surfaceForm(spellings map[string]int) string {
+	best := ""
+	for word, count := range spellings {
+		if best == "" || count > spellings[best] || count == spellings[best] && word < best {
+			best = word
+		}
+	}
+	return best
+}
+
+func titleCase(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[size:]
+}
+
+// chapterStopwords are words that say nothing about a chapter's topic,
+// including the fillers of spoken language
+var chapterStopwords = func() map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.Fields(`
+		about above after again against all also and any are aren't around as
+		because been before being below between both but by can can't cannot
+		come could couldn't did didn't does doesn't doing don't down during each
+		even every few for from further get gets getting go goes going gonna got
+		had hadn't has hasn't have haven't having her here hers herself him
+		himself his how i'd i'll i'm i've into isn't it'll its itself just
+		kind know let let's like lot lots make many may maybe me mean more most
+		much must my myself need now off okay once one only other our ours
+		ourselves out over own really right said same say says see she should
+		shouldn't so some something sort still such sure take than that the
+		their theirs them themselves then there these they they'd they'll
+		they're they've thing things think this those though through too under
+		until very want wanna was wasn't way we'd we'll we're we've well were
+		weren't what when where which while who whom why will with won't would
+		wouldn't yeah yes yet you you'd you'll you're you've your yours
+		yourself yourselves actually basically going kinda pretty stuff
+		there's here's what's that's who's it's uh um hmm oh two three four
+		five six seven eight nine ten hundred thousand first second third
+	`) {
+		words[word] = true
+	}
+	return words
+}()
+
+// videoInfo is the part of yt-dlp's info JSON the pipeline reads
+type videoInfo struct {
+	Duration float64 `json:"duration"`
+	Chapters []struct {
+		StartTime float64 `json:"start_time"`
+		EndTime   float64 `json:"end_time"`
+		Title     string  `json:"title"`
+	} `json:"chapters"`
+}
+
+// parseInfoChapters returns the chapters of a yt-dlp info JSON document,
+// or nil if the video has none
+func parseInfoChapters(data []byte) ([]models.Chapter, error) {
+	var info videoInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse video info: %w", err)
+	}
+	var chapters []models.Chapter
+	for i, chapter := range info.Chapters {
+		title := strings.TrimSpace(chapter.Title)
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		end := chapter.EndTime
+		if end <= chapter.StartTime {
+			end = info.Duration
+		}
+		chapters = append(chapters, models.Chapter{Start: chapter.StartTime, End: end, Title: title})
+	}
+	return chapters, nil
+}
+
+// jobChapters returns the chapters of the source video, read from the
+// yt-dlp info JSON at infoPath, and otherwise generates them from segments
+func jobChapters(infoPath string, segments []models.Segment) []models.Chapter {
+	data, err := os.ReadFile(infoPath)
+	if err == nil {
+		var chapters []models.Chapter
+		if chapters, err = parseInfoChapters(data); err == nil && len(chapters) > 0 {
+			return chapters
+		}
+	}
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Warning: failed to read source chapters: %v\n", err)
+	}
+	return GenerateChapters(segments, DefaultChapterOptions)
+}
@@ -0,0 +1,189 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+// topicSegments returns ten-second segments that talk about three topics in
+// turn, with the filler words of a spoken transcript
+func topicSegments() []models.Segment {
+	topics := [][]string{
+		{
+			"Welcome back to the kitchen, today we are making fresh pasta from scratch.",
+			"You need flour and eggs for the pasta dough, that's really all.",
+			"Knead the dough until it is smooth, then let the dough rest.",
+			"Roll the pasta dough thin and cut it into ribbons.",
+			"Fresh pasta cooks fast in salted boiling water.",
+			"Meanwhile the tomato sauce simmers with garlic and basil.",
+			"Toss the pasta with the sauce and a little pasta water.",
+			"Grate some parmesan over the pasta and serve it right away.",
+		},
+		{
+			"Now let's talk about the football match last night.",
+			"The home team pressed high and the striker scored early.",
+			"The keeper made three great saves in the first half.",
+			"After the break the away team changed their formation.",
+			"The striker hit the post and the crowd went quiet.",
+			"A late goal from a corner won the match for the home team.",
+			"The coach praised the keeper and the defence after the match.",
+			"The team plays again on Saturday in the league.",
+		},
+		{
+			"Finally, a few words about the stock market this week.",
+			"Shares of chip makers rallied as investors bought the dip.",
+			"Bond yields fell and the market priced in a rate cut.",
+			"Bank shares lagged while investors weighed the earnings reports.",
+			"Oil prices rose and energy shares followed the market up.",
+			"Analysts expect the market to stay volatile into the earnings season.",
+			"Investors should keep an eye on inflation data next week.",
+			"That's all for the market today, thanks for watching.",
+		},
+	}
+
+	var segments []models.Segment
+	for _, topic := range topics {
+		for _, text := range topic {
+			start := float64(len(segments) * 10)
+			segments = append(segments, models.Segment{Start: start, End: start + 9.5, Text: text})
+		}
+	}
+	return segments
+}
+
+func TestGenerateChapters(t *testing.T) {
+	segments := topicSegments()
+
+	chapters := GenerateChapters(segments, ChapterOptions{MinDuration: 30, MaxChapters: 10, BlockSize: 30, TitleWords: 2})
+	assert.Equal(t, []models.Chapter{
+		{Start: 0, End: 80, Title: "Pasta and Dough"},
+		{Start: 80, End: 160, Title: "Team and Match"},
+		{Start: 160, End: 239.5, Title: "Market and Investors"},
+	}, chapters)
+
+	// Chapters are at least MinDuration long and capped at MaxChapters
+	assert.Len(t, GenerateChapters(segments, ChapterOptions{MinDuration: 100, BlockSize: 30, TitleWords: 2}), 1)
+	assert.Len(t, GenerateChapters(segments, ChapterOptions{MinDuration: 30, MaxChapters: 2, BlockSize: 30, TitleWords: 2}), 2)
+
+	// A short transcript is a single chapter
+	chapters = GenerateChapters(segments[:3], DefaultChapterOptions)
+	require.Len(t, chapters, 1)
+	assert.Equal(t, models.Chapter{Start: 0, End: 29.5, Title: "Dough and Pasta"}, chapters[0])
+
+	assert.Nil(t, GenerateChapters(nil, DefaultChapterOptions))
+}
+
+func TestChapterTerms(t *testing.T) {
+	surface := map[string]map[string]int{}
+	assert.Equal(t, []string{"striker", "scored", "goal", "match", "party"},
+		chapterTerms("So the striker's scored two goals, it's the match of the 2024 parties!", surface))
+	assert.Equal(t, map[string]int{"parties": 1}, surface["party"])
+}
+
+func TestParseInfoChapters(t *testing.T) {
+	chapters, err := parseInfoChapters([]byte(`{
+		"id": "dQw4w9WgXcQ",
+		"duration": 212,
+		"chapters": [
+			{"start_time": 0, "end_time": 18.5, "title": "Intro"},
+			{"start_time": 18.5, "end_time": 0, "title": "  "}
+		]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, []models.Chapter{
+		{Start: 0, End: 18.5, Title: "Intro"},
+		{Start: 18.5, End: 212, Title: "Chapter 2"},
+	}, chapters)
+
+	chapters, err = parseInfoChapters([]byte(`{"duration": 212, "chapters": null}`))
+	require.NoError(t, err)
+	assert.Nil(t, chapters)
+
+	_, err = parseInfoChapters([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestWriteChapters(t *testing.T) {
+	chapters := []models.Chapter{
+		{Start: 0, End: 65.25, Title: "Intro"},
+		{Start: 65.25, End: 3725, Title: "Q&A <live>\nsession"},
+		{Start: 3725, End: 3800, Title: "Outro; credits = #1"},
+	}
+
+	var b strings.Builder
+	require.NoError(t, WriteYouTubeChapters(&b, chapters))
+	assert.Equal(t, "0:00:00 Intro\n0:01:05 Q&A <live> session\n1:02:05 Outro; credits = #1\n", b.String())
+
+	b.Reset()
+	require.NoError(t, WriteYouTubeChapters(&b, chapters[:2]))
+	assert.Equal(t, "0:00 Intro\n1:05 Q&A <live> session\n", b.String())
+
+	b.Reset()
+	require.NoError(t, WriteVTTChapters(&b, chapters[:2]))
+	assert.Equal(t, `WEBVTT
+
+Chapter 1
+00:00:00.000 --> 00:01:05.250
+Intro
+
+Chapter 2
+00:01:05.250 --> 01:02:05.000
+Q&amp;A &lt;live&gt; session
+
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, WriteFFMetadata(&b, "Demo", chapters[1:]))
+	assert.Equal(t, `;FFMETADATA1
+title=Demo
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=65250
+END=3725000
+title=Q&A <live>\
+session
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=3725000
+END=3800000
+title=Outro\; credits \= \#1
+`, b.String())
+}
+
+func TestRenderChapters(t *testing.T) {
+	job := models.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	_, err := RenderChapters(job, "txt")
+	assert.ErrorIs(t, err, ErrTranscriptNotReady)
+
+	require.NoError(t, job.MarkRunning())
+	require.NoError(t, job.MarkComplete("transcript", topicSegments()))
+
+	// Jobs without stored chapters get them generated
+	file, err := RenderChapters(job, "txt")
+	require.NoError(t, err)
+	assert.Equal(t, "chapters_dQw4w9WgXcQ.txt", file.Filename)
+	assert.Equal(t, "text/plain; charset=utf-8", file.ContentType)
+	assert.Equal(t, "0:00 Pasta, Dough and Fresh\n1:20 Team, Match and Home\n2:40 Market, Investors and Shares\n", string(file.Content))
+
+	job.Chapters = []models.Chapter{{Start: 0, End: 239.5, Title: "Everything"}}
+	file, err = RenderChapters(job, "json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"job_id": "`+job.ID+`", "video_id": "dQw4w9WgXcQ", "chapters": [{"start": 0, "end": 239.5, "title": "Everything"}]}`, string(file.Content))
+	assert.NotEmpty(t, file.ETag)
+
+	_, err = RenderChapters(job, "srt")
+	assert.ErrorIs(t, err, ErrUnknownTranscriptFormat)
+
+	format, err := ParseChaptersFileName("chapters.ffmetadata")
+	require.NoError(t, err)
+	assert.Equal(t, "ffmetadata", format)
+	_, err = ParseChaptersFileName("transcript.vtt")
+	assert.ErrorIs(t, err, ErrUnknownTranscriptFormat)
+}
@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lrstanley/go-ytdlp"
+
+	"videotranscript-app/models"
+)
+
+// channelTabRegex matches channel URLs that already name a tab
+var channelTabRegex = regexp.MustCompile(`/(videos|streams|shorts|featured)/?$`)
+
+// videoIDRegex matches YouTube video IDs, telling videos apart from nested
+// playlists and channel tabs in a flat listing
+var videoIDRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{11}$`)
+
+// flatPlaylist is the part of yt-dlp's --flat-playlist JSON output we use
+type flatPlaylist struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Entries []struct {
+		ID         string  `json:"id"`
+		Title      string  `json:"title"`
+		Duration   float64 `json:"duration"`
+		UploadDate string  `json:"upload_date"`
+		Timestamp  int64   `json:"timestamp"`
+	} `json:"entries"`
+}
+
+// ExpandCollection lists the videos of a playlist or channel with yt-dlp's
+// --flat-playlist, without downloading them, and selects the ones opts
+// allow. skip rejects further entries, such as videos already transcribed,
+// and may be nil. Failures are returned as classified *models.JobError values.
+func ExpandCollection(ctx context.Context, url string, opts models.CollectionOptions, skip func(models.CollectionEntry) bool) (*models.Collection, error) {
+	limit := 0
+	if !opts.HasDateRange() && skip == nil {
+		limit = opts.Limit()
+	}
+
+	collection, err := listCollection(ctx, url, limit, opts.HasDateRange())
+	if err != nil {
+		return nil, err
+	}
+	collection.Entries = opts.Select(collection.Entries, skip)
+	return collection, nil
+}
+
+// ListCollection lists the first limit videos of a playlist or channel,
+// or all of them if limit is 0.
+func ListCollection(ctx context.Context, url string, limit int) (*models.Collection, error) {
+	return listCollection(ctx, url, limit, false)
+}
+
+func listCollection(ctx context.Context, url string, limit int, withDates bool) (*models.Collection, error) {
+	kind := models.CollectionKindOf(url)
+	if kind == "" {
+		return nil, fmt.Errorf("not a playlist or channel URL: %s", url)
+	}
+
+	dl := ytdlp.New().
+		FlatPlaylist().
+		DumpSingleJSON().
+		NoWarnings()
+	if withDates {
+		// Flat listings only carry upload dates when yt-dlp approximates them
+		dl = dl.ExtractorArgs("youtubetab:approximate_date")
+	}
+	if limit > 0 {
+		dl = dl.PlaylistEnd(limit)
+	}
+
+	result, err := dl.Run(ctx, collectionListURL(kind, url))
+	if err != nil {
+		var stderr string
+		if result != nil {
+			stderr = result.Stderr
+		}
+		return nil, classifyDownloadError(fmt.Errorf("failed to list %s: yt-dlp failed: %w", kind, err), stderr)
+	}
+	if result.ExitCode != 0 {
+		return nil, classifyDownloadError(fmt.Errorf("failed to list %s: yt-dlp failed with code %d: %s", kind, result.ExitCode, result.Stderr), result.Stderr)
+	}
+
+	return parseFlatPlaylist(kind, url, []byte(result.Stdout))
+}
+
+// collectionListURL points channel URLs at their videos tab, as the channel
+// root lists its tabs rather than its videos.
+func collectionListURL(kind models.CollectionKind, url string) string {
+	if kind != models.CollectionChannel || channelTabRegex.MatchString(url) {
+		return url
+	}
+	return strings.TrimSuffix(url, "/") + "/videos"
+}
+
+// parseFlatPlaylist converts yt-dlp's --flat-playlist JSON into a
+// collection, keeping only video entries.
+func parseFlatPlaylist(kind models.CollectionKind, url string, data []byte) (*models.Collection, error) {
+	var playlist flatPlaylist
+	if err := json.Unmarshal(data, &playlist); err != nil {
+		return nil, models.NewJobError(models.ErrCodeDecode, fmt.Errorf("failed to parse %s listing: %w", kind, err))
+	}
+
+	collection := &models.Collection{
+		Kind:    kind,
+		URL:     url,
+		ID:      playlist.ID,
+		Title:   playlist.Title,
+		Entries: make([]models.CollectionEntry, 0, len(playlist.Entries)),
+	}
+	for _, entry := range playlist.Entries {
+		if !videoIDRegex.MatchString(entry.ID) {
+			continue
+		}
+
+		item := models.CollectionEntry{
+			VideoID:  entry.ID,
+			URL:      "https://www.youtube.com/watch?v=" + entry.ID,
+			Title:    entry.Title,
+			Duration: entry.Duration,
+		}
+		if uploaded, err := time.Parse("20060102", entry.UploadDate); err == nil {
+			item.UploadedAt = uploaded
+		} else if entry.Timestamp > 0 {
+			item.UploadedAt = time.Unix(entry.Timestamp, 0).UTC()
+		}
+		collection.Entries = append(collection.Entries, item)
+	}
+	return collection, nil
+}
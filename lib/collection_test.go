@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+func TestParseFlatPlaylist(t *testing.T) {
+	output := `{
+		"id": "PL590L5WQmH8fJ54F369BLDSqIwcs-TCfs",
+		"title": "Lecture Series",
+		"_type": "playlist",
+		"entries": [
+			{"_type": "url", "id": "dQw4w9WgXcQ", "title": "Lecture 1", "duration": 3120, "upload_date": "20240105"},
+			{"_type": "url", "id": "9bZkp7q19f0", "title": "Lecture 2", "timestamp": 1705312800},
+			{"_type": "url", "id": "UCEBb1b_L6zDS3xTUrIALZOw", "title": "Shorts"}
+		]
+	}`
+
+	collection, err := parseFlatPlaylist(models.CollectionPlaylist, "https://www.youtube.com/playlist?list=PL590L5WQmH8fJ54F369BLDSqIwcs-TCfs", []byte(output))
+	require.NoError(t, err)
+	assert.Equal(t, "Lecture Series", collection.Title)
+	require.Len(t, collection.Entries, 2)
+
+	first := collection.Entries[0]
+	assert.Equal(t, "https://www.youtube.com/watch?v=dQw4w9WgXcQ", first.URL)
+	assert.Equal(t, 3120.0, first.Duration)
+	assert.Equal(t, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), first.UploadedAt)
+	assert.Equal(t, time.Unix(1705312800, 0).UTC(), collection.Entries[1].UploadedAt)
+
+	_, err = parseFlatPlaylist(models.CollectionPlaylist, "", []byte("not json"))
+	assert.Equal(t, models.ErrCodeDecode, models.ErrorCodeOf(err))
+}
+
+func TestCollectionListURL(t *testing.T) {
+	assert.Equal(t, "https://youtube.com/@mitocw/videos", collectionListURL(models.CollectionChannel, "https://youtube.com/@mitocw/"))
+	assert.Equal(t, "https://youtube.com/@mitocw/streams", collectionListURL(models.CollectionChannel, "https://youtube.com/@mitocw/streams"))
+	assert.Equal(t, "https://www.youtube.com/playlist?list=PL1", collectionListURL(models.CollectionPlaylist, "https://www.youtube.com/playlist?list=PL1"))
+}
@@ -0,0 +1,252 @@
+package lib
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net/url"
+	"strings"
+
+	"videotranscript-app/models"
+)
+
+// TranscriptDocument is a transcript laid out in paragraphs for reading,
+// as rendered by the txt, md, html and docx formats
+type TranscriptDocument struct {
+	Title    string
+	URL      string
+	VideoID  string
+	Language string
+	// Paragraphs hold the transcript text, with start times for the marked
+	// ones linking back to the source video
+	Paragraphs []Paragraph
+}
+
+// NewTranscriptDocument lays a job's segments out in paragraphs. Jobs
+// without segments become a single paragraph of their transcript text.
+func NewTranscriptDocument(job *models.Job, opts ParagraphOptions) *TranscriptDocument {
+	doc := &TranscriptDocument{
+		Title:      job.Title,
+		URL:        job.URL,
+		VideoID:    job.VideoID,
+		Language:   job.Language,
+		Paragraphs: BuildParagraphs(job.Segments, opts),
+	}
+	if doc.Title == "" {
+		doc.Title = "Transcript"
+	}
+	if len(doc.Paragraphs) == 0 {
+		if text := strings.Join(strings.Fields(job.Transcript), " "); text != "" {
+			doc.Paragraphs = []Paragraph{{Text: text}}
+		}
+	}
+	return doc
+}
+
+// timeLabel formats a paragraph's start time, with hours for documents of
+// an hour or more
+func (d *TranscriptDocument) timeLabel(p Paragraph) string {
+	long := len(d.Paragraphs) > 0 && d.Paragraphs[len(d.Paragraphs)-1].End >= 3600
+	return formatYouTubeTime(p.Start, long)
+}
+
+// TimestampURL links to a moment of the source video with its t parameter,
+// such as https://www.youtube.com/watch?v={video_id}&t=65s. It returns ""
+// when the document has no usable source URL.
+func (d *TranscriptDocument) TimestampURL(seconds float64) string {
+	t := fmt.Sprintf("%ds", int64(math.Max(0, seconds)))
+	if videoIDRegex.MatchString(d.VideoID) {
+		return "https://www.youtube.com/watch?v=" + d.VideoID + "&t=" + t
+	}
+	u, err := url.Parse(d.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	query := u.Query()
+	query.Set("t", t)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// WriteText writes the document as plain text, one paragraph per block
+// with "[1:05]" timestamps and "Speaker:" labels where marked
+func (d *TranscriptDocument) WriteText(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	for i, p := range d.Paragraphs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if p.Timestamp {
+			fmt.Fprintf(b, "[%s] ", d.timeLabel(p))
+		}
+		if p.Speaker != "" {
+			fmt.Fprintf(b, "%s: ", p.Speaker)
+		}
+		fmt.Fprintf(b, "%s\n", p.Text)
+	}
+	return b.Flush()
+}
+
+// WriteMarkdown writes the document as Markdown under a title heading, with
+// timestamps linking to the source video
+func (d *TranscriptDocument) WriteMarkdown(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# %s\n", markdownEscape(oneLine(d.Title)))
+	if d.URL != "" {
+		fmt.Fprintf(b, "\nSource: <%s>\n", d.URL)
+	}
+	for _, p := range d.Paragraphs {
+		b.WriteString("\n")
+		if p.Timestamp {
+			label := d.timeLabel(p)
+			if link := d.TimestampURL(p.Start); link != "" {
+				fmt.Fprintf(b, "[%s](%s) ", label, link)
+			} else {
+				fmt.Fprintf(b, "%s ", label)
+			}
+		}
+		if p.Speaker != "" {
+			fmt.Fprintf(b, "**%s:** ", markdownEscape(p.Speaker))
+		}
+		fmt.Fprintf(b, "%s\n", markdownEscape(p.Text))
+	}
+	return b.Flush()
+}
+
+// markdownEscape escapes the characters that would format transcript text
+// as Markdown or inline HTML
+func markdownEscape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `#`, `\#`,
+	).Replace(text)
+}
+
+// documentCSS styles the standalone HTML document for reading and printing
+const documentCSS = `body { max-width: 42em; margin: 2em auto; padding: 0 1em; font: 17px/1.6 Georgia, serif; color: #222; }
+h1 { font: bold 1.6em/1.2 system-ui, sans-serif; }
+.source { font: 0.85em system-ui, sans-serif; color: #666; }
+.timestamp { font: 0.8em ui-monospace, monospace; color: #06c; text-decoration: none; margin-right: 0.5em; }
+.speaker { font-weight: bold; }
+`
+
+// WriteHTML writes the document as a standalone HTML page whose timestamps
+// link to the source video
+func (d *TranscriptDocument) WriteHTML(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	b.WriteString("<!DOCTYPE html>\n")
+	if d.Language != "" {
+		fmt.Fprintf(b, "<html lang=\"%s\">\n", html.EscapeString(d.Language))
+	} else {
+		b.WriteString("<html>\n")
+	}
+	fmt.Fprintf(b, "<head>\n<meta charset=\"utf-8\">\n<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n",
+		html.EscapeString(d.Title), documentCSS)
+	fmt.Fprintf(b, "<h1>%s</h1>\n", html.EscapeString(d.Title))
+	if d.URL != "" {
+		fmt.Fprintf(b, "<p class=\"source\"><a href=\"%s\">%s</a></p>\n", html.EscapeString(d.URL), html.EscapeString(d.URL))
+	}
+	for _, p := range d.Paragraphs {
+		b.WriteString("<p>")
+		if p.Timestamp {
+			label := d.timeLabel(p)
+			if link := d.TimestampURL(p.Start); link != "" {
+				fmt.Fprintf(b, "<a class=\"timestamp\" href=\"%s\">%s</a>", html.EscapeString(link), label)
+			} else {
+				fmt.Fprintf(b, "<span class=\"timestamp\">%s</span>", label)
+			}
+		}
+		if p.Speaker != "" {
+			fmt.Fprintf(b, "<span class=\"speaker\">%s:</span> ", html.EscapeString(p.Speaker))
+		}
+		fmt.Fprintf(b, "%s</p>\n", html.EscapeString(p.Text))
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.Flush()
+}
+
+const (
+	docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/><Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/><Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/></Types>`
+
+	docxPackageRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/></Relationships>`
+
+	docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:cs="Calibri"/><w:sz w:val="24"/></w:rPr></w:rPrDefault><w:pPrDefault><w:pPr><w:spacing w:after="200" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults><w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style><w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:spacing w:after="300"/></w:pPr><w:rPr><w:b/><w:sz w:val="48"/></w:rPr></w:style><w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0563C1"/><w:u w:val="single"/></w:rPr></w:style></w:styles>`
+)
+
+// WriteDOCX writes the document as a Word document whose timestamps are
+// hyperlinks to the source video. Archive entries carry no modification
+// time, so the same document always renders to the same bytes.
+func (d *TranscriptDocument) WriteDOCX(w io.Writer) error {
+	var body, rels strings.Builder
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+
+	fmt.Fprintf(&body, `<w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr>%s</w:p>`, docxRun(d.Title, ""))
+	links := 1
+	for _, p := range d.Paragraphs {
+		body.WriteString("<w:p>")
+		if p.Timestamp {
+			label := d.timeLabel(p)
+			if link := d.TimestampURL(p.Start); link != "" {
+				links++
+				fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`, links, xmlEscape(link))
+				fmt.Fprintf(&body, `<w:hyperlink r:id="rId%d">%s</w:hyperlink>%s`, links, docxRun(label, `<w:rStyle w:val="Hyperlink"/>`), docxRun(" ", ""))
+			} else {
+				body.WriteString(docxRun(label+" ", ""))
+			}
+		}
+		if p.Speaker != "" {
+			body.WriteString(docxRun(p.Speaker+": ", "<w:b/>"))
+		}
+		body.WriteString(docxRun(p.Text, ""))
+		body.WriteString("</w:p>")
+	}
+	rels.WriteString("</Relationships>")
+
+	document := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>` +
+		body.String() +
+		`<w:sectPr><w:pgSz w:w="12240" w:h="15840"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="720" w:footer="720" w:gutter="0"/></w:sectPr></w:body></w:document>`
+
+	core := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>` +
+		xmlEscape(d.Title) + `</dc:title>`
+	if d.Language != "" {
+		core += `<dc:language>` + xmlEscape(d.Language) + `</dc:language>`
+	}
+	core += `</cp:coreProperties>`
+
+	archive := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxPackageRels},
+		{"docProps/core.xml", core},
+		{"word/document.xml", document},
+		{"word/styles.xml", docxStyles},
+		{"word/_rels/document.xml.rels", rels.String()},
+	}
+	for _, part := range parts {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// docxRun is a run of text with optional run properties, keeping its
+// leading and trailing spaces
+func docxRun(text, properties string) string {
+	if properties != "" {
+		properties = "<w:rPr>" + properties + "</w:rPr>"
+	}
+	return fmt.Sprintf(`<w:r>%s<w:t xml:space="preserve">%s</w:t></w:r>`, properties, xmlEscape(text))
+}
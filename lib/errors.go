@@ -0,0 +1,40 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"videotranscript-app/models"
+)
+
+// downloadErrorPatterns map yt-dlp error output onto error codes. They are
+// checked in order, so the more specific messages come first.
+var downloadErrorPatterns = []struct {
+	code     models.ErrorCode
+	patterns []string
+}{
+	{models.ErrCodePrivateVideo, []string{"private video", "sign in to confirm your age", "members-only", "join this channel"}},
+	{models.ErrCodeGeoBlocked, []string{"available in your country", "geo restriction", "geo-restricted", "blocked it in your country"}},
+	{models.ErrCodeSourceUnavailable, []string{"video unavailable", "has been removed", "does not exist", "is not a valid url", "unsupported url", "account associated with this video has been terminated", "premieres in"}},
+	{models.ErrCodeTimeout, []string{"timed out", "timeout"}},
+}
+
+// classifyDownloadError assigns an error code to a failed download based on
+// the error and yt-dlp's stderr. Unrecognised failures are treated as
+// network errors so they get retried.
+func classifyDownloadError(err error, stderr string) *models.JobError {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return models.NewJobError(models.ErrCodeTimeout, err)
+	}
+
+	output := strings.ToLower(err.Error() + "\n" + stderr)
+	for _, class := range downloadErrorPatterns {
+		for _, pattern := range class.patterns {
+			if strings.Contains(output, pattern) {
+				return models.NewJobError(class.code, err)
+			}
+		}
+	}
+	return models.NewJobError(models.ErrCodeDownloadNetwork, err)
+}
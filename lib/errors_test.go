@@ -0,0 +1,36 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"videotranscript-app/models"
+)
+
+func TestClassifyDownloadError(t *testing.T) {
+	failed := errors.New("exit status 1")
+
+	tests := []struct {
+		stderr string
+		want   models.ErrorCode
+	}{
+		{"ERROR: [youtube] abc: Private video. Sign in if you've been granted access", models.ErrCodePrivateVideo},
+		{"ERROR: [youtube] abc: The uploader has not made this video available in your country", models.ErrCodeGeoBlocked},
+		{"ERROR: [youtube] abc: Video unavailable. This video has been removed by the uploader", models.ErrCodeSourceUnavailable},
+		{"ERROR: Unable to download webpage: The read operation timed out", models.ErrCodeTimeout},
+		{"ERROR: Unable to download webpage: <urlopen error [Errno -3] Temporary failure in name resolution>", models.ErrCodeDownloadNetwork},
+	}
+
+	for _, tt := range tests {
+		err := classifyDownloadError(failed, tt.stderr)
+		assert.Equal(t, tt.want, err.Code, tt.stderr)
+		assert.ErrorIs(t, err, failed)
+	}
+
+	deadline := classifyDownloadError(fmt.Errorf("yt-dlp: %w", context.DeadlineExceeded), "")
+	assert.Equal(t, models.ErrCodeTimeout, deadline.Code)
+}
@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"videotranscript-app/models"
+)
+
+// ParagraphOptions control how a transcript is broken into paragraphs for
+// the txt, md, html and docx formats. Durations are in seconds.
+type ParagraphOptions struct {
+	// Pause is the silence after a sentence that starts a new paragraph
+	Pause float64
+	// MaxDuration ends a paragraph at the next sentence end once it is this
+	// long, and anywhere once it is twice as long
+	MaxDuration float64
+	// TimestampEvery marks a paragraph with its start time when at least
+	// this long has passed since the last marked one. 0 marks none.
+	TimestampEvery float64
+	// Speakers labels paragraphs with their speaker and starts a new one
+	// when the speaker changes. Only segments parsed from subtitles that
+	// name speakers carry one; transcription engines do not set it yet.
+	Speakers bool
+}
+
+// DefaultParagraphOptions break paragraphs at pauses of 1.5 seconds or
+// after about half a minute of speech
+var DefaultParagraphOptions = ParagraphOptions{Pause: 1.5, MaxDuration: 30}
+
+// Validate reports paragraph options outside their ranges
+func (o ParagraphOptions) Validate() error {
+	if o.Pause <= 0 {
+		return fmt.Errorf("pause must be greater than 0")
+	}
+	if o.MaxDuration < 5 {
+		return fmt.Errorf("paragraph_length must be at least 5 seconds")
+	}
+	if o.TimestampEvery < 0 {
+		return fmt.Errorf("timestamps must not be negative")
+	}
+	return nil
+}
+
+// ParseParagraphOptions reads paragraph options from query parameters:
+// pause, paragraph_length, timestamps and speakers. Missing parameters keep
+// their DefaultParagraphOptions value.
+func ParseParagraphOptions(query func(string) string) (ParagraphOptions, error) {
+	opts := DefaultParagraphOptions
+	floats := []struct {
+		name   string
+		target *float64
+	}{
+		{"pause", &opts.Pause},
+		{"paragraph_length", &opts.MaxDuration},
+		{"timestamps", &opts.TimestampEvery},
+	}
+	for _, f := range floats {
+		if value := query(f.name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be a number of seconds", f.name)
+			}
+			*f.target = parsed
+		}
+	}
+	if value := query("speakers"); value != "" {
+		speakers, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("speakers must be true or false")
+		}
+		opts.Speakers = speakers
+	}
+	return opts, opts.Validate()
+}
+
+// Paragraph is a run of transcript segments read as one block of text
+type Paragraph struct {
+	Start float64
+	End   float64
+	Text  string
+	// Speaker is set when speakers are labelled and the segments name one
+	Speaker string
+	// Timestamp reports whether the paragraph is marked with its start
+	Timestamp bool
+}
+
+// BuildParagraphs joins segments into paragraphs. A paragraph ends after a
+// sentence that is followed by a pause or that takes it past MaxDuration,
+// and at the speaker's turn when speakers are labelled, so that text is
+// never split mid-sentence unless a paragraph runs twice as long without a
+// sentence end.
+func BuildParagraphs(segments []models.Segment, opts ParagraphOptions) []Paragraph {
+	var paragraphs []Paragraph
+	var text []string
+	var current Paragraph
+	var previous models.Segment
+	lastStamp := 0.0
+
+	flush := func() {
+		if len(text) == 0 {
+			return
+		}
+		current.Text = strings.Join(text, " ")
+		if opts.TimestampEvery > 0 && (len(paragraphs) == 0 || current.Start-lastStamp >= opts.TimestampEvery) {
+			current.Timestamp = true
+			lastStamp = current.Start
+		}
+		paragraphs = append(paragraphs, current)
+		text = nil
+	}
+
+	for _, segment := range segments {
+		words := strings.Fields(segment.Text)
+		if len(words) == 0 {
+			continue
+		}
+		if len(text) > 0 {
+			duration := previous.End - current.Start
+			turn := opts.Speakers && segment.Speaker != current.Speaker
+			sentence := endsSentence(previous.Text) && (segment.Start-previous.End >= opts.Pause || duration >= opts.MaxDuration)
+			if turn || sentence || duration >= 2*opts.MaxDuration {
+				flush()
+			}
+		}
+		if len(text) == 0 {
+			current = Paragraph{Start: segment.Start}
+			if opts.Speakers {
+				current.Speaker = segment.Speaker
+			}
+		}
+		text = append(text, words...)
+		current.End = segment.End
+		previous = segment
+	}
+	flush()
+	return paragraphs
+}
+
+// endsSentence reports whether text ends with sentence-ending punctuation,
+// possibly followed by closing quotes or brackets
+func endsSentence(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), `"')]”’`)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "!") || strings.HasSuffix(text, "?") || strings.HasSuffix(text, "…")
+}
@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+// interviewSegments returns segments of two speakers, with a pause after
+// the first sentence and a long run of speech without one
+func interviewSegments() []models.Segment {
+	return []models.Segment{
+		{Start: 0, End: 3, Text: "Welcome to the show.", Speaker: "Ann"},
+		{Start: 5, End: 8, Text: "Today we talk about bread", Speaker: "Ann"},
+		{Start: 8.2, End: 11, Text: "and why it rises.", Speaker: "Ann"},
+		{Start: 11.3, End: 14, Text: "Thanks for having me.", Speaker: "Bob"},
+		{Start: 14.2, End: 40, Text: "Yeast eats sugar and makes gas.", Speaker: "Bob"},
+		{Start: 40.1, End: 45, Text: "That is all.", Speaker: "Bob"},
+	}
+}
+
+func TestBuildParagraphs(t *testing.T) {
+	segments := interviewSegments()
+
+	// Paragraphs break after sentences followed by a pause or running past
+	// MaxDuration, never in the middle of a sentence
+	assert.Equal(t, []Paragraph{
+		{Start: 0, End: 3, Text: "Welcome to the show."},
+		{Start: 5, End: 40, Text: "Today we talk about bread and why it rises. Thanks for having me. Yeast eats sugar and makes gas."},
+		{Start: 40.1, End: 45, Text: "That is all."},
+	}, BuildParagraphs(segments, DefaultParagraphOptions))
+
+	// Speaker turns start a paragraph, and timestamps mark paragraphs at
+	// least TimestampEvery apart
+	paragraphs := BuildParagraphs(segments, ParagraphOptions{Pause: 1.5, MaxDuration: 30, TimestampEvery: 10, Speakers: true})
+	assert.Equal(t, []Paragraph{
+		{Start: 0, End: 3, Text: "Welcome to the show.", Speaker: "Ann", Timestamp: true},
+		{Start: 5, End: 11, Text: "Today we talk about bread and why it rises.", Speaker: "Ann"},
+		{Start: 11.3, End: 45, Text: "Thanks for having me. Yeast eats sugar and makes gas. That is all.", Speaker: "Bob", Timestamp: true},
+	}, paragraphs)
+
+	// Without sentence ends, paragraphs break at twice MaxDuration
+	var unpunctuated []models.Segment
+	for i := 0; i < 6; i++ {
+		unpunctuated = append(unpunctuated, models.Segment{Start: float64(i * 5), End: float64(i*5 + 5), Text: "and so on"})
+	}
+	paragraphs = BuildParagraphs(unpunctuated, ParagraphOptions{Pause: 1.5, MaxDuration: 5})
+	require.Len(t, paragraphs, 3)
+	assert.Equal(t, 10.0, paragraphs[1].Start)
+
+	assert.Nil(t, BuildParagraphs(nil, DefaultParagraphOptions))
+}
+
+func TestParseParagraphOptions(t *testing.T) {
+	query := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	opts, err := ParseParagraphOptions(query(nil))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultParagraphOptions, opts)
+
+	opts, err = ParseParagraphOptions(query(map[string]string{"pause": "0.8", "paragraph_length": "60", "timestamps": "30", "speakers": "true"}))
+	require.NoError(t, err)
+	assert.Equal(t, ParagraphOptions{Pause: 0.8, MaxDuration: 60, TimestampEvery: 30, Speakers: true}, opts)
+
+	for _, values := range []map[string]string{
+		{"pause": "0"},
+		{"pause": "soon"},
+		{"paragraph_length": "2"},
+		{"timestamps": "-1"},
+		{"speakers": "maybe"},
+	} {
+		_, err := ParseParagraphOptions(query(values))
+		assert.Error(t, err, values)
+	}
+}
+
+func TestTranscriptDocument(t *testing.T) {
+	job := models.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	job.VideoID = "dQw4w9WgXcQ"
+	job.Title = "Bread <live> & more"
+	job.Language = "en"
+	job.Segments = interviewSegments()[:4]
+	doc := NewTranscriptDocument(job, ParagraphOptions{Pause: 1.5, MaxDuration: 30, TimestampEvery: 10, Speakers: true})
+
+	var b bytes.Buffer
+	require.NoError(t, doc.WriteText(&b))
+	assert.Equal(t, "[0:00] Ann: Welcome to the show.\n\nAnn: Today we talk about bread and why it rises.\n\n[0:11] Bob: Thanks for having me.\n", b.String())
+
+	b.Reset()
+	require.NoError(t, doc.WriteMarkdown(&b))
+	assert.Equal(t, `# Bread \<live\> & more
+
+Source: <https://www.youtube.com/watch?v=dQw4w9WgXcQ>
+
+[0:00](https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=0s) **Ann:** Welcome to the show.
+
+**Ann:** Today we talk about bread and why it rises.
+
+[0:11](https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=11s) **Bob:** Thanks for having me.
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, doc.WriteHTML(&b))
+	page := b.String()
+	assert.True(t, strings.HasPrefix(page, "<!DOCTYPE html>\n<html lang=\"en\">\n"))
+	assert.Contains(t, page, "<title>Bread &lt;live&gt; &amp; more</title>")
+	assert.Contains(t, page, `<p><a class="timestamp" href="https://www.youtube.com/watch?v=dQw4w9WgXcQ&amp;t=11s">0:11</a><span class="speaker">Bob:</span> Thanks for having me.</p>`)
+
+	b.Reset()
+	require.NoError(t, doc.WriteDOCX(&b))
+	archive, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	require.NoError(t, err)
+	parts := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		parts[f.Name] = string(content)
+	}
+	assert.Contains(t, parts, "[Content_Types].xml")
+	assert.Contains(t, parts, "_rels/.rels")
+	assert.Contains(t, parts, "word/styles.xml")
+	assert.Contains(t, parts["word/document.xml"], `<w:t xml:space="preserve">Bread &lt;live&gt; &amp; more</w:t>`)
+	assert.Contains(t, parts["word/document.xml"], `<w:hyperlink r:id="rId3">`)
+	assert.Contains(t, parts["word/_rels/document.xml.rels"], `Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://www.youtube.com/watch?v=dQw4w9WgXcQ&amp;t=11s" TargetMode="External"`)
+
+	// Rendering is deterministic, so ETags are stable
+	var again bytes.Buffer
+	require.NoError(t, doc.WriteDOCX(&again))
+	assert.Equal(t, b.Bytes(), again.Bytes())
+}
+
+func TestTimestampURL(t *testing.T) {
+	doc := &TranscriptDocument{URL: "https://youtu.be/dQw4w9WgXcQ?si=abc", VideoID: "dQw4w9WgXcQ"}
+	assert.Equal(t, "https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=65s", doc.TimestampURL(65.9))
+
+	doc = &TranscriptDocument{URL: "https://vimeo.com/76979871?share=copy", VideoID: "unknown"}
+	assert.Equal(t, "https://vimeo.com/76979871?share=copy&t=5s", doc.TimestampURL(5))
+
+	doc = &TranscriptDocument{URL: "upload.mp4"}
+	assert.Equal(t, "", doc.TimestampURL(5))
+}
+
+func TestRenderTranscriptDocuments(t *testing.T) {
+	job := models.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	job.VideoID = "dQw4w9WgXcQ"
+	require.NoError(t, job.MarkRunning())
+	require.NoError(t, job.MarkComplete("unused", interviewSegments()))
+
+	// The zero options lay txt out with DefaultParagraphOptions
+	file, err := RenderTranscript(job, "txt", TranscriptOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome to the show.\n\nToday we talk about bread and why it rises. Thanks for having me. Yeast eats sugar and makes gas.\n\nThat is all.\n", string(file.Content))
+
+	file, err = RenderTranscript(job, "docx", TranscriptOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", file.ContentType)
+	assert.Equal(t, "transcript_dQw4w9WgXcQ.docx", file.Filename)
+
+	// Jobs without segments are one paragraph of their transcript
+	job.Segments = nil
+	job.Transcript = "Just  the\ntext"
+	file, err = RenderTranscript(job, "md", TranscriptOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "# Transcript\n\nSource: <https://www.youtube.com/watch?v=dQw4w9WgXcQ>\n\nJust the text\n", string(file.Content))
+}
@@ -0,0 +1,285 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"videotranscript-app/models"
+)
+
+// SubtitleFormats lists the formats subtitles can be converted between
+var SubtitleFormats = []SubtitleFormat{FormatSRT, FormatVTT, FormatASS, FormatTTML, FormatSBV, FormatJSON}
+
+var subtitleContentTypes = map[SubtitleFormat]string{
+	FormatSRT:  "application/x-subrip; charset=utf-8",
+	FormatVTT:  "text/vtt; charset=utf-8",
+	FormatASS:  "text/x-ssa; charset=utf-8",
+	FormatTTML: "application/ttml+xml; charset=utf-8",
+	FormatSBV:  "text/plain; charset=utf-8",
+	FormatJSON: "application/json",
+}
+
+var subtitleFormatAliases = map[string]SubtitleFormat{
+	"subrip": FormatSRT,
+	"webvtt": FormatVTT,
+	"ssa":    FormatASS,
+	"dfxp":   FormatTTML,
+	"xml":    FormatTTML,
+}
+
+// MaxSubtitleSize is the largest subtitle file ConvertSubtitles reads
+const MaxSubtitleSize = 10 << 20
+
+// ConvertOptions adjust subtitles while converting them. Timing changes
+// apply in order: the frame rate change, then the offset.
+type ConvertOptions struct {
+	// Offset shifts every cue by this many seconds. Cues that end up
+	// before zero are dropped, or clipped if they straddle it.
+	Offset float64
+	// FromFrameRate and ToFrameRate retime cues for video whose speed was
+	// changed between frame rates, such as a 23.976 to 25 fps PAL speed-up
+	FromFrameRate float64
+	ToFrameRate   float64
+	// SubtitleOptions configure the writer of the target format
+	SubtitleOptions
+}
+
+// Validate reports inconsistent timing options
+func (o ConvertOptions) Validate() error {
+	if (o.FromFrameRate == 0) != (o.ToFrameRate == 0) {
+		return fmt.Errorf("from_fps and to_fps must be given together")
+	}
+	for _, rate := range []float64{o.FromFrameRate, o.ToFrameRate} {
+		if rate != 0 && (rate < 1 || rate > 120) {
+			return fmt.Errorf("frame rates must be between 1 and 120")
+		}
+	}
+	return o.SubtitleOptions.Validate()
+}
+
+// ParseConvertOptions reads conversion options from query parameters:
+// offset, from_fps and to_fps, and the writer options read by
+// ParseSubtitleOptions
+func ParseConvertOptions(query func(string) string) (ConvertOptions, error) {
+	var opts ConvertOptions
+	floats := map[string]*float64{"offset": &opts.Offset, "from_fps": &opts.FromFrameRate, "to_fps": &opts.ToFrameRate}
+	for name, target := range floats {
+		if value := query(name); value != "" {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be a number", name)
+			}
+			*target = f
+		}
+	}
+
+	var err error
+	if opts.SubtitleOptions, err = ParseSubtitleOptions(query); err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
+// ParseSubtitleFormat returns the format of a name or file extension such
+// as "srt", ".vtt" or "dfxp"
+func ParseSubtitleFormat(name string) (SubtitleFormat, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "."))
+	if format, ok := subtitleFormatAliases[name]; ok {
+		return format, nil
+	}
+	format := SubtitleFormat(name)
+	if _, ok := subtitleContentTypes[format]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedSubtitleFormat, name)
+	}
+	return format, nil
+}
+
+// SubtitleContentType returns the Content-Type of a subtitle format
+func SubtitleContentType(format SubtitleFormat) string {
+	if contentType, ok := subtitleContentTypes[format]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+var sbvDetectRegex = regexp.MustCompile(`(?m)^\s*\d+:\d{1,2}:\d{1,2}\.\d{1,3},\d+:\d{1,2}:\d{1,2}\.\d{1,3}\s*$`)
+
+// DetectSubtitleFormat guesses the format of UTF-8 subtitle content from
+// its first characters and timing lines
+func DetectSubtitleFormat(data []byte) (SubtitleFormat, error) {
+	content := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(content, "WEBVTT"):
+		return FormatVTT, nil
+	case strings.HasPrefix(content, "[Script Info]"):
+		return FormatASS, nil
+	case strings.HasPrefix(content, "<"):
+		return FormatTTML, nil
+	case strings.HasPrefix(content, "{"), strings.HasPrefix(content, "["):
+		return FormatJSON, nil
+	case sbvDetectRegex.MatchString(content):
+		return FormatSBV, nil
+	case cueTimingRegex.MatchString(firstTimingLine(content)):
+		return FormatSRT, nil
+	}
+	return "", fmt.Errorf("%w: the format could not be detected", ErrUnsupportedSubtitleFormat)
+}
+
+func firstTimingLine(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.Contains(line, "-->") {
+			return line
+		}
+	}
+	return ""
+}
+
+// ConvertSubtitles reads subtitles in one format and writes them in another
+// with the writers used for job output. The input is normalized to UTF-8
+// first, and its format is detected when from is empty.
+func ConvertSubtitles(r io.Reader, from, to SubtitleFormat, opts ConvertOptions) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	if _, ok := subtitleWriters[to]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedSubtitleFormat, to)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, MaxSubtitleSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read subtitles: %w", err)
+	}
+	if len(data) > MaxSubtitleSize {
+		return "", fmt.Errorf("subtitles must not be larger than %d MB", MaxSubtitleSize>>20)
+	}
+	data = NormalizeSubtitleEncoding(data)
+
+	if from == "" {
+		if from, err = DetectSubtitleFormat(data); err != nil {
+			return "", err
+		}
+	}
+	segments, err := ParseSubtitles(bytes.NewReader(data), from)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := WriteSubtitles(&b, retimeSegments(segments, opts), to, opts.SubtitleOptions); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// retimeSegments applies the frame rate change and offset of the options
+func retimeSegments(segments []models.Segment, opts ConvertOptions) []models.Segment {
+	scale := 1.0
+	if opts.FromFrameRate > 0 && opts.ToFrameRate > 0 {
+		scale = opts.FromFrameRate / opts.ToFrameRate
+	}
+	if scale == 1 && opts.Offset == 0 {
+		return segments
+	}
+	retime := func(t float64) float64 {
+		return t*scale + opts.Offset
+	}
+
+	result := make([]models.Segment, 0, len(segments))
+	for _, segment := range segments {
+		segment.Start, segment.End = retime(segment.Start), retime(segment.End)
+		if segment.End <= 0 {
+			continue
+		}
+		segment.Start = max(segment.Start, 0)
+
+		if segment.Words != nil {
+			words := make([]models.Word, 0, len(segment.Words))
+			for _, word := range segment.Words {
+				word.Start, word.End = max(retime(word.Start), 0), retime(word.End)
+				if word.End > 0 {
+					words = append(words, word)
+				}
+			}
+			segment.Words = words
+		}
+		result = append(result, segment)
+	}
+	return result
+}
+
+// NormalizeSubtitleEncoding converts subtitle bytes to UTF-8 without a byte
+// order mark. UTF-16 is recognized by its byte order mark or by the zero
+// bytes of mostly-ASCII text; anything else that is not valid UTF-8 is read
+// as Windows-1252, the usual encoding of legacy SRT files.
+func NormalizeSubtitleEncoding(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], false)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], true)
+	}
+
+	if len(data) >= 4 && len(data)%2 == 0 {
+		var evenZeros, oddZeros int
+		for i := 0; i < len(data); i += 2 {
+			if data[i] == 0 {
+				evenZeros++
+			}
+			if data[i+1] == 0 {
+				oddZeros++
+			}
+		}
+		half := len(data) / 2
+		if oddZeros > half/2 && evenZeros == 0 {
+			return decodeUTF16(data, false)
+		}
+		if evenZeros > half/2 && oddZeros == 0 {
+			return decodeUTF16(data, true)
+		}
+	}
+
+	if utf8.Valid(data) {
+		return data
+	}
+	return decodeWindows1252(data)
+}
+
+func decodeUTF16(data []byte, bigEndian bool) []byte {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// windows1252 maps the bytes 0x80 to 0x9F, where Windows-1252 differs from
+// Latin-1; undefined bytes map to the replacement character
+var windows1252 = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+func decodeWindows1252(data []byte) []byte {
+	var b strings.Builder
+	b.Grow(len(data))
+	for _, c := range data {
+		if c >= 0x80 && c < 0xA0 {
+			b.WriteRune(windows1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return []byte(b.String())
+}
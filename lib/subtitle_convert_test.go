@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+const convertSRT = `1
+00:00:01,000 --> 00:00:02,500
+Hello & welcome
+
+2
+00:00:03,000 --> 00:00:05,000
+Two lines
+of text
+`
+
+func TestConvertSubtitles_RoundTrip(t *testing.T) {
+	expected, err := ParseSRT(strings.NewReader(convertSRT))
+	require.NoError(t, err)
+
+	// Every format parses back to the cues it was written from
+	for _, format := range SubtitleFormats {
+		t.Run(string(format), func(t *testing.T) {
+			converted, err := ConvertSubtitles(strings.NewReader(convertSRT), FormatSRT, format, ConvertOptions{})
+			require.NoError(t, err)
+
+			detected, err := DetectSubtitleFormat([]byte(converted))
+			require.NoError(t, err)
+			assert.Equal(t, format, detected)
+
+			segments, err := ParseSubtitles(strings.NewReader(converted), format)
+			require.NoError(t, err)
+			assert.Equal(t, expected, segments)
+
+			back, err := ConvertSubtitles(strings.NewReader(converted), "", FormatSRT, ConvertOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, convertSRT+"\n", back)
+		})
+	}
+}
+
+func TestConvertSubtitles_Timing(t *testing.T) {
+	converted, err := ConvertSubtitles(strings.NewReader(convertSRT), FormatSRT, FormatSBV, ConvertOptions{Offset: -1.5})
+	require.NoError(t, err)
+	// The first cue straddles zero and is clipped
+	assert.Equal(t, "0:00:00.000,0:00:01.000\nHello & welcome\n\n0:00:01.500,0:00:03.500\nTwo lines\nof text\n", converted)
+
+	converted, err = ConvertSubtitles(strings.NewReader(convertSRT), FormatSRT, FormatJSON, ConvertOptions{Offset: -2.5})
+	require.NoError(t, err)
+	segments, err := ParseSubtitleJSON(strings.NewReader(converted))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assert.Equal(t, 0.5, segments[0].Start)
+
+	// 23.976 fps film sped up to 25 fps plays in 959/1000 of the time
+	converted, err = ConvertSubtitles(strings.NewReader(convertSRT), FormatSRT, FormatVTT, ConvertOptions{FromFrameRate: 23.976, ToFrameRate: 25})
+	require.NoError(t, err)
+	assert.Contains(t, converted, "00:00:02.877 --> 00:00:04.795\n")
+
+	_, err = ConvertSubtitles(strings.NewReader(convertSRT), FormatSRT, FormatVTT, ConvertOptions{FromFrameRate: 25})
+	assert.Error(t, err)
+}
+
+func TestNormalizeSubtitleEncoding(t *testing.T) {
+	utf16le := []byte{0xFF, 0xFE}
+	for _, r := range "Café ☕" {
+		utf16le = append(utf16le, byte(r), byte(r>>8))
+	}
+	assert.Equal(t, "Café ☕", string(NormalizeSubtitleEncoding(utf16le)))
+
+	utf16be := []byte{0, 'H', 0, 'i', 0, '!', 0, '\n'}
+	assert.Equal(t, "Hi!\n", string(NormalizeSubtitleEncoding(utf16be)))
+
+	windows1252 := []byte("\x93Caf\xe9\x94 \x80")
+	assert.Equal(t, "“Café” €", string(NormalizeSubtitleEncoding(windows1252)))
+
+	assert.Equal(t, "Hi", string(NormalizeSubtitleEncoding([]byte("\xEF\xBB\xBFHi"))))
+}
+
+func TestParseASS(t *testing.T) {
+	script := `[Script Info]
+ScriptType: v4.00+
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize
+Style: Default,Arial,20
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Comment: 0,0:00:00.00,0:00:01.00,Default,,0,0,0,,Ignored
+Dialogue: 0,0:00:01.00,0:00:03.00,Default,,0,0,0,,{\k20}{\k30}Never {\k60}gonna\N{\i1}{\k20}give{\i0} {\k20}you {\k50}up
+Dialogue: 0,1:00:00.50,1:00:02.00,Default,,0,0,0,,{\an8}Commas, kept\hand spaced
+`
+	segments, err := ParseASS(strings.NewReader(script))
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+
+	assert.Equal(t, "Never gonna\ngive you up", segments[0].Text)
+	require.Len(t, segments[0].Words, 5)
+	assert.Equal(t, models.Word{Start: 1.2, End: 1.5, Text: "Never"}, segments[0].Words[0])
+	assert.InDelta(t, 2.5, segments[0].Words[4].Start, 1e-9)
+	assert.InDelta(t, 3.0, segments[0].Words[4].End, 1e-9)
+
+	assert.Equal(t, models.Segment{Start: 3600.5, End: 3602, Text: "Commas, kept and spaced"}, segments[1])
+}
+
+func TestParseTTML(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter"
+    ttp:timeBase="smpte" ttp:dropMode="dropNTSC" ttp:frameRate="30" ttp:frameRateMultiplier="1000 1001">
+  <body>
+    <div begin="00:00:10:00">
+      <p begin="00:00:00:00" end="00:00:01:15">Inside an
+        offset <span>div</span><br/>second line</p>
+    </div>
+    <div>
+      <p begin="00:01:00:02" dur="30f">Drop frame</p>
+      <p begin="1.5s">No end</p>
+    </div>
+  </body>
+</tt>`
+	segments, err := ParseTTML(strings.NewReader(document))
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+
+	assert.Equal(t, "Inside an offset div\nsecond line", segments[0].Text)
+	fps := 30000.0 / 1001
+	assert.InDelta(t, 300/fps, segments[0].Start, 1e-6)
+	assert.InDelta(t, 345/fps, segments[0].End, 1e-6)
+	// 00:01:00:02 is frame 1800, as labels 00:01:00:00 and 01 are dropped
+	assert.InDelta(t, 1800/fps, segments[1].Start, 1e-6)
+	assert.InDelta(t, 1830/fps, segments[1].End, 1e-6)
+
+	_, err = ParseTTML(strings.NewReader("<html></html>"))
+	assert.Error(t, err)
+}
+
+func TestParseSubtitleFormat(t *testing.T) {
+	for name, expected := range map[string]SubtitleFormat{"srt": FormatSRT, ".VTT": FormatVTT, "ssa": FormatASS, "dfxp": FormatTTML, "sbv": FormatSBV, "json": FormatJSON} {
+		format, err := ParseSubtitleFormat(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, format)
+	}
+	_, err := ParseSubtitleFormat("docx")
+	assert.ErrorIs(t, err, ErrUnsupportedSubtitleFormat)
+}
@@ -0,0 +1,398 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"videotranscript-app/models"
+)
+
+var (
+	// ErrUnsupportedSubtitleFormat is returned for a format that cannot be
+	// parsed
+	ErrUnsupportedSubtitleFormat = errors.New("unsupported subtitle format")
+	// ErrNoSubtitleCues is returned for non-empty input without a single cue
+	ErrNoSubtitleCues = errors.New("no subtitle cues found")
+)
+
+// cueTimingRegex matches a cue timing line. It accepts SRT and WebVTT
+// timestamps alike: hours are optional, the fraction may use a comma or a
+// dot and have one to three digits, and anything after the end time (VTT
+// cue settings, SRT coordinates) is ignored.
+var cueTimingRegex = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{1,2}(?:[.,]\d{1,3})?)\s*-+>\s*((?:\d+:)?\d{1,2}:\d{1,2}(?:[.,]\d{1,3})?)(?:\s.*)?$`)
+
+var (
+	// srtTagRegex matches SRT formatting tags and ASS override blocks that
+	// some SRT authoring tools emit
+	srtTagRegex = regexp.MustCompile(`(?i)</?(?:i|b|u|s|font)(?:\s[^>]*)?>|\{\\[^}]*\}`)
+	// vttTagRegex matches WebVTT cue text tags other than timestamps
+	vttTagRegex = regexp.MustCompile(`</?(?:c|i|b|u|v|lang|ruby|rt)(?:[.\s][^>]*)?>`)
+	// vttVoiceTagRegex matches a voice span, capturing the speaker's name
+	vttVoiceTagRegex = regexp.MustCompile(`<v(?:\.[^\s>]*)?\s+([^>]+)>`)
+	// vttTimestampTagRegex matches the inline timestamps of karaoke cues
+	vttTimestampTagRegex = regexp.MustCompile(`<((?:\d+:)?\d{2}:\d{2}\.\d{3})>`)
+)
+
+// ParseSubtitles parses subtitle content in a format into segments
+func ParseSubtitles(r io.Reader, format SubtitleFormat) ([]models.Segment, error) {
+	switch format {
+	case FormatSRT:
+		return ParseSRT(r)
+	case FormatVTT:
+		return ParseVTT(r)
+	case FormatASS:
+		return ParseASS(r)
+	case FormatTTML:
+		return ParseTTML(r)
+	case FormatSBV:
+		return ParseSBV(r)
+	case FormatJSON:
+		return ParseSubtitleJSON(r)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSubtitleFormat, format)
+	}
+}
+
+// ParseSRT parses SubRip subtitles. It is lenient about what real files get
+// wrong: missing or wrong sequence numbers, missing blank lines between
+// cues, blank lines inside cue text, dot separators, short timestamps, a
+// byte order mark and CRLF line endings. Formatting tags are removed and
+// cues are sorted by start time.
+func ParseSRT(r io.Reader) ([]models.Segment, error) {
+	lines, err := readSubtitleLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []models.Segment
+	for i := 0; i < len(lines); i++ {
+		start, end, ok := parseCueTiming(lines[i])
+		if !ok {
+			continue
+		}
+
+		var text []string
+		for i+1 < len(lines) {
+			next := strings.TrimSpace(lines[i+1])
+			if srtCueStart(lines, i+1) {
+				break
+			}
+			// A blank line ends the cue unless the text continues after it
+			if next == "" && !srtTextContinues(lines, i+1) {
+				break
+			}
+			if next != "" {
+				text = append(text, next)
+			}
+			i++
+		}
+
+		segments = appendCue(segments, start, end, cleanSRTText(strings.Join(text, "\n")), nil)
+	}
+
+	return finishCues(segments, blankLines(lines))
+}
+
+// ParseVTT parses WebVTT subtitles. Cue identifiers, cue settings and NOTE,
+// STYLE and REGION blocks are skipped, and cue text is stripped of its tags
+// and entities. Inline timestamps of karaoke cues become word timings. A
+// missing WEBVTT header is tolerated.
+func ParseVTT(r io.Reader) ([]models.Segment, error) {
+	lines, err := readSubtitleLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	i := 0
+	header := len(lines) > 0 && strings.HasPrefix(lines[0], "WEBVTT")
+	if header {
+		// The header block runs to the first blank line
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+			i++
+		}
+	}
+
+	var segments []models.Segment
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "NOTE" || strings.HasPrefix(line, "NOTE ") || line == "STYLE" || line == "REGION" {
+			for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+				i++
+			}
+			continue
+		}
+
+		start, end, ok := parseCueTiming(line)
+		if !ok {
+			continue
+		}
+
+		var text []string
+		for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			if _, _, ok := parseCueTiming(lines[i+1]); ok {
+				break
+			}
+			text = append(text, strings.TrimSpace(lines[i+1]))
+			i++
+		}
+
+		raw := strings.Join(text, "\n")
+		cues := len(segments)
+		segments = appendCue(segments, start, end, cleanVTTText(raw), vttWords(raw, start, end))
+		if voice := vttVoiceTagRegex.FindStringSubmatch(raw); voice != nil && len(segments) > cues {
+			segments[cues].Speaker = strings.TrimSpace(html.UnescapeString(voice[1]))
+		}
+	}
+
+	// A file with a header may legitimately hold no cues
+	return finishCues(segments, header || blankLines(lines))
+}
+
+// sbvTimingRegex matches a YouTube SBV timing line, "0:00:01.000,0:00:03.500"
+var sbvTimingRegex = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{1,2}(?:\.\d{1,3})?)\s*,\s*((?:\d+:)?\d{1,2}:\d{1,2}(?:\.\d{1,3})?)\s*$`)
+
+// ParseSBV parses YouTube SBV subtitles, whose cues are a timing line and
+// text separated by blank lines
+func ParseSBV(r io.Reader) ([]models.Segment, error) {
+	lines, err := readSubtitleLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []models.Segment
+	for i := 0; i < len(lines); i++ {
+		matches := sbvTimingRegex.FindStringSubmatch(lines[i])
+		if matches == nil {
+			continue
+		}
+		start, err := parseCueTimestamp(matches[1])
+		if err != nil {
+			continue
+		}
+		end, err := parseCueTimestamp(matches[2])
+		if err != nil {
+			continue
+		}
+
+		var text []string
+		for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" && !sbvTimingRegex.MatchString(lines[i+1]) {
+			text = append(text, strings.TrimSpace(lines[i+1]))
+			i++
+		}
+		segments = appendCue(segments, start, end, strings.Join(text, "\n"), nil)
+	}
+
+	return finishCues(segments, blankLines(lines))
+}
+
+// ParseSubtitleJSON parses the json subtitle format, an object with a
+// segments array as written by the json writer and transcript.json, or a
+// bare array of segments
+func ParseSubtitleJSON(r io.Reader) ([]models.Segment, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtitles: %w", err)
+	}
+
+	var segments []models.Segment
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &segments)
+	} else {
+		var doc subtitleJSON
+		err = json.Unmarshal(data, &doc)
+		segments = doc.Segments
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid subtitle JSON: %w", err)
+	}
+
+	var cues []models.Segment
+	for _, segment := range segments {
+		cues = appendCue(cues, segment.Start, segment.End, strings.TrimSpace(segment.Text), segment.Words)
+	}
+	return finishCues(cues, len(segments) == 0)
+}
+
+// readSubtitleLines reads input into lines without a byte order mark or
+// carriage returns
+func readSubtitleLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		// Old Mac files end lines with a lone carriage return
+		lines = append(lines, strings.Split(strings.TrimRight(line, "\r"), "\r")...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read subtitles: %w", err)
+	}
+	return lines, nil
+}
+
+// parseCueTiming parses a "start --> end [settings]" line
+func parseCueTiming(line string) (float64, float64, bool) {
+	matches := cueTimingRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return 0, 0, false
+	}
+	start, err := parseCueTimestamp(matches[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err := parseCueTimestamp(matches[2])
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// parseCueTimestamp parses [hh:]mm:ss[.,]fff into seconds
+func parseCueTimestamp(timestamp string) (float64, error) {
+	parts := strings.Split(strings.Replace(timestamp, ",", ".", 1), ":")
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, err
+	}
+	minutes := 0
+	for _, part := range parts[:len(parts)-1] {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, err
+		}
+		minutes = minutes*60 + n
+	}
+	return math.Round((float64(minutes)*60+seconds)*1000) / 1000, nil
+}
+
+// srtCueStart reports whether line i starts a new cue, either with its
+// timing line or with a sequence number directly followed by one
+func srtCueStart(lines []string, i int) bool {
+	if _, _, ok := parseCueTiming(lines[i]); ok {
+		return true
+	}
+	if _, err := strconv.Atoi(strings.TrimSpace(lines[i])); err != nil || i+1 >= len(lines) {
+		return false
+	}
+	_, _, ok := parseCueTiming(lines[i+1])
+	return ok
+}
+
+// srtTextContinues reports whether the text after a blank line at i belongs
+// to the current cue rather than starting the next one or ending the file
+func srtTextContinues(lines []string, i int) bool {
+	for j := i; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) == "" {
+			continue
+		}
+		return !srtCueStart(lines, j)
+	}
+	return false
+}
+
+// appendCue appends a cue unless its text is empty, clamping an end time
+// before the start
+func appendCue(segments []models.Segment, start, end float64, text string, words []models.Word) []models.Segment {
+	if text == "" {
+		return segments
+	}
+	if end < start {
+		end = start
+	}
+	return append(segments, models.Segment{Start: start, End: end, Text: text, Words: words})
+}
+
+// finishCues sorts cues by start time. It fails if there are none, unless
+// the input may be empty.
+func finishCues(segments []models.Segment, mayBeEmpty bool) ([]models.Segment, error) {
+	if len(segments) == 0 {
+		if !mayBeEmpty {
+			return nil, ErrNoSubtitleCues
+		}
+		return []models.Segment{}, nil
+	}
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+	return segments, nil
+}
+
+func blankLines(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func cleanSRTText(text string) string {
+	text = srtTagRegex.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+func cleanVTTText(text string) string {
+	text = vttTimestampTagRegex.ReplaceAllString(text, "")
+	text = vttTagRegex.ReplaceAllString(text, "")
+	return strings.TrimSpace(strings.ReplaceAll(html.UnescapeString(text), "\u00a0", " "))
+}
+
+// vttWords turns a cue with inline timestamps into word timings. Each
+// timestamp starts a run of words that lasts until the next one; the words
+// of a run share its time evenly.
+func vttWords(raw string, start, end float64) []models.Word {
+	tags := vttTimestampTagRegex.FindAllStringSubmatchIndex(raw, -1)
+	if len(tags) == 0 {
+		return nil
+	}
+
+	type run struct {
+		start float64
+		text  string
+	}
+	runs := []run{{start: start, text: raw[:tags[0][0]]}}
+	for i, tag := range tags {
+		at, err := parseCueTimestamp(raw[tag[2]:tag[3]])
+		if err != nil {
+			return nil
+		}
+		textEnd := len(raw)
+		if i+1 < len(tags) {
+			textEnd = tags[i+1][0]
+		}
+		runs = append(runs, run{start: at, text: raw[tag[1]:textEnd]})
+	}
+
+	var words []models.Word
+	for i, r := range runs {
+		fields := strings.Fields(cleanVTTText(r.text))
+		if len(fields) == 0 {
+			continue
+		}
+		runEnd := end
+		if i+1 < len(runs) {
+			runEnd = runs[i+1].start
+		}
+		step := (runEnd - r.start) / float64(len(fields))
+		for j, field := range fields {
+			words = append(words, models.Word{
+				Start: r.start + step*float64(j),
+				End:   r.start + step*float64(j+1),
+				Text:  field,
+			})
+		}
+	}
+	return words
+}
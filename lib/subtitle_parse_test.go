@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files")
+
+// TestParseSubtitles_Golden parses each file in testdata/subtitles, writes
+// it back in the same format and compares the result with its .golden file.
+// Parsing the golden file must give the same output again.
+func TestParseSubtitles_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "subtitles", "*.*"))
+	require.NoError(t, err)
+
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".golden") {
+			continue
+		}
+		format := SubtitleFormat(strings.TrimPrefix(filepath.Ext(input), "."))
+
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			file, err := os.Open(input)
+			require.NoError(t, err)
+			defer file.Close()
+
+			segments, err := ParseSubtitles(file, format)
+			require.NoError(t, err)
+			written := ConvertSegmentsToSubtitles(segments, format)
+
+			golden := input + ".golden"
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(golden, []byte(written), 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), written)
+
+			again, err := ParseSubtitles(strings.NewReader(written), format)
+			require.NoError(t, err)
+			assert.Equal(t, written, ConvertSegmentsToSubtitles(again, format))
+		})
+	}
+}
+
+func TestParseSRT(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "subtitles", "messy.srt"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	segments, err := ParseSRT(file)
+	require.NoError(t, err)
+	require.Len(t, segments, 5)
+
+	assert.Equal(t, models.Segment{Start: 1, End: 3.5, Text: "Hello there,\nGeneral Kenobi!"}, segments[0])
+	assert.Equal(t, "Out of order & unnumbered", segments[1].Text)
+	assert.Equal(t, "Missing blank line", segments[2].Text)
+	assert.Equal(t, "A cue with a blank line\ninside its text.", segments[3].Text)
+	assert.Equal(t, 10.0, segments[3].End)
+	assert.Equal(t, segments[4].Start, segments[4].End)
+}
+
+func TestParseVTT(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "subtitles", "styled.vtt"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	segments, err := ParseVTT(file)
+	require.NoError(t, err)
+	require.Len(t, segments, 3)
+
+	assert.Equal(t, "Hi <there>\nWelcome back", segments[0].Text)
+	assert.Equal(t, "Roger", segments[0].Speaker)
+	assert.Equal(t, 1.0, segments[0].Start)
+	assert.Equal(t, 3600.0, segments[2].Start)
+
+	// Inline timestamps become word timings
+	assert.Equal(t, []models.Word{
+		{Start: 4, End: 4.5, Text: "Never"},
+		{Start: 4.5, End: 5, Text: "gonna"},
+		{Start: 5, End: 5 + 1.0/3, Text: "give"},
+		{Start: 5 + 1.0/3, End: 5 + 2.0/3, Text: "you"},
+		{Start: 5 + 2.0/3, End: 6, Text: "up"},
+	}, segments[1].Words)
+}
+
+func TestParseSubtitles_Errors(t *testing.T) {
+	_, err := ParseSRT(strings.NewReader("this is not\na subtitle file\n"))
+	assert.ErrorIs(t, err, ErrNoSubtitleCues)
+
+	segments, err := ParseVTT(strings.NewReader("WEBVTT\n\nNOTE nothing yet\n"))
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+
+	segments, err = ParseSRT(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+
+	_, err = ParseSubtitles(strings.NewReader(""), "docx")
+	assert.ErrorIs(t, err, ErrUnsupportedSubtitleFormat)
+}
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"videotranscript-app/models"
+)
+
+// SubtitleOptions configure the writers of formats that take options
+type SubtitleOptions struct {
+	// VTT adds karaoke tags and cue settings to the vtt format
+	VTT VTTOptions
+	// ASS styles the ass format
+	ASS ASSOptions
+	// TTML configures the ttml format
+	TTML TTMLOptions
+}
+
+// Validate reports invalid writer options
+func (o SubtitleOptions) Validate() error {
+	if err := o.VTT.Validate(); err != nil {
+		return err
+	}
+	if err := o.ASS.Validate(); err != nil {
+		return err
+	}
+	return o.TTML.Validate()
+}
+
+// ParseSubtitleOptions reads writer options from query parameters, as read
+// by ParseVTTOptions, ParseASSOptions and ParseTTMLOptions
+func ParseSubtitleOptions(query func(string) string) (SubtitleOptions, error) {
+	var opts SubtitleOptions
+	var err error
+	if opts.VTT, err = ParseVTTOptions(query); err != nil {
+		return opts, err
+	}
+	if opts.ASS, err = ParseASSOptions(query); err != nil {
+		return opts, err
+	}
+	if opts.TTML, err = ParseTTMLOptions(query); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// SubtitleWriter writes segments to w in one subtitle format
+type SubtitleWriter func(w io.Writer, segments []models.Segment, opts SubtitleOptions) error
+
+// subtitleWriters is the registry of formats segments can be written in.
+// Every subtitle the pipeline, the APIs and the dashboard produce goes
+// through one of these writers.
+var subtitleWriters = map[SubtitleFormat]SubtitleWriter{
+	FormatSRT: writeSRT,
+	FormatVTT: func(w io.Writer, segments []models.Segment, opts SubtitleOptions) error {
+		return WriteVTT(w, segments, opts.VTT)
+	},
+	FormatASS: func(w io.Writer, segments []models.Segment, opts SubtitleOptions) error {
+		return WriteASS(w, segments, opts.ASS)
+	},
+	FormatTTML: func(w io.Writer, segments []models.Segment, opts SubtitleOptions) error {
+		return WriteTTML(w, segments, opts.TTML)
+	},
+	FormatSBV:  writeSBV,
+	FormatJSON: writeSubtitleJSON,
+}
+
+// WriteSubtitles writes segments to w in the given format
+func WriteSubtitles(w io.Writer, segments []models.Segment, format SubtitleFormat, opts SubtitleOptions) error {
+	writer, ok := subtitleWriters[format]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedSubtitleFormat, format)
+	}
+	return writer(w, segments, opts)
+}
+
+// WriteSubtitleFile writes segments to a file in the given format
+func WriteSubtitleFile(path string, segments []models.Segment, format SubtitleFormat, opts SubtitleOptions) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteSubtitles(file, segments, format, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeSRT writes numbered SubRip cues
+func writeSRT(w io.Writer, segments []models.Segment, _ SubtitleOptions) error {
+	b := bufio.NewWriter(w)
+	for i, segment := range segments {
+		fmt.Fprintf(b, "%d\n%s --> %s\n%s\n\n", i+1, formatSRTTime(segment.Start), formatSRTTime(segment.End), segment.Text)
+	}
+	return b.Flush()
+}
+
+// writeSBV writes YouTube SubViewer cues
+func writeSBV(w io.Writer, segments []models.Segment, _ SubtitleOptions) error {
+	b := bufio.NewWriter(w)
+	for i, segment := range segments {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "%s,%s\n%s\n", formatSBVTime(segment.Start), formatSBVTime(segment.End), segment.Text)
+	}
+	return b.Flush()
+}
+
+// subtitleJSON is the document of the json subtitle format
+type subtitleJSON struct {
+	Segments []models.Segment `json:"segments"`
+}
+
+// writeSubtitleJSON writes segments as an indented {"segments": [...]}
+// document
+func writeSubtitleJSON(w io.Writer, segments []models.Segment, _ SubtitleOptions) error {
+	if segments == nil {
+		segments = []models.Segment{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(subtitleJSON{Segments: segments})
+}
+
+// clockTime rounds seconds to the nearest 1/scale of a second and splits
+// the result into hours, minutes, seconds and the remaining fraction.
+// Rounding the total first carries into the next second, minute and hour,
+// so 3599.9996 seconds is 1:00:00.000 rather than 0:59:59.999. Negative
+// times are clamped to zero.
+func clockTime(seconds float64, scale int64) (hours, minutes, secs, fraction int64) {
+	total := int64(math.Round(math.Max(0, seconds) * float64(scale)))
+	return total / (3600 * scale), total / (60 * scale) % 60, total / scale % 60, total % scale
+}
+
+// formatSRTTime formats seconds to SRT time format (HH:MM:SS,mmm)
+func formatSRTTime(seconds float64) string {
+	h, m, s, ms := clockTime(seconds, 1000)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, ms)
+}
+
+// formatVTTTime formats seconds to VTT time format (HH:MM:SS.mmm)
+func formatVTTTime(seconds float64) string {
+	h, m, s, ms := clockTime(seconds, 1000)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+// formatSBVTime formats seconds to YouTube SBV time format (H:MM:SS.mmm)
+func formatSBVTime(seconds float64) string {
+	h, m, s, ms := clockTime(seconds, 1000)
+	return fmt.Sprintf("%d:%02d:%02d.%03d", h, m, s, ms)
+}
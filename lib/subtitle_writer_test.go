@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+// hourBoundarySegments have times just short of whole hours, which must
+// round up into the next hour rather than truncate to :59:59.999
+var hourBoundarySegments = []models.Segment{
+	{Start: 4.35, End: 59.9996, Text: "4.35 is 4.349999 in binary"},
+	{Start: 3599.9996, End: 3601.0004, Text: "Rounds into the first hour"},
+	{Start: 3659.9951, End: 7199.9999, Text: "Ends on the second hour"},
+	{Start: 35999.99, End: 36000.5, Text: "Ten hours & counting"},
+}
+
+// TestWriteSubtitles_Golden writes hourBoundarySegments with every writer
+// and compares the result with testdata/subtitles/rounding/*.golden
+func TestWriteSubtitles_Golden(t *testing.T) {
+	cases := []struct {
+		name   string
+		format SubtitleFormat
+		opts   SubtitleOptions
+	}{
+		{"srt", FormatSRT, SubtitleOptions{}},
+		{"vtt", FormatVTT, SubtitleOptions{}},
+		{"sbv", FormatSBV, SubtitleOptions{}},
+		{"ass", FormatASS, SubtitleOptions{}},
+		{"ttml", FormatTTML, SubtitleOptions{}},
+		{"ttml-25fps", FormatTTML, SubtitleOptions{TTML: TTMLOptions{FrameRate: 25}}},
+		{"ttml-29.97df", FormatTTML, SubtitleOptions{TTML: TTMLOptions{FrameRate: 29.97, DropFrame: true}}},
+		{"json", FormatJSON, SubtitleOptions{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			require.NoError(t, WriteSubtitles(&b, hourBoundarySegments, tc.format, tc.opts))
+
+			golden := filepath.Join("testdata", "subtitles", "rounding", "hour_boundaries."+tc.name+".golden")
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(golden, []byte(b.String()), 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), b.String())
+		})
+	}
+}
+
+func TestClockTime(t *testing.T) {
+	for seconds, expected := range map[float64]string{
+		0:          "00:00:00,000",
+		-1:         "00:00:00,000",
+		0.0004:     "00:00:00,000",
+		0.0005:     "00:00:00,001",
+		59.9996:    "00:01:00,000",
+		3599.999:   "00:59:59,999",
+		3599.9995:  "01:00:00,000",
+		86399.9999: "24:00:00,000",
+		359999.999: "99:59:59,999",
+	} {
+		assert.Equal(t, expected, formatSRTTime(seconds), seconds)
+	}
+	assert.Equal(t, "1:00:00.00", formatASSTime(3599.996))
+	assert.Equal(t, "0:59:59.99", formatASSTime(3599.994))
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteSubtitles_Errors(t *testing.T) {
+	err := WriteSubtitles(&strings.Builder{}, hourBoundarySegments, "docx", SubtitleOptions{})
+	assert.ErrorIs(t, err, ErrUnsupportedSubtitleFormat)
+
+	for format := range subtitleWriters {
+		err := WriteSubtitles(failingWriter{}, hourBoundarySegments, format, SubtitleOptions{})
+		assert.EqualError(t, err, "disk full", format)
+	}
+
+	err = WriteSubtitles(&strings.Builder{}, hourBoundarySegments, FormatASS, SubtitleOptions{ASS: ASSOptions{Style: ASSStyle{Alignment: 12}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,235 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"videotranscript-app/models"
+)
+
+// TranscriptFormats lists the formats a finished transcript can be
+// downloaded in: subtitles, JSON, and documents laid out in paragraphs
+var TranscriptFormats = []string{"txt", "srt", "vtt", "ass", "ttml", "json", "md", "html", "docx"}
+
+var transcriptContentTypes = map[string]string{
+	"txt":  "text/plain; charset=utf-8",
+	"srt":  "application/x-subrip; charset=utf-8",
+	"vtt":  "text/vtt; charset=utf-8",
+	"ass":  "text/x-ssa; charset=utf-8",
+	"ttml": "application/ttml+xml; charset=utf-8",
+	"json": "application/json",
+	"md":   "text/markdown; charset=utf-8",
+	"html": "text/html; charset=utf-8",
+	"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+}
+
+var (
+	// ErrUnknownTranscriptFormat is returned for a format not listed in
+	// TranscriptFormats
+	ErrUnknownTranscriptFormat = errors.New("unknown transcript format")
+	// ErrTranscriptNotReady is returned for jobs that have not completed
+	ErrTranscriptNotReady = errors.New("transcript is not available until the job is complete")
+)
+
+// TranscriptFile is a transcript rendered for download
+type TranscriptFile struct {
+	Content     []byte
+	ContentType string
+	Filename    string
+	ETag        string
+}
+
+// ContentDisposition returns the Content-Disposition header value that makes
+// clients save the file under its filename
+func (f *TranscriptFile) ContentDisposition() string {
+	return fmt.Sprintf("attachment; filename=%q", f.Filename)
+}
+
+// NotModified reports whether an If-None-Match header value matches the
+// file's ETag. Weak validators match as well, as RFC 9110 requires for
+// If-None-Match.
+func (f *TranscriptFile) NotModified(ifNoneMatch string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag := strings.TrimPrefix(f.ETag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// transcriptJSON is the document served as transcript.json
+type transcriptJSON struct {
+	JobID       string           `json:"job_id"`
+	VideoID     string           `json:"video_id,omitempty"`
+	Title       string           `json:"title,omitempty"`
+	URL         string           `json:"url"`
+	Engine      string           `json:"engine,omitempty"`
+	Language    string           `json:"language,omitempty"`
+	Transcript  string           `json:"transcript"`
+	Segments    []models.Segment `json:"segments"`
+	Chapters    []models.Chapter `json:"chapters,omitempty"`
+	Duration    float64          `json:"duration_seconds"`
+	CreatedAt   time.Time        `json:"created_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+}
+
+// ParseTranscriptFileName returns the format of a download file name such
+// as "transcript.srt"
+func ParseTranscriptFileName(name string) (string, error) {
+	format, ok := strings.CutPrefix(name, "transcript.")
+	if !ok {
+		return "", ErrUnknownTranscriptFormat
+	}
+	if _, ok := transcriptContentTypes[format]; !ok {
+		return "", ErrUnknownTranscriptFormat
+	}
+	return format, nil
+}
+
+// TranscriptURL returns the download path of a job's transcript in a format
+func TranscriptURL(jobID, format string) string {
+	return fmt.Sprintf("/transcribe/%s/transcript.%s", jobID, format)
+}
+
+// TranscriptOptions control how subtitle and document formats are rendered
+type TranscriptOptions struct {
+	// Captions lays subtitles out in its style unless it is nil
+	Captions *CaptionStyle
+	// SubtitleOptions configure the ass and ttml writers. Their titles and
+	// the TTML language default to the job's.
+	SubtitleOptions
+	// Paragraphs lay out the txt, md, html and docx formats. The zero value
+	// means DefaultParagraphOptions.
+	Paragraphs ParagraphOptions
+}
+
+// RenderTranscript renders a complete job's stored transcript and segments
+// in the given format
+func RenderTranscript(job *models.Job, format string, opts TranscriptOptions) (*TranscriptFile, error) {
+	contentType, ok := transcriptContentTypes[format]
+	if !ok {
+		return nil, ErrUnknownTranscriptFormat
+	}
+	if job.Status != models.StatusComplete {
+		return nil, ErrTranscriptNotReady
+	}
+
+	var content []byte
+	switch format {
+	case "txt", "md", "html", "docx":
+		paragraphOpts := opts.Paragraphs
+		if paragraphOpts == (ParagraphOptions{}) {
+			paragraphOpts = DefaultParagraphOptions
+		}
+		doc := NewTranscriptDocument(job, paragraphOpts)
+		var b bytes.Buffer
+		var err error
+		switch format {
+		case "txt":
+			err = doc.WriteText(&b)
+		case "md":
+			err = doc.WriteMarkdown(&b)
+		case "html":
+			err = doc.WriteHTML(&b)
+		case "docx":
+			err = doc.WriteDOCX(&b)
+		}
+		if err != nil {
+			return nil, err
+		}
+		content = b.Bytes()
+	case "srt", "vtt", "ass", "ttml":
+		subtitleOpts := opts.SubtitleOptions
+		if subtitleOpts.ASS.Title == "" {
+			subtitleOpts.ASS.Title = job.Title
+		}
+		if subtitleOpts.TTML.Language == "" {
+			subtitleOpts.TTML.Language = job.Language
+		}
+		if subtitleOpts.TTML.Title == "" {
+			subtitleOpts.TTML.Title = job.Title
+		}
+		var b bytes.Buffer
+		if err := WriteSubtitles(&b, captionSegments(job.Segments, opts.Captions), SubtitleFormat(format), subtitleOpts); err != nil {
+			return nil, err
+		}
+		content = b.Bytes()
+	case "json":
+		segments := job.Segments
+		if segments == nil {
+			segments = []models.Segment{}
+		}
+		data, err := json.MarshalIndent(transcriptJSON{
+			JobID:       job.ID,
+			VideoID:     job.VideoID,
+			Title:       job.Title,
+			URL:         job.URL,
+			Engine:      job.Engine,
+			Language:    job.Language,
+			Transcript:  job.Transcript,
+			Segments:    segments,
+			Chapters:    job.Chapters,
+			Duration:    transcriptDuration(job),
+			CreatedAt:   job.CreatedAt,
+			CompletedAt: job.CompletedAt,
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		content = data
+	}
+
+	return newTranscriptFile(content, contentType, jobFileName(job, "transcript", format)), nil
+}
+
+// newTranscriptFile wraps rendered content with an ETag hashed from it
+func newTranscriptFile(content []byte, contentType, filename string) *TranscriptFile {
+	sum := sha256.Sum256(content)
+	return &TranscriptFile{
+		Content:     content,
+		ContentType: contentType,
+		Filename:    filename,
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// captionSegments lays segments out as captions, or returns them unchanged
+// for a nil style
+func captionSegments(segments []models.Segment, style *CaptionStyle) []models.Segment {
+	if style == nil {
+		return segments
+	}
+	return LayoutCaptions(segments, *style)
+}
+
+// transcriptDuration prefers the video's duration and falls back to the end
+// of the last segment
+func transcriptDuration(job *models.Job) float64 {
+	if job.Metadata != nil && job.Metadata.Duration > 0 {
+		return job.Metadata.Duration
+	}
+	if n := len(job.Segments); n > 0 {
+		return job.Segments[n-1].End
+	}
+	return 0
+}
+
+// jobFileName names a download of a job, such as transcript_{video_id}.srt
+func jobFileName(job *models.Job, prefix, format string) string {
+	name := job.VideoID
+	if name == "" || name == "unknown" {
+		name = job.ID
+	}
+	return fmt.Sprintf("%s_%s.%s", prefix, name, format)
+}
@@ -2,7 +2,6 @@ package lib
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,57 +13,128 @@ import (
 	"videotranscript-app/models"
 )
 
-func ProcessTranscription(url, jobID string) (string, []models.Segment, error) {
+// TranscriptionResult holds the output of the transcription pipeline
+type TranscriptionResult struct {
+	Transcript string
+	Segments   []models.Segment
+	Engine     string
+	Model      string
+	Language   string
+	// Chapters are the source video's, or generated from Segments
+	Chapters []models.Chapter
+	// AudioPath is the normalized audio, kept when ARTIFACT_STORE_AUDIO is
+	// set; the caller stores it as an artifact and removes the file
+	AudioPath string
+}
+
+// CacheKey returns the key the result is cached under for url
+func (r *TranscriptionResult) CacheKey(url string) string {
+	return models.ResultCacheKey(url, r.Engine, r.Model, r.Language)
+}
+
+// ResultCacheKey returns the key a new transcription of url would be cached
+// under with the engines configured now. A cached result only matches if it
+// came from the engine the pipeline would try first.
+func ResultCacheKey(url string) string {
+	engine, model := preferredEngine()
+	return models.ResultCacheKey(url, engine, model, transcriptLanguage)
+}
+
+// transcriptLanguage is the language every engine currently transcribes in
+const transcriptLanguage = "en"
+
+// StageFunc is called as the pipeline enters each stage
+type StageFunc func(stage models.JobStage, progress int)
+
+func ProcessTranscription(url, jobID string, onStage StageFunc) (*TranscriptionResult, error) {
 	cfg := config.Load()
 
+	if onStage == nil {
+		onStage = func(models.JobStage, int) {}
+	}
+
 	if err := os.MkdirAll(cfg.WorkDir, 0755); err != nil {
-		return "", nil, fmt.Errorf("failed to create work directory: %w", err)
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
 	}
 
 	audioFile := filepath.Join(cfg.WorkDir, fmt.Sprintf("%s.wav", jobID))
 	normalizedAudio := filepath.Join(cfg.WorkDir, fmt.Sprintf("%s_norm.wav", jobID))
 	transcriptFile := filepath.Join(cfg.WorkDir, fmt.Sprintf("%s_transcript.txt", jobID))
+	infoFile := filepath.Join(cfg.WorkDir, fmt.Sprintf("%s.info.json", jobID))
 
+	keepAudio := false
 	defer func() {
 		os.Remove(audioFile)
-		os.Remove(normalizedAudio)
+		if !keepAudio {
+			os.Remove(normalizedAudio)
+		}
 		os.Remove(transcriptFile)
+		os.Remove(infoFile)
 	}()
 
-	if err := downloadAudio(url, audioFile); err != nil {
-		return "", nil, fmt.Errorf("failed to download audio: %w", err)
+	onStage(models.StageDownloading, 10)
+	if err := downloadAudio(url, audioFile, infoFile); err != nil {
+		return nil, err
 	}
 
+	onStage(models.StageExtracting, 30)
 	if err := normalizeAudio(audioFile, normalizedAudio); err != nil {
-		return "", nil, fmt.Errorf("failed to normalize audio: %w", err)
+		return nil, models.NewJobError(models.ErrCodeDecode, fmt.Errorf("failed to normalize audio: %w", err))
 	}
 
-	if err := transcribeAudio(normalizedAudio, transcriptFile); err != nil {
-		return "", nil, fmt.Errorf("failed to transcribe audio: %w", err)
+	onStage(models.StageTranscribing, 50)
+	engine, err := transcribeAudio(normalizedAudio, transcriptFile)
+	if err != nil {
+		return nil, models.NewJobError(models.ErrCodeEngineFailure, fmt.Errorf("failed to transcribe audio: %w", err))
 	}
 
 	transcript, segments, err := models.LoadTranscript(transcriptFile)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to load transcript: %w", err)
+		return nil, models.NewJobError(models.ErrCodeEngineFailure, fmt.Errorf("failed to load transcript: %w", err))
+	}
+
+	var model string
+	if engine == EngineNativeWhisper {
+		_, model = preferredEngine()
 	}
 
-	return transcript, segments, nil
+	result := &TranscriptionResult{
+		Transcript: transcript,
+		Segments:   segments,
+		Chapters:   jobChapters(infoFile, segments),
+		Engine:     engine,
+		Model:      model,
+		Language:   transcriptLanguage,
+	}
+	if cfg.StoreAudio {
+		keepAudio = true
+		result.AudioPath = normalizedAudio
+	}
+	return result, nil
 }
 
-func downloadAudio(url, outputPath string) error {
+// downloadAudio fetches the audio track with yt-dlp, and writes the video's
+// info JSON, which lists its chapters, to infoPath. Failures are returned
+// as classified *models.JobError values.
+func downloadAudio(url, outputPath, infoPath string) error {
 	dl := ytdlp.New().
 		ExtractAudio().
 		AudioFormat("wav").
 		AudioQuality("0").
+		WriteInfoJSON().
 		Output(outputPath)
 
-	result, err := dl.Run(context.Background(), url)
+	result, err := dl.Run(context.Background(), "--output", "infojson:"+infoPath, url)
 	if err != nil {
-		return fmt.Errorf("yt-dlp failed: %w", err)
+		var stderr string
+		if result != nil {
+			stderr = result.Stderr
+		}
+		return classifyDownloadError(fmt.Errorf("failed to download audio: yt-dlp failed: %w", err), stderr)
 	}
 
 	if result.ExitCode != 0 {
-		return fmt.Errorf("yt-dlp failed with code %d: %s", result.ExitCode, result.Stderr)
+		return classifyDownloadError(fmt.Errorf("failed to download audio: yt-dlp failed with code %d: %s", result.ExitCode, result.Stderr), result.Stderr)
 	}
 
 	return nil
@@ -88,7 +158,31 @@ func normalizeAudio(inputPath, outputPath string) error {
 	return nil
 }
 
-func transcribeAudio(audioPath, outputPath string) error {
+// Transcription engine names reported on jobs
+const (
+	EngineNativeWhisper = "whisper.cpp"
+	EngineAssemblyAI    = "assemblyai"
+	EngineWhisperServer = "whisper-server"
+	EngineDemo          = "demo"
+)
+
+// preferredEngine returns the engine transcribeAudio tries first, and its
+// model where the engine has one
+func preferredEngine() (engine, model string) {
+	switch {
+	case os.Getenv("WHISPER_MODEL_PATH") != "":
+		return EngineNativeWhisper, filepath.Base(os.Getenv("WHISPER_MODEL_PATH"))
+	case os.Getenv("ASSEMBLYAI_API_KEY") != "":
+		return EngineAssemblyAI, ""
+	case os.Getenv("WHISPER_SERVER_URL") != "":
+		return EngineWhisperServer, ""
+	default:
+		return EngineDemo, ""
+	}
+}
+
+// transcribeAudio runs the first available engine and returns its name
+func transcribeAudio(audioPath, outputPath string) (string, error) {
 	// Hybrid transcription system with graceful fallbacks
 
 	// 1. Try native whisper.cpp (if model path available)
@@ -98,7 +192,7 @@ func transcribeAudio(audioPath, outputPath string) error {
 			fmt.Printf("Native Whisper transcription failed: %v, falling back...\n", err)
 		} else {
 			fmt.Println("Native Whisper transcription completed successfully")
-			return nil
+			return EngineNativeWhisper, nil
 		}
 	}
 
@@ -109,7 +203,7 @@ func transcribeAudio(audioPath, outputPath string) error {
 			fmt.Printf("AssemblyAI transcription failed: %v, falling back...\n", err)
 		} else {
 			fmt.Println("AssemblyAI transcription completed successfully")
-			return nil
+			return EngineAssemblyAI, nil
 		}
 	}
 
@@ -120,13 +214,13 @@ func transcribeAudio(audioPath, outputPath string) error {
 			fmt.Printf("Whisper server transcription failed: %v, falling back...\n", err)
 		} else {
 			fmt.Println("Whisper server transcription completed successfully")
-			return nil
+			return EngineWhisperServer, nil
 		}
 	}
 
 	// 4. Final fallback to demo transcription
 	fmt.Println("Using demo transcription (no transcription services configured)")
-	return transcribeDemo(audioPath, outputPath)
+	return EngineDemo, transcribeDemo(audioPath, outputPath)
 }
 
 func transcribeWithNativeWhisper(audioPath, outputPath, modelPath string) error {
@@ -149,12 +243,12 @@ func transcribeWithNativeWhisper(audioPath, outputPath, modelPath string) error
 		return fmt.Errorf("transcription failed: %w", err)
 	}
 
-	// Convert to WhisperSegment format
-	whisperSegments := make([]WhisperSegment, len(segments))
+	// Convert to models.Segment format
+	whisperSegments := make([]models.Segment, len(segments))
 	fullTranscript := ""
 
 	for i, seg := range segments {
-		whisperSegments[i] = WhisperSegment{
+		whisperSegments[i] = models.Segment{
 			Start: float64(seg.StartTime) / 1000.0, // Convert milliseconds to seconds
 			End:   float64(seg.EndTime) / 1000.0,   // Convert milliseconds to seconds
 			Text:  seg.Text,
@@ -177,7 +271,7 @@ In today's session, we'll explore the revolutionary capabilities of Model Contex
 Throughout this presentation, we'll demonstrate practical implementations, share real-world use cases, and provide you with actionable insights that you can immediately apply to your own projects.
 The combination of MCP's structured approach and Claude's natural language understanding creates unprecedented opportunities for automation and intelligent task execution.`
 
-	segments := []WhisperSegment{
+	segments := []models.Segment{
 		{Start: 0.0, End: 8.5, Text: "Welcome to this comprehensive video tutorial on building next-generation AI agents using MCP and Claude."},
 		{Start: 8.5, End: 17.2, Text: "In today's session, we'll explore the revolutionary capabilities of Model Context Protocol and how it integrates seamlessly with Claude's advanced reasoning engine."},
 		{Start: 17.2, End: 26.8, Text: "Throughout this presentation, we'll demonstrate practical implementations, share real-world use cases, and provide you with actionable insights that you can immediately apply to your own projects."},
@@ -196,7 +290,7 @@ The presenter walks through practical implementation examples showcasing how MCP
 Key topics covered include workflow automation, intelligent task delegation, and best practices for building robust AI agent architectures.
 The tutorial concludes with actionable recommendations for developers looking to implement these technologies in production environments.`
 
-	segments := []WhisperSegment{
+	segments := []models.Segment{
 		{Start: 0.0, End: 7.8, Text: "This video demonstrates advanced AI agent development techniques using the Model Context Protocol with Claude."},
 		{Start: 7.8, End: 15.6, Text: "The presenter walks through practical implementation examples showcasing how MCP enables seamless integration between different AI systems."},
 		{Start: 15.6, End: 23.4, Text: "Key topics covered include workflow automation, intelligent task delegation, and best practices for building robust AI agent architectures."},
@@ -214,7 +308,7 @@ The audio download and normalization stages completed successfully, demonstratin
 This fallback system ensures continuous functionality while real transcription services are being configured.
 To enable actual transcription, please set ASSEMBLYAI_API_KEY or WHISPER_SERVER_URL environment variables.`
 
-	segments := []WhisperSegment{
+	segments := []models.Segment{
 		{Start: 0.0, End: 6.5, Text: "Demo transcription system: This is a placeholder transcript generated by the native Go transcription pipeline."},
 		{Start: 6.5, End: 12.2, Text: "The audio download and normalization stages completed successfully, demonstrating that the core infrastructure is operational."},
 		{Start: 12.2, End: 17.8, Text: "This fallback system ensures continuous functionality while real transcription services are being configured."},
@@ -224,7 +318,7 @@ To enable actual transcription, please set ASSEMBLYAI_API_KEY or WHISPER_SERVER_
 	return writeTranscriptFiles(transcript, segments, audioPath, outputPath, "Demo")
 }
 
-func writeTranscriptFiles(transcript string, segments []WhisperSegment, audioPath, outputPath, method string) error {
+func writeTranscriptFiles(transcript string, segments []models.Segment, audioPath, outputPath, method string) error {
 	// Write main transcript file
 	if err := os.WriteFile(outputPath, []byte(transcript), 0644); err != nil {
 		return fmt.Errorf("failed to write transcript: %w", err)
@@ -236,14 +330,8 @@ func writeTranscriptFiles(transcript string, segments []WhisperSegment, audioPat
 		baseName := filepath.Base(audioPath)
 		baseName = baseName[:len(baseName)-len(filepath.Ext(baseName))]
 
-		// Write SRT file
-		if err := writeWhisperSegmentsAsSRT(segments, filepath.Join(outputDir, baseName+".srt")); err != nil {
-			fmt.Printf("Warning: failed to write SRT file: %v\n", err)
-		}
-
-		// Write VTT file
-		if err := writeWhisperSegmentsAsVTT(segments, filepath.Join(outputDir, baseName+".vtt")); err != nil {
-			fmt.Printf("Warning: failed to write VTT file: %v\n", err)
+		if _, _, err := GenerateSubtitles(segments, outputDir, baseName); err != nil {
+			fmt.Printf("Warning: failed to write subtitle files: %v\n", err)
 		}
 	}
 
@@ -269,85 +357,3 @@ func GetVideoDuration(url string) (int, error) {
 func parseDuration(duration string) int {
 	return 120
 }
-
-// Whisper data structures
-type WhisperSegment struct {
-	Start float64
-	End   float64
-	Text  string
-}
-
-// writeWhisperSegmentsAsSRT writes Whisper segments in SRT subtitle format
-func writeWhisperSegmentsAsSRT(segments []WhisperSegment, outputPath string) error {
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	for i, segment := range segments {
-		startTime := formatSRTTime(segment.Start)
-		endTime := formatSRTTime(segment.End)
-
-		_, err := fmt.Fprintf(file, "%d\n%s --> %s\n%s\n\n",
-			i+1, startTime, endTime, segment.Text)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// writeWhisperSegmentsAsVTT writes Whisper segments in VTT subtitle format
-func writeWhisperSegmentsAsVTT(segments []WhisperSegment, outputPath string) error {
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Write VTT header
-	if _, err := file.WriteString("WEBVTT\n\n"); err != nil {
-		return err
-	}
-
-	for _, segment := range segments {
-		startTime := formatVTTTime(segment.Start)
-		endTime := formatVTTTime(segment.End)
-
-		_, err := fmt.Fprintf(file, "%s --> %s\n%s\n\n",
-			startTime, endTime, segment.Text)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-
-// formatSRTTime formats seconds as SRT timestamp (HH:MM:SS,mmm)
-func formatSRTTime(seconds float64) string {
-	totalMs := int(seconds * 1000)
-	ms := totalMs % 1000
-	totalSec := totalMs / 1000
-	sec := totalSec % 60
-	totalMin := totalSec / 60
-	min := totalMin % 60
-	hour := totalMin / 60
-
-	return fmt.Sprintf("%02d:%02d:%02d,%03d", hour, min, sec, ms)
-}
-
-// formatVTTTime formats seconds as VTT timestamp (HH:MM:SS.mmm)
-func formatVTTTime(seconds float64) string {
-	totalMs := int(seconds * 1000)
-	ms := totalMs % 1000
-	totalSec := totalMs / 1000
-	sec := totalSec % 60
-	totalMin := totalSec / 60
-	min := totalMin % 60
-	hour := totalMin / 60
-
-	return fmt.Sprintf("%02d:%02d:%02d.%03d", hour, min, sec, ms)
-}
-
@@ -144,6 +144,7 @@ func BenchmarkMemoryUsage_LargeTranscript(b *testing.B) {
 
 	for i := 0; i < b.N; i++ {
 		job := jobs.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+		job.MarkRunning()
 		job.MarkComplete("Large transcript content", segments)
 	}
 }
@@ -225,6 +226,7 @@ func BenchmarkStringBuilder_Transcript(b *testing.B) {
 
 func BenchmarkJSONSerialization_Job(b *testing.B) {
 	job := jobs.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	job.MarkRunning()
 	job.MarkComplete("Test transcript", []jobs.Segment{
 		{Start: 0.0, End: 5.0, Text: "Test segment"},
 	})
@@ -0,0 +1,429 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"videotranscript-app/models"
+)
+
+// TTMLProfile selects the TTML flavour a document is written for
+type TTMLProfile string
+
+const (
+	// TTMLProfileDefault is plain TTML1, also known as DFXP
+	TTMLProfileDefault TTMLProfile = ""
+	// TTMLProfileSMPTE is SMPTE-TT (ST 2052-1)
+	TTMLProfileSMPTE TTMLProfile = "smpte"
+	// TTMLProfileIMSC1 is the IMSC1 text profile
+	TTMLProfileIMSC1 TTMLProfile = "imsc1"
+)
+
+var ttmlProfileDesignators = map[TTMLProfile]string{
+	TTMLProfileSMPTE: "http://www.smpte-ra.org/schemas/2052-1/2010/profiles/smpte-tt-full",
+	TTMLProfileIMSC1: "http://www.w3.org/ns/ttml/profile/imsc1/text",
+}
+
+// TTMLOptions configure TTML export. Without a frame rate, times are
+// written as clock times with milliseconds; with one, as frame-accurate
+// hh:mm:ss:ff timecodes. DropFrame counts 29.97 and 59.94 fps timecodes in
+// SMPTE drop-frame, which needs the smpte time base that IMSC1 forbids.
+type TTMLOptions struct {
+	Profile   TTMLProfile
+	Language  string
+	Title     string
+	FrameRate float64
+	DropFrame bool
+	// Region places cues at the "bottom" (default) or "top" of the frame
+	Region string
+}
+
+// Validate reports options that cannot be written as valid TTML
+func (o TTMLOptions) Validate() error {
+	if _, ok := ttmlProfileDesignators[o.Profile]; !ok && o.Profile != TTMLProfileDefault {
+		return fmt.Errorf("profile must be ttml, smpte or imsc1")
+	}
+	if o.FrameRate != 0 && (o.FrameRate < 1 || o.FrameRate > 120) {
+		return fmt.Errorf("frame_rate must be between 1 and 120")
+	}
+	if o.Region != "" && o.Region != "bottom" && o.Region != "top" {
+		return fmt.Errorf("region must be bottom or top")
+	}
+	if o.DropFrame {
+		if nominal, ntsc := nominalFrameRate(o.FrameRate); !ntsc || (nominal != 30 && nominal != 60) {
+			return fmt.Errorf("drop_frame requires a frame_rate of 29.97 or 59.94")
+		}
+		if o.Profile == TTMLProfileIMSC1 {
+			return fmt.Errorf("imsc1 does not allow drop-frame timecodes")
+		}
+	}
+	return nil
+}
+
+// nominalFrameRate returns the integer frame rate TTML declares and whether
+// the actual rate is that rate times 1000/1001, as NTSC rates are
+func nominalFrameRate(frameRate float64) (int, bool) {
+	nominal := math.Round(frameRate)
+	if math.Abs(frameRate-nominal) < 0.001 {
+		return int(nominal), false
+	}
+	ntsc := math.Round(frameRate * 1001 / 1000)
+	if math.Abs(frameRate-ntsc*1000/1001) < 0.01 {
+		return int(ntsc), true
+	}
+	return int(nominal), false
+}
+
+// WriteTTML writes segments to w as a TTML document with a default style
+// and top and bottom regions. Lines of multi-line cues become <br/>.
+func WriteTTML(w io.Writer, segments []models.Segment, opts TTMLOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if opts.Language == "" || opts.Language == "auto" {
+		opts.Language = "en"
+	}
+	if opts.Region == "" {
+		opts.Region = "bottom"
+	}
+
+	b := bufio.NewWriter(w)
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" xmlns:tts="http://www.w3.org/ns/ttml#styling" xmlns:ttm="http://www.w3.org/ns/ttml#metadata"`)
+	if opts.Profile == TTMLProfileSMPTE {
+		b.WriteString(` xmlns:smpte="http://www.smpte-ra.org/schemas/2052-1/2010/smpte-tt"`)
+	}
+	fmt.Fprintf(b, ` xml:lang="%s"`, xmlEscape(opts.Language))
+	if designator, ok := ttmlProfileDesignators[opts.Profile]; ok {
+		fmt.Fprintf(b, ` ttp:profile="%s"`, designator)
+	}
+	if opts.FrameRate > 0 {
+		nominal, ntsc := nominalFrameRate(opts.FrameRate)
+		if opts.DropFrame {
+			b.WriteString(` ttp:timeBase="smpte" ttp:dropMode="dropNTSC"`)
+		} else {
+			b.WriteString(` ttp:timeBase="media"`)
+		}
+		fmt.Fprintf(b, ` ttp:frameRate="%d"`, nominal)
+		if ntsc {
+			b.WriteString(` ttp:frameRateMultiplier="1000 1001"`)
+		}
+	} else {
+		b.WriteString(` ttp:timeBase="media"`)
+	}
+	b.WriteString(">\n")
+
+	b.WriteString("  <head>\n")
+	if opts.Title != "" {
+		fmt.Fprintf(b, "    <metadata>\n      <ttm:title>%s</ttm:title>\n    </metadata>\n", xmlEscape(opts.Title))
+	}
+	b.WriteString("    <styling>\n")
+	b.WriteString(`      <style xml:id="default" tts:fontFamily="proportionalSansSerif" tts:fontSize="100%" tts:lineHeight="125%" tts:color="white" tts:backgroundColor="black" tts:textAlign="center"/>` + "\n")
+	b.WriteString("    </styling>\n")
+	b.WriteString("    <layout>\n")
+	b.WriteString(`      <region xml:id="bottom" tts:origin="10% 70%" tts:extent="80% 20%" tts:displayAlign="after"/>` + "\n")
+	b.WriteString(`      <region xml:id="top" tts:origin="10% 10%" tts:extent="80% 20%" tts:displayAlign="before"/>` + "\n")
+	b.WriteString("    </layout>\n")
+	b.WriteString("  </head>\n")
+
+	fmt.Fprintf(b, "  <body style=\"default\" region=\"%s\">\n    <div>\n", opts.Region)
+	for i, segment := range segments {
+		lines := strings.Split(strings.TrimSpace(segment.Text), "\n")
+		for j, line := range lines {
+			lines[j] = xmlEscape(strings.TrimSpace(line))
+		}
+		fmt.Fprintf(b, "      <p xml:id=\"c%d\" begin=\"%s\" end=\"%s\">%s</p>\n",
+			i+1, formatTTMLTime(segment.Start, opts), formatTTMLTime(segment.End, opts), strings.Join(lines, "<br/>"))
+	}
+	b.WriteString("    </div>\n  </body>\n</tt>\n")
+
+	return b.Flush()
+}
+
+// formatTTMLTime formats seconds as hh:mm:ss.mmm, or as hh:mm:ss:ff when the
+// options have a frame rate
+func formatTTMLTime(seconds float64, opts TTMLOptions) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	if opts.FrameRate <= 0 {
+		return formatVTTTime(seconds)
+	}
+
+	nominal, _ := nominalFrameRate(opts.FrameRate)
+	if opts.DropFrame {
+		return dropFrameTimecode(int64(math.Round(seconds*opts.FrameRate)), nominal)
+	}
+
+	// In the media time base, frames count at the actual rate within each
+	// whole second
+	whole := math.Floor(seconds)
+	frames := int64(math.Round((seconds - whole) * opts.FrameRate))
+	if frames >= int64(nominal) {
+		whole++
+		frames = 0
+	}
+	s := int64(whole)
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", s/3600, s/60%60, s%60, frames)
+}
+
+// dropFrameTimecode labels a frame count in SMPTE drop-frame timecode, which
+// skips the first two (at 30 fps) or four (at 60 fps) frame numbers of every
+// minute except each tenth, keeping labels in step with the 1000/1001 clock
+func dropFrameTimecode(frame int64, nominal int) string {
+	fps := int64(nominal)
+	dropped := fps / 15
+	perMinute := fps*60 - dropped
+	perTenMinutes := perMinute*10 + dropped
+
+	tens, rest := frame/perTenMinutes, frame%perTenMinutes
+	frame += 9 * dropped * tens
+	if rest > dropped {
+		frame += dropped * ((rest - dropped) / perMinute)
+	}
+
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", frame/(fps*3600), frame/(fps*60)%60, frame/fps%60, frame%fps)
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// ParseTTMLOptions reads TTML export options from query parameters:
+// profile, frame_rate, drop_frame and region
+func ParseTTMLOptions(query func(string) string) (TTMLOptions, error) {
+	opts := TTMLOptions{Region: query("region")}
+	switch profile := query("profile"); profile {
+	case "", "ttml", "dfxp":
+	default:
+		opts.Profile = TTMLProfile(profile)
+	}
+	if value := query("frame_rate"); value != "" {
+		frameRate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return opts, fmt.Errorf("frame_rate must be a number")
+		}
+		opts.FrameRate = frameRate
+	}
+	if value := query("drop_frame"); value != "" {
+		dropFrame, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("drop_frame must be true or false")
+		}
+		opts.DropFrame = dropFrame
+	}
+	return opts, opts.Validate()
+}
+
+var (
+	ttmlClockRegex  = regexp.MustCompile(`^(\d{2,}):(\d{2}):(\d{2})(?:(\.\d+)|:(\d{2,})(?:\.\d+)?)?$`)
+	ttmlOffsetRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)(h|ms|m|s|f|t)$`)
+)
+
+// ttmlTiming holds the parameters of a tt element that time expressions
+// depend on
+type ttmlTiming struct {
+	frameRate float64
+	nominal   int
+	tickRate  float64
+	// dropFrames is the number of frame labels skipped each minute in the
+	// smpte time base's dropNTSC mode
+	dropFrames int
+}
+
+func newTTMLTiming(attrs map[string]string) ttmlTiming {
+	timing := ttmlTiming{frameRate: 30, nominal: 30, tickRate: 1}
+	if n, err := strconv.Atoi(attrs["frameRate"]); err == nil && n > 0 {
+		timing.frameRate, timing.nominal = float64(n), n
+		timing.tickRate = float64(n)
+	}
+	if parts := strings.Fields(attrs["frameRateMultiplier"]); len(parts) == 2 {
+		num, err1 := strconv.ParseFloat(parts[0], 64)
+		den, err2 := strconv.ParseFloat(parts[1], 64)
+		if err1 == nil && err2 == nil && num > 0 && den > 0 {
+			timing.frameRate *= num / den
+		}
+	}
+	if tickRate, err := strconv.ParseFloat(attrs["tickRate"], 64); err == nil && tickRate > 0 {
+		timing.tickRate = tickRate
+	}
+	if attrs["timeBase"] == "smpte" && attrs["dropMode"] == "dropNTSC" {
+		timing.dropFrames = timing.nominal / 15
+	}
+	return timing
+}
+
+// parse converts a TTML time expression, a clock time such as
+// "00:00:01.500" or "00:00:01:12", or an offset such as "1.5s" or "36f",
+// into seconds
+func (t ttmlTiming) parse(expr string) (float64, error) {
+	expr = strings.TrimSpace(expr)
+	if m := ttmlClockRegex.FindStringSubmatch(expr); m != nil {
+		hours, _ := strconv.Atoi(m[1])
+		minutes, _ := strconv.Atoi(m[2])
+		seconds, _ := strconv.Atoi(m[3])
+		whole := float64((hours*60+minutes)*60 + seconds)
+		if m[5] == "" {
+			fraction := 0.0
+			if m[4] != "" {
+				fraction, _ = strconv.ParseFloat("0"+m[4], 64)
+			}
+			return whole + fraction, nil
+		}
+
+		frames, _ := strconv.Atoi(m[5])
+		if t.dropFrames > 0 {
+			// Undo the labels skipped in every minute but each tenth
+			elapsed := hours*60 + minutes
+			label := int(whole)*t.nominal + frames
+			return float64(label-t.dropFrames*(elapsed-elapsed/10)) / t.frameRate, nil
+		}
+		return whole + float64(frames)/t.frameRate, nil
+	}
+
+	if m := ttmlOffsetRegex.FindStringSubmatch(expr); m != nil {
+		value, _ := strconv.ParseFloat(m[1], 64)
+		switch m[2] {
+		case "h":
+			return value * 3600, nil
+		case "m":
+			return value * 60, nil
+		case "ms":
+			return value / 1000, nil
+		case "f":
+			return value / t.frameRate, nil
+		case "t":
+			return value / t.tickRate, nil
+		}
+		return value, nil
+	}
+
+	return 0, fmt.Errorf("invalid TTML time expression %q", expr)
+}
+
+// ParseTTML parses the p elements of a TTML, DFXP, SMPTE-TT or IMSC1
+// document. Times honour the document's frame rate, tick rate and
+// drop-frame mode and the begin offsets of enclosing body and div elements;
+// br elements become line breaks.
+func ParseTTML(r io.Reader) ([]models.Segment, error) {
+	decoder := xml.NewDecoder(r)
+	// Input is read as UTF-8 whatever the declaration says; ConvertSubtitles
+	// normalizes the encoding before parsing
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	timing := newTTMLTiming(nil)
+	var offsets []float64
+	var segments []models.Segment
+	var text *strings.Builder
+	var start, end float64
+	found := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid TTML: %w", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			attrs := make(map[string]string, len(element.Attr))
+			for _, attr := range element.Attr {
+				attrs[attr.Name.Local] = attr.Value
+			}
+			offset := 0.0
+			if len(offsets) > 0 {
+				offset = offsets[len(offsets)-1]
+			}
+
+			switch {
+			case element.Name.Local == "tt":
+				timing = newTTMLTiming(attrs)
+				found = true
+			case text != nil:
+				if element.Name.Local == "br" {
+					text.WriteString("\u2028")
+				}
+			case element.Name.Local == "p":
+				var ok bool
+				start, end, ok, err = timing.interval(attrs, offset)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					text = &strings.Builder{}
+				}
+			}
+
+			if begin := attrs["begin"]; begin != "" && text == nil {
+				value, err := timing.parse(begin)
+				if err != nil {
+					return nil, err
+				}
+				offset += value
+			}
+			offsets = append(offsets, offset)
+
+		case xml.EndElement:
+			offsets = offsets[:len(offsets)-1]
+			if element.Name.Local == "p" && text != nil {
+				segments = appendCue(segments, start, end, ttmlText(text.String()), nil)
+				text = nil
+			}
+
+		case xml.CharData:
+			if text != nil {
+				text.Write(element)
+			}
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("invalid TTML: no tt element")
+	}
+	return finishCues(segments, true)
+}
+
+// interval returns the begin and end of a p element. It reports false for
+// a p without an end or dur, which has no usable timing.
+func (t ttmlTiming) interval(attrs map[string]string, offset float64) (float64, float64, bool, error) {
+	start := offset
+	if begin := attrs["begin"]; begin != "" {
+		value, err := t.parse(begin)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		start += value
+	}
+	if end := attrs["end"]; end != "" {
+		value, err := t.parse(end)
+		return start, offset + value, err == nil, err
+	}
+	if dur := attrs["dur"]; dur != "" {
+		value, err := t.parse(dur)
+		return start, start + value, err == nil, err
+	}
+	return 0, 0, false, nil
+}
+
+// ttmlText collapses the whitespace of p content and turns br markers into
+// line breaks
+func ttmlText(content string) string {
+	lines := strings.Split(content, "\u2028")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
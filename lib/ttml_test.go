@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+func TestWriteTTML(t *testing.T) {
+	segments := []models.Segment{
+		{Start: 0.5, End: 2.25, Text: "Fish & chips"},
+		{Start: 3661, End: 3663.5, Text: "First line\nSecond <line>"},
+	}
+
+	var b strings.Builder
+	require.NoError(t, WriteTTML(&b, segments, TTMLOptions{Language: "en-GB", Title: "Demo"}))
+	content := b.String()
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" xmlns:tts="http://www.w3.org/ns/ttml#styling" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xml:lang="en-GB" ttp:timeBase="media">
+  <head>
+    <metadata>
+      <ttm:title>Demo</ttm:title>
+    </metadata>
+    <styling>
+      <style xml:id="default" tts:fontFamily="proportionalSansSerif" tts:fontSize="100%" tts:lineHeight="125%" tts:color="white" tts:backgroundColor="black" tts:textAlign="center"/>
+    </styling>
+    <layout>
+      <region xml:id="bottom" tts:origin="10% 70%" tts:extent="80% 20%" tts:displayAlign="after"/>
+      <region xml:id="top" tts:origin="10% 10%" tts:extent="80% 20%" tts:displayAlign="before"/>
+    </layout>
+  </head>
+  <body style="default" region="bottom">
+    <div>
+      <p xml:id="c1" begin="00:00:00.500" end="00:00:02.250">Fish &amp; chips</p>
+      <p xml:id="c2" begin="01:01:01.000" end="01:01:03.500">First line<br/>Second &lt;line&gt;</p>
+    </div>
+  </body>
+</tt>
+`, content)
+
+	// The document is well-formed XML
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	for {
+		_, err := decoder.Token()
+		if err != nil {
+			assert.Equal(t, "EOF", err.Error())
+			break
+		}
+	}
+}
+
+func TestWriteTTML_FrameRates(t *testing.T) {
+	segments := []models.Segment{{Start: 1.5, End: 60.06, Text: "Hi"}}
+
+	var b strings.Builder
+	require.NoError(t, WriteTTML(&b, segments, TTMLOptions{FrameRate: 25}))
+	content := b.String()
+	assert.Contains(t, content, `ttp:timeBase="media" ttp:frameRate="25">`)
+	assert.Contains(t, content, `begin="00:00:01:13" end="00:01:00:02"`)
+
+	b.Reset()
+	require.NoError(t, WriteTTML(&b, segments, TTMLOptions{FrameRate: 23.976, Profile: TTMLProfileIMSC1}))
+	content = b.String()
+	assert.Contains(t, content, `ttp:profile="http://www.w3.org/ns/ttml/profile/imsc1/text" ttp:timeBase="media" ttp:frameRate="24" ttp:frameRateMultiplier="1000 1001">`)
+
+	b.Reset()
+	require.NoError(t, WriteTTML(&b, segments, TTMLOptions{FrameRate: 29.97, DropFrame: true, Profile: TTMLProfileSMPTE}))
+	content = b.String()
+	assert.Contains(t, content, `xmlns:smpte="http://www.smpte-ra.org/schemas/2052-1/2010/smpte-tt"`)
+	assert.Contains(t, content, `ttp:timeBase="smpte" ttp:dropMode="dropNTSC" ttp:frameRate="30" ttp:frameRateMultiplier="1000 1001">`)
+	// 60.06s is frame 1800 at 29.97 fps, labelled 00:01:00;02 because frame
+	// numbers 0 and 1 of the first minute are dropped
+	assert.Contains(t, content, `begin="00:00:01:15" end="00:01:00:02"`)
+}
+
+func TestDropFrameTimecode(t *testing.T) {
+	tests := []struct {
+		frame    int64
+		nominal  int
+		expected string
+	}{
+		{0, 30, "00:00:00:00"},
+		{1799, 30, "00:00:59:29"},
+		{1800, 30, "00:01:00:02"},
+		{17982, 30, "00:10:00:00"},
+		{107892, 30, "01:00:00:00"},
+		{3600, 60, "00:01:00:04"},
+		{215784, 60, "01:00:00:00"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, dropFrameTimecode(tt.frame, tt.nominal), tt.frame)
+	}
+}
+
+func TestParseTTMLOptions(t *testing.T) {
+	query := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	opts, err := ParseTTMLOptions(query(map[string]string{"profile": "dfxp", "frame_rate": "59.94", "drop_frame": "true"}))
+	require.NoError(t, err)
+	assert.Equal(t, TTMLProfileDefault, opts.Profile)
+	assert.True(t, opts.DropFrame)
+
+	for _, values := range []map[string]string{
+		{"profile": "webvtt"},
+		{"frame_rate": "fast"},
+		{"frame_rate": "25", "drop_frame": "true"},
+		{"frame_rate": "29.97", "drop_frame": "true", "profile": "imsc1"},
+		{"region": "left"},
+	} {
+		_, err := ParseTTMLOptions(query(values))
+		assert.Error(t, err, values)
+	}
+}
@@ -0,0 +1,365 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lrstanley/go-ytdlp"
+	ffmpeg_go "github.com/u2takey/ffmpeg-go"
+
+	"videotranscript-app/config"
+	"videotranscript-app/models"
+)
+
+// VideoRenderMode selects how captions are added to a rendered video
+type VideoRenderMode string
+
+const (
+	// RenderBurnIn draws the captions into the picture with ffmpeg's ass
+	// filter, so they show on every player and platform
+	RenderBurnIn VideoRenderMode = "burn"
+	// RenderSoftSub muxes the captions as a subtitle track viewers can
+	// switch on: mov_text in MP4 and WebVTT in MKV
+	RenderSoftSub VideoRenderMode = "soft"
+)
+
+// ErrNoCaptions is returned when a render would contain no captions, such
+// as for a clip of a silent part of the video
+var ErrNoCaptions error = models.NewJobError(models.ErrCodeNoCaptions, errors.New("no captions in the rendered range"))
+
+// RenderOptions configure a captioned video render
+type RenderOptions struct {
+	// Mode defaults to RenderBurnIn
+	Mode VideoRenderMode
+	// Container is mp4 (the default) or mkv
+	Container string
+	// Start and End cut a clip from the source video, in seconds. An End
+	// of 0 runs to the end of the video.
+	Start float64
+	End   float64
+	// Captions lays the captions out in its style unless it is nil
+	Captions *CaptionStyle
+	// ASS styles burned-in captions; the title defaults to the job's
+	ASS ASSOptions
+	// Language tags the soft subtitle track with an ISO 639-2 code such as
+	// "eng"
+	Language string
+}
+
+var iso6392Regex = regexp.MustCompile(`^[a-z]{3}$`)
+
+// Validate reports unsupported render options
+func (o RenderOptions) Validate() error {
+	switch o.Mode {
+	case "", RenderBurnIn, RenderSoftSub:
+	default:
+		return fmt.Errorf("mode must be burn or soft")
+	}
+	switch o.Container {
+	case "", "mp4", "mkv":
+	default:
+		return fmt.Errorf("container must be mp4 or mkv")
+	}
+	if o.Start < 0 || o.End < 0 {
+		return fmt.Errorf("start and end must not be negative")
+	}
+	if o.End != 0 && o.End <= o.Start {
+		return fmt.Errorf("end must be after start")
+	}
+	if o.Language != "" && !iso6392Regex.MatchString(o.Language) {
+		return fmt.Errorf("language must be a three-letter ISO 639-2 code such as eng")
+	}
+	if o.Captions != nil {
+		if err := o.Captions.Validate(); err != nil {
+			return err
+		}
+	}
+	return o.ASS.Validate()
+}
+
+func (o RenderOptions) withDefaults() RenderOptions {
+	if o.Mode == "" {
+		o.Mode = RenderBurnIn
+	}
+	if o.Container == "" {
+		o.Container = "mp4"
+	}
+	return o
+}
+
+// ParseRenderOptions reads render options from query parameters: mode,
+// container, start, end and language, the caption layout parameters preset,
+// max_line_length, max_lines and max_cps, and the ASS style parameters read
+// by ParseASSOptions
+func ParseRenderOptions(query func(string) string) (RenderOptions, error) {
+	opts := RenderOptions{
+		Mode:      VideoRenderMode(query("mode")),
+		Container: query("container"),
+		Language:  query("language"),
+	}
+	floats := map[string]*float64{"start": &opts.Start, "end": &opts.End}
+	for name, target := range floats {
+		if value := query(name); value != "" {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be a number of seconds", name)
+			}
+			*target = f
+		}
+	}
+
+	captions, err := ParseCaptionOptions(query("preset"), query("max_line_length"), query("max_lines"), query("max_cps"))
+	if err != nil {
+		return opts, err
+	}
+	if opts.Captions, err = captions.Style(); err != nil {
+		return opts, err
+	}
+	if opts.ASS, err = ParseASSOptions(query); err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
+// VideoRenderName names a render by its mode and container, as in
+// "burn.mp4". Renders with the same name replace each other.
+func VideoRenderName(opts RenderOptions) string {
+	opts = opts.withDefaults()
+	return fmt.Sprintf("%s.%s", opts.Mode, opts.Container)
+}
+
+// VideoArtifactKey returns the key of the job's render with the given name
+func VideoArtifactKey(jobID, name string) string {
+	return ArtifactKey(jobID, "video_"+name)
+}
+
+// VideoArtifact describes the "video" artifact a render of the job with
+// opts is stored as
+func VideoArtifact(jobID string, opts RenderOptions) models.Artifact {
+	return models.Artifact{
+		Kind:   "video",
+		Format: opts.withDefaults().Container,
+		Key:    VideoArtifactKey(jobID, VideoRenderName(opts)),
+	}
+}
+
+// RenderJobVideo renders a complete job's captions into its video and
+// stores the result as VideoArtifact. The video is read from upload,
+// or downloaded from the job's URL with yt-dlp if upload is nil. The caller
+// adds the returned "video" artifact to the job and saves it.
+func RenderJobVideo(ctx context.Context, store ArtifactStore, job *models.Job, upload io.Reader, opts RenderOptions) (models.Artifact, error) {
+	if err := opts.Validate(); err != nil {
+		return models.Artifact{}, err
+	}
+	if job.Status != models.StatusComplete {
+		return models.Artifact{}, ErrTranscriptNotReady
+	}
+	opts = opts.withDefaults()
+	if opts.ASS.Title == "" {
+		opts.ASS.Title = job.Title
+	}
+
+	if err := os.MkdirAll(config.Load().WorkDir, 0755); err != nil {
+		return models.Artifact{}, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(config.Load().WorkDir, job.ID+"_render_")
+	if err != nil {
+		return models.Artifact{}, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	videoPath := filepath.Join(dir, "source.mp4")
+	if upload != nil {
+		file, err := os.Create(videoPath)
+		if err != nil {
+			return models.Artifact{}, err
+		}
+		_, err = io.Copy(file, upload)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return models.Artifact{}, fmt.Errorf("failed to save uploaded video: %w", err)
+		}
+	} else if err := DownloadVideo(ctx, job.URL, videoPath); err != nil {
+		return models.Artifact{}, err
+	}
+
+	outputPath := filepath.Join(dir, "captioned."+opts.Container)
+	if err := RenderCaptionedVideo(ctx, videoPath, outputPath, job.Segments, opts); err != nil {
+		return models.Artifact{}, err
+	}
+
+	output, err := os.Open(outputPath)
+	if err != nil {
+		return models.Artifact{}, err
+	}
+	defer output.Close()
+
+	artifact := VideoArtifact(job.ID, opts)
+	artifact.Size, err = store.Put(ctx, artifact.Key, output, ArtifactContentType(artifact.Key))
+	if err != nil {
+		return models.Artifact{}, fmt.Errorf("failed to store video: %w", err)
+	}
+	return artifact, nil
+}
+
+// DownloadVideo fetches a video of at most 1080p with yt-dlp and merges it
+// into an MP4 file. Failures are returned as classified *models.JobError
+// values.
+func DownloadVideo(ctx context.Context, url, outputPath string) error {
+	dl := ytdlp.New().
+		NoPlaylist().
+		Format("bv*[height<=1080]+ba/b[height<=1080]/b").
+		MergeOutputFormat("mp4").
+		Output(outputPath)
+
+	result, err := dl.Run(ctx, url)
+	if err != nil {
+		var stderr string
+		if result != nil {
+			stderr = result.Stderr
+		}
+		return classifyDownloadError(fmt.Errorf("failed to download video: yt-dlp failed: %w", err), stderr)
+	}
+	if result.ExitCode != 0 {
+		return classifyDownloadError(fmt.Errorf("failed to download video: yt-dlp failed with code %d: %s", result.ExitCode, result.Stderr), result.Stderr)
+	}
+	return nil
+}
+
+// RenderCaptionedVideo writes the video at videoPath with the captions of
+// segments to outputPath, cut to the options' clip
+func RenderCaptionedVideo(ctx context.Context, videoPath, outputPath string, segments []models.Segment, opts RenderOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	opts = opts.withDefaults()
+
+	captions := clipSegments(captionSegments(segments, opts.Captions), opts.Start, opts.End)
+	if len(captions) == 0 {
+		return ErrNoCaptions
+	}
+
+	format := renderSubtitleFormat(opts)
+	subtitlePath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".captions." + string(format)
+	if err := WriteSubtitleFile(subtitlePath, captions, format, SubtitleOptions{ASS: opts.ASS}); err != nil {
+		return fmt.Errorf("failed to write captions: %w", err)
+	}
+	defer os.Remove(subtitlePath)
+
+	var stderr bytes.Buffer
+	err := renderCommand(ctx, videoPath, subtitlePath, outputPath, opts).
+		WithErrorOutput(&stderr).
+		OverWriteOutput().
+		Run()
+	if err != nil {
+		return fmt.Errorf("ffmpeg render failed: %w: %s", err, lastLines(stderr.String(), 5))
+	}
+	return nil
+}
+
+// renderSubtitleFormat is the caption file ffmpeg reads: ASS for burn-in,
+// so the style applies, and otherwise the format closest to the track codec
+func renderSubtitleFormat(opts RenderOptions) SubtitleFormat {
+	switch {
+	case opts.Mode == RenderBurnIn:
+		return FormatASS
+	case opts.Container == "mkv":
+		return FormatVTT
+	default:
+		return FormatSRT
+	}
+}
+
+// renderCommand builds the ffmpeg command of a render. Burn-in re-encodes
+// the picture with libx264; soft subtitles copy the video and audio and
+// add the captions as a third stream.
+func renderCommand(ctx context.Context, videoPath, subtitlePath, outputPath string, opts RenderOptions) *ffmpeg_go.Stream {
+	inputArgs := ffmpeg_go.KwArgs{}
+	if opts.Start > 0 {
+		inputArgs["ss"] = strconv.FormatFloat(opts.Start, 'f', 3, 64)
+	}
+	if opts.End > 0 {
+		inputArgs["t"] = strconv.FormatFloat(opts.End-opts.Start, 'f', 3, 64)
+	}
+	video := ffmpeg_go.Input(videoPath, inputArgs)
+
+	outputArgs := ffmpeg_go.KwArgs{}
+	if opts.Container == "mp4" {
+		outputArgs["movflags"] = "+faststart"
+	}
+
+	if opts.Mode == RenderBurnIn {
+		outputArgs["vf"] = "ass=" + ffmpegFilterEscape(subtitlePath)
+		outputArgs["c:v"] = "libx264"
+		outputArgs["preset"] = "veryfast"
+		outputArgs["crf"] = "20"
+		outputArgs["pix_fmt"] = "yuv420p"
+		outputArgs["c:a"] = "aac"
+		return ffmpeg_go.OutputContext(ctx, []*ffmpeg_go.Stream{video}, outputPath, outputArgs)
+	}
+
+	codec := "mov_text"
+	if opts.Container == "mkv" {
+		codec = "webvtt"
+	}
+	outputArgs["c:v"] = "copy"
+	outputArgs["c:a"] = "copy"
+	outputArgs["c:s"] = codec
+	if opts.Language != "" {
+		outputArgs["metadata:s:s:0"] = "language=" + opts.Language
+	}
+	captions := ffmpeg_go.Input(subtitlePath)
+	streams := []*ffmpeg_go.Stream{video.Get("v:0"), video.Get("a?"), captions}
+	return ffmpeg_go.OutputContext(ctx, streams, outputPath, outputArgs)
+}
+
+// clipSegments moves segments to the timeline of a clip from start to end,
+// dropping cues outside it and cutting those that straddle its ends. An end
+// of 0 keeps everything after start.
+func clipSegments(segments []models.Segment, start, end float64) []models.Segment {
+	if start > 0 {
+		segments = retimeSegments(segments, ConvertOptions{Offset: -start})
+	}
+	if end == 0 {
+		return segments
+	}
+
+	length := end - start
+	var clipped []models.Segment
+	for _, segment := range segments {
+		if segment.Start >= length {
+			break
+		}
+		segment.End = min(segment.End, length)
+		clipped = append(clipped, segment)
+	}
+	return clipped
+}
+
+// ffmpegFilterEscape escapes a filter option value, such as a file name,
+// for an ffmpeg filtergraph. Values are escaped once for the option parser
+// and again for the graph parser.
+func ffmpegFilterEscape(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `:`, `\:`, `'`, `\'`).Replace(value)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(value)
+}
+
+// lastLines returns the last n lines of ffmpeg's output, where it reports
+// the error
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
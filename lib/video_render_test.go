@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+func TestRenderCommand(t *testing.T) {
+	ctx := context.Background()
+
+	burn := renderCommand(ctx, "/work/source.mp4", "/work/it's:here.ass", "/work/out.mp4",
+		RenderOptions{Mode: RenderBurnIn, Container: "mp4", Start: 10, End: 25.5})
+	assert.Equal(t, []string{
+		"-ss", "10.000", "-t", "15.500", "-i", "/work/source.mp4",
+		"-c:a", "aac", "-c:v", "libx264", "-crf", "20", "-movflags", "+faststart", "-pix_fmt", "yuv420p",
+		"-preset", "veryfast", "-vf", `ass=/work/it\\\'s\\:here.ass`, "/work/out.mp4",
+	}, burn.GetArgs())
+
+	soft := renderCommand(ctx, "/work/source.mp4", "/work/out.captions.srt", "/work/out.mp4",
+		RenderOptions{Mode: RenderSoftSub, Container: "mp4", Language: "eng"})
+	assert.Equal(t, []string{
+		"-i", "/work/source.mp4", "-i", "/work/out.captions.srt",
+		"-map", "0:v:0", "-map", "0:a?", "-map", "1",
+		"-c:a", "copy", "-c:s", "mov_text", "-c:v", "copy", "-metadata:s:s:0", "language=eng",
+		"-movflags", "+faststart", "/work/out.mp4",
+	}, soft.GetArgs())
+
+	mkv := renderCommand(ctx, "/work/source.mp4", "/work/out.captions.vtt", "/work/out.mkv",
+		RenderOptions{Mode: RenderSoftSub, Container: "mkv"})
+	assert.Equal(t, []string{
+		"-i", "/work/source.mp4", "-i", "/work/out.captions.vtt",
+		"-map", "0:v:0", "-map", "0:a?", "-map", "1",
+		"-c:a", "copy", "-c:s", "webvtt", "-c:v", "copy", "/work/out.mkv",
+	}, mkv.GetArgs())
+}
+
+func TestClipSegments(t *testing.T) {
+	segments := []models.Segment{
+		{Start: 0, End: 4, Text: "Before"},
+		{Start: 9, End: 12, Text: "Straddles the start"},
+		{Start: 15, End: 18, Text: "Inside"},
+		{Start: 24, End: 27, Text: "Straddles the end"},
+		{Start: 30, End: 32, Text: "After"},
+	}
+
+	clipped := clipSegments(segments, 10, 25)
+	assert.Equal(t, []models.Segment{
+		{Start: 0, End: 2, Text: "Straddles the start"},
+		{Start: 5, End: 8, Text: "Inside"},
+		{Start: 14, End: 15, Text: "Straddles the end"},
+	}, clipped)
+
+	assert.Equal(t, segments, clipSegments(segments, 0, 0))
+	assert.Empty(t, clipSegments(segments, 40, 50))
+}
+
+func TestParseRenderOptions(t *testing.T) {
+	query := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	opts, err := ParseRenderOptions(query(map[string]string{
+		"mode": "soft", "container": "mkv", "start": "1.5", "end": "30", "language": "deu",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, RenderSoftSub, opts.Mode)
+	assert.Equal(t, "mkv", opts.Container)
+	assert.Equal(t, 1.5, opts.Start)
+	assert.Equal(t, 30.0, opts.End)
+	assert.Equal(t, "deu", opts.Language)
+
+	assert.Equal(t, models.Artifact{Kind: "video", Format: "mkv", Key: "jobs/job-1/video_soft.mkv"}, VideoArtifact("job-1", opts))
+
+	opts, err = ParseRenderOptions(query(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "burn.mp4", VideoRenderName(opts))
+	opts = opts.withDefaults()
+	assert.Equal(t, RenderBurnIn, opts.Mode)
+	assert.Equal(t, "mp4", opts.Container)
+
+	for _, values := range []map[string]string{
+		{"mode": "overlay"},
+		{"container": "avi"},
+		{"start": "soon"},
+		{"start": "-1"},
+		{"start": "20", "end": "10"},
+		{"language": "en"},
+		{"preset": "cinema"},
+	} {
+		_, err := ParseRenderOptions(query(values))
+		assert.Error(t, err, values)
+	}
+}
+
+func TestRenderCaptionedVideo_NoCaptions(t *testing.T) {
+	dir := t.TempDir()
+	segments := []models.Segment{{Start: 0, End: 2, Text: "Hello"}}
+
+	err := RenderCaptionedVideo(context.Background(), filepath.Join(dir, "source.mp4"), filepath.Join(dir, "out.mp4"), segments, RenderOptions{Start: 5, End: 10})
+	assert.ErrorIs(t, err, ErrNoCaptions)
+
+	// No caption file is left behind when nothing is rendered
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRenderJobVideo_NotReady(t *testing.T) {
+	store, err := NewLocalArtifactStore(t.TempDir(), NewArtifactSigner("https://api.example.com", "secret"))
+	require.NoError(t, err)
+
+	job := models.NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	_, err = RenderJobVideo(context.Background(), store, job, strings.NewReader("video"), RenderOptions{})
+	assert.ErrorIs(t, err, ErrTranscriptNotReady)
+	assert.Empty(t, job.Artifacts)
+}
+
+func TestFFmpegFilterEscape(t *testing.T) {
+	assert.Equal(t, `/tmp/plain.ass`, ffmpegFilterEscape("/tmp/plain.ass"))
+	assert.Equal(t, `C\\:/subs/a\,b\[1\].ass`, ffmpegFilterEscape("C:/subs/a,b[1].ass"))
+	assert.Equal(t, `it\\\'s.ass`, ffmpegFilterEscape("it's.ass"))
+}
@@ -0,0 +1,172 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"videotranscript-app/models"
+)
+
+// VTTWordClass is the <c> class of words in karaoke cues, so players can
+// style them with ::cue(c.word), and the word being spoken with the
+// :past and :future pseudo-classes
+const VTTWordClass = "word"
+
+// VTTOptions configure WebVTT export. Karaoke adds an inline timestamp tag
+// to each word of cues with word timings. Line, Position, Size and Align
+// are cue settings written on every timing line; empty ones are left to
+// the player.
+type VTTOptions struct {
+	Karaoke bool
+	// Line is a line number, negative from the bottom, or a percentage,
+	// optionally followed by ",start", ",center" or ",end"
+	Line string
+	// Position is a percentage, optionally followed by ",line-left",
+	// ",center" or ",line-right"
+	Position string
+	// Size is the cue box width as a percentage
+	Size string
+	// Align is start, center, end, left or right
+	Align string
+}
+
+var (
+	vttLineSettingRegex     = regexp.MustCompile(`^(?:-?\d+|(\d+(?:\.\d+)?)%)(?:,(?:start|center|end))?$`)
+	vttPositionSettingRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)%(?:,(?:line-left|center|line-right))?$`)
+	vttSizeSettingRegex     = regexp.MustCompile(`^(\d+(?:\.\d+)?)%$`)
+)
+
+// Validate reports cue settings WebVTT does not allow
+func (o VTTOptions) Validate() error {
+	if o.Line != "" && !validVTTPercentage(vttLineSettingRegex, o.Line) {
+		return fmt.Errorf("line must be a line number or a percentage, optionally followed by ,start ,center or ,end")
+	}
+	if o.Position != "" && !validVTTPercentage(vttPositionSettingRegex, o.Position) {
+		return fmt.Errorf("position must be a percentage, optionally followed by ,line-left ,center or ,line-right")
+	}
+	if o.Size != "" && !validVTTPercentage(vttSizeSettingRegex, o.Size) {
+		return fmt.Errorf("size must be a percentage")
+	}
+	switch o.Align {
+	case "", "start", "center", "end", "left", "right":
+	default:
+		return fmt.Errorf("align must be start, center, end, left or right")
+	}
+	return nil
+}
+
+// validVTTPercentage matches a setting and checks that its percentage,
+// captured by the first group of re, is at most 100
+func validVTTPercentage(re *regexp.Regexp, value string) bool {
+	match := re.FindStringSubmatch(value)
+	if match == nil {
+		return false
+	}
+	if match[1] == "" {
+		return true
+	}
+	percent, err := strconv.ParseFloat(match[1], 64)
+	return err == nil && percent <= 100
+}
+
+// cueSettings returns the settings appended to each timing line, with a
+// leading space, or "" if there are none
+func (o VTTOptions) cueSettings() string {
+	var settings []string
+	for _, setting := range [][2]string{{"line", o.Line}, {"position", o.Position}, {"size", o.Size}, {"align", o.Align}} {
+		if setting[1] != "" {
+			settings = append(settings, setting[0]+":"+setting[1])
+		}
+	}
+	if len(settings) == 0 {
+		return ""
+	}
+	return " " + strings.Join(settings, " ")
+}
+
+// WriteVTT writes segments to w as a WebVTT file with escaped cue text and
+// a voice span naming the speaker of labelled cues
+func WriteVTT(w io.Writer, segments []models.Segment, opts VTTOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	settings := opts.cueSettings()
+
+	b := bufio.NewWriter(w)
+	b.WriteString("WEBVTT\n\n")
+	for _, segment := range segments {
+		text := escapeVTTText(segment.Text)
+		if opts.Karaoke {
+			if karaoke, ok := vttKaraoke(segment); ok {
+				text = karaoke
+			}
+		}
+		if segment.Speaker != "" {
+			text = fmt.Sprintf("<v %s>%s</v>", escapeVTTText(oneLine(segment.Speaker)), text)
+		}
+		fmt.Fprintf(b, "%s --> %s%s\n%s\n\n", formatVTTTime(segment.Start), formatVTTTime(segment.End), settings, text)
+	}
+	return b.Flush()
+}
+
+// vttKaraoke wraps each word of a cue in a <c.word> class and precedes it
+// with a timestamp tag at its start. Words that start with or before the
+// cue, or at the same millisecond as the previous tag, get no tag of their
+// own, as timestamps must increase strictly within the cue. It reports
+// false if the cue has no word timings or they do not match its text.
+func vttKaraoke(segment models.Segment) (string, bool) {
+	lines := strings.Split(strings.TrimSpace(segment.Text), "\n")
+	total := 0
+	for _, line := range lines {
+		total += len(strings.Fields(line))
+	}
+	if len(segment.Words) == 0 || len(segment.Words) != total {
+		return "", false
+	}
+
+	var b strings.Builder
+	last := math.Round(segment.Start * 1000)
+	end := math.Round(segment.End * 1000)
+	i := 0
+	for l, line := range lines {
+		if l > 0 {
+			b.WriteString("\n")
+		}
+		for j, field := range strings.Fields(line) {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			if start := math.Round(segment.Words[i].Start * 1000); start > last && start < end {
+				fmt.Fprintf(&b, "<%s>", formatVTTTime(start/1000))
+				last = start
+			}
+			fmt.Fprintf(&b, "<c.%s>%s</c>", VTTWordClass, escapeVTTText(field))
+			i++
+		}
+	}
+	return b.String(), true
+}
+
+// ParseVTTOptions reads WebVTT export options from query parameters:
+// karaoke, line, position, size and align
+func ParseVTTOptions(query func(string) string) (VTTOptions, error) {
+	opts := VTTOptions{
+		Line:     query("line"),
+		Position: query("position"),
+		Size:     query("size"),
+		Align:    query("align"),
+	}
+	if value := query("karaoke"); value != "" {
+		karaoke, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("karaoke must be true or false")
+		}
+		opts.Karaoke = karaoke
+	}
+	return opts, opts.Validate()
+}
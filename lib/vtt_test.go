@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+func TestWriteVTT_Karaoke(t *testing.T) {
+	segments := []models.Segment{
+		{Start: 1, End: 3, Text: "Never gonna\ngive <you> up", Words: []models.Word{
+			{Start: 1, End: 1.3, Text: "Never"},
+			{Start: 1.3, End: 1.9, Text: "gonna"},
+			{Start: 2.1, End: 2.3, Text: "give"},
+			// Shares its start millisecond with the previous word
+			{Start: 2.1004, End: 2.5, Text: "<you>"},
+			{Start: 2.5, End: 3, Text: "up"},
+		}},
+		// Without word timings the cue is written as plain text
+		{Start: 4, End: 5, Text: "Never gonna let you down"},
+	}
+
+	var b strings.Builder
+	require.NoError(t, WriteVTT(&b, segments, VTTOptions{Karaoke: true, Line: "85%", Position: "50%,center", Size: "80%", Align: "center"}))
+	assert.Equal(t, `WEBVTT
+
+00:00:01.000 --> 00:00:03.000 line:85% position:50%,center size:80% align:center
+<c.word>Never</c> <00:00:01.300><c.word>gonna</c>
+<00:00:02.100><c.word>give</c> <c.word>&lt;you&gt;</c> <00:00:02.500><c.word>up</c>
+
+00:00:04.000 --> 00:00:05.000 line:85% position:50%,center size:80% align:center
+Never gonna let you down
+
+`, b.String())
+
+	// The inline timestamps parse back into the word starts
+	parsed, err := ParseVTT(strings.NewReader(b.String()))
+	require.NoError(t, err)
+	require.Len(t, parsed, 2)
+	assert.Equal(t, "Never gonna\ngive <you> up", parsed[0].Text)
+	require.Len(t, parsed[0].Words, 5)
+	for i, start := range []float64{1, 1.3, 2.1, 2.3, 2.5} {
+		assert.InDelta(t, start, parsed[0].Words[i].Start, 1e-9, parsed[0].Words[i].Text)
+	}
+}
+
+func TestParseVTTOptions(t *testing.T) {
+	query := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	opts, err := ParseVTTOptions(query(map[string]string{"karaoke": "1", "line": "-1,end", "align": "left"}))
+	require.NoError(t, err)
+	assert.Equal(t, VTTOptions{Karaoke: true, Line: "-1,end", Align: "left"}, opts)
+	assert.Equal(t, " line:-1,end align:left", opts.cueSettings())
+
+	for _, values := range []map[string]string{
+		{"karaoke": "maybe"},
+		{"line": "150%"},
+		{"line": "top"},
+		{"position": "50"},
+		{"position": "50%,start"},
+		{"size": "101%"},
+		{"align": "middle"},
+	} {
+		_, err := ParseVTTOptions(query(values))
+		assert.Error(t, err, values)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"syscall"
 	"time"
 
 	"videotranscript-app/models"
@@ -14,36 +16,48 @@ import (
 
 // WebhookPayload represents the data sent to webhook URLs
 type WebhookPayload struct {
-	Event     string               `json:"event"`
-	JobID     string               `json:"job_id"`
-	URL       string               `json:"url"`
-	Status    string               `json:"status"`
-	Timestamp time.Time            `json:"timestamp"`
-	Data      *WebhookJobData      `json:"data,omitempty"`
-	Error     string               `json:"error,omitempty"`
-	Metadata  *WebhookMetadata     `json:"metadata,omitempty"`
+	Event          string            `json:"event"`
+	JobID          string            `json:"job_id,omitempty"`
+	BatchID        string            `json:"batch_id,omitempty"`
+	SubscriptionID string            `json:"subscription_id,omitempty"`
+	URL            string            `json:"url,omitempty"`
+	Status         string            `json:"status"`
+	Timestamp      time.Time         `json:"timestamp"`
+	Data           *WebhookJobData   `json:"data,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	ErrorCode      string            `json:"error_code,omitempty"`
+	Metadata       *WebhookMetadata  `json:"metadata,omitempty"`
+	Batch          *WebhookBatchData `json:"batch,omitempty"`
+}
+
+// WebhookBatchData summarises a finished batch
+type WebhookBatchData struct {
+	Total    int      `json:"total"`
+	Complete int      `json:"complete"`
+	Failed   int      `json:"failed"`
+	JobIDs   []string `json:"job_ids"`
 }
 
 // WebhookJobData contains the job results
 type WebhookJobData struct {
 	Transcript    string           `json:"transcript,omitempty"`
 	Segments      []models.Segment `json:"segments,omitempty"`
+	Chapters      []models.Chapter `json:"chapters,omitempty"`
 	SegmentCount  int              `json:"segment_count"`
 	Duration      float64          `json:"duration_seconds"`
 	SubtitleFiles *SubtitleFiles   `json:"subtitle_files,omitempty"`
 }
 
-// SubtitleFiles contains paths to generated subtitle files
+// SubtitleFiles contains time-limited download URLs of a job's subtitle files
 type SubtitleFiles struct {
-	SRTPath string `json:"srt_path,omitempty"`
-	VTTPath string `json:"vtt_path,omitempty"`
-	SRTURL  string `json:"srt_url,omitempty"`
-	VTTURL  string `json:"vtt_url,omitempty"`
+	SRTURL string `json:"srt_url,omitempty"`
+	VTTURL string `json:"vtt_url,omitempty"`
 }
 
 // WebhookMetadata contains processing metadata
 type WebhookMetadata struct {
 	ProcessingTimeMs int64  `json:"processing_time_ms"`
+	Engine           string `json:"engine,omitempty"`
 	AudioFormat      string `json:"audio_format"`
 	WhisperModel     string `json:"whisper_model"`
 	Language         string `json:"language"`
@@ -56,7 +70,7 @@ type WebhookConfig struct {
 	Headers map[string]string `json:"headers,omitempty"`
 	Timeout time.Duration     `json:"timeout"`
 	Retries int               `json:"retries"`
-	Events  []string          `json:"events"` // job.started, job.completed, job.failed
+	Events  []string          `json:"events"` // job.started, job.completed, job.failed, batch.completed, subscription.new_videos
 }
 
 // WebhookManager handles webhook notifications
@@ -84,6 +98,52 @@ func NewWebhookManager(config WebhookConfig) *WebhookManager {
 	}
 }
 
+// NewSubscriptionWebhookManager creates a webhook manager notifying a
+// subscription's owner, or returns nil if the subscription has no webhook
+func NewSubscriptionWebhookManager(sub models.Subscription) *WebhookManager {
+	if sub.WebhookURL == "" {
+		return nil
+	}
+
+	config := WebhookConfig{URL: sub.WebhookURL}
+	if sub.WebhookSecret != "" {
+		config.Headers = map[string]string{
+			"X-Webhook-Secret": sub.WebhookSecret,
+		}
+	}
+	wm := NewWebhookManager(config)
+	wm.client = publicWebhookClient(wm.config.Timeout)
+	return wm
+}
+
+// publicWebhookClient only connects to public addresses. Subscription
+// webhooks are supplied by API callers, and their host names may resolve
+// to the server's own network however the URL was validated.
+func publicWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !models.PublicWebhookAddress(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would connect on the client's behalf, past the check
+	transport.Proxy = nil
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
 // SendJobStarted sends a webhook when a job starts processing
 func (wm *WebhookManager) SendJobStarted(ctx context.Context, job *models.Job) error {
 	if !wm.shouldSendEvent("job.started") {
@@ -96,19 +156,15 @@ func (wm *WebhookManager) SendJobStarted(ctx context.Context, job *models.Job) e
 		URL:       job.URL,
 		Status:    string(job.Status),
 		Timestamp: time.Now(),
-		Metadata: &WebhookMetadata{
-			AudioFormat:    "wav",
-			WhisperModel:   "base.en",
-			Language:       "en",
-			WordTimestamps: true,
-		},
+		Metadata:  jobWebhookMetadata(job, 0),
 	}
 
 	return wm.sendWebhook(ctx, payload)
 }
 
-// SendJobCompleted sends a webhook when a job completes successfully
-func (wm *WebhookManager) SendJobCompleted(ctx context.Context, job *models.Job, srtPath, vttPath string, processingTime time.Duration) error {
+// SendJobCompleted sends a webhook when a job completes successfully.
+// subtitleFiles links the job's stored subtitles and may be nil.
+func (wm *WebhookManager) SendJobCompleted(ctx context.Context, job *models.Job, subtitleFiles *SubtitleFiles, processingTime time.Duration) error {
 	if !wm.shouldSendEvent("job.completed") {
 		return nil
 	}
@@ -118,17 +174,6 @@ func (wm *WebhookManager) SendJobCompleted(ctx context.Context, job *models.Job,
 		duration = job.Segments[len(job.Segments)-1].End
 	}
 
-	subtitleFiles := &SubtitleFiles{}
-	if srtPath != "" {
-		subtitleFiles.SRTPath = srtPath
-		// In production, these would be public URLs
-		subtitleFiles.SRTURL = fmt.Sprintf("https://api.videotranscript.app/files/%s.srt", job.ID)
-	}
-	if vttPath != "" {
-		subtitleFiles.VTTPath = vttPath
-		subtitleFiles.VTTURL = fmt.Sprintf("https://api.videotranscript.app/files/%s.vtt", job.ID)
-	}
-
 	payload := WebhookPayload{
 		Event:     "job.completed",
 		JobID:     job.ID,
@@ -138,17 +183,12 @@ func (wm *WebhookManager) SendJobCompleted(ctx context.Context, job *models.Job,
 		Data: &WebhookJobData{
 			Transcript:    job.Transcript,
 			Segments:      job.Segments,
+			Chapters:      job.Chapters,
 			SegmentCount:  len(job.Segments),
 			Duration:      duration,
 			SubtitleFiles: subtitleFiles,
 		},
-		Metadata: &WebhookMetadata{
-			ProcessingTimeMs: processingTime.Milliseconds(),
-			AudioFormat:      "wav",
-			WhisperModel:     "base.en",
-			Language:         "en",
-			WordTimestamps:   true,
-		},
+		Metadata: jobWebhookMetadata(job, processingTime),
 	}
 
 	return wm.sendWebhook(ctx, payload)
@@ -167,18 +207,81 @@ func (wm *WebhookManager) SendJobFailed(ctx context.Context, job *models.Job, er
 		Status:    string(job.Status),
 		Timestamp: time.Now(),
 		Error:     errorMsg,
-		Metadata: &WebhookMetadata{
-			ProcessingTimeMs: processingTime.Milliseconds(),
-			AudioFormat:      "wav",
-			WhisperModel:     "base.en",
-			Language:         "en",
-			WordTimestamps:   true,
+		ErrorCode: string(job.ErrorCode),
+		Metadata: jobWebhookMetadata(job, processingTime),
+	}
+
+	return wm.sendWebhook(ctx, payload)
+}
+
+// SendBatchCompleted sends a webhook once every job of a batch has finished
+func (wm *WebhookManager) SendBatchCompleted(ctx context.Context, status models.BatchStatus) error {
+	if !wm.shouldSendEvent("batch.completed") {
+		return nil
+	}
+
+	jobIDs := make([]string, 0, len(status.Jobs))
+	for _, job := range status.Jobs {
+		jobIDs = append(jobIDs, job.ID)
+	}
+
+	payload := WebhookPayload{
+		Event:          "batch.completed",
+		BatchID:        status.ID,
+		SubscriptionID: status.SubscriptionID,
+		Status:         string(status.Status),
+		Timestamp:      time.Now(),
+		Batch: &WebhookBatchData{
+			Total:    status.Total,
+			Complete: status.Complete,
+			Failed:   status.Failed,
+			JobIDs:   jobIDs,
 		},
 	}
 
 	return wm.sendWebhook(ctx, payload)
 }
 
+// SendSubscriptionVideos sends a webhook when a subscription scan found new
+// videos and submitted them as a batch
+func (wm *WebhookManager) SendSubscriptionVideos(ctx context.Context, sub models.Subscription, batchID string, jobIDs []string) error {
+	if !wm.shouldSendEvent("subscription.new_videos") {
+		return nil
+	}
+
+	payload := WebhookPayload{
+		Event:          "subscription.new_videos",
+		BatchID:        batchID,
+		SubscriptionID: sub.ID,
+		URL:            sub.URL,
+		Status:         string(models.StatusPending),
+		Timestamp:      time.Now(),
+		Batch: &WebhookBatchData{
+			Total:  len(jobIDs),
+			JobIDs: jobIDs,
+		},
+	}
+
+	return wm.sendWebhook(ctx, payload)
+}
+
+// jobWebhookMetadata builds the processing metadata for a job
+func jobWebhookMetadata(job *models.Job, processingTime time.Duration) *WebhookMetadata {
+	language := job.Language
+	if language == "" {
+		language = "en"
+	}
+
+	return &WebhookMetadata{
+		ProcessingTimeMs: processingTime.Milliseconds(),
+		Engine:           job.Engine,
+		AudioFormat:      "wav",
+		WhisperModel:     "base.en",
+		Language:         language,
+		WordTimestamps:   true,
+	}
+}
+
 // sendWebhook sends the webhook with retry logic
 func (wm *WebhookManager) sendWebhook(ctx context.Context, payload WebhookPayload) error {
 	jsonData, err := json.Marshal(payload)
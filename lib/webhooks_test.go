@@ -0,0 +1,35 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"videotranscript-app/models"
+)
+
+func TestSubscriptionWebhookRefusesPrivateAddresses(t *testing.T) {
+	var calls int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	// The operator's webhook may point anywhere
+	wm := NewWebhookManager(WebhookConfig{URL: server.URL, Retries: 1})
+	wm.client = server.Client()
+	require.NoError(t, wm.SendBatchCompleted(context.Background(), models.BatchStatus{}))
+	assert.Equal(t, 1, calls)
+
+	// A subscription's webhook may not, even behind a host name
+	wm = NewSubscriptionWebhookManager(models.Subscription{WebhookURL: server.URL})
+	wm.retryDelay = 0
+	err := wm.SendBatchCompleted(context.Background(), models.BatchStatus{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not public")
+	assert.Equal(t, 1, calls)
+}
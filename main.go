@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -17,6 +19,10 @@ func main() {
 	cfg := config.Load()
 
 	app := fiber.New(fiber.Config{
+		// Bodies are streamed so that video uploads can exceed the default
+		// limit lib.BodyLimit holds every other route to
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
@@ -26,8 +32,28 @@ func main() {
 
 	app.Use(cors.New())
 	app.Use(logger.New())
+	app.Use(lib.BodyLimit(fiber.DefaultBodyLimit, handlers.IsVideoUpload))
 
-	jobs.Initialize()
+	store, err := jobs.OpenStore(cfg.JobStore, cfg.JobStoreDSN)
+	if err != nil {
+		log.Fatalf("Failed to open job store: %v", err)
+	}
+
+	jobs.InitializeWithStore(store)
+	jobs.InitializePool(cfg.WorkerCount, cfg.MaxQueueSize, handlers.ProcessJob)
+	jobs.GetPool().SetWeights(cfg.KeyWeights)
+
+	if recovered, err := jobs.RecoverJobs(store, jobs.GetPool()); err != nil {
+		log.Printf("Failed to recover interrupted jobs: %v", err)
+	} else if recovered > 0 {
+		log.Printf("Re-queued %d interrupted jobs", recovered)
+	}
+
+	artifacts, err := lib.OpenArtifactStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open artifact store: %v", err)
+	}
+	lib.InitializeArtifacts(artifacts)
 
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -36,9 +62,30 @@ func main() {
 		})
 	})
 
+	// Signed artifact URLs carry their own credential
+	app.Get("/artifacts/*", handlers.GetArtifact)
+
 	api := app.Group("/", lib.AuthMiddleware())
-	api.Post("/transcribe", handlers.PostTranscribe)
+	api.Post("/transcribe", handlers.Idempotent, handlers.PostTranscribe)
+	api.Get("/transcribe", handlers.ListTranscribeJobs)
+	api.Post("/transcribe/batch", handlers.Idempotent, handlers.PostTranscribeBatch)
+	api.Get("/transcribe/batch/:batch_id", handlers.GetTranscribeBatch)
 	api.Get("/transcribe/:job_id", handlers.GetTranscribeJob)
+	api.Get("/transcribe/:job_id/events", handlers.GetTranscribeJobEvents)
+	api.Get("/transcribe/:job_id/transcript.:format", handlers.GetTranscriptFile)
+	api.Get("/transcribe/:job_id/chapters.:format", handlers.GetChaptersFile)
+	api.Post("/transcribe/:job_id/video", lib.BodyLimit(cfg.MaxUploadSize, nil), handlers.PostRenderVideo)
+	api.Get("/transcribe/:job_id/video/:render", handlers.GetRenderedVideo)
+	api.Post("/subtitles/convert", handlers.PostConvertSubtitles)
+	api.Post("/subscriptions", handlers.PostSubscription)
+	api.Get("/subscriptions", handlers.ListSubscriptions)
+	api.Get("/subscriptions/:subscription_id", handlers.GetSubscription)
+	api.Patch("/subscriptions/:subscription_id", handlers.PatchSubscription)
+	api.Delete("/subscriptions/:subscription_id", handlers.DeleteSubscription)
+
+	if cfg.ScanInterval > 0 {
+		handlers.StartSubscriptionScanner(context.Background(), time.Duration(cfg.ScanInterval)*time.Second)
+	}
 
 	log.Printf("Starting server on port %s", cfg.Port)
 	log.Fatal(app.Listen(":" + cfg.Port))
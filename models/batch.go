@@ -0,0 +1,166 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxBatchSize is the largest number of URLs accepted in one batch
+const MaxBatchSize = 100
+
+// BatchRequest submits several URLs with shared options
+type BatchRequest struct {
+	URLs         []string `json:"urls"`
+	ForceRefresh bool     `json:"force_refresh"`
+	Priority     string   `json:"priority,omitempty"`
+	// IdempotencyKey is read from the Idempotency-Key header by the Encore
+	// service
+	IdempotencyKey string `header:"Idempotency-Key" json:"-"`
+}
+
+// BatchResponse lists the jobs created for a batch
+type BatchResponse struct {
+	BatchID string        `json:"batch_id"`
+	Total   int           `json:"total"`
+	Jobs    []BatchJobRef `json:"jobs"`
+}
+
+// BatchJobRef maps a submitted URL to its job
+type BatchJobRef struct {
+	URL   string `json:"url"`
+	JobID string `json:"job_id"`
+}
+
+// Batch is the parent record of jobs submitted together. Its children carry
+// the batch ID in Job.BatchID. Batches expanded from a playlist or channel
+// record its URL and title, and those found by a subscription scan also the
+// subscription.
+type Batch struct {
+	ID             string     `json:"id"`
+	SourceURL      string     `json:"source_url,omitempty"`
+	Title          string     `json:"title,omitempty"`
+	SubscriptionID string     `json:"subscription_id,omitempty"`
+	Total          int        `json:"total"`
+	ForceRefresh   bool       `json:"force_refresh"`
+	APIKeyID       string     `json:"api_key_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+// BatchStatus is the aggregate progress of a batch
+type BatchStatus struct {
+	ID             string       `json:"id"`
+	SourceURL      string       `json:"source_url,omitempty"`
+	Title          string       `json:"title,omitempty"`
+	SubscriptionID string       `json:"subscription_id,omitempty"`
+	Status         JobStatus    `json:"status"`
+	Total          int          `json:"total"`
+	Pending        int          `json:"pending"`
+	Running        int          `json:"running"`
+	Complete       int          `json:"complete"`
+	Failed         int          `json:"failed"`
+	Progress       int          `json:"progress"`
+	Jobs           []JobSummary `json:"jobs"`
+	CreatedAt      time.Time    `json:"created_at"`
+	CompletedAt    *time.Time   `json:"completed_at,omitempty"`
+}
+
+// NewBatch creates a batch record for the given number of jobs
+func NewBatch(total int, forceRefresh bool) *Batch {
+	return &Batch{
+		ID:           uuid.New().String(),
+		Total:        total,
+		ForceRefresh: forceRefresh,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// Clone returns a copy of the batch that shares no pointers with it, like
+// Job.Clone
+func (b *Batch) Clone() *Batch {
+	clone := *b
+	clone.CompletedAt = cloneTime(b.CompletedAt)
+	return &clone
+}
+
+// ValidateBatchURLs checks a batch's URLs and returns them with duplicate
+// videos removed, in submission order
+func ValidateBatchURLs(urls []string) ([]string, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("urls must contain at least one URL")
+	}
+	if len(urls) > MaxBatchSize {
+		return nil, fmt.Errorf("a batch accepts at most %d URLs", MaxBatchSize)
+	}
+
+	seen := make(map[string]bool, len(urls))
+	unique := make([]string, 0, len(urls))
+	for i, url := range urls {
+		if !ValidateURL(url) {
+			return nil, fmt.Errorf("urls[%d] is not a valid YouTube URL", i)
+		}
+		key := ExtractVideoID(url)
+		if key == "unknown" {
+			key = url
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, url)
+	}
+	return unique, nil
+}
+
+// Summarize aggregates the batch's child jobs. Progress averages the
+// children's progress, counting finished jobs as 100.
+func (b *Batch) Summarize(jobs []*Job) BatchStatus {
+	status := BatchStatus{
+		ID:             b.ID,
+		SourceURL:      b.SourceURL,
+		Title:          b.Title,
+		SubscriptionID: b.SubscriptionID,
+		Total:          b.Total,
+		Jobs:           make([]JobSummary, 0, len(jobs)),
+		CreatedAt:      b.CreatedAt,
+		CompletedAt:    b.CompletedAt,
+	}
+
+	progress := 0
+	for _, job := range jobs {
+		status.Jobs = append(status.Jobs, job.Summary())
+		switch job.Status {
+		case StatusPending:
+			status.Pending++
+		case StatusRunning:
+			status.Running++
+			progress += job.Progress
+		case StatusComplete:
+			status.Complete++
+			progress += 100
+		case StatusError:
+			status.Failed++
+			progress += 100
+		}
+	}
+	if b.Total > 0 {
+		status.Progress = progress / b.Total
+	}
+
+	switch {
+	case status.Complete+status.Failed == b.Total:
+		status.Status = StatusComplete
+	case status.Pending == b.Total:
+		status.Status = StatusPending
+	default:
+		status.Status = StatusRunning
+	}
+	return status
+}
+
+// IsFinished reports whether every child job reached a terminal status
+func (s BatchStatus) IsFinished() bool {
+	return s.Status == StatusComplete
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBatchURLs(t *testing.T) {
+	urls, err := ValidateBatchURLs([]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://youtu.be/dQw4w9WgXcQ",
+		"https://www.youtube.com/watch?v=9bZkp7q19f0",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://www.youtube.com/watch?v=9bZkp7q19f0",
+	}, urls)
+
+	_, err = ValidateBatchURLs(nil)
+	assert.Error(t, err)
+
+	_, err = ValidateBatchURLs([]string{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "not-a-url"})
+	assert.EqualError(t, err, "urls[1] is not a valid YouTube URL")
+
+	_, err = ValidateBatchURLs(make([]string, MaxBatchSize+1))
+	assert.Error(t, err)
+}
+
+func TestBatch_Summarize(t *testing.T) {
+	batch := NewBatch(3, false)
+
+	pending := NewJob("https://www.youtube.com/watch?v=aaaaaaaaaaa")
+	running := NewJob("https://www.youtube.com/watch?v=bbbbbbbbbbb")
+	require.NoError(t, running.MarkRunning())
+	require.NoError(t, running.MarkStage(StageTranscribing, 50))
+	failed := NewJob("https://www.youtube.com/watch?v=ccccccccccc")
+	require.NoError(t, failed.MarkRunning())
+	require.NoError(t, failed.MarkError(errors.New("boom")))
+
+	status := batch.Summarize([]*Job{pending, running, failed})
+	assert.Equal(t, StatusRunning, status.Status)
+	assert.Equal(t, 1, status.Pending)
+	assert.Equal(t, 1, status.Running)
+	assert.Equal(t, 1, status.Failed)
+	assert.Equal(t, 50, status.Progress)
+	assert.Len(t, status.Jobs, 3)
+	assert.False(t, status.IsFinished())
+
+	require.NoError(t, pending.MarkRunning())
+	require.NoError(t, pending.MarkComplete("done", nil))
+	require.NoError(t, running.MarkComplete("done", nil))
+
+	status = batch.Summarize([]*Job{pending, running, failed})
+	assert.Equal(t, StatusComplete, status.Status)
+	assert.Equal(t, 2, status.Complete)
+	assert.Equal(t, 100, status.Progress)
+	assert.True(t, status.IsFinished())
+}
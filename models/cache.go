@@ -0,0 +1,40 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// ResultCacheKey identifies a transcription result by its source video and
+// the engine settings that produced it. It returns "" for sources without a
+// YouTube video ID, which are never cached. Only YouTube URLs are accepted
+// for transcription, so results are not keyed on a hash of their media.
+func ResultCacheKey(url, engine, model, language string) string {
+	videoID := ExtractVideoID(url)
+	if videoID == "unknown" {
+		return ""
+	}
+	return strings.Join([]string{"youtube:" + videoID, engine, model, language}, "|")
+}
+
+// CompleteFromCache completes the job with the result of an earlier job for
+// the same source, without running the pipeline. The job gets copies of the
+// source's segments, chapters and artifacts, so neither changes the other.
+func (j *Job) CompleteFromCache(source *Job) error {
+	if err := j.MarkRunning(); err != nil {
+		return err
+	}
+
+	j.Title = source.Title
+	j.Engine = source.Engine
+	j.Language = source.Language
+	j.Chapters = slices.Clone(source.Chapters)
+	j.Artifacts = slices.Clone(source.Artifacts)
+	j.CacheKey = source.CacheKey
+	j.CachedFrom = source.ID
+	if j.Metadata == nil && source.Metadata != nil {
+		metadata := *source.Metadata
+		j.Metadata = &metadata
+	}
+	return j.MarkComplete(source.Transcript, cloneSegments(source.Segments))
+}
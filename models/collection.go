@@ -0,0 +1,155 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// CollectionKind is the kind of a multi-video source
+type CollectionKind string
+
+const (
+	CollectionPlaylist CollectionKind = "playlist"
+	CollectionChannel  CollectionKind = "channel"
+)
+
+// collectionDateLayout is the format of the date_after and date_before options
+const collectionDateLayout = "2006-01-02"
+
+var (
+	playlistURLRegex = regexp.MustCompile(`^(https?://)?(www\.|m\.)?youtube\.com/playlist\?(.*&)?list=[\w-]+`)
+	channelURLRegex  = regexp.MustCompile(`^(https?://)?(www\.|m\.)?youtube\.com/(@[\w.-]+|channel/UC[\w-]+|c/[\w.-]+|user/[\w.-]+)(/(videos|streams|shorts|featured))?/?$`)
+)
+
+// CollectionKindOf returns the kind of a playlist or channel URL, or "" for
+// any other URL. Watch URLs with a list parameter are single videos.
+func CollectionKindOf(url string) CollectionKind {
+	switch {
+	case playlistURLRegex.MatchString(url):
+		return CollectionPlaylist
+	case channelURLRegex.MatchString(url):
+		return CollectionChannel
+	}
+	return ""
+}
+
+// ValidateSourceURL reports whether url is a video, playlist or channel URL
+func ValidateSourceURL(url string) bool {
+	return ValidateURL(url) || CollectionKindOf(url) != ""
+}
+
+// CollectionOptions limits which videos of a playlist or channel become
+// jobs. Dates are inclusive and formatted as YYYY-MM-DD.
+type CollectionOptions struct {
+	MaxItems     int    `json:"max_items,omitempty"`
+	DateAfter    string `json:"date_after,omitempty"`
+	DateBefore   string `json:"date_before,omitempty"`
+	SkipExisting bool   `json:"skip_existing,omitempty"`
+}
+
+// Collection is the flat listing of a playlist or channel
+type Collection struct {
+	Kind    CollectionKind    `json:"kind"`
+	URL     string            `json:"url"`
+	ID      string            `json:"id,omitempty"`
+	Title   string            `json:"title,omitempty"`
+	Entries []CollectionEntry `json:"entries"`
+}
+
+// CollectionEntry is one video of a playlist or channel. UploadedAt is
+// zero when the listing does not include the upload date.
+type CollectionEntry struct {
+	VideoID    string    `json:"video_id"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title,omitempty"`
+	Duration   float64   `json:"duration_seconds,omitempty"`
+	UploadedAt time.Time `json:"uploaded_at,omitempty"`
+}
+
+// Validate checks the options' limits and date range
+func (o CollectionOptions) Validate() error {
+	if o.MaxItems < 0 || o.MaxItems > MaxBatchSize {
+		return fmt.Errorf("max_items must be between 1 and %d", MaxBatchSize)
+	}
+
+	after, before, err := o.dateRange()
+	if err != nil {
+		return err
+	}
+	if !after.IsZero() && !before.IsZero() && after.After(before) {
+		return fmt.Errorf("date_after must not be later than date_before")
+	}
+	return nil
+}
+
+// Limit returns the maximum number of videos to expand, MaxBatchSize unless
+// MaxItems is set
+func (o CollectionOptions) Limit() int {
+	if o.MaxItems > 0 {
+		return o.MaxItems
+	}
+	return MaxBatchSize
+}
+
+// HasDateRange reports whether the options filter on upload date
+func (o CollectionOptions) HasDateRange() bool {
+	return o.DateAfter != "" || o.DateBefore != ""
+}
+
+// Select returns the entries inside the date range that skip does not
+// reject, up to Limit, in listing order. Entries without an upload date are
+// left out when a date range is set. skip may be nil.
+func (o CollectionOptions) Select(entries []CollectionEntry, skip func(CollectionEntry) bool) []CollectionEntry {
+	after, before, _ := o.dateRange()
+	if !before.IsZero() {
+		before = before.AddDate(0, 0, 1)
+	}
+
+	selected := make([]CollectionEntry, 0, len(entries))
+	for _, entry := range entries {
+		if len(selected) == o.Limit() {
+			break
+		}
+		if o.HasDateRange() {
+			if entry.UploadedAt.IsZero() {
+				continue
+			}
+			if !after.IsZero() && entry.UploadedAt.Before(after) {
+				continue
+			}
+			if !before.IsZero() && !entry.UploadedAt.Before(before) {
+				continue
+			}
+		}
+		if skip != nil && skip(entry) {
+			continue
+		}
+		selected = append(selected, entry)
+	}
+	return selected
+}
+
+func (o CollectionOptions) dateRange() (after, before time.Time, err error) {
+	if o.DateAfter != "" {
+		if after, err = time.Parse(collectionDateLayout, o.DateAfter); err != nil {
+			return after, before, fmt.Errorf("date_after must be a YYYY-MM-DD date")
+		}
+	}
+	if o.DateBefore != "" {
+		if before, err = time.Parse(collectionDateLayout, o.DateBefore); err != nil {
+			return after, before, fmt.Errorf("date_before must be a YYYY-MM-DD date")
+		}
+	}
+	return after, before, nil
+}
+
+// NewJob creates the child job for the entry
+func (e CollectionEntry) NewJob() *Job {
+	job := NewJob(e.URL)
+	job.Title = e.Title
+	if e.Duration > 0 {
+		job.Metadata = &VideoMetadata{Duration: e.Duration}
+	}
+	return job
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionKindOf(t *testing.T) {
+	tests := []struct {
+		url  string
+		want CollectionKind
+	}{
+		{"https://www.youtube.com/playlist?list=PL590L5WQmH8fJ54F369BLDSqIwcs-TCfs", CollectionPlaylist},
+		{"https://youtube.com/@mitocw", CollectionChannel},
+		{"https://www.youtube.com/@mitocw/videos", CollectionChannel},
+		{"https://www.youtube.com/channel/UCEBb1b_L6zDS3xTUrIALZOw", CollectionChannel},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PL590L5WQmH8fJ54F369BLDSqIwcs-TCfs", ""},
+		{"https://www.youtube.com/@mitocw/community", ""},
+		{"not-a-url", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CollectionKindOf(tt.url), tt.url)
+	}
+	assert.True(t, ValidateSourceURL("https://youtube.com/@mitocw"))
+}
+
+func TestCollectionOptions_Validate(t *testing.T) {
+	assert.NoError(t, CollectionOptions{}.Validate())
+	assert.NoError(t, CollectionOptions{MaxItems: 10, DateAfter: "2024-01-01", DateBefore: "2024-01-01"}.Validate())
+	assert.Error(t, CollectionOptions{MaxItems: MaxBatchSize + 1}.Validate())
+	assert.Error(t, CollectionOptions{DateBefore: "01/02/2024"}.Validate())
+	assert.Error(t, CollectionOptions{DateAfter: "2024-02-01", DateBefore: "2024-01-01"}.Validate())
+}
+
+func TestCollectionOptions_Select(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 15, 0, 0, 0, time.UTC) }
+	entries := []CollectionEntry{
+		{VideoID: "aaaaaaaaaaa", UploadedAt: day(1)},
+		{VideoID: "bbbbbbbbbbb", UploadedAt: day(10)},
+		{VideoID: "ccccccccccc"},
+		{VideoID: "ddddddddddd", UploadedAt: day(20)},
+		{VideoID: "eeeeeeeeeee", UploadedAt: day(31)},
+	}
+	ids := func(entries []CollectionEntry) []string {
+		var ids []string
+		for _, entry := range entries {
+			ids = append(ids, entry.VideoID)
+		}
+		return ids
+	}
+
+	assert.Len(t, CollectionOptions{}.Select(entries, nil), 5)
+	assert.Equal(t, []string{"aaaaaaaaaaa", "bbbbbbbbbbb"}, ids(CollectionOptions{MaxItems: 2}.Select(entries, nil)))
+
+	inJanuary := CollectionOptions{DateAfter: "2024-01-10", DateBefore: "2024-01-20"}
+	assert.Equal(t, []string{"bbbbbbbbbbb", "ddddddddddd"}, ids(inJanuary.Select(entries, nil)))
+
+	skipB := func(entry CollectionEntry) bool { return entry.VideoID == "bbbbbbbbbbb" }
+	assert.Equal(t, []string{"aaaaaaaaaaa", "ccccccccccc"}, ids(CollectionOptions{MaxItems: 2}.Select(entries, skipB)))
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrorCode is a machine-readable class of job failure
+type ErrorCode string
+
+const (
+	ErrCodeSourceUnavailable ErrorCode = "source_unavailable"
+	ErrCodeGeoBlocked        ErrorCode = "geo_blocked"
+	ErrCodePrivateVideo      ErrorCode = "private_video"
+	ErrCodeDownloadNetwork   ErrorCode = "download_network_error"
+	ErrCodeDecode            ErrorCode = "decode_error"
+	ErrCodeEngineFailure     ErrorCode = "engine_failure"
+	ErrCodeTimeout           ErrorCode = "timeout"
+	ErrCodeNoCaptions        ErrorCode = "no_captions"
+	ErrCodeInternal          ErrorCode = "internal"
+)
+
+// retryableCodes are the failures that may succeed when the job runs again.
+// Problems with the source video itself are permanent.
+var retryableCodes = map[ErrorCode]bool{
+	ErrCodeDownloadNetwork: true,
+	ErrCodeEngineFailure:   true,
+	ErrCodeTimeout:         true,
+}
+
+// JobError is a pipeline failure classified by error code
+type JobError struct {
+	Code ErrorCode
+	Err  error
+}
+
+// NewJobError classifies err with the given code
+func NewJobError(code ErrorCode, err error) *JobError {
+	return &JobError{Code: code, Err: err}
+}
+
+func (e *JobError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the job may succeed if it runs again
+func (e *JobError) Retryable() bool {
+	return retryableCodes[e.Code]
+}
+
+// ErrorCodeOf returns the code of a classified error. Deadline errors are
+// timeouts; anything else unclassified is internal.
+func ErrorCodeOf(err error) ErrorCode {
+	var jobErr *JobError
+	if errors.As(err, &jobErr) {
+		return jobErr.Code
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrCodeTimeout
+	}
+	return ErrCodeInternal
+}
+
+// IsRetryable reports whether a failed job may succeed if it runs again
+func IsRetryable(err error) bool {
+	return retryableCodes[ErrorCodeOf(err)]
+}
+
+// RetryPolicy decides whether a failed job runs again and how long it waits
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// ShouldRetry reports whether a job that failed with err gets another attempt
+func (r RetryPolicy) ShouldRetry(job *Job, err error) bool {
+	return IsRetryable(err) && job.Attempts < r.MaxAttempts
+}
+
+// Delay returns the backoff after the given number of attempts, doubling
+// from Backoff up to MaxBackoff
+func (r RetryPolicy) Delay(attempts int) time.Duration {
+	delay := r.Backoff
+	for i := 1; i < attempts && (r.MaxBackoff <= 0 || delay < r.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	wrapped := fmt.Errorf("pipeline: %w", NewJobError(ErrCodeGeoBlocked, errors.New("blocked")))
+	assert.Equal(t, ErrCodeGeoBlocked, ErrorCodeOf(wrapped))
+	assert.False(t, IsRetryable(wrapped))
+
+	assert.Equal(t, ErrCodeTimeout, ErrorCodeOf(fmt.Errorf("run: %w", context.DeadlineExceeded)))
+	assert.True(t, IsRetryable(context.DeadlineExceeded))
+
+	assert.Equal(t, ErrCodeInternal, ErrorCodeOf(errors.New("boom")))
+	assert.False(t, IsRetryable(errors.New("boom")))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: 10 * time.Second, MaxBackoff: 30 * time.Second}
+
+	assert.Equal(t, 10*time.Second, policy.Delay(1))
+	assert.Equal(t, 20*time.Second, policy.Delay(2))
+	assert.Equal(t, 30*time.Second, policy.Delay(3))
+	assert.Equal(t, 30*time.Second, policy.Delay(10))
+
+	job := NewJob("https://youtube.com/watch?v=test")
+	transient := NewJobError(ErrCodeDownloadNetwork, errors.New("connection reset"))
+
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		require.NoError(t, job.MarkRunning())
+		require.True(t, policy.ShouldRetry(job, transient))
+		require.NoError(t, job.MarkRetry(transient, time.Now()))
+		assert.Equal(t, StatusPending, job.Status)
+		assert.Equal(t, ErrCodeDownloadNetwork, job.ErrorCode)
+		require.NotNil(t, job.RetryAt)
+	}
+
+	require.NoError(t, job.MarkRunning())
+	assert.Equal(t, policy.MaxAttempts, job.Attempts)
+	assert.Nil(t, job.RetryAt)
+	assert.False(t, policy.ShouldRetry(job, transient))
+
+	require.NoError(t, job.MarkError(transient))
+	assert.Equal(t, ErrCodeDownloadNetwork, job.ErrorCode)
+}
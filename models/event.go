@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// JobEventType names the kind of change a JobEvent reports
+type JobEventType string
+
+const (
+	// EventStatus reports a status transition, including retries
+	EventStatus JobEventType = "status"
+	// EventStage reports that a running job entered a new pipeline stage
+	EventStage JobEventType = "stage"
+	// EventProgress reports progress within the current stage
+	EventProgress JobEventType = "progress"
+	// EventSegment carries a newly transcribed segment
+	EventSegment JobEventType = "segment"
+)
+
+// JobEvent is a change to a job published by the job runner
+type JobEvent struct {
+	Type      JobEventType `json:"type"`
+	JobID     string       `json:"job_id"`
+	Status    JobStatus    `json:"status"`
+	Stage     JobStage     `json:"stage,omitempty"`
+	Progress  int          `json:"progress"`
+	Segment   *Segment     `json:"segment,omitempty"`
+	Index     *int         `json:"index,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	ErrorCode ErrorCode    `json:"error_code,omitempty"`
+	RetryAt   *time.Time   `json:"retry_at,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// NewJobEvent snapshots the job's status, stage and progress
+func NewJobEvent(eventType JobEventType, j *Job) JobEvent {
+	event := JobEvent{
+		Type:      eventType,
+		JobID:     j.ID,
+		Status:    j.Status,
+		Stage:     j.Stage,
+		Progress:  j.Progress,
+		Timestamp: time.Now(),
+	}
+	if eventType == EventStatus {
+		event.Error = j.Error
+		event.ErrorCode = j.ErrorCode
+		event.RetryAt = j.RetryAt
+	}
+	return event
+}
+
+// NewSegmentEvent reports the job's segment at index
+func NewSegmentEvent(j *Job, index int) JobEvent {
+	event := NewJobEvent(EventSegment, j)
+	segment := j.Segments[index]
+	event.Segment = &segment
+	event.Index = &index
+	return event
+}
+
+// IsFinal reports whether the event ends the job's event stream
+func (e JobEvent) IsFinal() bool {
+	return e.Type == EventStatus && (e.Status == StatusComplete || e.Status == StatusError)
+}
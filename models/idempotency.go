@@ -0,0 +1,75 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// IdempotencyKeyHeader names the request header carrying the client's key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// MaxIdempotencyKeyLength bounds the length of a client's key
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyKey remembers which job or batch a request with a client's
+// Idempotency-Key created, so a repeated request returns it instead of
+// creating another. Keys are scoped to the API key that sent them.
+type IdempotencyKey struct {
+	APIKeyID string `json:"api_key_id"`
+	Key      string `json:"key"`
+	// Fingerprint identifies the request the key was first used with
+	Fingerprint string `json:"fingerprint"`
+	// JobID or BatchID is set once the request created it; until then the
+	// first request is still being processed
+	JobID      string    `json:"job_id,omitempty"`
+	BatchID    string    `json:"batch_id,omitempty"`
+	StatusCode int       `json:"status_code,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// NewIdempotencyKey validates a client's key and records it for ttl
+func NewIdempotencyKey(apiKeyID, key, fingerprint string, ttl time.Duration) (*IdempotencyKey, error) {
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return nil, fmt.Errorf("%s must be 1 to %d characters", IdempotencyKeyHeader, MaxIdempotencyKeyLength)
+	}
+
+	now := time.Now()
+	return &IdempotencyKey{
+		APIKeyID:    apiKeyID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}, nil
+}
+
+// RequestFingerprint hashes a request's method, path and body. JSON bodies
+// are compared by content, so whitespace and key order do not matter.
+func RequestFingerprint(method, path string, body []byte) string {
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			body = canonical
+		}
+	}
+
+	sum := sha256.New()
+	sum.Write([]byte(method + " " + path + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// Expired reports whether the key may be reused for a new request
+func (k *IdempotencyKey) Expired(at time.Time) bool {
+	return !at.Before(k.ExpiresAt)
+}
+
+// Completed reports whether the first request finished creating its job
+// or batch
+func (k *IdempotencyKey) Completed() bool {
+	return k.JobID != "" || k.BatchID != ""
+}
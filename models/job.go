@@ -0,0 +1,346 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobStatus represents the status of a transcription job
+type JobStatus string
+
+const (
+	StatusPending  JobStatus = "pending"
+	StatusRunning  JobStatus = "running"
+	StatusComplete JobStatus = "complete"
+	StatusError    JobStatus = "error"
+)
+
+// JobStage represents the pipeline stage of a running job
+type JobStage string
+
+const (
+	StageDownloading  JobStage = "downloading"
+	StageExtracting   JobStage = "extracting"
+	StageTranscribing JobStage = "transcribing"
+)
+
+// Job represents a transcription job. It is the single job model shared by
+// the Fiber handlers, the Encore service, webhooks and the dashboard.
+type Job struct {
+	ID       string    `json:"id"`
+	URL      string    `json:"url"`
+	VideoID  string    `json:"video_id,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Status   JobStatus `json:"status"`
+	Stage    JobStage  `json:"stage,omitempty"`
+	Progress int       `json:"progress"`
+
+	Metadata *VideoMetadata `json:"metadata,omitempty"`
+	Engine   string         `json:"engine,omitempty"`
+	Language string         `json:"language,omitempty"`
+
+	Transcript string     `json:"transcript,omitempty"`
+	Segments   []Segment  `json:"segments,omitempty"`
+	Chapters   []Chapter  `json:"chapters,omitempty"`
+	Artifacts  []Artifact `json:"artifacts,omitempty"`
+
+	// CacheKey is set on complete jobs whose result can be reused;
+	// CachedFrom names the job a cached result was copied from
+	CacheKey   string `json:"cache_key,omitempty"`
+	CachedFrom string `json:"cached_from,omitempty"`
+
+	Error     string    `json:"error,omitempty"`
+	ErrorCode ErrorCode `json:"error_code,omitempty"`
+
+	// Attempts counts how often the job started running; RetryAt is set
+	// while a failed attempt waits to be retried
+	Attempts int        `json:"attempts"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+
+	// APIKeyID attributes the job to the caller that created it; BatchID
+	// is set on jobs submitted as part of a batch
+	APIKeyID string      `json:"api_key_id,omitempty"`
+	BatchID  string      `json:"batch_id,omitempty"`
+	Priority JobPriority `json:"priority,omitempty"`
+
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// VideoMetadata describes the source video of a job
+type VideoMetadata struct {
+	Channel    string  `json:"channel,omitempty"`
+	Duration   float64 `json:"duration_seconds,omitempty"`
+	UploadDate string  `json:"upload_date,omitempty"`
+	Thumbnail  string  `json:"thumbnail,omitempty"`
+}
+
+// Artifact describes a file produced by a job, such as a subtitle file.
+// Key locates it in the artifact store; clients download it through a
+// signed URL, as stored URLs would expire. Status is empty once the file
+// is stored.
+type Artifact struct {
+	Kind      string         `json:"kind"`
+	Format    string         `json:"format"`
+	Key       string         `json:"key"`
+	Size      int64          `json:"size,omitempty"`
+	Status    ArtifactStatus `json:"status,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	ErrorCode ErrorCode      `json:"error_code,omitempty"`
+}
+
+// ArtifactStatus tracks an artifact that is produced after its job
+// completed, such as a rendered video
+type ArtifactStatus string
+
+const (
+	// ArtifactPending is an artifact waiting to be produced
+	ArtifactPending ArtifactStatus = "pending"
+	// ArtifactError is an artifact that could not be produced
+	ArtifactError ArtifactStatus = "error"
+)
+
+// Ready reports whether the artifact's file is stored
+func (a Artifact) Ready() bool {
+	return a.Status == ""
+}
+
+// MarkError records why the artifact could not be produced
+func (a *Artifact) MarkError(err error) {
+	a.Status = ArtifactError
+	a.Error = err.Error()
+	a.ErrorCode = ErrorCodeOf(err)
+}
+
+// ErrInvalidTransition is returned when a job is moved to a status that is
+// not reachable from its current one
+type ErrInvalidTransition struct {
+	From JobStatus
+	To   JobStatus
+}
+
+func (e *ErrInvalidTransition) Error() string {
+	return fmt.Sprintf("invalid job transition from %s to %s", e.From, e.To)
+}
+
+// jobTransitions lists the statuses reachable from each status. Running jobs
+// may go back to pending when they are re-queued after a restart; complete
+// and error are terminal.
+var jobTransitions = map[JobStatus][]JobStatus{
+	StatusPending: {StatusRunning, StatusError},
+	StatusRunning: {StatusPending, StatusComplete, StatusError},
+}
+
+// CanTransition reports whether a job may move from one status to another
+func CanTransition(from, to JobStatus) bool {
+	for _, allowed := range jobTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseJobStatus maps a status name onto the canonical status and stage. It
+// also accepts the legacy dashboard names (queued, downloading, extracting,
+// transcribing, completed, failed).
+func ParseJobStatus(status string) (JobStatus, JobStage) {
+	switch status {
+	case "queued", string(StatusPending):
+		return StatusPending, ""
+	case string(StageDownloading), string(StageExtracting), string(StageTranscribing):
+		return StatusRunning, JobStage(status)
+	case string(StatusRunning):
+		return StatusRunning, ""
+	case "completed", string(StatusComplete):
+		return StatusComplete, ""
+	case "failed", string(StatusError):
+		return StatusError, ""
+	default:
+		return JobStatus(status), ""
+	}
+}
+
+// NewJob creates a new transcription job
+func NewJob(url string) *Job {
+	now := time.Now()
+	return &Job{
+		ID:        uuid.New().String(),
+		URL:       url,
+		VideoID:   ExtractVideoID(url),
+		Status:    StatusPending,
+		Priority:  PriorityNormal,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Transition moves the job to a new status, rejecting illegal moves such as
+// complete to running
+func (j *Job) Transition(to JobStatus) error {
+	if !CanTransition(j.Status, to) {
+		return &ErrInvalidTransition{From: j.Status, To: to}
+	}
+
+	now := time.Now()
+	j.Status = to
+	j.UpdatedAt = now
+
+	switch to {
+	case StatusPending:
+		j.Stage = ""
+		j.Progress = 0
+	case StatusRunning:
+		j.StartedAt = &now
+		j.Attempts++
+		j.RetryAt = nil
+	case StatusComplete, StatusError:
+		j.Stage = ""
+		j.CompletedAt = &now
+	}
+	return nil
+}
+
+// MarkPending re-queues a running job
+func (j *Job) MarkPending() error {
+	return j.Transition(StatusPending)
+}
+
+// MarkRunning marks the job as running
+func (j *Job) MarkRunning() error {
+	return j.Transition(StatusRunning)
+}
+
+// MarkStage records the pipeline stage and progress of a running job
+func (j *Job) MarkStage(stage JobStage, progress int) error {
+	if j.Status != StatusRunning {
+		return fmt.Errorf("cannot set stage %s on %s job", stage, j.Status)
+	}
+	j.Stage = stage
+	j.Progress = progress
+	j.UpdatedAt = time.Now()
+	return nil
+}
+
+// MarkComplete marks the job as complete with transcript and segments
+func (j *Job) MarkComplete(transcript string, segments []Segment) error {
+	if err := j.Transition(StatusComplete); err != nil {
+		return err
+	}
+	j.Transcript = transcript
+	j.Segments = segments
+	j.Progress = 100
+	j.Error = ""
+	j.ErrorCode = ""
+	return nil
+}
+
+// MarkError marks the job as failed with an error
+func (j *Job) MarkError(err error) error {
+	if transitionErr := j.Transition(StatusError); transitionErr != nil {
+		return transitionErr
+	}
+	j.Error = err.Error()
+	j.ErrorCode = ErrorCodeOf(err)
+	return nil
+}
+
+// MarkRetry records a failed attempt and re-queues the running job to be
+// retried at the given time
+func (j *Job) MarkRetry(err error, at time.Time) error {
+	if transitionErr := j.Transition(StatusPending); transitionErr != nil {
+		return transitionErr
+	}
+	j.Error = err.Error()
+	j.ErrorCode = ErrorCodeOf(err)
+	j.RetryAt = &at
+	return nil
+}
+
+// PutArtifact adds an artifact to the job, replacing one stored under the
+// same key
+func (j *Job) PutArtifact(artifact Artifact) {
+	for i, existing := range j.Artifacts {
+		if existing.Key == artifact.Key {
+			j.Artifacts[i] = artifact
+			j.UpdatedAt = time.Now()
+			return
+		}
+	}
+	j.Artifacts = append(j.Artifacts, artifact)
+	j.UpdatedAt = time.Now()
+}
+
+// Artifact returns the job's artifact stored under key
+func (j *Job) Artifact(key string) (Artifact, bool) {
+	for _, artifact := range j.Artifacts {
+		if artifact.Key == key {
+			return artifact, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// FailPendingArtifacts marks the job's pending artifacts as failed with
+// err, and reports whether there were any
+func (j *Job) FailPendingArtifacts(err error) bool {
+	failed := false
+	for i := range j.Artifacts {
+		if j.Artifacts[i].Status == ArtifactPending {
+			j.Artifacts[i].MarkError(err)
+			failed = true
+		}
+	}
+	if failed {
+		j.UpdatedAt = time.Now()
+	}
+	return failed
+}
+
+// Clone returns a copy of the job that shares no slices or pointers with
+// it, so stores can hand jobs out without callers racing on them
+func (j *Job) Clone() *Job {
+	clone := *j
+	if j.Metadata != nil {
+		metadata := *j.Metadata
+		clone.Metadata = &metadata
+	}
+	clone.Segments = cloneSegments(j.Segments)
+	clone.Chapters = slices.Clone(j.Chapters)
+	clone.Artifacts = slices.Clone(j.Artifacts)
+	clone.RetryAt = cloneTime(j.RetryAt)
+	clone.StartedAt = cloneTime(j.StartedAt)
+	clone.CompletedAt = cloneTime(j.CompletedAt)
+	return &clone
+}
+
+// cloneSegments copies segments along with their word timings
+func cloneSegments(segments []Segment) []Segment {
+	if segments == nil {
+		return nil
+	}
+	clone := make([]Segment, len(segments))
+	for i, segment := range segments {
+		segment.Words = slices.Clone(segment.Words)
+		clone[i] = segment
+	}
+	return clone
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	return &clone
+}
+
+// IsComplete reports whether the job reached a terminal status
+func (j *Job) IsComplete() bool {
+	return j.Status == StatusComplete || j.Status == StatusError
+}
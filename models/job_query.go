@@ -0,0 +1,370 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JobSortField is a field job listings can be sorted on
+type JobSortField string
+
+const (
+	SortByCreatedAt JobSortField = "created_at"
+	SortByDuration  JobSortField = "duration"
+)
+
+const (
+	DefaultJobPageSize = 20
+	MaxJobPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// JobQuery filters, sorts and paginates a job listing. Zero values mean
+// "no filter"; pages are sorted newest first unless Ascending is set.
+type JobQuery struct {
+	Status        JobStatus
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	URL           string
+	VideoID       string
+	APIKeyID      string
+	BatchID       string
+
+	SortBy    JobSortField
+	Ascending bool
+	Limit     int
+	Cursor    string
+}
+
+// JobPage is one page of a job listing. NextCursor is empty on the last page.
+type JobPage struct {
+	Jobs       []*Job
+	NextCursor string
+}
+
+// JobSummary is the listing view of a job, without transcript and segments
+type JobSummary struct {
+	ID          string      `json:"id"`
+	URL         string      `json:"url"`
+	VideoID     string      `json:"video_id,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	Status      JobStatus   `json:"status"`
+	Stage       JobStage    `json:"stage,omitempty"`
+	Priority    JobPriority `json:"priority,omitempty"`
+	Progress    int         `json:"progress"`
+	Duration    float64     `json:"duration_seconds,omitempty"`
+	Engine      string      `json:"engine,omitempty"`
+	Language    string      `json:"language,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	APIKeyID    string      `json:"api_key_id,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+}
+
+// jobCursor marks the last job of a page. Only the field the listing is
+// sorted on is compared, with the job ID as tie-breaker.
+type jobCursor struct {
+	CreatedAt time.Time `json:"c"`
+	Duration  float64   `json:"d"`
+	ID        string    `json:"id"`
+}
+
+// ParseJobQuery builds a query from request parameters: status,
+// created_after, created_before (RFC 3339), url, video_id, api_key_id,
+// batch_id, sort (created_at or duration), order (asc or desc), limit and cursor.
+func ParseJobQuery(params map[string]string) (JobQuery, error) {
+	var q JobQuery
+
+	if status := params["status"]; status != "" {
+		q.Status, _ = ParseJobStatus(status)
+		switch q.Status {
+		case StatusPending, StatusRunning, StatusComplete, StatusError:
+		default:
+			return q, fmt.Errorf("unknown status %q", status)
+		}
+	}
+
+	for key, dest := range map[string]*time.Time{
+		"created_after":  &q.CreatedAfter,
+		"created_before": &q.CreatedBefore,
+	} {
+		if value := params[key]; value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return q, fmt.Errorf("%s must be an RFC 3339 timestamp", key)
+			}
+			*dest = t
+		}
+	}
+
+	q.URL = params["url"]
+	q.VideoID = params["video_id"]
+	q.APIKeyID = params["api_key_id"]
+	q.BatchID = params["batch_id"]
+	q.SortBy = JobSortField(params["sort"])
+	q.Cursor = params["cursor"]
+
+	switch strings.ToLower(params["order"]) {
+	case "", "desc":
+	case "asc":
+		q.Ascending = true
+	default:
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+
+	if limit := params["limit"]; limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("limit must be a positive integer")
+		}
+		q.Limit = n
+	}
+
+	return q, q.Normalize()
+}
+
+// RestrictToAPIKey limits the query to the jobs of the calling API key.
+// Asking for another key's jobs is an error.
+func (q *JobQuery) RestrictToAPIKey(apiKeyID string) error {
+	if q.APIKeyID != "" && q.APIKeyID != apiKeyID {
+		return fmt.Errorf("api_key_id can only be the caller's own key")
+	}
+	q.APIKeyID = apiKeyID
+	return nil
+}
+
+// Normalize applies the default sort and page size and validates the query
+func (q *JobQuery) Normalize() error {
+	switch q.SortBy {
+	case "":
+		q.SortBy = SortByCreatedAt
+	case SortByCreatedAt, SortByDuration:
+	default:
+		return fmt.Errorf("cannot sort by %q", q.SortBy)
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultJobPageSize
+	}
+	if q.Limit > MaxJobPageSize {
+		q.Limit = MaxJobPageSize
+	}
+
+	if q.Cursor != "" {
+		if _, err := decodeJobCursor(q.Cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a job passes the query's filters
+func (q JobQuery) Matches(job *Job) bool {
+	if q.Status != "" && job.Status != q.Status {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && !job.CreatedAt.After(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !job.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	if q.URL != "" && job.URL != q.URL {
+		return false
+	}
+	if q.VideoID != "" && job.VideoID != q.VideoID {
+		return false
+	}
+	if q.APIKeyID != "" && job.APIKeyID != q.APIKeyID {
+		return false
+	}
+	if q.BatchID != "" && job.BatchID != q.BatchID {
+		return false
+	}
+	return true
+}
+
+// Apply filters, sorts and pages an in-memory list of jobs
+func (q JobQuery) Apply(jobs []*Job) (*JobPage, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	matched := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		if q.Matches(job) {
+			matched = append(matched, job)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return q.before(jobSortKey(matched[i]), jobSortKey(matched[j]))
+	})
+
+	if q.Cursor != "" {
+		cursor, _ := decodeJobCursor(q.Cursor)
+		start := sort.Search(len(matched), func(i int) bool {
+			return q.before(cursor, jobSortKey(matched[i]))
+		})
+		matched = matched[start:]
+	}
+
+	if len(matched) > q.Limit+1 {
+		matched = matched[:q.Limit+1]
+	}
+	return q.Paginate(matched), nil
+}
+
+// Paginate turns up to Limit+1 sorted jobs following the cursor into a
+// page. The extra job only signals that another page exists.
+func (q JobQuery) Paginate(jobs []*Job) *JobPage {
+	page := &JobPage{Jobs: jobs}
+	if len(jobs) > q.Limit {
+		page.Jobs = jobs[:q.Limit]
+		last := jobSortKey(page.Jobs[len(page.Jobs)-1])
+		page.NextCursor = encodeJobCursor(last)
+	}
+	return page
+}
+
+// SQL returns the WHERE, ORDER BY and LIMIT clauses selecting the query's
+// page from the shared jobs table, with placeholders starting at $1. The
+// limit is one more than the page size; pass the rows to Paginate.
+func (q JobQuery) SQL() (string, []any, error) {
+	if err := q.Normalize(); err != nil {
+		return "", nil, err
+	}
+
+	var conditions []string
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if q.Status != "" {
+		conditions = append(conditions, "status = "+arg(string(q.Status)))
+	}
+	if !q.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at > "+arg(q.CreatedAfter))
+	}
+	if !q.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(q.CreatedBefore))
+	}
+	if q.URL != "" {
+		conditions = append(conditions, "url = "+arg(q.URL))
+	}
+	if q.VideoID != "" {
+		conditions = append(conditions, "video_id = "+arg(q.VideoID))
+	}
+	if q.APIKeyID != "" {
+		conditions = append(conditions, "api_key_id = "+arg(q.APIKeyID))
+	}
+	if q.BatchID != "" {
+		conditions = append(conditions, "batch_id = "+arg(q.BatchID))
+	}
+
+	sortColumn := "created_at"
+	if q.SortBy == SortByDuration {
+		sortColumn = "COALESCE((metadata->>'duration_seconds')::float8, 0)"
+	}
+	op, direction := "<", "DESC"
+	if q.Ascending {
+		op, direction = ">", "ASC"
+	}
+
+	if q.Cursor != "" {
+		cursor, _ := decodeJobCursor(q.Cursor)
+		var value any = cursor.CreatedAt
+		if q.SortBy == SortByDuration {
+			value = cursor.Duration
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)", sortColumn, op, arg(value), arg(cursor.ID)))
+	}
+
+	var clause strings.Builder
+	if len(conditions) > 0 {
+		clause.WriteString("WHERE " + strings.Join(conditions, " AND "))
+	}
+	fmt.Fprintf(&clause, " ORDER BY %s %s, id %s LIMIT %d", sortColumn, direction, direction, q.Limit+1)
+	return clause.String(), args, nil
+}
+
+// before reports whether a sorts ahead of b in the query's order
+func (q JobQuery) before(a, b jobCursor) bool {
+	cmp := a.CreatedAt.Compare(b.CreatedAt)
+	if q.SortBy == SortByDuration {
+		cmp = cmpFloat(a.Duration, b.Duration)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.ID, b.ID)
+	}
+	if q.Ascending {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func jobSortKey(job *Job) jobCursor {
+	return jobCursor{CreatedAt: job.CreatedAt, Duration: job.VideoDuration(), ID: job.ID}
+}
+
+func encodeJobCursor(cursor jobCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeJobCursor(encoded string) (jobCursor, error) {
+	var cursor jobCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(data, &cursor) != nil || cursor.ID == "" {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// VideoDuration returns the source video length in seconds, or 0 if unknown
+func (j *Job) VideoDuration() float64 {
+	if j.Metadata == nil {
+		return 0
+	}
+	return j.Metadata.Duration
+}
+
+// Summary returns the listing view of the job
+func (j *Job) Summary() JobSummary {
+	return JobSummary{
+		ID:          j.ID,
+		URL:         j.URL,
+		VideoID:     j.VideoID,
+		Title:       j.Title,
+		Status:      j.Status,
+		Stage:       j.Stage,
+		Priority:    j.Priority,
+		Progress:    j.Progress,
+		Duration:    j.VideoDuration(),
+		Engine:      j.Engine,
+		Language:    j.Language,
+		Error:       j.Error,
+		APIKeyID:    j.APIKeyID,
+		CreatedAt:   j.CreatedAt,
+		CompletedAt: j.CompletedAt,
+	}
+}
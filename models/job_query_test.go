@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobQuery_ApplyPagesInOrder(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var jobs []*Job
+	for i := 0; i < 5; i++ {
+		job := NewJob(fmt.Sprintf("https://youtube.com/watch?v=video%06d", i))
+		job.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		job.Metadata = &VideoMetadata{Duration: float64(100 - i*10)}
+		jobs = append(jobs, job)
+	}
+
+	tests := []struct {
+		name  string
+		query JobQuery
+		want  []int
+	}{
+		{"newest first", JobQuery{Limit: 2}, []int{4, 3, 2, 1, 0}},
+		{"oldest first", JobQuery{Limit: 2, Ascending: true}, []int{0, 1, 2, 3, 4}},
+		{"longest first", JobQuery{Limit: 2, SortBy: SortByDuration}, []int{0, 1, 2, 3, 4}},
+		{"created window", JobQuery{Limit: 2, CreatedAfter: base, CreatedBefore: base.Add(4 * time.Minute)}, []int{3, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			query := tt.query
+			for {
+				page, err := query.Apply(jobs)
+				require.NoError(t, err)
+				for _, job := range page.Jobs {
+					for i := range jobs {
+						if jobs[i] == job {
+							got = append(got, i)
+						}
+					}
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseJobQuery(t *testing.T) {
+	query, err := ParseJobQuery(map[string]string{
+		"status":        "completed",
+		"created_after": "2024-01-01T00:00:00Z",
+		"video_id":      "dQw4w9WgXcQ",
+		"sort":          "duration",
+		"order":         "asc",
+		"limit":         "500",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StatusComplete, query.Status)
+	assert.Equal(t, SortByDuration, query.SortBy)
+	assert.True(t, query.Ascending)
+	assert.Equal(t, MaxJobPageSize, query.Limit)
+
+	for _, params := range []map[string]string{
+		{"status": "bogus"},
+		{"created_before": "yesterday"},
+		{"sort": "title"},
+		{"order": "sideways"},
+		{"limit": "0"},
+		{"cursor": "not-a-cursor"},
+	} {
+		_, err := ParseJobQuery(params)
+		assert.Error(t, err, params)
+	}
+
+	require.NoError(t, query.RestrictToAPIKey("key_mine"))
+	assert.Equal(t, "key_mine", query.APIKeyID)
+	require.NoError(t, query.RestrictToAPIKey("key_mine"))
+	assert.Error(t, query.RestrictToAPIKey("key_theirs"))
+}
+
+func TestJobQuery_SQL(t *testing.T) {
+	query := JobQuery{Status: StatusComplete, APIKeyID: "key_1", SortBy: SortByDuration, Limit: 10}
+	query.Cursor = encodeJobCursor(jobCursor{Duration: 42, ID: "job-1"})
+
+	clause, args, err := query.SQL()
+	require.NoError(t, err)
+	assert.Equal(t, "WHERE status = $1 AND api_key_id = $2 AND "+
+		"(COALESCE((metadata->>'duration_seconds')::float8, 0), id) < ($3, $4) "+
+		"ORDER BY COALESCE((metadata->>'duration_seconds')::float8, 0) DESC, id DESC LIMIT 11", clause)
+	assert.Equal(t, []any{"complete", "key_1", 42.0, "job-1"}, args)
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJob_Lifecycle(t *testing.T) {
+	job := NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	assert.Equal(t, StatusPending, job.Status)
+	assert.Equal(t, "dQw4w9WgXcQ", job.VideoID)
+
+	require.NoError(t, job.MarkRunning())
+	require.NotNil(t, job.StartedAt)
+
+	require.NoError(t, job.MarkStage(StageTranscribing, 50))
+	assert.Equal(t, StageTranscribing, job.Stage)
+
+	require.NoError(t, job.MarkComplete("Test transcript", []Segment{{Start: 0, End: 5, Text: "Test"}}))
+	assert.Equal(t, StatusComplete, job.Status)
+	assert.Equal(t, 100, job.Progress)
+	assert.Empty(t, job.Stage)
+	require.NotNil(t, job.CompletedAt)
+	assert.True(t, job.IsComplete())
+}
+
+func TestJob_RejectsIllegalTransitions(t *testing.T) {
+	job := NewJob("https://youtube.com/watch?v=test")
+
+	err := job.MarkComplete("too early", nil)
+	var transitionErr *ErrInvalidTransition
+	require.True(t, errors.As(err, &transitionErr))
+	assert.Equal(t, StatusPending, transitionErr.From)
+	assert.Equal(t, StatusComplete, transitionErr.To)
+
+	require.NoError(t, job.MarkRunning())
+	require.NoError(t, job.MarkError(errors.New("boom")))
+
+	assert.Error(t, job.MarkRunning())
+	assert.Error(t, job.MarkStage(StageDownloading, 10))
+	assert.Equal(t, StatusError, job.Status)
+	assert.Equal(t, "boom", job.Error)
+}
+
+func TestParseJobStatus_LegacyNames(t *testing.T) {
+	tests := []struct {
+		in     string
+		status JobStatus
+		stage  JobStage
+	}{
+		{"queued", StatusPending, ""},
+		{"downloading", StatusRunning, StageDownloading},
+		{"transcribing", StatusRunning, StageTranscribing},
+		{"completed", StatusComplete, ""},
+		{"failed", StatusError, ""},
+		{"running", StatusRunning, ""},
+	}
+
+	for _, tt := range tests {
+		status, stage := ParseJobStatus(tt.in)
+		assert.Equal(t, tt.status, status, tt.in)
+		assert.Equal(t, tt.stage, stage, tt.in)
+	}
+}
+
+func TestParseJobPriority(t *testing.T) {
+	priority, err := ParseJobPriority("")
+	require.NoError(t, err)
+	assert.Equal(t, PriorityNormal, priority)
+
+	priority, err = ParseJobPriority("high")
+	require.NoError(t, err)
+	assert.Equal(t, 0, priority.Lane())
+
+	_, err = ParseJobPriority("urgent")
+	assert.Error(t, err)
+
+	assert.Equal(t, PriorityNormal.Lane(), JobPriority("").Lane())
+	assert.Equal(t, PriorityNormal, NewJob("https://youtube.com/watch?v=test").Priority)
+}
+
+func TestJob_PutArtifact(t *testing.T) {
+	job := NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	job.PutArtifact(Artifact{Kind: "subtitles", Format: "srt", Key: "jobs/1/subtitles.srt", Size: 10})
+	job.PutArtifact(Artifact{Kind: "video", Format: "mp4", Key: "jobs/1/video_burn.mp4", Size: 100})
+
+	// A render of the same key replaces the earlier one in place
+	job.PutArtifact(Artifact{Kind: "video", Format: "mp4", Key: "jobs/1/video_burn.mp4", Size: 200})
+	require.Len(t, job.Artifacts, 2)
+	assert.Equal(t, int64(200), job.Artifacts[1].Size)
+}
+
+func TestJob_Clone(t *testing.T) {
+	job := NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	job.Metadata = &VideoMetadata{Duration: 212}
+	require.NoError(t, job.MarkRunning())
+	require.NoError(t, job.MarkComplete("Hello", []Segment{{Start: 0, End: 1, Text: "Hello", Words: []Word{{Start: 0, End: 1, Text: "Hello"}}}}))
+	job.PutArtifact(Artifact{Kind: "subtitles", Format: "srt", Key: "jobs/1/subtitles.srt"})
+
+	clone := job.Clone()
+	assert.Equal(t, job, clone)
+
+	clone.Metadata.Duration = 1
+	clone.Segments[0].Words[0].Text = "Bye"
+	clone.Artifacts[0].Size = 10
+	*clone.CompletedAt = clone.CompletedAt.Add(time.Hour)
+	assert.Equal(t, 212.0, job.Metadata.Duration)
+	assert.Equal(t, "Hello", job.Segments[0].Words[0].Text)
+	assert.Zero(t, job.Artifacts[0].Size)
+	assert.NotEqual(t, job.CompletedAt, clone.CompletedAt)
+}
+
+func TestJob_CompleteFromCache(t *testing.T) {
+	source := NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	source.Metadata = &VideoMetadata{Duration: 212}
+	require.NoError(t, source.MarkRunning())
+	require.NoError(t, source.MarkComplete("Hello", []Segment{{Start: 0, End: 1, Text: "Hello", Words: []Word{{Start: 0, End: 1, Text: "Hello"}}}}))
+	source.Chapters = []Chapter{{Start: 0, End: 1, Title: "Intro"}}
+	source.PutArtifact(Artifact{Kind: "subtitles", Format: "srt", Key: "jobs/1/subtitles.srt"})
+
+	job := NewJob(source.URL)
+	require.NoError(t, job.CompleteFromCache(source))
+	assert.Equal(t, StatusComplete, job.Status)
+	assert.Equal(t, source.ID, job.CachedFrom)
+	assert.Equal(t, source.Segments, job.Segments)
+	assert.Equal(t, source.Chapters, job.Chapters)
+	assert.Equal(t, source.Artifacts, job.Artifacts)
+
+	// Changes to the reusing job leave the cached result alone
+	job.PutArtifact(Artifact{Kind: "subtitles", Format: "srt", Key: "jobs/1/subtitles.srt", Size: 10})
+	job.Segments[0].Words[0].Text = "Bye"
+	job.Chapters[0].Title = "Outro"
+	job.Metadata.Duration = 1
+	assert.Zero(t, source.Artifacts[0].Size)
+	assert.Equal(t, "Hello", source.Segments[0].Words[0].Text)
+	assert.Equal(t, "Intro", source.Chapters[0].Title)
+	assert.Equal(t, 212.0, source.Metadata.Duration)
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// TranscribeMode selects whether POST /transcribe waits for the transcript
+type TranscribeMode string
+
+const (
+	// ModeAuto waits for videos up to SyncDurationLimit long
+	ModeAuto TranscribeMode = "auto"
+	// ModeSync waits for any video
+	ModeSync TranscribeMode = "sync"
+	// ModeAsync answers with the job at once
+	ModeAsync TranscribeMode = "async"
+)
+
+const (
+	// SyncDurationLimit is the longest video, in seconds, auto mode waits for
+	SyncDurationLimit = 120
+	// DefaultWaitSeconds is how long sync and auto requests wait by default
+	DefaultWaitSeconds = 120
+	// MaxWaitSeconds is the longest wait a request may ask for
+	MaxWaitSeconds = 600
+)
+
+// WaitOptions is a validated mode and wait time
+type WaitOptions struct {
+	Mode TranscribeMode
+	Wait time.Duration
+}
+
+// ParseWaitOptions validates a requested mode and wait_seconds. An empty
+// mode is auto and a zero wait is DefaultWaitSeconds.
+func ParseWaitOptions(mode string, waitSeconds int) (WaitOptions, error) {
+	opts := WaitOptions{Mode: TranscribeMode(mode)}
+	switch opts.Mode {
+	case "":
+		opts.Mode = ModeAuto
+	case ModeAuto, ModeSync, ModeAsync:
+	default:
+		return WaitOptions{}, fmt.Errorf("mode must be sync, async or auto")
+	}
+
+	if waitSeconds < 0 || waitSeconds > MaxWaitSeconds {
+		return WaitOptions{}, fmt.Errorf("wait_seconds must be between 1 and %d", MaxWaitSeconds)
+	}
+	if waitSeconds == 0 {
+		waitSeconds = DefaultWaitSeconds
+	}
+	opts.Wait = time.Duration(waitSeconds) * time.Second
+	return opts, nil
+}
+
+// WaitFor returns how long to wait for the result of a video with the given
+// duration in seconds, or zero to answer with the job at once. A negative
+// duration means the length is not known yet.
+func (o WaitOptions) WaitFor(duration int) time.Duration {
+	switch o.Mode {
+	case ModeSync:
+		return o.Wait
+	case ModeAsync:
+		return 0
+	}
+	if duration < 0 || duration > SyncDurationLimit {
+		return 0
+	}
+	return o.Wait
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWaitOptions(t *testing.T) {
+	opts, err := ParseWaitOptions("", 0)
+	require.NoError(t, err)
+	assert.Equal(t, ModeAuto, opts.Mode)
+	assert.Equal(t, DefaultWaitSeconds*time.Second, opts.Wait)
+
+	_, err = ParseWaitOptions("blocking", 0)
+	assert.Error(t, err)
+	_, err = ParseWaitOptions("sync", MaxWaitSeconds+1)
+	assert.Error(t, err)
+	_, err = ParseWaitOptions("sync", -1)
+	assert.Error(t, err)
+}
+
+func TestWaitOptions_WaitFor(t *testing.T) {
+	auto, _ := ParseWaitOptions("auto", 30)
+	assert.Equal(t, 30*time.Second, auto.WaitFor(SyncDurationLimit))
+	assert.Zero(t, auto.WaitFor(SyncDurationLimit+1))
+	assert.Zero(t, auto.WaitFor(-1))
+
+	sync, _ := ParseWaitOptions("sync", 30)
+	assert.Equal(t, 30*time.Second, sync.WaitFor(3600))
+	assert.Equal(t, 30*time.Second, sync.WaitFor(-1))
+
+	async, _ := ParseWaitOptions("async", 30)
+	assert.Zero(t, async.WaitFor(10))
+}
@@ -0,0 +1,41 @@
+package models
+
+import "fmt"
+
+// JobPriority is a scheduling lane within the share of workers each API key
+// gets. Higher lanes are served first; jobs of other API keys are not
+// overtaken.
+type JobPriority string
+
+const (
+	PriorityHigh   JobPriority = "high"
+	PriorityNormal JobPriority = "normal"
+	PriorityLow    JobPriority = "low"
+)
+
+// PriorityLanes is the number of distinct priorities
+const PriorityLanes = 3
+
+// ParseJobPriority validates a requested priority. An empty string is
+// normal priority.
+func ParseJobPriority(priority string) (JobPriority, error) {
+	switch JobPriority(priority) {
+	case "":
+		return PriorityNormal, nil
+	case PriorityHigh, PriorityNormal, PriorityLow:
+		return JobPriority(priority), nil
+	}
+	return "", fmt.Errorf("priority must be high, normal or low")
+}
+
+// Lane returns the scheduling lane of the priority, 0 being served first.
+// Unset priorities are normal.
+func (p JobPriority) Lane() int {
+	switch p {
+	case PriorityHigh:
+		return 0
+	case PriorityLow:
+		return 2
+	}
+	return 1
+}
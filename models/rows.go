@@ -0,0 +1,271 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// The row mappings below are shared by the Fiber server's Postgres store and
+// the Encore service, which keep jobs in the same tables. Each column list
+// is in the order of its Values and Scan functions; a new column is added to
+// the list and to both functions, and to the migrations.
+
+var (
+	jobColumns = []string{
+		"id", "url", "video_id", "title", "status", "stage", "progress", "engine", "language",
+		"metadata", "transcript", "segments", "artifacts", "cache_key", "cached_from", "error",
+		"error_code", "attempts", "retry_at", "api_key_id", "batch_id", "priority",
+		"created_at", "start_time", "update_time", "completed_at", "chapters",
+	}
+	batchColumns = []string{
+		"id", "source_url", "title", "subscription_id", "total", "force_refresh", "api_key_id",
+		"created_at", "completed_at",
+	}
+	subscriptionColumns = []string{
+		"id", "url", "kind", "title", "active", "webhook_url", "webhook_secret", "api_key_id",
+		"seen_video_ids", "last_scanned_at", "last_error", "created_at", "updated_at",
+	}
+	idempotencyKeyColumns = []string{
+		"api_key_id", "key", "fingerprint", "job_id", "batch_id", "status_code", "created_at", "expires_at",
+	}
+)
+
+var (
+	// JobColumns selects the columns ScanJob reads
+	JobColumns = strings.Join(jobColumns, ", ")
+	// InsertJobSQL inserts the values of JobValues
+	InsertJobSQL = insertSQL("jobs", jobColumns)
+	// UpdateJobSQL updates the job with the ID and values of JobValues
+	UpdateJobSQL = updateSQL("jobs", jobColumns)
+
+	// BatchColumns selects the columns ScanBatch reads
+	BatchColumns = strings.Join(batchColumns, ", ")
+	// InsertBatchSQL inserts the values of BatchValues
+	InsertBatchSQL = insertSQL("batches", batchColumns)
+
+	// SubscriptionColumns selects the columns ScanSubscription reads
+	SubscriptionColumns = strings.Join(subscriptionColumns, ", ")
+	// InsertSubscriptionSQL inserts the values of SubscriptionValues
+	InsertSubscriptionSQL = insertSQL("subscriptions", subscriptionColumns)
+	// UpdateSubscriptionSQL updates the subscription with the ID and values
+	// of SubscriptionValues
+	UpdateSubscriptionSQL = updateSQL("subscriptions", subscriptionColumns)
+
+	// IdempotencyKeyColumns selects the columns ScanIdempotencyKey reads
+	IdempotencyKeyColumns = strings.Join(idempotencyKeyColumns, ", ")
+	// ClaimIdempotencyKeySQL inserts the values of IdempotencyKeyValues
+	// unless the API key already holds the key
+	ClaimIdempotencyKeySQL = insertSQL("idempotency_keys", idempotencyKeyColumns) + " ON CONFLICT (api_key_id, key) DO NOTHING"
+)
+
+// insertSQL inserts a row with one placeholder per column
+func insertSQL(table string, columns []string) string {
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+	}
+	return "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+}
+
+// updateSQL sets every column but the first, which identifies the row
+func updateSQL(table string, columns []string) string {
+	assignments := make([]string, 0, len(columns)-1)
+	for i, column := range columns[1:] {
+		assignments = append(assignments, column+" = $"+strconv.Itoa(i+2))
+	}
+	return "UPDATE " + table + " SET " + strings.Join(assignments, ", ") + " WHERE " + columns[0] + " = $1"
+}
+
+// RowScanner is a database row, as returned by QueryRow or iterated by Rows
+type RowScanner interface {
+	Scan(dest ...any) error
+}
+
+// JobValues returns the column values of a job in JobColumns order
+func JobValues(job *Job) ([]any, error) {
+	metadataJSON, err := json.Marshal(job.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	segmentsJSON, err := json.Marshal(job.Segments)
+	if err != nil {
+		return nil, err
+	}
+	artifactsJSON, err := json.Marshal(job.Artifacts)
+	if err != nil {
+		return nil, err
+	}
+	chaptersJSON, err := json.Marshal(job.Chapters)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		job.ID, job.URL, job.VideoID, job.Title, job.Status, job.Stage, job.Progress,
+		job.Engine, job.Language, metadataJSON, job.Transcript, segmentsJSON,
+		artifactsJSON, job.CacheKey, job.CachedFrom, job.Error, job.ErrorCode,
+		job.Attempts, job.RetryAt, job.APIKeyID, job.BatchID, job.Priority,
+		job.CreatedAt, job.StartedAt, job.UpdatedAt, job.CompletedAt, chaptersJSON,
+	}, nil
+}
+
+// ScanJob reads a row selected with JobColumns into a job
+func ScanJob(row RowScanner) (*Job, error) {
+	var job Job
+	var videoID, title, stage, engine, language, transcript, cacheKey, cachedFrom sql.NullString
+	var errorText, errorCode, apiKeyID, batchID, priority sql.NullString
+	var progress, attempts sql.NullInt64
+	var updatedAt sql.NullTime
+	var metadataJSON, segmentsJSON, artifactsJSON, chaptersJSON []byte
+
+	err := row.Scan(
+		&job.ID, &job.URL, &videoID, &title, &job.Status, &stage, &progress,
+		&engine, &language, &metadataJSON, &transcript, &segmentsJSON,
+		&artifactsJSON, &cacheKey, &cachedFrom, &errorText, &errorCode,
+		&attempts, &job.RetryAt, &apiKeyID, &batchID, &priority,
+		&job.CreatedAt, &job.StartedAt, &updatedAt, &job.CompletedAt, &chaptersJSON,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.VideoID = videoID.String
+	job.Title = title.String
+	job.Stage = JobStage(stage.String)
+	job.Progress = int(progress.Int64)
+	job.Engine = engine.String
+	job.Language = language.String
+	job.Transcript = transcript.String
+	job.CacheKey = cacheKey.String
+	job.CachedFrom = cachedFrom.String
+	job.Error = errorText.String
+	job.ErrorCode = ErrorCode(errorCode.String)
+	job.Attempts = int(attempts.Int64)
+	job.APIKeyID = apiKeyID.String
+	job.BatchID = batchID.String
+	job.Priority = JobPriority(priority.String)
+	job.UpdatedAt = updatedAt.Time
+
+	for _, column := range []struct {
+		data   []byte
+		target any
+	}{
+		{metadataJSON, &job.Metadata},
+		{segmentsJSON, &job.Segments},
+		{artifactsJSON, &job.Artifacts},
+		{chaptersJSON, &job.Chapters},
+	} {
+		if len(column.data) > 0 {
+			if err := json.Unmarshal(column.data, column.target); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &job, nil
+}
+
+// BatchValues returns the column values of a batch in BatchColumns order
+func BatchValues(batch *Batch) []any {
+	return []any{
+		batch.ID, batch.SourceURL, batch.Title, batch.SubscriptionID, batch.Total,
+		batch.ForceRefresh, batch.APIKeyID, batch.CreatedAt, batch.CompletedAt,
+	}
+}
+
+// ScanBatch reads a row selected with BatchColumns into a batch
+func ScanBatch(row RowScanner) (*Batch, error) {
+	var batch Batch
+	var sourceURL, title, subscriptionID, apiKeyID sql.NullString
+
+	err := row.Scan(
+		&batch.ID, &sourceURL, &title, &subscriptionID, &batch.Total,
+		&batch.ForceRefresh, &apiKeyID, &batch.CreatedAt, &batch.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	batch.SourceURL = sourceURL.String
+	batch.Title = title.String
+	batch.SubscriptionID = subscriptionID.String
+	batch.APIKeyID = apiKeyID.String
+	return &batch, nil
+}
+
+// SubscriptionValues returns the column values of a subscription in
+// SubscriptionColumns order
+func SubscriptionValues(sub *Subscription) ([]any, error) {
+	seenJSON, err := json.Marshal(sub.SeenVideoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		sub.ID, sub.URL, string(sub.Kind), sub.Title, sub.Active, sub.WebhookURL,
+		sub.WebhookSecret, sub.APIKeyID, seenJSON, sub.LastScannedAt, sub.LastError,
+		sub.CreatedAt, sub.UpdatedAt,
+	}, nil
+}
+
+// ScanSubscription reads a row selected with SubscriptionColumns into a
+// subscription
+func ScanSubscription(row RowScanner) (*Subscription, error) {
+	var sub Subscription
+	var kind string
+	var title, webhookURL, webhookSecret, apiKeyID, lastError sql.NullString
+	var seenJSON []byte
+
+	err := row.Scan(
+		&sub.ID, &sub.URL, &kind, &title, &sub.Active, &webhookURL,
+		&webhookSecret, &apiKeyID, &seenJSON, &sub.LastScannedAt, &lastError,
+		&sub.CreatedAt, &sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.Kind = CollectionKind(kind)
+	sub.Title = title.String
+	sub.WebhookURL = webhookURL.String
+	sub.WebhookSecret = webhookSecret.String
+	sub.APIKeyID = apiKeyID.String
+	sub.LastError = lastError.String
+	if len(seenJSON) > 0 {
+		if err := json.Unmarshal(seenJSON, &sub.SeenVideoIDs); err != nil {
+			return nil, err
+		}
+	}
+	return &sub, nil
+}
+
+// IdempotencyKeyValues returns the column values of an idempotency key in
+// IdempotencyKeyColumns order
+func IdempotencyKeyValues(key *IdempotencyKey) []any {
+	return []any{
+		key.APIKeyID, key.Key, key.Fingerprint, key.JobID, key.BatchID,
+		key.StatusCode, key.CreatedAt, key.ExpiresAt,
+	}
+}
+
+// ScanIdempotencyKey reads a row selected with IdempotencyKeyColumns into
+// an idempotency key
+func ScanIdempotencyKey(row RowScanner) (*IdempotencyKey, error) {
+	var key IdempotencyKey
+	var jobID, batchID sql.NullString
+	var statusCode sql.NullInt64
+
+	err := row.Scan(
+		&key.APIKeyID, &key.Key, &key.Fingerprint, &jobID, &batchID,
+		&statusCode, &key.CreatedAt, &key.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.JobID = jobID.String
+	key.BatchID = batchID.String
+	key.StatusCode = int(statusCode.Int64)
+	return &key, nil
+}
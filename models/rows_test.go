@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRow records how many destinations a scan function passes
+type countingRow struct {
+	dests int
+}
+
+var errCounted = errors.New("counted")
+
+func (r *countingRow) Scan(dest ...any) error {
+	r.dests = len(dest)
+	return errCounted
+}
+
+func TestRowMappingsMatchColumns(t *testing.T) {
+	job := NewJob("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	jobValues, err := JobValues(job)
+	require.NoError(t, err)
+	sub := &Subscription{ID: "sub_1", CreatedAt: time.Now()}
+	subValues, err := SubscriptionValues(sub)
+	require.NoError(t, err)
+
+	for _, mapping := range []struct {
+		name    string
+		columns string
+		insert  string
+		values  []any
+		scan    func(RowScanner) error
+	}{
+		{"jobs", JobColumns, InsertJobSQL, jobValues, func(r RowScanner) error { _, err := ScanJob(r); return err }},
+		{"batches", BatchColumns, InsertBatchSQL, BatchValues(&Batch{}), func(r RowScanner) error { _, err := ScanBatch(r); return err }},
+		{"subscriptions", SubscriptionColumns, InsertSubscriptionSQL, subValues, func(r RowScanner) error { _, err := ScanSubscription(r); return err }},
+		{"idempotency_keys", IdempotencyKeyColumns, ClaimIdempotencyKeySQL, IdempotencyKeyValues(&IdempotencyKey{}), func(r RowScanner) error { _, err := ScanIdempotencyKey(r); return err }},
+	} {
+		columns := len(strings.Split(mapping.columns, ", "))
+		assert.Len(t, mapping.values, columns, mapping.name)
+		assert.Contains(t, mapping.insert, "INSERT INTO "+mapping.name+" ("+mapping.columns+") VALUES ($1, ", mapping.name)
+		assert.Contains(t, mapping.insert, "$"+strconv.Itoa(columns)+")", mapping.name)
+
+		row := &countingRow{}
+		assert.ErrorIs(t, mapping.scan(row), errCounted)
+		assert.Equal(t, columns, row.dests, mapping.name)
+	}
+
+	assert.True(t, strings.HasPrefix(UpdateJobSQL, "UPDATE jobs SET url = $2, "))
+	assert.True(t, strings.HasSuffix(UpdateJobSQL, ", chapters = $27 WHERE id = $1"))
+	assert.True(t, strings.HasSuffix(UpdateSubscriptionSQL, ", updated_at = $13 WHERE id = $1"))
+}
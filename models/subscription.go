@@ -0,0 +1,192 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxSeenVideoIDs bounds how many video IDs a subscription remembers
+const MaxSeenVideoIDs = 5000
+
+// Subscription watches a channel or playlist and transcribes videos
+// published after it was registered. Each scan that finds new videos
+// submits them as one batch.
+type Subscription struct {
+	ID            string         `json:"id"`
+	URL           string         `json:"url"`
+	Kind          CollectionKind `json:"kind"`
+	Title         string         `json:"title,omitempty"`
+	Active        bool           `json:"active"`
+	WebhookURL    string         `json:"webhook_url,omitempty"`
+	WebhookSecret string         `json:"webhook_secret,omitempty"`
+	APIKeyID      string         `json:"api_key_id,omitempty"`
+	// SeenVideoIDs lists the videos already handled, newest first
+	SeenVideoIDs  []string   `json:"seen_video_ids,omitempty"`
+	LastScannedAt *time.Time `json:"last_scanned_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// SubscriptionRequest creates or updates a subscription. URL is only read
+// on creation; nil fields are left unchanged on update.
+type SubscriptionRequest struct {
+	URL           string  `json:"url"`
+	Active        *bool   `json:"active,omitempty"`
+	WebhookURL    *string `json:"webhook_url,omitempty"`
+	WebhookSecret *string `json:"webhook_secret,omitempty"`
+}
+
+// NewSubscription validates the request and creates an active subscription
+func NewSubscription(req SubscriptionRequest) (*Subscription, error) {
+	kind := CollectionKindOf(req.URL)
+	if kind == "" {
+		return nil, fmt.Errorf("url must be a YouTube playlist or channel URL")
+	}
+
+	now := time.Now()
+	sub := &Subscription{
+		ID:        uuid.New().String(),
+		URL:       req.URL,
+		Kind:      kind,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := sub.Apply(req); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Apply validates the request and updates the subscription's settings
+// from it. The subscription is left unchanged if the request is invalid.
+func (s *Subscription) Apply(req SubscriptionRequest) error {
+	if req.WebhookURL != nil && *req.WebhookURL != "" {
+		if err := ValidateWebhookURL(*req.WebhookURL); err != nil {
+			return err
+		}
+	}
+
+	if req.Active != nil {
+		s.Active = *req.Active
+	}
+	if req.WebhookURL != nil {
+		s.WebhookURL = *req.WebhookURL
+	}
+	if req.WebhookSecret != nil {
+		s.WebhookSecret = *req.WebhookSecret
+	}
+	s.UpdatedAt = time.Now()
+	return nil
+}
+
+// ValidateWebhookURL checks that a caller-supplied webhook URL is an
+// absolute https URL whose host is not a loopback, private or link-local
+// address, so subscriptions can not make the server call its own network.
+// Host names are checked again when the webhook connects, with
+// PublicWebhookAddress, as they may resolve to any address.
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return fmt.Errorf("webhook_url must be an absolute https URL")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if zone := strings.IndexByte(host, '%'); zone >= 0 {
+		host = host[:zone]
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("webhook_url must not point at a loopback, private or link-local address")
+	}
+	if ip := net.ParseIP(host); ip != nil && !PublicWebhookAddress(ip) {
+		return fmt.Errorf("webhook_url must not point at a loopback, private or link-local address")
+	}
+	return nil
+}
+
+// PublicWebhookAddress reports whether webhooks may connect to ip
+func PublicWebhookAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// Redacted returns a copy safe to return from the API, without the webhook
+// secret and seen video IDs
+func (s Subscription) Redacted() Subscription {
+	s.WebhookSecret = ""
+	s.SeenVideoIDs = nil
+	return s
+}
+
+// Clone returns a copy of the subscription that shares no slices or
+// pointers with it, like Job.Clone
+func (s *Subscription) Clone() *Subscription {
+	clone := *s
+	clone.SeenVideoIDs = slices.Clone(s.SeenVideoIDs)
+	clone.LastScannedAt = cloneTime(s.LastScannedAt)
+	return &clone
+}
+
+// RecordScan records a successful scan of the listed videos and returns
+// the ones to transcribe: those the subscription has not seen, at most
+// MaxBatchSize per scan. Videos beyond that stay unseen for the next scan.
+// The first scan only records the current videos, so registering a channel
+// does not transcribe its back catalogue.
+func (s *Subscription) RecordScan(entries []CollectionEntry, at time.Time) []CollectionEntry {
+	seen := make(map[string]bool, len(s.SeenVideoIDs))
+	for _, id := range s.SeenVideoIDs {
+		seen[id] = true
+	}
+
+	var fresh []CollectionEntry
+	ids := make([]string, 0, len(entries)+len(s.SeenVideoIDs))
+	for _, entry := range entries {
+		if seen[entry.VideoID] {
+			continue
+		}
+		if s.LastScannedAt != nil {
+			if len(fresh) == MaxBatchSize {
+				continue
+			}
+			fresh = append(fresh, entry)
+		}
+		seen[entry.VideoID] = true
+		ids = append(ids, entry.VideoID)
+	}
+
+	// Newly listed videos go first so the oldest IDs are dropped
+	ids = append(ids, s.SeenVideoIDs...)
+	if len(ids) > MaxSeenVideoIDs {
+		ids = ids[:MaxSeenVideoIDs]
+	}
+
+	s.SeenVideoIDs = ids
+	s.LastScannedAt = &at
+	s.LastError = ""
+	s.UpdatedAt = at
+	return fresh
+}
+
+// MarkScanFailed records a failed scan. Seen videos are kept, so the next
+// successful scan picks up where the last one left off.
+func (s *Subscription) MarkScanFailed(err error, at time.Time) {
+	s.LastError = err.Error()
+	s.UpdatedAt = at
+}
+
+// ScanLimit is how many of a channel's newest videos a scan
+// lists. Playlists are listed in full, as new videos may be added anywhere.
+func (s *Subscription) ScanLimit() int {
+	if s.Kind == CollectionChannel {
+		return MaxBatchSize
+	}
+	return 0
+}
@@ -2,84 +2,80 @@ package models
 
 import (
 	"bufio"
+	"io"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
-
-	"github.com/google/uuid"
 )
 
 type TranscribeRequest struct {
 	URL string `json:"url" validate:"required"`
+	// ForceRefresh transcribes the video again instead of reusing a cached
+	// or in-flight result
+	ForceRefresh bool `json:"force_refresh"`
+	// Priority is high, normal (the default) or low
+	Priority string `json:"priority,omitempty"`
+	// Mode is sync, async or auto (the default); WaitSeconds bounds how long
+	// sync and auto requests wait for the result
+	Mode        string `json:"mode,omitempty"`
+	WaitSeconds int    `json:"wait_seconds,omitempty"`
+	// Options for playlist and channel URLs, which expand into a batch
+	MaxItems     int    `json:"max_items,omitempty"`
+	DateAfter    string `json:"date_after,omitempty"`
+	DateBefore   string `json:"date_before,omitempty"`
+	SkipExisting bool   `json:"skip_existing,omitempty"`
+}
+
+// WaitOptions validates and returns the request's mode and wait_seconds
+func (r TranscribeRequest) WaitOptions() (WaitOptions, error) {
+	return ParseWaitOptions(r.Mode, r.WaitSeconds)
+}
+
+// CollectionOptions returns the request's playlist and channel options
+func (r TranscribeRequest) CollectionOptions() CollectionOptions {
+	return CollectionOptions{
+		MaxItems:     r.MaxItems,
+		DateAfter:    r.DateAfter,
+		DateBefore:   r.DateBefore,
+		SkipExisting: r.SkipExisting,
+	}
 }
 
 type TranscribeResponse struct {
 	JobID      string    `json:"job_id,omitempty"`
 	Transcript string    `json:"transcript,omitempty"`
 	Segments   []Segment `json:"segments,omitempty"`
+	// Set instead of JobID when a playlist or channel was expanded
+	BatchID string        `json:"batch_id,omitempty"`
+	Jobs    []BatchJobRef `json:"jobs,omitempty"`
 }
 
-// JobStatus represents the status of a transcription job
-type JobStatus string
-
-const (
-	StatusPending  JobStatus = "pending"
-	StatusRunning  JobStatus = "running"
-	StatusComplete JobStatus = "complete"
-	StatusError    JobStatus = "error"
-)
-
-// Job represents a transcription job
-type Job struct {
-	ID          string     `json:"id"`
-	URL         string     `json:"url"`
-	Status      JobStatus  `json:"status"`
-	Transcript  string     `json:"transcript,omitempty"`
-	Segments    []Segment  `json:"segments,omitempty"`
-	Error       string     `json:"error,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	CompletedAt *time.Time `json:"completed_at,omitempty"`
+// Segment represents a timestamped segment of transcribed text. Words is
+// only set by engines that report word timings. Speaker is only set by
+// subtitle files that label speakers, such as WebVTT <v> voices; none of the
+// transcription engines diarize yet.
+type Segment struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Text    string  `json:"text"`
+	Speaker string  `json:"speaker,omitempty"`
+	Words   []Word  `json:"words,omitempty"`
 }
 
-// Segment represents a timestamped segment of transcribed text
-type Segment struct {
+// Word is a single timed word of a segment
+type Word struct {
 	Start float64 `json:"start"`
 	End   float64 `json:"end"`
 	Text  string  `json:"text"`
 }
 
-// NewJob creates a new transcription job
-func NewJob(url string) *Job {
-	return &Job{
-		ID:        uuid.New().String(),
-		URL:       url,
-		Status:    StatusPending,
-		CreatedAt: time.Now(),
-	}
-}
-
-// MarkRunning marks the job as running
-func (j *Job) MarkRunning() {
-	j.Status = StatusRunning
-}
-
-// MarkComplete marks the job as complete with transcript and segments
-func (j *Job) MarkComplete(transcript string, segments []Segment) {
-	j.Status = StatusComplete
-	j.Transcript = transcript
-	j.Segments = segments
-	now := time.Now()
-	j.CompletedAt = &now
-}
-
-// MarkError marks the job as failed with an error
-func (j *Job) MarkError(err error) {
-	j.Status = StatusError
-	j.Error = err.Error()
-	now := time.Now()
-	j.CompletedAt = &now
+// Chapter is a titled section of a video. Chapters come from the source
+// video when it has them and are otherwise generated from the transcript.
+type Chapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Title string  `json:"title"`
 }
 
 func ValidateURL(url string) bool {
@@ -87,6 +83,17 @@ func ValidateURL(url string) bool {
 	return youtubeRegex.MatchString(url)
 }
 
+// ExtractVideoID returns the 11-character YouTube video ID of a URL, or
+// "unknown" if the URL does not contain one
+func ExtractVideoID(url string) string {
+	re := regexp.MustCompile(`(?:youtube\.com/watch\?v=|youtu\.be/|youtube\.com/embed/)([a-zA-Z0-9_-]{11})`)
+	matches := re.FindStringSubmatch(url)
+	if len(matches) >= 2 {
+		return matches[1]
+	}
+	return "unknown"
+}
+
 func LoadTranscript(filePath string) (string, []Segment, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -94,10 +101,18 @@ func LoadTranscript(filePath string) (string, []Segment, error) {
 	}
 	defer file.Close()
 
+	return ParseWhisperTranscript(file)
+}
+
+// ParseWhisperTranscript reads whisper's text output, in which timed lines
+// look like "[00:00:01.000 --> 00:00:04.500]  text". Hours may be left out
+// and milliseconds may follow a comma. Untimed lines are kept in the
+// transcript but have no segment.
+func ParseWhisperTranscript(r io.Reader) (string, []Segment, error) {
 	var transcript strings.Builder
 	var segments []Segment
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
@@ -119,9 +134,10 @@ func LoadTranscript(filePath string) (string, []Segment, error) {
 	return strings.TrimSpace(transcript.String()), segments, nil
 }
 
+var whisperLineRegex = regexp.MustCompile(`^\[((?:\d+:)?\d+:\d+[.,]\d+)\s*-->\s*((?:\d+:)?\d+:\d+[.,]\d+)\]\s*(.*)`)
+
 func parseWhisperLine(line string) *Segment {
-	timestampRegex := regexp.MustCompile(`^\[(\d+:\d+:\d+\.\d+) --> (\d+:\d+:\d+\.\d+)\]\s*(.*)`)
-	matches := timestampRegex.FindStringSubmatch(line)
+	matches := whisperLineRegex.FindStringSubmatch(line)
 
 	if len(matches) != 4 {
 		return nil
@@ -143,7 +159,10 @@ func parseWhisperLine(line string) *Segment {
 }
 
 func parseTimestamp(timestamp string) float64 {
-	parts := strings.Split(timestamp, ":")
+	parts := strings.Split(strings.Replace(timestamp, ",", ".", 1), ":")
+	if len(parts) == 2 {
+		parts = append([]string{"0"}, parts...)
+	}
 	if len(parts) != 3 {
 		return 0
 	}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWhisperTranscript(t *testing.T) {
+	input := strings.Join([]string{
+		"[00:00:00.000 --> 00:00:02.500]   Hello there.",
+		"",
+		"[00:02.500 --> 00:04.000] Short timestamps.",
+		"[01:00:00,000 --> 01:00:01,250] Comma milliseconds.",
+		"An untimed line",
+		"[00:00:05.000 --> 00:00:06.000]",
+	}, "\n")
+
+	transcript, segments, err := ParseWhisperTranscript(strings.NewReader(input))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Hello there. Short timestamps. Comma milliseconds. An untimed line [00:00:05.000 --> 00:00:06.000]", transcript)
+	assert.Equal(t, []Segment{
+		{Start: 0, End: 2.5, Text: "Hello there."},
+		{Start: 2.5, End: 4, Text: "Short timestamps."},
+		{Start: 3600, End: 3601.25, Text: "Comma milliseconds."},
+	}, segments)
+}
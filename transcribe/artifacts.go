@@ -0,0 +1,177 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"encore.dev"
+	"encore.dev/beta/errs"
+	"encore.dev/rlog"
+	"encore.dev/storage/objects"
+
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
+)
+
+// artifactBucket holds subtitle files and, optionally, normalized audio
+// under job-scoped keys.
+var artifactBucket = objects.NewBucket("artifacts", objects.BucketConfig{})
+
+// secrets are the service's Encore secrets. ArtifactSigningKey signs
+// artifact URLs; the instances must accept each other's URLs, so it is set
+// with "encore secret set" instead of being generated.
+var secrets struct {
+	ArtifactSigningKey string
+}
+
+// artifacts is the service's artifact store. Its signed URLs point at
+// DownloadArtifact.
+var artifacts = &bucketArtifactStore{
+	bucket: artifactBucket,
+	signer: newArtifactSigner(),
+}
+
+// errNoSigningKey is returned for artifact URLs while the
+// ArtifactSigningKey secret is not set.
+var errNoSigningKey = &errs.Error{
+	Code:    errs.Unavailable,
+	Message: "Artifact URLs can not be signed: the ArtifactSigningKey secret is not set",
+}
+
+// newArtifactSigner signs URLs with the ArtifactSigningKey secret. Without
+// it there is no signer, and the store stores artifacts but refuses to
+// sign or verify their URLs.
+func newArtifactSigner() *lib.ArtifactSigner {
+	if secrets.ArtifactSigningKey == "" {
+		return nil
+	}
+	return lib.NewArtifactSigner(cfg.PublicBaseURL, secrets.ArtifactSigningKey)
+}
+
+// bucketArtifactStore implements lib.ArtifactStore on an Encore object
+// storage bucket.
+type bucketArtifactStore struct {
+	bucket *objects.Bucket
+	signer *lib.ArtifactSigner
+}
+
+func (s *bucketArtifactStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	w := s.bucket.Upload(ctx, key, objects.WithUploadAttrs(objects.UploadAttrs{ContentType: contentType}))
+	size, err := io.Copy(w, r)
+	if err != nil {
+		w.Abort(err)
+		return 0, err
+	}
+	return size, w.Close()
+}
+
+func (s *bucketArtifactStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	r := s.bucket.Download(ctx, key)
+	if err := r.Err(); errors.Is(err, objects.ErrObjectNotFound) {
+		return nil, lib.ErrArtifactNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *bucketArtifactStore) Delete(ctx context.Context, key string) error {
+	err := s.bucket.Remove(ctx, key)
+	if errors.Is(err, objects.ErrObjectNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *bucketArtifactStore) SignedURL(key string, ttl time.Duration) (string, error) {
+	if s.signer == nil {
+		return "", errNoSigningKey
+	}
+	return s.signer.URL(key, time.Now().Add(ttl)), nil
+}
+
+func (s *bucketArtifactStore) VerifyURL(key, expires, signature string) error {
+	if s.signer == nil {
+		return errNoSigningKey
+	}
+	return s.signer.Verify(key, expires, signature)
+}
+
+// artifactURLTTL is how long signed artifact URLs stay valid.
+func artifactURLTTL() time.Duration {
+	if cfg.ArtifactURLTTL > 0 {
+		return time.Duration(cfg.ArtifactURLTTL) * time.Second
+	}
+	return lib.DefaultArtifactURLTTL
+}
+
+// DownloadArtifact serves a stored job artifact to the holder of a signed
+// URL. It is public because the signature is the credential.
+//
+//encore:api public raw method=GET path=/artifacts/*key
+func DownloadArtifact(w http.ResponseWriter, req *http.Request) {
+	key := encore.CurrentRequest().PathParams.Get("key")
+	query := req.URL.Query()
+	if err := artifacts.VerifyURL(key, query.Get("expires"), query.Get("signature")); errors.Is(err, errNoSigningKey) {
+		errs.HTTPError(w, err)
+		return
+	} else if err != nil {
+		errs.HTTPError(w, &errs.Error{
+			Code:    errs.PermissionDenied,
+			Message: "Invalid or expired artifact URL",
+		})
+		return
+	}
+
+	r, err := artifacts.Get(req.Context(), key)
+	if errors.Is(err, lib.ErrArtifactNotFound) {
+		errs.HTTPError(w, &errs.Error{
+			Code:    errs.NotFound,
+			Message: "Artifact not found",
+		})
+		return
+	} else if err != nil {
+		errs.HTTPError(w, err)
+		return
+	}
+	defer r.Close()
+
+	w.Header().Set("Content-Type", lib.ArtifactContentType(key))
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, r)
+}
+
+// storeArtifacts writes a complete job's subtitles and kept audio to the
+// bucket. Failures are logged and leave the job complete.
+func storeArtifacts(ctx context.Context, job *models.Job, audioPath string) {
+	if audioPath != "" {
+		defer os.Remove(audioPath)
+	}
+
+	stored, err := lib.StoreJobArtifacts(ctx, artifacts, job, audioPath)
+	if err != nil {
+		rlog.Error("failed to store artifacts", "error", err, "job_id", job.ID)
+	}
+	job.Artifacts = stored
+}
+
+// subtitleFiles links a complete job's subtitle files: signed artifact URLs
+// when they were stored, and the transcript downloads otherwise.
+func subtitleFiles(job *models.Job) *SubtitleFiles {
+	files, err := lib.SignedSubtitleFiles(artifacts, job, artifactURLTTL())
+	if err != nil {
+		rlog.Error("failed to sign artifact URLs", "error", err, "job_id", job.ID)
+	}
+	if files == nil {
+		return &SubtitleFiles{
+			SRTURL: lib.TranscriptURL(job.ID, "srt"),
+			VTTURL: lib.TranscriptURL(job.ID, "vtt"),
+		}
+	}
+	return &SubtitleFiles{SRTURL: files.SRTURL, VTTURL: files.VTTURL}
+}
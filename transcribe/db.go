@@ -2,7 +2,8 @@ package transcribe
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"time"
 
 	"encore.dev/storage/sqldb"
 
@@ -16,66 +17,287 @@ var db = sqldb.NewDatabase("jobs", sqldb.DatabaseConfig{
 
 // storeJob stores a job in the database.
 func storeJob(ctx context.Context, job *models.Job) error {
-	segmentsJSON, err := json.Marshal(job.Segments)
+	values, err := models.JobValues(job)
 	if err != nil {
 		return err
 	}
 
-	query := `
-		INSERT INTO jobs (id, url, status, transcript, segments, error, created_at, completed_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`
-
-	_, err = db.Exec(ctx, query,
-		job.ID, job.URL, job.Status, job.Transcript,
-		segmentsJSON, job.Error, job.CreatedAt, job.CompletedAt,
-	)
+	_, err = db.Exec(ctx, models.InsertJobSQL, values...)
 	return err
 }
 
 // getJob retrieves a job from the database.
 func getJob(ctx context.Context, id string) (*models.Job, error) {
-	query := `
-		SELECT id, url, status, transcript, segments, error, created_at, completed_at
-		FROM jobs WHERE id = $1
-	`
+	query := `SELECT ` + models.JobColumns + ` FROM jobs WHERE id = $1`
+
+	return models.ScanJob(db.QueryRow(ctx, query, id))
+}
+
+// updateJob updates a job in the database.
+func updateJob(ctx context.Context, job *models.Job) error {
+	values, err := models.JobValues(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, models.UpdateJobSQL, values...)
+	return err
+}
+
+// putJobArtifact adds an artifact to the stored job, replacing one with the
+// same key. The row is locked while it is updated, so concurrent renders of
+// a job keep each other's artifacts.
+func putJobArtifact(ctx context.Context, jobID string, artifact models.Artifact) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + models.JobColumns + ` FROM jobs WHERE id = $1 FOR UPDATE`
+	job, err := models.ScanJob(tx.QueryRow(ctx, query, jobID))
+	if err != nil {
+		return err
+	}
+	job.PutArtifact(artifact)
 
-	var job models.Job
-	var segmentsJSON []byte
+	values, err := models.JobValues(job)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, models.UpdateJobSQL, values...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// listJobs returns one page of jobs matching the query.
+func listJobs(ctx context.Context, query models.JobQuery) (*models.JobPage, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, err
+	}
+	clause, args, err := query.SQL()
+	if err != nil {
+		return nil, err
+	}
 
-	err := db.QueryRow(ctx, query, id).Scan(
-		&job.ID, &job.URL, &job.Status, &job.Transcript,
-		&segmentsJSON, &job.Error, &job.CreatedAt, &job.CompletedAt,
-	)
+	rows, err := db.Query(ctx, `SELECT `+models.JobColumns+` FROM jobs `+clause, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	if len(segmentsJSON) > 0 {
-		if err := json.Unmarshal(segmentsJSON, &job.Segments); err != nil {
+	var jobs []*models.Job
+	for rows.Next() {
+		job, err := models.ScanJob(rows)
+		if err != nil {
 			return nil, err
 		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
+	return query.Paginate(jobs), nil
+}
+
+// findCachedJob returns the newest complete job for the video whose result
+// is stored under cacheKey, or nil if there is none.
+func findCachedJob(ctx context.Context, videoID, cacheKey string) (*models.Job, error) {
+	if cacheKey == "" {
+		return nil, nil
+	}
+
+	query := `SELECT ` + models.JobColumns + ` FROM jobs
+		WHERE video_id = $1 AND cache_key = $2 AND status = 'complete'
+		ORDER BY completed_at DESC LIMIT 1`
 
-	return &job, nil
+	job, err := models.ScanJob(db.QueryRow(ctx, query, videoID, cacheKey))
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
 }
 
-// updateJob updates a job in the database.
-func updateJob(ctx context.Context, job *models.Job) error {
-	segmentsJSON, err := json.Marshal(job.Segments)
+// findInFlightJob returns the oldest pending or running job for the video,
+// or nil if there is none.
+func findInFlightJob(ctx context.Context, videoID string) (*models.Job, error) {
+	query := `SELECT ` + models.JobColumns + ` FROM jobs
+		WHERE video_id = $1 AND status IN ('pending', 'running')
+		ORDER BY created_at LIMIT 1`
+
+	job, err := models.ScanJob(db.QueryRow(ctx, query, videoID))
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// storeBatchJobs stores a batch and its child jobs in one transaction, so a
+// failed submission leaves none of them behind.
+func storeBatchJobs(ctx context.Context, batch *models.Batch, children []*models.Job) error {
+	tx, err := db.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	query := `
-		UPDATE jobs
-		SET status = $2, transcript = $3, segments = $4, error = $5, completed_at = $6
-		WHERE id = $1
-	`
+	if _, err := tx.Exec(ctx, models.InsertBatchSQL, models.BatchValues(batch)...); err != nil {
+		return err
+	}
+	for _, job := range children {
+		values, err := models.JobValues(job)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, models.InsertJobSQL, values...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// getBatch retrieves a batch from the database.
+func getBatch(ctx context.Context, id string) (*models.Batch, error) {
+	query := `SELECT ` + models.BatchColumns + ` FROM batches WHERE id = $1`
+
+	return models.ScanBatch(db.QueryRow(ctx, query, id))
+}
+
+// listBatchJobs returns the child jobs of a batch in submission order.
+func listBatchJobs(ctx context.Context, batchID string) ([]*models.Job, error) {
+	var children []*models.Job
+	query := models.JobQuery{BatchID: batchID, Ascending: true, Limit: models.MaxJobPageSize}
+	for {
+		page, err := listJobs(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, page.Jobs...)
+		if page.NextCursor == "" {
+			return children, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
 
-	_, err = db.Exec(ctx, query,
-		job.ID, job.Status, job.Transcript,
-		segmentsJSON, job.Error, job.CompletedAt,
-	)
+// completeBatch marks a batch complete. It reports false if the batch was
+// already complete, so only one caller sends the completion webhook.
+func completeBatch(ctx context.Context, id string, at time.Time) (bool, error) {
+	result, err := db.Exec(ctx, `UPDATE batches SET completed_at = $2 WHERE id = $1 AND completed_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// storeSubscription stores a subscription in the database.
+func storeSubscription(ctx context.Context, sub *models.Subscription) error {
+	values, err := models.SubscriptionValues(sub)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, models.InsertSubscriptionSQL, values...)
 	return err
-}
\ No newline at end of file
+}
+
+// getSubscription retrieves a subscription from the database.
+func getSubscription(ctx context.Context, id string) (*models.Subscription, error) {
+	query := `SELECT ` + models.SubscriptionColumns + ` FROM subscriptions WHERE id = $1`
+
+	return models.ScanSubscription(db.QueryRow(ctx, query, id))
+}
+
+// listSubscriptions returns all subscriptions, oldest first.
+func listSubscriptions(ctx context.Context) ([]*models.Subscription, error) {
+	rows, err := db.Query(ctx, `SELECT `+models.SubscriptionColumns+` FROM subscriptions ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*models.Subscription
+	for rows.Next() {
+		sub, err := models.ScanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// modifySubscription applies modify to the stored subscription and saves
+// it. The row is locked in between, so a scan and a concurrent update keep
+// each other's changes. Nothing is saved if modify fails.
+func modifySubscription(ctx context.Context, id string, modify func(*models.Subscription) error) (*models.Subscription, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + models.SubscriptionColumns + ` FROM subscriptions WHERE id = $1 FOR UPDATE`
+	sub, err := models.ScanSubscription(tx.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := modify(sub); err != nil {
+		return nil, err
+	}
+
+	values, err := models.SubscriptionValues(sub)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, models.UpdateSubscriptionSQL, values...); err != nil {
+		return nil, err
+	}
+	return sub, tx.Commit()
+}
+
+// deleteSubscription deletes a subscription. It reports false if there was
+// no subscription with the ID.
+func deleteSubscription(ctx context.Context, id string) (bool, error) {
+	result, err := db.Exec(ctx, `DELETE FROM subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// claimIdempotencyKey stores an idempotency key unless an unexpired one
+// with the same API key and value exists, which is returned instead.
+func claimIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	if _, err := db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, key.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	result, err := db.Exec(ctx, models.ClaimIdempotencyKeySQL, models.IdempotencyKeyValues(key)...)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + models.IdempotencyKeyColumns + ` FROM idempotency_keys WHERE api_key_id = $1 AND key = $2`
+
+	return models.ScanIdempotencyKey(db.QueryRow(ctx, query, key.APIKeyID, key.Key))
+}
+
+// updateIdempotencyKey records what the request with the key created.
+func updateIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	_, err := db.Exec(ctx, `
+		UPDATE idempotency_keys SET job_id = $3, batch_id = $4, status_code = $5
+		WHERE api_key_id = $1 AND key = $2
+	`, key.APIKeyID, key.Key, key.JobID, key.BatchID, key.StatusCode)
+	return err
+}
+
+// deleteIdempotencyKey releases a key whose request created nothing.
+func deleteIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	_, err := db.Exec(ctx, `DELETE FROM idempotency_keys WHERE api_key_id = $1 AND key = $2`, key.APIKeyID, key.Key)
+	return err
+}
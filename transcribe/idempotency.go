@@ -0,0 +1,149 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+	"encore.dev/rlog"
+
+	"videotranscript-app/models"
+)
+
+// idempotencyTTL is how long an Idempotency-Key is remembered.
+func idempotencyTTL() time.Duration {
+	if cfg.IdempotencyKeyTTL > 0 {
+		return time.Duration(cfg.IdempotencyKeyTTL) * time.Second
+	}
+	return 24 * time.Hour
+}
+
+// checkIdempotencyKey claims a client's Idempotency-Key for a request to
+// path. If the same request was already made with the key, the earlier
+// record is returned; a different request with the key, or a repeat while
+// the first request is still being processed, is rejected. A nil key means
+// the request has none.
+func checkIdempotencyKey(ctx context.Context, value, path string, req any) (key, existing *models.IdempotencyKey, err error) {
+	if value == "" {
+		return nil, nil, nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	var apiKeyID string
+	if uid, ok := auth.UserID(); ok {
+		apiKeyID = string(uid)
+	}
+	key, err = models.NewIdempotencyKey(apiKeyID, value, models.RequestFingerprint("POST", path, body), idempotencyTTL())
+	if err != nil {
+		return nil, nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+
+	existing, err = claimIdempotencyKey(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing == nil {
+		return key, nil, nil
+	}
+	if existing.Fingerprint != key.Fingerprint {
+		return nil, nil, &errs.Error{
+			Code:    errs.AlreadyExists,
+			Message: "Idempotency-Key was already used with a different request",
+		}
+	}
+	if !existing.Completed() {
+		return nil, nil, &errs.Error{
+			Code:    errs.Aborted,
+			Message: "A request with this Idempotency-Key is still being processed",
+		}
+	}
+	rlog.Info("replaying idempotent request", "idempotency_key", existing.Key, "job_id", existing.JobID, "batch_id", existing.BatchID)
+	return nil, existing, nil
+}
+
+// recordIdempotencyKey remembers what the request with the key created.
+func recordIdempotencyKey(ctx context.Context, key *models.IdempotencyKey, jobID, batchID string, status int) {
+	if key == nil {
+		return
+	}
+	if jobID == "" && batchID == "" {
+		releaseIdempotencyKey(ctx, key)
+		return
+	}
+
+	key.JobID = jobID
+	key.BatchID = batchID
+	key.StatusCode = status
+	if err := updateIdempotencyKey(ctx, key); err != nil {
+		rlog.Error("failed to save idempotency key", "error", err, "idempotency_key", key.Key)
+	}
+}
+
+// releaseIdempotencyKey lets the client retry a request that created
+// nothing with the same key.
+func releaseIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) {
+	if key == nil {
+		return
+	}
+	if err := deleteIdempotencyKey(ctx, key); err != nil {
+		rlog.Error("failed to release idempotency key", "error", err, "idempotency_key", key.Key)
+	}
+}
+
+// replayTranscribe answers a repeated transcription request with the
+// current state of the job or batch the first request created.
+func replayTranscribe(ctx context.Context, key *models.IdempotencyKey) (*TranscribeResponse, error) {
+	if key.JobID != "" {
+		job, err := getJob(ctx, key.JobID)
+		if err != nil {
+			return nil, err
+		}
+		return jobResponse(job), nil
+	}
+
+	batch, err := replayBatch(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &TranscribeResponse{
+		HTTPStatus: 202,
+		Location:   "/transcribe/batch/" + batch.BatchID,
+		BatchID:    batch.BatchID,
+		Jobs:       batch.Jobs,
+	}, nil
+}
+
+// replayBatch answers a repeated batch request with the batch the first
+// request created.
+func replayBatch(ctx context.Context, key *models.IdempotencyKey) (*models.BatchResponse, error) {
+	if key.BatchID == "" {
+		return nil, errors.New("idempotency key does not refer to a batch")
+	}
+	batch, err := getBatch(ctx, key.BatchID)
+	if err != nil {
+		return nil, err
+	}
+	children, err := listBatchJobs(ctx, batch.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]models.BatchJobRef, 0, len(children))
+	for _, job := range children {
+		refs = append(refs, models.BatchJobRef{URL: job.URL, JobID: job.ID})
+	}
+	return &models.BatchResponse{
+		BatchID: batch.ID,
+		Total:   batch.Total,
+		Jobs:    refs,
+	}, nil
+}
@@ -0,0 +1,2 @@
+-- Remove job priority
+ALTER TABLE jobs DROP COLUMN IF EXISTS priority;
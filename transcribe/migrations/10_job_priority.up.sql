@@ -0,0 +1,2 @@
+-- Scheduling priority of a job within its API key's share
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS priority TEXT DEFAULT 'normal';
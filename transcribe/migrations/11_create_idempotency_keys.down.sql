@@ -0,0 +1,2 @@
+-- Remove idempotency keys
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,2 @@
+-- Remove job chapters
+ALTER TABLE jobs DROP COLUMN IF EXISTS chapters;
@@ -0,0 +1,2 @@
+-- Chapters of the source video or generated from the transcript
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS chapters JSONB;
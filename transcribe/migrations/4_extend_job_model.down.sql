@@ -0,0 +1,8 @@
+-- Remove shared job model columns
+DROP INDEX IF EXISTS idx_jobs_api_key_id;
+
+ALTER TABLE jobs DROP COLUMN IF EXISTS engine;
+ALTER TABLE jobs DROP COLUMN IF EXISTS language;
+ALTER TABLE jobs DROP COLUMN IF EXISTS metadata;
+ALTER TABLE jobs DROP COLUMN IF EXISTS artifacts;
+ALTER TABLE jobs DROP COLUMN IF EXISTS api_key_id;
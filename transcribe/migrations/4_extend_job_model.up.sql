@@ -0,0 +1,14 @@
+-- Columns for the shared job model (engine, language, metadata, artifacts, attribution)
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS engine TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS language TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS metadata JSONB;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS artifacts JSONB;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS api_key_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_api_key_id ON jobs(api_key_id);
+
+-- Normalize legacy dashboard status names onto the canonical ones
+UPDATE jobs SET status = 'pending' WHERE status = 'queued';
+UPDATE jobs SET status = 'complete' WHERE status = 'completed';
+UPDATE jobs SET status = 'error' WHERE status = 'failed';
+UPDATE jobs SET stage = status, status = 'running' WHERE status IN ('downloading', 'extracting', 'transcribing');
@@ -0,0 +1,6 @@
+-- Remove retry columns
+DROP INDEX IF EXISTS idx_jobs_error_code;
+
+ALTER TABLE jobs DROP COLUMN IF EXISTS error_code;
+ALTER TABLE jobs DROP COLUMN IF EXISTS attempts;
+ALTER TABLE jobs DROP COLUMN IF EXISTS retry_at;
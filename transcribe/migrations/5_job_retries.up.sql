@@ -0,0 +1,6 @@
+-- Classified errors and automatic retries
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS error_code TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempts INTEGER DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS retry_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_error_code ON jobs(error_code);
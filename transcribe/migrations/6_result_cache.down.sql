@@ -0,0 +1,5 @@
+-- Remove result cache columns
+DROP INDEX IF EXISTS idx_jobs_cache_key;
+
+ALTER TABLE jobs DROP COLUMN IF EXISTS cache_key;
+ALTER TABLE jobs DROP COLUMN IF EXISTS cached_from;
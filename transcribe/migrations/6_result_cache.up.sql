@@ -0,0 +1,5 @@
+-- Reuse of completed transcripts for the same source and engine settings
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cache_key TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cached_from TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_cache_key ON jobs(cache_key) WHERE cache_key IS NOT NULL;
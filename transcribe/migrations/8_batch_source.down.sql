@@ -0,0 +1,3 @@
+-- Remove batch source columns
+ALTER TABLE batches DROP COLUMN IF EXISTS title;
+ALTER TABLE batches DROP COLUMN IF EXISTS source_url;
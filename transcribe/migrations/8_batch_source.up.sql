@@ -0,0 +1,3 @@
+-- Batches expanded from a playlist or channel record their source
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS source_url TEXT;
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS title TEXT;
@@ -0,0 +1,4 @@
+-- Remove subscriptions
+ALTER TABLE batches DROP COLUMN IF EXISTS subscription_id;
+
+DROP TABLE IF EXISTS subscriptions;
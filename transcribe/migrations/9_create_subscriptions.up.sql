@@ -0,0 +1,18 @@
+-- Subscriptions watch a channel or playlist for new videos
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id TEXT PRIMARY KEY,
+    url TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    title TEXT,
+    active BOOLEAN NOT NULL DEFAULT true,
+    webhook_url TEXT,
+    webhook_secret TEXT,
+    api_key_id TEXT,
+    seen_video_ids JSONB,
+    last_scanned_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS subscription_id TEXT;
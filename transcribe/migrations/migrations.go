@@ -0,0 +1,11 @@
+// Package migrations holds the schema of the jobs database. The Encore
+// service applies these files itself; the Fiber server's Postgres job store
+// embeds them from here, so both run the same migrations.
+package migrations
+
+import "embed"
+
+// FS holds the up migrations, named "<version>_<description>.up.sql".
+//
+//go:embed *.up.sql
+var FS embed.FS
@@ -3,7 +3,7 @@ package transcribe
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"time"
 
 	"encore.dev/beta/auth"
@@ -11,6 +11,7 @@ import (
 	"encore.dev/beta/pubsub"
 	"encore.dev/config"
 	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
 
 	"videotranscript-app/lib"
 	"videotranscript-app/models"
@@ -20,34 +21,90 @@ import (
 var cfg = config.Load[Config]()
 
 type Config struct {
-	APIKey         string               `json:"api_key"`
-	WorkDir        string               `json:"work_dir"`
-	MaxVideoLength int                  `json:"max_video_length"`
-	FreeJobLimit   int                  `json:"free_job_limit"`
-	WebhookURL     string               `json:"webhook_url"`
-	WebhookSecret  string               `json:"webhook_secret"`
-	WebhookEvents  []string             `json:"webhook_events"`
+	APIKey         string   `json:"api_key"`
+	WorkDir        string   `json:"work_dir"`
+	MaxVideoLength int      `json:"max_video_length"`
+	FreeJobLimit   int      `json:"free_job_limit"`
+	WebhookURL     string   `json:"webhook_url"`
+	WebhookSecret  string   `json:"webhook_secret"`
+	WebhookEvents  []string `json:"webhook_events"`
+	// PublicBaseURL prefixes signed artifact URLs, which stay valid for
+	// ArtifactURLTTL seconds and are signed with the ArtifactSigningKey
+	// secret.
+	PublicBaseURL  string `json:"public_base_url"`
+	ArtifactURLTTL int    `json:"artifact_url_ttl"`
+	// MaxUploadMB caps video uploads for captioned renders; it defaults
+	// to 512.
+	MaxUploadMB int `json:"max_upload_mb"`
+	// IdempotencyKeyTTL is how many seconds Idempotency-Key headers are
+	// remembered, like IDEMPOTENCY_KEY_TTL; it defaults to 24 hours.
+	IdempotencyKeyTTL int `json:"idempotency_key_ttl"`
 }
 
 // TranscribeRequest represents a transcription request.
 type TranscribeRequest struct {
 	URL string `json:"url"`
+	// ForceRefresh transcribes the video again instead of reusing a cached
+	// or in-flight result.
+	ForceRefresh bool `json:"force_refresh"`
+	// Priority is high, normal (the default) or low. Jobs are stored with
+	// their priority; Pub/Sub delivers them in publish order.
+	Priority string `json:"priority,omitempty"`
+	// Mode is sync, async or auto (the default); WaitSeconds bounds how
+	// long sync and auto requests wait for the result.
+	Mode        string `json:"mode,omitempty"`
+	WaitSeconds int    `json:"wait_seconds,omitempty"`
+	// IdempotencyKey makes retries of the request return the job it created.
+	IdempotencyKey string `header:"Idempotency-Key" json:"-"`
+	// Options for playlist and channel URLs, which expand into a batch.
+	MaxItems     int    `json:"max_items,omitempty"`
+	DateAfter    string `json:"date_after,omitempty"`
+	DateBefore   string `json:"date_before,omitempty"`
+	SkipExisting bool   `json:"skip_existing,omitempty"`
 }
 
-// TranscribeResponse represents the response from a transcription request.
+// CollectionOptions returns the request's playlist and channel options.
+func (r *TranscribeRequest) CollectionOptions() models.CollectionOptions {
+	return models.CollectionOptions{
+		MaxItems:     r.MaxItems,
+		DateAfter:    r.DateAfter,
+		DateBefore:   r.DateBefore,
+		SkipExisting: r.SkipExisting,
+	}
+}
+
+// TranscribeResponse is the job resource, as returned by GetJob. It is sent
+// with 200 once the job completed, 500 if it failed, and 202 with a
+// Location header while it is pending or running.
 type TranscribeResponse struct {
-	JobID      string         `json:"job_id,omitempty"`
-	Transcript string         `json:"transcript,omitempty"`
-	Segments   []models.Segment `json:"segments,omitempty"`
+	HTTPStatus int    `encore:"httpstatus"`
+	Location   string `header:"Location"`
+	// JobID predates the job resource and is kept for existing clients.
+	JobID string `json:"job_id,omitempty"`
+	*JobStatusResponse
+	// Set instead of the job when a playlist or channel was expanded.
+	BatchID string               `json:"batch_id,omitempty"`
+	Jobs    []models.BatchJobRef `json:"jobs,omitempty"`
 }
 
 // JobStatusResponse represents the response for job status queries.
 type JobStatusResponse struct {
 	ID            string           `json:"id"`
+	VideoID       string           `json:"video_id,omitempty"`
 	Status        string           `json:"status"`
+	Priority      string           `json:"priority,omitempty"`
+	Stage         string           `json:"stage,omitempty"`
+	Progress      int              `json:"progress"`
+	Engine        string           `json:"engine,omitempty"`
+	Language      string           `json:"language,omitempty"`
+	CachedFrom    string           `json:"cached_from,omitempty"`
 	Transcript    string           `json:"transcript,omitempty"`
 	Segments      []models.Segment `json:"segments,omitempty"`
+	Chapters      []models.Chapter `json:"chapters,omitempty"`
 	Error         string           `json:"error,omitempty"`
+	ErrorCode     string           `json:"error_code,omitempty"`
+	Attempts      int              `json:"attempts"`
+	RetryAt       *time.Time       `json:"retry_at,omitempty"`
 	CreatedAt     time.Time        `json:"created_at"`
 	CompletedAt   *time.Time       `json:"completed_at,omitempty"`
 	SubtitleFiles *SubtitleFiles   `json:"subtitle_files,omitempty"`
@@ -77,8 +134,46 @@ type HealthResponse struct {
 //
 //encore:api auth method=POST path=/transcribe
 func Transcribe(ctx context.Context, req *TranscribeRequest) (*TranscribeResponse, error) {
+	key, existing, err := checkIdempotencyKey(ctx, req.IdempotencyKey, "/transcribe", req)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return replayTranscribe(ctx, existing)
+	}
+
+	resp, err := transcribe(ctx, req)
+	if err != nil {
+		releaseIdempotencyKey(ctx, key)
+		return nil, err
+	}
+	recordIdempotencyKey(ctx, key, resp.JobID, resp.BatchID, resp.HTTPStatus)
+	return resp, nil
+}
+
+func transcribe(ctx context.Context, req *TranscribeRequest) (*TranscribeResponse, error) {
 	rlog.Info("transcribe request", "url", req.URL)
 
+	priority, err := models.ParseJobPriority(req.Priority)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	opts, err := models.ParseWaitOptions(req.Mode, req.WaitSeconds)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+
+	// Playlists and channels expand into a batch of jobs
+	if models.CollectionKindOf(req.URL) != "" {
+		return transcribeCollection(ctx, req, priority)
+	}
+
 	// Validate URL
 	if !models.ValidateURL(req.URL) {
 		return nil, &errs.Error{
@@ -87,6 +182,15 @@ func Transcribe(ctx context.Context, req *TranscribeRequest) (*TranscribeRespons
 		}
 	}
 
+	// Reuse a cached result or attach to a job already processing the video
+	if !req.ForceRefresh {
+		if resp, err := reuseExistingJob(ctx, req.URL, opts); err != nil {
+			rlog.Error("failed to look up existing jobs", "error", err, "url", req.URL)
+		} else if resp != nil {
+			return resp, nil
+		}
+	}
+
 	// Get video duration to determine processing strategy
 	duration, err := lib.GetVideoDuration(req.URL)
 	if err != nil {
@@ -99,42 +203,136 @@ func Transcribe(ctx context.Context, req *TranscribeRequest) (*TranscribeRespons
 
 	// Create job
 	job := models.NewJob(req.URL)
+	job.Metadata = &models.VideoMetadata{Duration: float64(duration)}
+	job.Priority = priority
+	if uid, ok := auth.UserID(); ok {
+		job.APIKeyID = string(uid)
+	}
+
+	// Store the job before processing so it can be polled
+	if err := storeJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	// Every job is processed by the job-processing subscription, which
+	// retries transient failures and bounds concurrency. Requests that wait
+	// watch the stored job until it finishes or the wait runs out, and the
+	// job keeps processing after that.
+	if err := publishJob(ctx, job); err != nil {
+		return nil, err
+	}
+	wait := opts.WaitFor(duration)
+	if wait == 0 {
+		rlog.Info("queued video for async processing", "duration", duration, "job_id", job.ID)
+		return jobResponse(job), nil
+	}
 
-	// For short videos (≤2 min), process synchronously
-	if duration <= 120 {
-		rlog.Info("processing video synchronously", "duration", duration, "job_id", job.ID)
+	rlog.Info("queued video and waiting for the result", "duration", duration, "job_id", job.ID, "wait", wait)
+	finished, err := waitForJob(ctx, job.ID, wait)
+	if err != nil {
+		return nil, err
+	}
+	return jobResponse(finished), nil
+}
 
-		transcript, segments, err := lib.ProcessTranscription(req.URL, job.ID)
+// syncPollInterval is how often waiting requests reload their job
+const syncPollInterval = time.Second
+
+// waitForJob reloads a job until it completes or fails, a retry is
+// scheduled, or wait runs out, and returns its latest state.
+func waitForJob(ctx context.Context, id string, wait time.Duration) (*models.Job, error) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(syncPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return getJob(ctx, id)
+		case <-ticker.C:
+		}
+
+		job, err := getJob(ctx, id)
 		if err != nil {
-			rlog.Error("transcription failed", "error", err, "job_id", job.ID)
-			return nil, &errs.Error{
-				Code:    errs.Internal,
-				Message: "Transcription failed",
-			}
+			return nil, err
+		}
+		if job.Status == models.StatusComplete || job.Status == models.StatusError || job.RetryAt != nil {
+			return job, nil
 		}
+	}
+}
 
-		return &TranscribeResponse{
-			Transcript: transcript,
-			Segments:   segments,
-		}, nil
+// jobResponse answers a transcription request with the job resource and
+// the status code matching its state.
+func jobResponse(job *models.Job) *TranscribeResponse {
+	response := &TranscribeResponse{
+		JobID:             job.ID,
+		JobStatusResponse: jobStatus(job),
 	}
+	switch job.Status {
+	case models.StatusComplete:
+		response.HTTPStatus = 200
+	case models.StatusError:
+		response.HTTPStatus = 500
+	default:
+		response.HTTPStatus = 202
+		response.Location = "/transcribe/" + job.ID
+	}
+	return response
+}
 
-	// For longer videos, queue for async processing
-	rlog.Info("queueing video for async processing", "duration", duration, "job_id", job.ID)
+// reuseExistingJob answers a request from a cached result or an in-flight
+// job for the same video, waiting for the in-flight job as opts ask. It
+// returns nil if the video must be processed.
+func reuseExistingJob(ctx context.Context, url string, opts models.WaitOptions) (*TranscribeResponse, error) {
+	videoID := models.ExtractVideoID(url)
 
-	// Store job in database and publish to queue
-	if err := storeJob(ctx, job); err != nil {
+	cached, err := findCachedJob(ctx, videoID, lib.ResultCacheKey(url))
+	if err != nil {
 		return nil, err
 	}
+	if cached != nil {
+		job := models.NewJob(url)
+		if uid, ok := auth.UserID(); ok {
+			job.APIKeyID = string(uid)
+		}
+		if err := job.CompleteFromCache(cached); err != nil {
+			return nil, err
+		}
+		if err := storeJob(ctx, job); err != nil {
+			return nil, err
+		}
 
-	// Publish job to processing queue
-	if err := publishJob(ctx, job); err != nil {
+		rlog.Info("reused cached transcript", "job_id", job.ID, "cached_from", cached.ID)
+		return jobResponse(job), nil
+	}
+
+	existing, err := findInFlightJob(ctx, videoID)
+	if err != nil || existing == nil {
 		return nil, err
 	}
 
-	return &TranscribeResponse{
-		JobID: job.ID,
-	}, nil
+	// The existing job may not know its video length yet
+	duration := -1
+	if existing.Metadata != nil {
+		duration = int(existing.VideoDuration())
+	}
+	wait := opts.WaitFor(duration)
+	rlog.Info("attached to in-flight job", "job_id", existing.ID, "url", url, "wait", wait)
+	if wait == 0 {
+		return jobResponse(existing), nil
+	}
+
+	// The job may be processed by another instance, so it is waited for
+	// through the database like a new job
+	finished, err := waitForJob(ctx, existing.ID, wait)
+	if err != nil {
+		return nil, err
+	}
+	return jobResponse(finished), nil
 }
 
 // GetJob retrieves the status and result of a transcription job.
@@ -148,25 +346,387 @@ func GetJob(ctx context.Context, id string) (*JobStatusResponse, error) {
 			Message: "Job not found",
 		}
 	}
+	return jobStatus(job), nil
+}
+
+// findJob loads one of the caller's jobs, returning a NotFound error if it
+// does not exist or belongs to another API key.
+func findJob(ctx context.Context, id string) (*models.Job, error) {
+	job, err := getJob(ctx, id)
+	if err == nil {
+		if uid, _ := auth.UserID(); job.APIKeyID == string(uid) {
+			return job, nil
+		}
+	} else if !errors.Is(err, sqldb.ErrNoRows) {
+		return nil, err
+	}
+	return nil, &errs.Error{
+		Code:    errs.NotFound,
+		Message: "Job not found",
+	}
+}
 
+// jobStatus returns the job resource with the fields relevant to its status.
+func jobStatus(job *models.Job) *JobStatusResponse {
 	response := &JobStatusResponse{
 		ID:        job.ID,
+		VideoID:   job.VideoID,
 		Status:    string(job.Status),
+		Priority:  string(job.Priority),
+		Stage:     string(job.Stage),
+		Progress:  job.Progress,
+		Error:     job.Error,
+		ErrorCode: string(job.ErrorCode),
+		Attempts:  job.Attempts,
+		RetryAt:   job.RetryAt,
 		CreatedAt: job.CreatedAt,
 	}
 
 	if job.Status == models.StatusComplete {
+		response.Engine = job.Engine
+		response.Language = job.Language
+		response.CachedFrom = job.CachedFrom
 		response.Transcript = job.Transcript
 		response.Segments = job.Segments
+		response.Chapters = job.Chapters
 		response.CompletedAt = job.CompletedAt
+		response.SubtitleFiles = subtitleFiles(job)
 	} else if job.Status == models.StatusError {
-		response.Error = job.Error
 		response.CompletedAt = job.CompletedAt
 	}
 
+	return response
+}
+
+// ListJobsParams filters, sorts and paginates a job listing.
+type ListJobsParams struct {
+	Status        string `query:"status"`
+	CreatedAfter  string `query:"created_after"`
+	CreatedBefore string `query:"created_before"`
+	URL           string `query:"url"`
+	VideoID       string `query:"video_id"`
+	APIKeyID      string `query:"api_key_id"`
+	BatchID       string `query:"batch_id"`
+	Sort          string `query:"sort"`
+	Order         string `query:"order"`
+	Limit         string `query:"limit"`
+	Cursor        string `query:"cursor"`
+}
+
+// ListJobsResponse is one page of a job listing.
+type ListJobsResponse struct {
+	Jobs       []models.JobSummary `json:"jobs"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// ListJobs lists transcription jobs, newest first by default.
+//
+//encore:api auth method=GET path=/transcribe
+func ListJobs(ctx context.Context, params *ListJobsParams) (*ListJobsResponse, error) {
+	query, err := models.ParseJobQuery(map[string]string{
+		"status":         params.Status,
+		"created_after":  params.CreatedAfter,
+		"created_before": params.CreatedBefore,
+		"url":            params.URL,
+		"video_id":       params.VideoID,
+		"api_key_id":     params.APIKeyID,
+		"batch_id":       params.BatchID,
+		"sort":           params.Sort,
+		"order":          params.Order,
+		"limit":          params.Limit,
+		"cursor":         params.Cursor,
+	})
+	if err == nil {
+		uid, _ := auth.UserID()
+		err = query.RestrictToAPIKey(string(uid))
+	}
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+
+	page, err := listJobs(ctx, query)
+	if err != nil {
+		rlog.Error("failed to list jobs", "error", err)
+		return nil, err
+	}
+
+	response := &ListJobsResponse{
+		Jobs:       make([]models.JobSummary, 0, len(page.Jobs)),
+		NextCursor: page.NextCursor,
+	}
+	for _, job := range page.Jobs {
+		response.Jobs = append(response.Jobs, job.Summary())
+	}
 	return response, nil
 }
 
+// TranscribeBatch transcribes several YouTube videos with shared options.
+// Each URL becomes a job of the batch; cached results complete at once.
+//
+//encore:api auth method=POST path=/transcribe/batch
+func TranscribeBatch(ctx context.Context, req *models.BatchRequest) (*models.BatchResponse, error) {
+	key, existing, err := checkIdempotencyKey(ctx, req.IdempotencyKey, "/transcribe/batch", req)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return replayBatch(ctx, existing)
+	}
+
+	resp, err := transcribeBatch(ctx, req)
+	if err != nil {
+		releaseIdempotencyKey(ctx, key)
+		return nil, err
+	}
+	recordIdempotencyKey(ctx, key, "", resp.BatchID, 200)
+	return resp, nil
+}
+
+func transcribeBatch(ctx context.Context, req *models.BatchRequest) (*models.BatchResponse, error) {
+	urls, err := models.ValidateBatchURLs(req.URLs)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	priority, err := models.ParseJobPriority(req.Priority)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+
+	batch := models.NewBatch(len(urls), req.ForceRefresh)
+	children := make([]*models.Job, 0, len(urls))
+	for _, url := range urls {
+		job := models.NewJob(url)
+		job.Priority = priority
+		children = append(children, job)
+	}
+
+	rlog.Info("transcribe batch request", "batch_id", batch.ID, "total", batch.Total)
+
+	refs, err := submitBatch(ctx, batch, children)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.BatchResponse{
+		BatchID: batch.ID,
+		Total:   batch.Total,
+		Jobs:    refs,
+	}, nil
+}
+
+// transcribeCollection expands a playlist or channel URL into a batch with
+// one job per selected video.
+func transcribeCollection(ctx context.Context, req *TranscribeRequest, priority models.JobPriority) (*TranscribeResponse, error) {
+	opts := req.CollectionOptions()
+	if err := opts.Validate(); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+
+	var skip func(models.CollectionEntry) bool
+	if opts.SkipExisting {
+		skip = func(entry models.CollectionEntry) bool {
+			cached, err := findCachedJob(ctx, entry.VideoID, lib.ResultCacheKey(entry.URL))
+			return err == nil && cached != nil
+		}
+	}
+
+	collection, err := lib.ExpandCollection(ctx, req.URL, opts, skip)
+	if err != nil {
+		rlog.Error("failed to expand collection", "error", err, "url", req.URL)
+		code := errs.Unavailable
+		if !models.IsRetryable(err) {
+			code = errs.InvalidArgument
+		}
+		return nil, &errs.Error{
+			Code:    code,
+			Message: "Failed to list playlist or channel videos",
+			Meta:    errs.Metadata{"error_code": models.ErrorCodeOf(err)},
+		}
+	}
+	if len(collection.Entries) == 0 {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: "No videos in the " + string(collection.Kind) + " match the request",
+		}
+	}
+
+	batch := models.NewBatch(len(collection.Entries), req.ForceRefresh)
+	batch.SourceURL = req.URL
+	batch.Title = collection.Title
+	children := make([]*models.Job, 0, len(collection.Entries))
+	for _, entry := range collection.Entries {
+		job := entry.NewJob()
+		job.Priority = priority
+		children = append(children, job)
+	}
+
+	rlog.Info("expanded collection", "kind", collection.Kind, "batch_id", batch.ID, "total", batch.Total, "url", req.URL)
+
+	refs, err := submitBatch(ctx, batch, children)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TranscribeResponse{
+		HTTPStatus: 202,
+		Location:   "/transcribe/batch/" + batch.ID,
+		BatchID:    batch.ID,
+		Jobs:       refs,
+	}, nil
+}
+
+// submitBatch stores the batch and its children and publishes every child
+// that cannot reuse a cached result. Everything is stored in one
+// transaction before anything is published, so a failed submission runs no
+// job and its retry with the same idempotency key does not duplicate any.
+// A child that fails to publish is marked failed, so the batch completes.
+func submitBatch(ctx context.Context, batch *models.Batch, children []*models.Job) ([]models.BatchJobRef, error) {
+	if uid, ok := auth.UserID(); ok {
+		batch.APIKeyID = string(uid)
+	}
+
+	refs := make([]models.BatchJobRef, 0, len(children))
+	var queued []*models.Job
+	for _, job := range children {
+		job.APIKeyID = batch.APIKeyID
+		job.BatchID = batch.ID
+		refs = append(refs, models.BatchJobRef{URL: job.URL, JobID: job.ID})
+
+		if !batch.ForceRefresh {
+			cached, err := findCachedJob(ctx, job.VideoID, lib.ResultCacheKey(job.URL))
+			if err != nil {
+				rlog.Error("failed to look up cached result", "error", err, "url", job.URL)
+			} else if cached != nil && job.CompleteFromCache(cached) == nil {
+				continue
+			}
+		}
+		queued = append(queued, job)
+	}
+
+	if err := storeBatchJobs(ctx, batch, children); err != nil {
+		return nil, err
+	}
+
+	for _, job := range queued {
+		if err := publishJob(ctx, job); err != nil {
+			rlog.Error("failed to publish batch job", "error", err, "job_id", job.ID, "batch_id", batch.ID)
+			job.MarkError(err)
+			if err := updateJob(ctx, job); err != nil {
+				rlog.Error("failed to store failed batch job", "error", err, "job_id", job.ID)
+			}
+		}
+	}
+
+	// Every child may already be finished, e.g. when all were cached.
+	finishBatch(ctx, batch.ID)
+
+	return refs, nil
+}
+
+// GetBatch retrieves the aggregate progress of a batch.
+//
+//encore:api auth method=GET path=/transcribe/batch/:id
+func GetBatch(ctx context.Context, id string) (*models.BatchStatus, error) {
+	status, err := batchStatus(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: "Batch not found",
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// batchStatus aggregates the progress of a batch's child jobs.
+func batchStatus(ctx context.Context, id string) (*models.BatchStatus, error) {
+	batch, err := getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	children, err := listBatchJobs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	status := batch.Summarize(children)
+	return &status, nil
+}
+
+// finishBatch completes a batch once its last job has finished and sends
+// the batch.completed webhook.
+func finishBatch(ctx context.Context, batchID string) {
+	status, err := batchStatus(ctx, batchID)
+	if err != nil {
+		rlog.Error("failed to check batch", "error", err, "batch_id", batchID)
+		return
+	}
+	if !status.IsFinished() || status.CompletedAt != nil {
+		return
+	}
+
+	now := time.Now()
+	completed, err := completeBatch(ctx, batchID, now)
+	if err != nil {
+		rlog.Error("failed to complete batch", "error", err, "batch_id", batchID)
+		return
+	}
+	if !completed {
+		return
+	}
+	status.CompletedAt = &now
+
+	rlog.Info("batch completed", "batch_id", batchID, "complete", status.Complete, "failed", status.Failed)
+	if webhookManager := newWebhookManager(); webhookManager != nil {
+		webhookManager.SendBatchCompleted(ctx, *status)
+	}
+
+	// Batches found by a subscription scan are also reported to its owner.
+	if status.SubscriptionID != "" {
+		sub, err := getSubscription(ctx, status.SubscriptionID)
+		if err != nil {
+			rlog.Warn("failed to load batch subscription", "error", err, "batch_id", batchID)
+		} else if webhookManager := lib.NewSubscriptionWebhookManager(*sub); webhookManager != nil {
+			webhookManager.SendBatchCompleted(ctx, *status)
+		}
+	}
+}
+
+// newWebhookManager returns the configured webhook sender, or nil if no
+// webhook URL is set.
+func newWebhookManager() *lib.WebhookManager {
+	if cfg.WebhookURL == "" {
+		return nil
+	}
+
+	webhookConfig := lib.WebhookConfig{
+		URL:     cfg.WebhookURL,
+		Events:  cfg.WebhookEvents,
+		Timeout: 10 * time.Second,
+		Retries: 3,
+	}
+	if cfg.WebhookSecret != "" {
+		webhookConfig.Headers = map[string]string{
+			"X-Webhook-Secret": cfg.WebhookSecret,
+		}
+	}
+	return lib.NewWebhookManager(webhookConfig)
+}
+
 // AuthHandler validates API key authentication.
 //
 //encore:authhandler
@@ -177,7 +737,7 @@ func AuthHandler(ctx context.Context, token string) (auth.UID, error) {
 			Message: "Invalid API key",
 		}
 	}
-	return auth.UID("authenticated"), nil
+	return auth.UID(lib.APIKeyID(token)), nil
 }
 
 // Topic for job processing
@@ -191,72 +751,107 @@ func publishJob(ctx context.Context, job *models.Job) error {
 	return err
 }
 
-// Subscribe to job processing
+// retryPolicy decides which failed jobs are retried. Retries are delivered
+// by the subscription's backoff, so Backoff and MaxBackoff mirror it and
+// only set the retry_at estimate reported to clients.
+var retryPolicy = models.RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     30 * time.Second,
+	MaxBackoff:  10 * time.Minute,
+}
+
+// maxConcurrentJobs bounds how many jobs an instance transcribes at once
+const maxConcurrentJobs = 4
+
+// Subscribe to job processing. MaxRetries leaves room for redeliveries
+// caused by database errors on top of retryPolicy.MaxAttempts.
 var _ = pubsub.NewSubscription(jobTopic, "process-jobs", pubsub.SubscriptionConfig[*models.Job]{
-	Handler: processJobAsync,
+	Handler:        processJobAsync,
+	MaxConcurrency: maxConcurrentJobs,
+	RetryPolicy: &pubsub.RetryPolicy{
+		MinBackoff: 30 * time.Second,
+		MaxBackoff: 10 * time.Minute,
+		MaxRetries: 10,
+	},
 })
 
 // processJobAsync processes a job asynchronously.
-func processJobAsync(ctx context.Context, job *models.Job) error {
+func processJobAsync(ctx context.Context, msg *models.Job) error {
 	startTime := time.Now()
+
+	// Work on the stored job rather than the message snapshot so that
+	// redelivered messages see the job's current status.
+	job, err := getJob(ctx, msg.ID)
+	if err != nil {
+		return err
+	}
+
 	rlog.Info("processing job async", "job_id", job.ID, "url", job.URL)
 
-	// Initialize webhook manager if configured
-	var webhookManager *lib.WebhookManager
-	if cfg.WebhookURL != "" {
-		webhookConfig := lib.WebhookConfig{
-			URL:     cfg.WebhookURL,
-			Events:  cfg.WebhookEvents,
-			Timeout: 10 * time.Second,
-			Retries: 3,
-		}
-		if cfg.WebhookSecret != "" {
-			webhookConfig.Headers = map[string]string{
-				"X-Webhook-Secret": cfg.WebhookSecret,
-			}
+	// Mark job as running. A job that is already running was interrupted
+	// and is picked up again; redelivered messages for finished jobs are dropped.
+	if job.Status != models.StatusRunning {
+		if err := job.MarkRunning(); err != nil {
+			rlog.Warn("skipping job", "error", err, "job_id", job.ID)
+			return nil
 		}
-		webhookManager = lib.NewWebhookManager(webhookConfig)
-
-		// Send job started webhook
-		webhookManager.SendJobStarted(ctx, job)
 	}
-
-	// Mark job as running
-	job.MarkRunning()
 	if err := updateJob(ctx, job); err != nil {
 		return err
 	}
 
+	// Initialize webhook manager if configured
+	webhookManager := newWebhookManager()
+	if webhookManager != nil {
+		// Send job started webhook
+		webhookManager.SendJobStarted(ctx, job)
+	}
+
 	// Process transcription
-	transcript, segments, err := lib.ProcessTranscription(job.URL, job.ID)
+	result, err := lib.ProcessTranscription(job.URL, job.ID, func(stage models.JobStage, progress int) {
+		job.MarkStage(stage, progress)
+		if err := updateJob(ctx, job); err != nil {
+			rlog.Error("failed to record job stage", "error", err, "job_id", job.ID)
+		}
+	})
 	if err != nil {
 		processingTime := time.Since(startTime)
-		rlog.Error("async transcription failed", "error", err, "job_id", job.ID)
+
+		// Transient failures are returned so the subscription redelivers
+		// the job after its backoff.
+		if retryPolicy.ShouldRetry(job, err) {
+			rlog.Warn("transcription attempt failed, retrying", "error", err, "error_code", models.ErrorCodeOf(err), "attempt", job.Attempts, "job_id", job.ID)
+			if markErr := job.MarkRetry(err, time.Now().Add(retryPolicy.Delay(job.Attempts))); markErr == nil {
+				if updateErr := updateJob(ctx, job); updateErr != nil {
+					rlog.Error("failed to record job retry", "error", updateErr, "job_id", job.ID)
+				}
+				return err
+			}
+		}
+
+		rlog.Error("async transcription failed", "error", err, "error_code", models.ErrorCodeOf(err), "job_id", job.ID)
 		job.MarkError(err)
-		updateJob(ctx, job)
+		if err := updateJob(ctx, job); err != nil {
+			return err
+		}
 
 		// Send failure webhook
 		if webhookManager != nil {
 			webhookManager.SendJobFailed(ctx, job, err.Error(), processingTime)
 		}
-		return err
-	}
-
-	// Generate subtitle files
-	var srtPath, vttPath string
-	if len(segments) > 0 {
-		outputDir := cfg.WorkDir
-		srtPath, vttPath, err = lib.GenerateSubtitles(segments, outputDir, job.ID)
-		if err != nil {
-			rlog.Error("subtitle generation failed", "error", err, "job_id", job.ID)
-			// Don't fail the job for subtitle errors, just log
-		} else {
-			rlog.Info("subtitles generated", "job_id", job.ID, "srt", srtPath, "vtt", vttPath)
+		if job.BatchID != "" {
+			finishBatch(ctx, job.BatchID)
 		}
+		return nil
 	}
 
 	// Mark job as complete
-	job.MarkComplete(transcript, segments)
+	job.Engine = result.Engine
+	job.Language = result.Language
+	job.CacheKey = result.CacheKey(job.URL)
+	job.Chapters = result.Chapters
+	job.MarkComplete(result.Transcript, result.Segments)
+	storeArtifacts(ctx, job, result.AudioPath)
 	if err := updateJob(ctx, job); err != nil {
 		return err
 	}
@@ -264,9 +859,16 @@ func processJobAsync(ctx context.Context, job *models.Job) error {
 	// Send completion webhook
 	if webhookManager != nil {
 		processingTime := time.Since(startTime)
-		webhookManager.SendJobCompleted(ctx, job, srtPath, vttPath, processingTime)
+		files, err := lib.SignedSubtitleFiles(artifacts, job, artifactURLTTL())
+		if err != nil {
+			rlog.Error("failed to sign artifact URLs", "error", err, "job_id", job.ID)
+		}
+		webhookManager.SendJobCompleted(ctx, job, files, processingTime)
+	}
+	if job.BatchID != "" {
+		finishBatch(ctx, job.BatchID)
 	}
 
 	rlog.Info("job completed successfully", "job_id", job.ID, "processing_time", time.Since(startTime))
 	return nil
-}
\ No newline at end of file
+}
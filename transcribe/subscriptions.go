@@ -0,0 +1,237 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+	"encore.dev/cron"
+	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
+)
+
+// Scan subscriptions for new videos every 15 minutes.
+var _ = cron.NewJob("scan-subscriptions", cron.JobConfig{
+	Title:    "Scan subscriptions for new videos",
+	Every:    15 * cron.Minute,
+	Endpoint: ScanSubscriptions,
+})
+
+// ListSubscriptionsResponse lists the registered subscriptions.
+type ListSubscriptionsResponse struct {
+	Subscriptions []models.Subscription `json:"subscriptions"`
+}
+
+// CreateSubscription registers a channel or playlist whose new videos are
+// transcribed automatically.
+//
+//encore:api auth method=POST path=/subscriptions
+func CreateSubscription(ctx context.Context, req *models.SubscriptionRequest) (*models.Subscription, error) {
+	sub, err := models.NewSubscription(*req)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	if uid, ok := auth.UserID(); ok {
+		sub.APIKeyID = string(uid)
+	}
+
+	if err := storeSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	rlog.Info("subscription created", "subscription_id", sub.ID, "url", sub.URL)
+	redacted := sub.Redacted()
+	return &redacted, nil
+}
+
+// ListSubscriptions lists the registered subscriptions.
+//
+//encore:api auth method=GET path=/subscriptions
+func ListSubscriptions(ctx context.Context) (*ListSubscriptionsResponse, error) {
+	subs, err := listSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ListSubscriptionsResponse{
+		Subscriptions: make([]models.Subscription, 0, len(subs)),
+	}
+	uid, _ := auth.UserID()
+	for _, sub := range subs {
+		if sub.APIKeyID == string(uid) {
+			response.Subscriptions = append(response.Subscriptions, sub.Redacted())
+		}
+	}
+	return response, nil
+}
+
+// GetSubscription retrieves a subscription and the result of its last scan.
+//
+//encore:api auth method=GET path=/subscriptions/:id
+func GetSubscription(ctx context.Context, id string) (*models.Subscription, error) {
+	sub, err := findSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	redacted := sub.Redacted()
+	return &redacted, nil
+}
+
+// UpdateSubscription pauses or resumes a subscription or changes its webhook.
+//
+//encore:api auth method=PATCH path=/subscriptions/:id
+func UpdateSubscription(ctx context.Context, id string, req *models.SubscriptionRequest) (*models.Subscription, error) {
+	if _, err := findSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+
+	sub, err := modifySubscription(ctx, id, func(sub *models.Subscription) error {
+		if err := sub.Apply(*req); err != nil {
+			return &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: err.Error(),
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, subscriptionNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+	redacted := sub.Redacted()
+	return &redacted, nil
+}
+
+// DeleteSubscription stops watching a channel or playlist. Jobs it already
+// submitted are kept.
+//
+//encore:api auth method=DELETE path=/subscriptions/:id
+func DeleteSubscription(ctx context.Context, id string) error {
+	if _, err := findSubscription(ctx, id); err != nil {
+		return err
+	}
+	deleted, err := deleteSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return subscriptionNotFound()
+	}
+	return nil
+}
+
+// ScanSubscriptions checks every active subscription for new videos. It is
+// called by the scan-subscriptions cron job.
+//
+//encore:api private
+func ScanSubscriptions(ctx context.Context) error {
+	subs, err := listSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		if !sub.Active {
+			continue
+		}
+		if err := checkSubscription(ctx, sub); err != nil {
+			rlog.Error("subscription scan failed", "error", err, "subscription_id", sub.ID)
+		}
+	}
+	return nil
+}
+
+// checkSubscription lists the subscription's source and submits the videos
+// it has not seen yet as a batch. The seen videos are only saved once the
+// batch was created, so a failed scan is repeated.
+func checkSubscription(ctx context.Context, sub *models.Subscription) error {
+	listCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	collection, listErr := lib.ListCollection(listCtx, sub.URL, sub.ScanLimit())
+
+	// The scan is applied to the stored subscription, so settings changed
+	// while listing are kept
+	_, err := modifySubscription(ctx, sub.ID, func(sub *models.Subscription) error {
+		if listErr != nil {
+			sub.MarkScanFailed(listErr, time.Now())
+			return nil
+		}
+
+		if sub.Title == "" {
+			sub.Title = collection.Title
+		}
+		fresh := sub.RecordScan(collection.Entries, time.Now())
+		if len(fresh) == 0 {
+			return nil
+		}
+
+		batch := models.NewBatch(len(fresh), false)
+		batch.SourceURL = sub.URL
+		batch.Title = sub.Title
+		batch.SubscriptionID = sub.ID
+		batch.APIKeyID = sub.APIKeyID
+		children := make([]*models.Job, 0, len(fresh))
+		for _, entry := range fresh {
+			children = append(children, entry.NewJob())
+		}
+
+		refs, err := submitBatch(ctx, batch, children)
+		if err != nil {
+			return err
+		}
+		rlog.Info("subscription found new videos", "subscription_id", sub.ID, "batch_id", batch.ID, "total", len(fresh))
+
+		if webhookManager := lib.NewSubscriptionWebhookManager(*sub); webhookManager != nil {
+			jobIDs := make([]string, 0, len(refs))
+			for _, ref := range refs {
+				jobIDs = append(jobIDs, ref.JobID)
+			}
+			webhookManager.SendSubscriptionVideos(ctx, *sub, batch.ID, jobIDs)
+		}
+		return nil
+	})
+	if errors.Is(err, sqldb.ErrNoRows) {
+		// Deleted while listing
+		return nil
+	}
+	if listErr != nil {
+		if err != nil {
+			rlog.Error("failed to record subscription scan error", "error", err, "subscription_id", sub.ID)
+		}
+		return listErr
+	}
+	return err
+}
+
+// findSubscription loads one of the caller's subscriptions, returning a
+// NotFound error if it does not exist or belongs to another API key.
+func findSubscription(ctx context.Context, id string) (*models.Subscription, error) {
+	sub, err := getSubscription(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, subscriptionNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uid, _ := auth.UserID(); sub.APIKeyID != string(uid) {
+		return nil, subscriptionNotFound()
+	}
+	return sub, nil
+}
+
+func subscriptionNotFound() error {
+	return &errs.Error{
+		Code:    errs.NotFound,
+		Message: "Subscription not found",
+	}
+}
@@ -0,0 +1,101 @@
+package transcribe
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"encore.dev/beta/errs"
+
+	"videotranscript-app/lib"
+)
+
+// ConvertSubtitles converts an uploaded subtitle file between srt, vtt, ass,
+// ttml, sbv and json. The file is the raw request body or the "file" field
+// of a multipart form. The source format comes from the from query
+// parameter, the uploaded file name or the content itself; to is required.
+// The offset, from_fps and to_fps parameters retime the cues, and the ASS
+// and TTML download parameters style the output.
+//
+// It is a raw endpoint because neither the request nor the response is JSON.
+//
+//encore:api auth raw method=POST path=/subtitles/convert
+func ConvertSubtitles(w http.ResponseWriter, req *http.Request) {
+	req.Body = http.MaxBytesReader(w, req.Body, lib.MaxSubtitleSize)
+	query := req.URL.Query()
+
+	var input io.Reader = req.Body
+	name := "subtitles"
+	fromName := query.Get("from")
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := req.FormFile("file")
+		if err != nil {
+			errs.HTTPError(w, &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: "Multipart requests must carry the subtitles in a file field",
+			})
+			return
+		}
+		defer file.Close()
+
+		input = file
+		ext := filepath.Ext(header.Filename)
+		if base := strings.TrimSuffix(filepath.Base(header.Filename), ext); base != "" && base != "." {
+			name = base
+		}
+		if fromName == "" {
+			fromName = ext
+		}
+	}
+
+	var from lib.SubtitleFormat
+	if fromName != "" {
+		format, err := lib.ParseSubtitleFormat(fromName)
+		if err != nil {
+			errs.HTTPError(w, unsupportedSubtitleFormat("from"))
+			return
+		}
+		from = format
+	}
+	to, err := lib.ParseSubtitleFormat(query.Get("to"))
+	if err != nil {
+		errs.HTTPError(w, unsupportedSubtitleFormat("to"))
+		return
+	}
+
+	opts, err := lib.ParseConvertOptions(query.Get)
+	if err != nil {
+		errs.HTTPError(w, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	content, err := lib.ConvertSubtitles(input, from, to, opts)
+	if err != nil {
+		errs.HTTPError(w, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: fmt.Sprintf("Failed to convert subtitles: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", lib.SubtitleContentType(to))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+string(to)))
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, content)
+}
+
+func unsupportedSubtitleFormat(param string) *errs.Error {
+	formats := make([]string, len(lib.SubtitleFormats))
+	for i, format := range lib.SubtitleFormats {
+		formats[i] = string(format)
+	}
+	return &errs.Error{
+		Code:    errs.InvalidArgument,
+		Message: fmt.Sprintf("Unsupported %s format. Supported: %s", param, strings.Join(formats, ", ")),
+	}
+}
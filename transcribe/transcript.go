@@ -0,0 +1,104 @@
+package transcribe
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"encore.dev"
+	"encore.dev/beta/errs"
+
+	"videotranscript-app/lib"
+)
+
+// DownloadTranscript serves a complete job's transcript as a file. The file
+// path segment names the format: transcript.txt, transcript.srt,
+// transcript.vtt, transcript.ass, transcript.ttml, transcript.json or the
+// paragraphed documents transcript.md, transcript.html and
+// transcript.docx. The job's chapters are served as chapters.txt (YouTube
+// description timestamps), chapters.vtt, chapters.ffmetadata or
+// chapters.json. Subtitles are laid out by the caption preset and overrides
+// in the query, which also styles ASS and times TTML files, and documents
+// by the pause, paragraph_length, timestamps and speakers parameters.
+// Responses carry an ETag, and a matching If-None-Match is answered with
+// 304 Not Modified.
+//
+// It is a raw endpoint because the response is not JSON.
+//
+//encore:api auth raw method=GET path=/transcribe/:id/:file
+func DownloadTranscript(w http.ResponseWriter, req *http.Request) {
+	params := encore.CurrentRequest().PathParams
+
+	format, err := lib.ParseTranscriptFileName(params.Get("file"))
+	chapters := false
+	if err != nil {
+		format, err = lib.ParseChaptersFileName(params.Get("file"))
+		chapters = true
+	}
+	if err != nil {
+		errs.HTTPError(w, &errs.Error{
+			Code: errs.InvalidArgument,
+			Message: "Unsupported file. Supported: transcript." + strings.Join(lib.TranscriptFormats, ", transcript.") +
+				", chapters." + strings.Join(lib.ChapterFormats, ", chapters."),
+		})
+		return
+	}
+
+	job, err := getJob(req.Context(), params.Get("id"))
+	if err != nil {
+		errs.HTTPError(w, &errs.Error{
+			Code:    errs.NotFound,
+			Message: "Job not found",
+		})
+		return
+	}
+
+	query := req.URL.Query()
+	captions, err := lib.ParseCaptionOptions(query.Get("preset"), query.Get("max_line_length"), query.Get("max_lines"), query.Get("max_cps"))
+	var opts lib.TranscriptOptions
+	if err == nil {
+		opts.Captions, err = captions.Style()
+	}
+	if err == nil {
+		opts.SubtitleOptions, err = lib.ParseSubtitleOptions(query.Get)
+	}
+	if err == nil {
+		opts.Paragraphs, err = lib.ParseParagraphOptions(query.Get)
+	}
+	if err != nil {
+		errs.HTTPError(w, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var file *lib.TranscriptFile
+	if chapters {
+		file, err = lib.RenderChapters(job, format)
+	} else {
+		file, err = lib.RenderTranscript(job, format, opts)
+	}
+	if errors.Is(err, lib.ErrTranscriptNotReady) {
+		errs.HTTPError(w, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: "Transcript is not available until the job is complete",
+		})
+		return
+	} else if err != nil {
+		errs.HTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", file.ETag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if file.NotModified(req.Header.Get("If-None-Match")) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Disposition", file.ContentDisposition())
+	w.WriteHeader(http.StatusOK)
+	w.Write(file.Content)
+}
@@ -0,0 +1,224 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"encore.dev"
+	"encore.dev/beta/errs"
+	"encore.dev/beta/pubsub"
+	"encore.dev/rlog"
+	"github.com/google/uuid"
+
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
+)
+
+// RenderVideoResponse is a captioned video render stored as a job artifact.
+type RenderVideoResponse struct {
+	HTTPStatus int             `encore:"httpstatus" json:"-"`
+	Artifact   models.Artifact `json:"artifact"`
+	// URL is a signed download link, set once the render is stored, that
+	// expires after the configured ArtifactURLTTL.
+	URL string `json:"url,omitempty"`
+}
+
+// renderMessage asks the render-videos subscription to render a job's
+// video. Query holds the request's render options, and UploadKey the
+// uploaded video in the artifacts bucket, if there was one.
+type renderMessage struct {
+	JobID     string `json:"job_id"`
+	Query     string `json:"query"`
+	UploadKey string `json:"upload_key,omitempty"`
+}
+
+// renderTopic carries queued video renders.
+var renderTopic = pubsub.NewTopic[*renderMessage]("video-renders", pubsub.TopicConfig{
+	DeliveryGuarantee: pubsub.AtLeastOnce,
+})
+
+// maxConcurrentRenders bounds how many ffmpeg renders an instance runs at
+// once.
+const maxConcurrentRenders = 2
+
+var _ = pubsub.NewSubscription(renderTopic, "render-videos", pubsub.SubscriptionConfig[*renderMessage]{
+	Handler:        renderVideoAsync,
+	MaxConcurrency: maxConcurrentRenders,
+})
+
+// RenderVideo queues a render of a complete job's captions into its video,
+// burned in or as a soft subtitle track. The video is the "video" field of
+// a multipart upload or, without one, is downloaded from the job's URL.
+// Renders run on the render-videos subscription; the response is 202 with
+// the Location of GetRenderedVideo, which reports when the render is stored.
+//
+// It is a raw endpoint because the request carries a video upload.
+//
+//encore:api auth raw method=POST path=/transcribe/:id/video
+func RenderVideo(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	job, err := findJob(ctx, encore.CurrentRequest().PathParams.Get("id"))
+	if err != nil {
+		errs.HTTPError(w, err)
+		return
+	}
+
+	opts, err := lib.ParseRenderOptions(req.URL.Query().Get)
+	if err != nil {
+		errs.HTTPError(w, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if job.Status != models.StatusComplete {
+		errs.HTTPError(w, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: "Video can not be rendered until the job is complete",
+		})
+		return
+	}
+
+	// The upload is kept in the bucket until the render picks it up
+	msg := &renderMessage{JobID: job.ID, Query: req.URL.RawQuery}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		req.Body = http.MaxBytesReader(w, req.Body, maxUploadSize())
+		file, _, err := req.FormFile("video")
+		if err != nil {
+			errs.HTTPError(w, &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: "Multipart requests must carry the video in a video field",
+			})
+			return
+		}
+		defer file.Close()
+
+		msg.UploadKey = lib.ArtifactKey(job.ID, "upload_"+uuid.New().String())
+		if _, err := artifacts.Put(ctx, msg.UploadKey, file, "application/octet-stream"); err != nil {
+			errs.HTTPError(w, err)
+			return
+		}
+	}
+
+	artifact := lib.VideoArtifact(job.ID, opts)
+	artifact.Status = models.ArtifactPending
+	if err := putJobArtifact(ctx, job.ID, artifact); err != nil {
+		errs.HTTPError(w, err)
+		return
+	}
+	if _, err := renderTopic.Publish(ctx, msg); err != nil {
+		artifact.MarkError(err)
+		if err := putJobArtifact(ctx, job.ID, artifact); err != nil {
+			rlog.Error("failed to record failed render", "error", err, "job_id", job.ID)
+		}
+		errs.HTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/transcribe/"+job.ID+"/video/"+lib.VideoRenderName(opts))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(&RenderVideoResponse{Artifact: artifact})
+}
+
+// GetRenderedVideo reports a render queued by RenderVideo, named by its
+// mode and container as in "burn.mp4". A stored render is returned with a
+// signed download URL, and a queued one with 202.
+//
+//encore:api auth method=GET path=/transcribe/:id/video/:render
+func GetRenderedVideo(ctx context.Context, id, render string) (*RenderVideoResponse, error) {
+	job, err := findJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	artifact, ok := job.Artifact(lib.VideoArtifactKey(job.ID, render))
+	if !ok || artifact.Kind != "video" {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: "Video render not found",
+		}
+	}
+
+	switch artifact.Status {
+	case models.ArtifactPending:
+		return &RenderVideoResponse{HTTPStatus: http.StatusAccepted, Artifact: artifact}, nil
+	case models.ArtifactError:
+		if artifact.ErrorCode == models.ErrCodeInternal {
+			return nil, errors.New(artifact.Error)
+		}
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: artifact.Error,
+		}
+	}
+
+	signed, err := artifacts.SignedURL(artifact.Key, artifactURLTTL())
+	if err != nil {
+		return nil, err
+	}
+	return &RenderVideoResponse{HTTPStatus: http.StatusOK, Artifact: artifact, URL: signed}, nil
+}
+
+// renderVideoAsync renders a queued video and records the stored artifact,
+// or why it failed, on the job. Only failures to record the outcome are
+// returned for redelivery.
+func renderVideoAsync(ctx context.Context, msg *renderMessage) error {
+	query, err := url.ParseQuery(msg.Query)
+	if err != nil {
+		return nil
+	}
+	opts, err := lib.ParseRenderOptions(query.Get)
+	if err != nil {
+		return nil
+	}
+
+	artifact := lib.VideoArtifact(msg.JobID, opts)
+	rendered, err := renderVideo(ctx, msg, opts)
+	if err != nil {
+		rlog.Error("failed to render video", "error", err, "job_id", msg.JobID)
+		artifact.MarkError(err)
+	} else {
+		artifact = rendered
+	}
+	return putJobArtifact(ctx, msg.JobID, artifact)
+}
+
+// renderVideo renders the job's video from the message's upload, which it
+// removes, or from the job's URL.
+func renderVideo(ctx context.Context, msg *renderMessage, opts lib.RenderOptions) (models.Artifact, error) {
+	job, err := getJob(ctx, msg.JobID)
+	if err != nil {
+		return models.Artifact{}, err
+	}
+
+	var upload io.Reader
+	if msg.UploadKey != "" {
+		defer func() {
+			if err := artifacts.Delete(ctx, msg.UploadKey); err != nil {
+				rlog.Warn("failed to remove video upload", "error", err, "key", msg.UploadKey)
+			}
+		}()
+		r, err := artifacts.Get(ctx, msg.UploadKey)
+		if err != nil {
+			return models.Artifact{}, err
+		}
+		defer r.Close()
+		upload = r
+	}
+	return lib.RenderJobVideo(ctx, artifacts, job, upload, opts)
+}
+
+// maxUploadSize is the largest video upload RenderVideo accepts.
+func maxUploadSize() int64 {
+	if cfg.MaxUploadMB > 0 {
+		return int64(cfg.MaxUploadMB) << 20
+	}
+	return 512 << 20
+}
@@ -14,6 +14,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"videotranscript-app/lib"
+	"videotranscript-app/models"
 )
 
 var startTime = time.Now()
@@ -22,46 +25,30 @@ var fileMutex sync.RWMutex
 
 // Demo transcript data for testing
 var demoTranscripts = map[string]Job{
-	"job_1234567890": {
+	"job_1234567890": {Job: models.Job{
 		Transcript: "Welcome to this demonstration video. Today we're going to explore the amazing world of video transcription and how it can transform your content workflow. This technology has revolutionized the way we process and understand multimedia content, making it accessible to everyone.",
-		Segments: []TranscriptSegment{
+		Segments: []models.Segment{
 			{Start: 0.0, End: 4.5, Text: "Welcome to this demonstration video."},
 			{Start: 4.5, End: 12.8, Text: "Today we're going to explore the amazing world of video transcription"},
 			{Start: 12.8, End: 18.2, Text: "and how it can transform your content workflow."},
 			{Start: 18.2, End: 25.4, Text: "This technology has revolutionized the way we process"},
 			{Start: 25.4, End: 32.1, Text: "and understand multimedia content, making it accessible to everyone."},
 		},
-	},
+	}},
 }
 
+// Job is the dashboard view of a job: the shared job model plus the fields
+// the dashboard derives from the local transcripts/ and logs/ directories.
 type Job struct {
-	ID         string    `json:"id"`
-	VideoID    string    `json:"video_id"`
-	URL        string    `json:"url"`
-	Title      string    `json:"title"`
-	Status     string    `json:"status"`
-	Progress   int       `json:"progress"`
-	StartTime  time.Time `json:"start_time"`
-	UpdateTime time.Time `json:"update_time"`
-	LogFile    string    `json:"log_file"`
-	OutputDir  string    `json:"output_dir"`
-	Duration   string    `json:"duration"`
-	FileCount  int       `json:"file_count"`
-	FileSize   string    `json:"file_size"`
-	Stage      string    `json:"stage"`
-	StageProgress map[string]int `json:"stage_progress"`
+	models.Job
+	LogFile       string `json:"log_file"`
+	OutputDir     string `json:"output_dir"`
+	Duration      string `json:"duration"`
+	FileCount     int    `json:"file_count"`
+	FileSize      string `json:"file_size"`
 	CategoryClass string `json:"category_class"`
 	CategoryIcon  string `json:"category_icon"`
 	StatusText    string `json:"status_text"`
-	// Transcript fields
-	Transcript    string          `json:"transcript,omitempty"`
-	Segments      []TranscriptSegment `json:"segments,omitempty"`
-}
-
-type TranscriptSegment struct {
-	Start float64 `json:"start"`
-	End   float64 `json:"end"`
-	Text  string  `json:"text"`
 }
 
 const dashboardHTML = `
@@ -1367,7 +1354,7 @@ const dashboardHTML = `
                             <div class="transaction-icon {{.CategoryClass}}">{{.CategoryIcon}}</div>
                             <div class="transaction-details">
                                 <div class="transaction-title clickable">{{.Title}}</div>
-                                <div class="transaction-subtitle">{{.UpdateTime.Format "15:04"}} • {{.StatusText}} • {{.Duration}}</div>
+                                <div class="transaction-subtitle">{{.UpdatedAt.Format "15:04"}} • {{.StatusText}} • {{.Duration}}</div>
                             </div>
                         </div>
                         <div class="transaction-meta">
@@ -1551,13 +1538,13 @@ const dashboardHTML = `
             div.setAttribute('onclick', 'showJobDetails(\'' + job.id + '\')');
 
             const statusIconMap = {
-                'completed': '✅',
-                'failed': '❌',
-                'queued': '⏳',
+                'complete': '✅',
+                'error': '❌',
+                'pending': '⏳',
                 'running': '🔄'
             };
 
-            const updateTime = new Date(job.update_time);
+            const updateTime = new Date(job.updated_at);
             const timeStr = updateTime.toLocaleTimeString('en-US', {hour12: false, hour: '2-digit', minute: '2-digit'});
 
             div.innerHTML =
@@ -1811,7 +1798,7 @@ const dashboardHTML = `
             const container = document.getElementById('transcriptions-list');
             container.innerHTML = '';
 
-            const completedJobs = jobs.filter(job => job.status === 'completed');
+            const completedJobs = jobs.filter(job => job.status === 'complete');
 
             if (completedJobs.length === 0) {
                 container.innerHTML = '<div class="empty-state">No completed transcriptions yet. Add a job to get started!</div>';
@@ -1828,7 +1815,7 @@ const dashboardHTML = `
             const div = document.createElement('div');
             div.className = 'transcription-card';
 
-            const updateTime = new Date(job.update_time);
+            const updateTime = new Date(job.updated_at);
             const timeStr = updateTime.toLocaleDateString('en-US', {month: 'short', day: 'numeric', year: 'numeric'});
 
             div.innerHTML = ` + "`" + `
@@ -1893,11 +1880,11 @@ const dashboardHTML = `
 
             const jobs = window.currentJobs || [];
             const now = new Date();
-            let filteredJobs = jobs.filter(job => job.status === 'completed');
+            let filteredJobs = jobs.filter(job => job.status === 'complete');
 
             if (period !== 'all') {
                 filteredJobs = filteredJobs.filter(job => {
-                    const jobDate = new Date(job.update_time);
+                    const jobDate = new Date(job.updated_at);
                     switch (period) {
                         case 'today':
                             return jobDate.toDateString() === now.toDateString();
@@ -2076,9 +2063,9 @@ const transactionDetailHTML = `
             letter-spacing: 0.05em;
         }
 
-        .status-completed { background: #d1fae5; color: #065f46; }
-        .status-failed { background: #fee2e2; color: #991b1b; }
-        .status-queued { background: #fef3c7; color: #92400e; }
+        .status-complete { background: #d1fae5; color: #065f46; }
+        .status-error { background: #fee2e2; color: #991b1b; }
+        .status-pending { background: #fef3c7; color: #92400e; }
         .status-running { background: #dbeafe; color: #1e40af; }
 
         .detail-grid {
@@ -2234,11 +2221,11 @@ const transactionDetailHTML = `
                     <h3>Timing</h3>
                     <div class="detail-row">
                         <span class="detail-label">Started</span>
-                        <span class="detail-value">{{.Job.StartTime.Format "Jan 2, 2006 15:04"}}</span>
+                        <span class="detail-value">{{.Job.CreatedAt.Format "Jan 2, 2006 15:04"}}</span>
                     </div>
                     <div class="detail-row">
                         <span class="detail-label">Last Updated</span>
-                        <span class="detail-value">{{.Job.UpdateTime.Format "Jan 2, 2006 15:04"}}</span>
+                        <span class="detail-value">{{.Job.UpdatedAt.Format "Jan 2, 2006 15:04"}}</span>
                     </div>
                     <div class="detail-row">
                         <span class="detail-label">Duration</span>
@@ -2336,7 +2323,7 @@ const transactionDetailHTML = `
             </div>
             {{else}}
             <!-- No Transcript Available -->
-            {{if eq .Job.Status "completed"}}
+            {{if eq .Job.Status "complete"}}
             <div class="detail-card">
                 <div style="text-align: center; padding: 40px; color: #6b7280;">
                     <div style="font-size: 48px; margin-bottom: 16px;">📄</div>
@@ -2344,7 +2331,7 @@ const transactionDetailHTML = `
                     <p style="margin: 0;">The transcription completed but no transcript content was found.</p>
                 </div>
             </div>
-            {{else if eq .Job.Status "failed"}}
+            {{else if eq .Job.Status "error"}}
             <div class="detail-card">
                 <div style="text-align: center; padding: 40px; color: #ef4444;">
                     <div style="font-size: 48px; margin-bottom: 16px;">❌</div>
@@ -2667,13 +2654,13 @@ func dashboardHandler(w http.ResponseWriter, r *http.Request) {
 	// Calculate basic job counts
 	for _, job := range jobs {
 		switch job.Status {
-		case "downloading", "extracting", "transcribing":
+		case models.StatusRunning:
 			data.RunningJobs++
-		case "completed":
+		case models.StatusComplete:
 			data.CompletedJobs++
-		case "failed":
+		case models.StatusError:
 			data.FailedJobs++
-		case "queued":
+		case models.StatusPending:
 			data.QueuedJobs++
 		}
 	}
@@ -2714,8 +2701,8 @@ func calculatePerformanceMetrics(data *DashboardData, jobs []Job) {
 	var completedJobs int
 
 	for _, job := range jobs {
-		if job.Status == "completed" && !job.StartTime.IsZero() && !job.UpdateTime.IsZero() {
-			processingTime := job.UpdateTime.Sub(job.StartTime)
+		if job.Status == models.StatusComplete && !job.CreatedAt.IsZero() && !job.UpdatedAt.IsZero() {
+			processingTime := job.UpdatedAt.Sub(job.CreatedAt)
 			totalProcessingSeconds += int64(processingTime.Seconds())
 			completedJobs++
 		}
@@ -2739,7 +2726,7 @@ func calculatePerformanceMetrics(data *DashboardData, jobs []Job) {
 	// Calculate total storage used
 	var totalStorage int64
 	for _, job := range jobs {
-		if job.Status == "completed" {
+		if job.Status == models.StatusComplete {
 			outputDir := fmt.Sprintf("transcripts/%s", job.VideoID)
 			if files, err := os.ReadDir(outputDir); err == nil {
 				for _, file := range files {
@@ -2760,12 +2747,12 @@ func calculateAPIUsageMetrics(data *DashboardData, jobs []Job) {
 
 	for _, job := range jobs {
 		// Jobs today
-		if job.StartTime.After(today) {
+		if job.CreatedAt.After(today) {
 			data.JobsToday++
 		}
 
 		// Jobs this week
-		if job.StartTime.After(weekAgo) {
+		if job.CreatedAt.After(weekAgo) {
 			data.JobsThisWeek++
 		}
 	}
@@ -2844,27 +2831,18 @@ func addJobHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	videoID := extractVideoID(req.URL)
-	jobID := generateJobID()
-
 	job := Job{
-		ID:         jobID,
-		VideoID:    videoID,
-		URL:        req.URL,
-		Title:      "Loading...",
-		Status:     "queued",
-		Progress:   0,
-		StartTime:  time.Now(),
-		UpdateTime: time.Now(),
-		LogFile:    fmt.Sprintf("logs/%s.log", jobID),
-		OutputDir:  fmt.Sprintf("transcripts/%s", videoID),
-		Duration:   "00:00",
-		FileCount:  0,
-		FileSize:   "0 KB",
+		Job:           *models.NewJob(req.URL),
+		Duration:      "00:00",
+		FileCount:     0,
+		FileSize:      "0 KB",
 		CategoryClass: "entertainment",
 		CategoryIcon:  "🎬",
 		StatusText:    "Queued for processing",
 	}
+	job.Title = "Loading..."
+	job.LogFile = fmt.Sprintf("logs/%s.log", job.ID)
+	job.OutputDir = fmt.Sprintf("transcripts/%s", job.VideoID)
 
 	jobs := loadJobs()
 	jobs = append(jobs, job)
@@ -2996,11 +2974,11 @@ func downloadHandler(w http.ResponseWriter, r *http.Request) {
 		filename = fmt.Sprintf("transcript_%s.txt", job.VideoID)
 
 	case "srt":
-		content = generateSRT(job.Segments, job.Transcript)
+		content = lib.ConvertSegmentsToSubtitles(subtitleSegments(job.Segments, job.Transcript), lib.FormatSRT)
 		filename = fmt.Sprintf("transcript_%s.srt", job.VideoID)
 
 	case "vtt":
-		content = generateVTT(job.Segments, job.Transcript)
+		content = lib.ConvertSegmentsToSubtitles(subtitleSegments(job.Segments, job.Transcript), lib.FormatVTT)
 		filename = fmt.Sprintf("transcript_%s.vtt", job.VideoID)
 
 	case "json":
@@ -3012,7 +2990,7 @@ func downloadHandler(w http.ResponseWriter, r *http.Request) {
 			"transcript": job.Transcript,
 			"segments":   job.Segments,
 			"duration":   job.Duration,
-			"created_at": job.StartTime,
+			"created_at": job.CreatedAt,
 		}
 		contentBytes, _ := json.MarshalIndent(jsonData, "", "  ")
 		content = string(contentBytes)
@@ -3028,55 +3006,13 @@ func downloadHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(content))
 }
 
-func generateSRT(segments []TranscriptSegment, fallbackText string) string {
+// subtitleSegments returns a job's segments, or a single cue spanning the
+// whole transcript for jobs without timing
+func subtitleSegments(segments []models.Segment, fallbackText string) []models.Segment {
 	if len(segments) == 0 {
-		// Fallback for plain text
-		return fmt.Sprintf("1\n00:00:00,000 --> 99:59:59,999\n%s\n", fallbackText)
+		return []models.Segment{{Start: 0, End: 359999.999, Text: fallbackText}}
 	}
-
-	var srt strings.Builder
-	for i, segment := range segments {
-		start := formatSRTTime(segment.Start)
-		end := formatSRTTime(segment.End)
-		srt.WriteString(fmt.Sprintf("%d\n%s --> %s\n%s\n\n", i+1, start, end, segment.Text))
-	}
-	return srt.String()
-}
-
-func generateVTT(segments []TranscriptSegment, fallbackText string) string {
-	var vtt strings.Builder
-	vtt.WriteString("WEBVTT\n\n")
-
-	if len(segments) == 0 {
-		// Fallback for plain text
-		vtt.WriteString("00:00:00.000 --> 99:59:59.999\n")
-		vtt.WriteString(fallbackText)
-		vtt.WriteString("\n")
-		return vtt.String()
-	}
-
-	for _, segment := range segments {
-		start := formatVTTTime(segment.Start)
-		end := formatVTTTime(segment.End)
-		vtt.WriteString(fmt.Sprintf("%s --> %s\n%s\n\n", start, end, segment.Text))
-	}
-	return vtt.String()
-}
-
-func formatSRTTime(seconds float64) string {
-	hours := int(seconds) / 3600
-	minutes := (int(seconds) % 3600) / 60
-	secs := int(seconds) % 60
-	millis := int((seconds - float64(int(seconds))) * 1000)
-	return fmt.Sprintf("%02d:%02d:%02d,%03d", hours, minutes, secs, millis)
-}
-
-func formatVTTTime(seconds float64) string {
-	hours := int(seconds) / 3600
-	minutes := (int(seconds) % 3600) / 60
-	secs := int(seconds) % 60
-	millis := int((seconds - float64(int(seconds))) * 1000)
-	return fmt.Sprintf("%02d:%02d:%02d.%03d", hours, minutes, secs, millis)
+	return segments
 }
 
 func logsHandler(w http.ResponseWriter, r *http.Request) {
@@ -3196,13 +3132,13 @@ func calculateDashboardData(jobs []Job) DashboardData {
 	// Calculate job statistics
 	for _, job := range jobs {
 		switch job.Status {
-		case "completed":
+		case models.StatusComplete:
 			data.CompletedJobs++
-		case "failed":
+		case models.StatusError:
 			data.FailedJobs++
-		case "queued":
+		case models.StatusPending:
 			data.QueuedJobs++
-		case "downloading", "extracting", "transcribing", "running":
+		case models.StatusRunning:
 			data.RunningJobs++
 		}
 	}
@@ -3240,8 +3176,30 @@ func loadJobs() []Job {
 	var jobs []Job
 	json.Unmarshal(data, &jobs)
 
+	// Older jobs.json files use the dashboard's own status names and
+	// start_time/update_time keys
+	var legacy []struct {
+		Status     string    `json:"status"`
+		StartTime  time.Time `json:"start_time"`
+		UpdateTime time.Time `json:"update_time"`
+	}
+	json.Unmarshal(data, &legacy)
+
 	// Set default values for new fields
 	for i := range jobs {
+		if i < len(legacy) {
+			status, stage := models.ParseJobStatus(legacy[i].Status)
+			jobs[i].Status = status
+			if stage != "" {
+				jobs[i].Stage = stage
+			}
+			if jobs[i].CreatedAt.IsZero() {
+				jobs[i].CreatedAt = legacy[i].StartTime
+			}
+			if jobs[i].UpdatedAt.IsZero() {
+				jobs[i].UpdatedAt = legacy[i].UpdateTime
+			}
+		}
 		if jobs[i].CategoryClass == "" {
 			jobs[i].CategoryClass = "entertainment"
 		}
@@ -3294,10 +3252,15 @@ func updateJobStatus(job *Job) {
 	// Check if transcription process is running
 	cmd := exec.Command("pgrep", "-f", fmt.Sprintf("transcribe.*%s", job.VideoID))
 	if output, err := cmd.Output(); err == nil && len(strings.TrimSpace(string(output))) > 0 {
-		status, progress := parseJobProgress(job)
-		job.Status = status
-		job.Progress = progress
-		job.UpdateTime = time.Now()
+		stage, progress := parseJobProgress(job)
+		if job.Status == models.StatusPending {
+			job.MarkRunning()
+		}
+		if stage == "completed" {
+			job.MarkComplete(job.Transcript, job.Segments)
+		} else {
+			job.MarkStage(models.JobStage(stage), progress)
+		}
 		updateJobStats(job)
 		updateStatusText(job)
 		return
@@ -3306,11 +3269,14 @@ func updateJobStatus(job *Job) {
 	// Check if completed
 	outputDir := fmt.Sprintf("transcripts/%s", job.VideoID)
 	if files, err := os.ReadDir(outputDir); err == nil && len(files) > 0 {
-		if job.Status != "completed" {
+		if job.Status != models.StatusComplete {
 			// Only update status and stats if not already completed
-			job.Status = "completed"
-			job.Progress = 100
-			job.UpdateTime = time.Now()
+			if job.Status == models.StatusPending {
+				job.MarkRunning()
+			}
+			if err := job.MarkComplete(job.Transcript, job.Segments); err != nil {
+				return
+			}
 			job.FileCount = len(files)
 			updateJobStats(job)
 			updateStatusText(job)
@@ -3319,26 +3285,25 @@ func updateJobStatus(job *Job) {
 	}
 
 	// If not running and not completed, check if it failed
-	if job.Status != "queued" && job.Status != "completed" {
-		job.Status = "failed"
-		job.UpdateTime = time.Now()
+	if job.Status == models.StatusRunning {
+		job.MarkError(fmt.Errorf("transcription process exited without output"))
 		updateStatusText(job)
 	}
 }
 
 func updateStatusText(job *Job) {
-	switch job.Status {
-	case "queued":
+	switch {
+	case job.Status == models.StatusPending:
 		job.StatusText = "Queued for processing"
-	case "downloading":
+	case job.Stage == models.StageDownloading:
 		job.StatusText = "Downloading video"
-	case "extracting":
+	case job.Stage == models.StageExtracting:
 		job.StatusText = "Extracting audio"
-	case "transcribing":
+	case job.Stage == models.StageTranscribing:
 		job.StatusText = "Transcribing audio"
-	case "completed":
+	case job.Status == models.StatusComplete:
 		job.StatusText = "Transcription complete"
-	case "failed":
+	case job.Status == models.StatusError:
 		job.StatusText = "Processing failed"
 	default:
 		job.StatusText = "Processing"
@@ -3347,9 +3312,9 @@ func updateStatusText(job *Job) {
 
 func updateJobStats(job *Job) {
 	// Calculate duration - only update for running jobs, preserve completed job durations
-	if !job.StartTime.IsZero() && job.Status != "completed" && job.Status != "failed" {
+	if !job.CreatedAt.IsZero() && !job.IsComplete() {
 		// For running jobs, show elapsed time
-		duration := time.Since(job.StartTime)
+		duration := time.Since(job.CreatedAt)
 		job.Duration = formatDuration(duration)
 	}
 
@@ -3433,19 +3398,6 @@ func extractTitleFromLog(filename string) string {
 	return ""
 }
 
-func extractVideoID(url string) string {
-	re := regexp.MustCompile(`(?:youtube\.com/watch\?v=|youtu\.be/|youtube\.com/embed/)([a-zA-Z0-9_-]{11})`)
-	matches := re.FindStringSubmatch(url)
-	if len(matches) >= 2 {
-		return matches[1]
-	}
-	return "unknown"
-}
-
-func generateJobID() string {
-	return fmt.Sprintf("job_%d", time.Now().Unix())
-}
-
 func formatDuration(d time.Duration) string {
 	d = d.Round(time.Second)
 	h := d / time.Hour